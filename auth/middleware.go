@@ -66,3 +66,26 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminTicketMiddleware 浏览器 EventSource 不能设置请求头，没有 Authorization header 时用查询参数中的一次性票据认证
+// 票据由 /admin/events/ticket 签发，使用一次后失效；有 Authorization header 时按后台 token 认证
+func AdminTicketMiddleware(key string) gin.HandlerFunc {
+	admin := AdminAuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			admin(c)
+			return
+		}
+		ticket, ok := pkg.ConsumeAdminTicket(c.Query(key))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "票据无效或已过期"})
+			return
+		}
+		c.Set("operator_id", ticket.OperatorID)
+		c.Set("operator_name", ticket.OperatorName)
+		c.Set("shop_id", ticket.ShopID)
+		c.Set("is_root", ticket.IsRoot)
+		c.Set("auth_type", "admin")
+		c.Next()
+	}
+}
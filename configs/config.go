@@ -9,6 +9,18 @@ import (
 type WechatConfig struct {
 	AppID     string `mapstructure:"app_id"`
 	AppSecret string `mapstructure:"app_secret"`
+
+	APIBaseURL           string                             `mapstructure:"api_base_url"`           // 微信服务端接口地址，为空时使用 https://api.weixin.qq.com，测试时可指向本地模拟服务
+	MiniprogramState     string                             `mapstructure:"miniprogram_state"`      // 订阅消息跳转的小程序版本：developer/trial/formal(默认)
+	SubscribeMaxAttempts int                                `mapstructure:"subscribe_max_attempts"` // 订阅消息最多发送次数(含重试)
+	SubscribeTemplates   map[string]SubscribeTemplateConfig `mapstructure:"subscribe_templates"`    // 订阅消息模板，键为通知场景(order_paid/order_shipped/order_pickup)
+}
+
+// SubscribeTemplateConfig 订阅消息模板配置，page 和 data 的值中可使用 {order_no} 等变量
+type SubscribeTemplateConfig struct {
+	TemplateID string            `mapstructure:"template_id"` // 模板ID，为空时该场景不发送
+	Page       string            `mapstructure:"page"`        // 点击消息跳转的小程序页面
+	Data       map[string]string `mapstructure:"data"`        // 模板字段 => 取值，如 character_string1: "{order_no}"
 }
 type OssConfig struct {
 	Endpoint        string `mapstructure:"endpoint"`
@@ -16,9 +28,49 @@ type OssConfig struct {
 	AccessKeySecret string `mapstructure:"access_key_secret"`
 	BucketName      string `mapstructure:"bucket_name"`
 }
+type StorageConfig struct {
+	Driver    string `mapstructure:"driver"`      // 存储方式：oss(阿里云OSS，默认)、local(本地磁盘)、memory(内存，开发测试用)
+	LocalDir  string `mapstructure:"local_dir"`   // 本地存储目录
+	URLPrefix string `mapstructure:"url_prefix"`  // 本地/内存存储的静态访问路由前缀
+	BaseURL   string `mapstructure:"base_url"`    // 本地/内存存储的访问域名，如 http://127.0.0.1:8009
+	MaxSizeMB int64  `mapstructure:"max_size_mb"` // 上传图片大小上限(MB)
+
+	CleanupGraceHours int `mapstructure:"cleanup_grace_hours"` // 未引用图片的保留时长(小时)，超过后由清理任务删除
+}
+type PdfConfig struct {
+	FontPath string `mapstructure:"font_path"` // PDF中文字体文件(TrueType .ttf)，用于条码标签、送货单等
+}
+type StockConfig struct {
+	LowStockThreshold int `mapstructure:"low_stock_threshold"` // 低库存阈值，库存不高于该值的上架商品计为低库存
+}
+type SmsConfig struct {
+	Provider        string `mapstructure:"provider"`          // 短信服务商：aliyun(阿里云短信)、log(只写日志，默认，开发测试用)
+	AccessKeyID     string `mapstructure:"access_key_id"`     // 阿里云 AccessKey
+	AccessKeySecret string `mapstructure:"access_key_secret"` // 阿里云 AccessKey Secret
+	SignName        string `mapstructure:"sign_name"`         // 短信签名
+	Endpoint        string `mapstructure:"endpoint"`          // 接口地址，为空时使用 https://dysmsapi.aliyuncs.com
+
+	RateLimitPerMinute int `mapstructure:"rate_limit_per_minute"` // 同一手机号每分钟最多发送条数
+	RateLimitPerDay    int `mapstructure:"rate_limit_per_day"`    // 同一手机号每天最多发送条数
+	MonthlyBalanceDay  int `mapstructure:"monthly_balance_day"`   // 每月几号发送上月对账短信，0为不自动发送
+
+	Templates map[string]SmsTemplateConfig `mapstructure:"templates"` // 短信模板，键为通知场景(outbound_delivery/payment_reminder/monthly_balance)
+}
+
+// SmsTemplateConfig 短信模板配置，content 和 params 的值中可使用 {customer_name} 等变量
+type SmsTemplateConfig struct {
+	TemplateCode string            `mapstructure:"template_code"` // 阿里云短信模板编码
+	Content      string            `mapstructure:"content"`       // 短信全文(与阿里云模板内容一致)，用于日志模式和发送记录
+	Params       map[string]string `mapstructure:"params"`        // 阿里云模板变量 => 取值
+}
+
 type Config struct {
-	Wechat WechatConfig `mapstructure:"wechat"`
-	Oss    OssConfig    `mapstructure:"oss"`
+	Wechat  WechatConfig  `mapstructure:"wechat"`
+	Oss     OssConfig     `mapstructure:"oss"`
+	Storage StorageConfig `mapstructure:"storage"`
+	Pdf     PdfConfig     `mapstructure:"pdf"`
+	Stock   StockConfig   `mapstructure:"stock"`
+	Sms     SmsConfig     `mapstructure:"sms"`
 }
 
 var Cfg *Config
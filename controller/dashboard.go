@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"cmf/paint_proj/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardController struct {
+	dashboardService service.DashboardService
+}
+
+func NewDashboardController(ds service.DashboardService) *DashboardController {
+	return &DashboardController{dashboardService: ds}
+}
+
+// GetDashboard 后台首页看板：超级管理员不传 shop_id 时统计全部店铺，refresh=1 时忽略缓存重新统计
+func (dc *DashboardController) GetDashboard(c *gin.Context) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return
+	}
+
+	dashboard, err := dc.dashboardService.GetDashboard(shopID, c.Query("refresh") == "1")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取首页看板失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": dashboard})
+}
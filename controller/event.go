@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"cmf/paint_proj/pkg"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeat SSE 心跳间隔，防止代理因连接空闲断开
+const eventHeartbeat = 25 * time.Second
+
+type EventController struct{}
+
+func NewEventController() *EventController {
+	return &EventController{}
+}
+
+// Stream 后台实时事件推送(SSE)：新订单、支付成功、订单取消、低库存
+// 超级管理员不传 shop_id 时接收全部店铺的事件；types 为逗号分隔的事件类型，不传时接收全部
+// 断线重连时按 Last-Event-ID 或 last_event_id 参数补发期间错过的近期事件
+func (ec *EventController) Stream(c *gin.Context) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return
+	}
+	types := make(map[string]bool)
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	filter := func(event pkg.Event) bool {
+		if shopID > 0 && event.ShopID != shopID {
+			return false
+		}
+		return len(types) == 0 || types[event.Type]
+	}
+
+	events, cancel := pkg.Events.Subscribe(fmt.Sprintf("后台推送(管理员%d)", c.GetInt64("operator_id")), 64, filter)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// 补发断线期间的事件；换新票据重新连接时浏览器不会带 Last-Event-ID，可用 last_event_id 参数传递
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	lastID, _ := strconv.ParseInt(lastEventID, 10, 64)
+	if lastID > 0 {
+		for _, event := range pkg.Events.Since(lastID, filter) {
+			if writeSSEvent(c, event) != nil {
+				return
+			}
+		}
+	}
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok || writeSSEvent(c, event) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// Ticket 换取连接事件推送用的一次性票据，票据30秒内有效且只能使用一次
+func (ec *EventController) Ticket(c *gin.Context) {
+	ticket, expiresAt, err := pkg.IssueAdminTicket(c.GetInt64("operator_id"), c.GetString("operator_name"), c.GetInt64("shop_id"), c.GetBool("is_root"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "生成票据失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "获取成功",
+		"data": gin.H{
+			"ticket":     ticket,
+			"expires_at": expiresAt,
+		},
+	})
+}
+
+// writeSSEvent 按 SSE 格式写出一个事件，event 为事件类型，data 为完整事件 JSON
+func writeSSEvent(c *gin.Context, event pkg.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
@@ -0,0 +1,347 @@
+package controller
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotifyController struct {
+	wechatNotifyService service.WechatNotifyService
+	smsService          service.SmsService
+	stockService        service.StockService
+	reminderService     service.PaymentReminderService
+}
+
+func NewNotifyController(wechatNotifyService service.WechatNotifyService, smsService service.SmsService, stockService service.StockService, reminderService service.PaymentReminderService) *NotifyController {
+	return &NotifyController{
+		wechatNotifyService: wechatNotifyService,
+		smsService:          smsService,
+		stockService:        stockService,
+		reminderService:     reminderService,
+	}
+}
+
+// GetSubscribeTemplates 小程序获取可订阅的消息模板
+func (nc *NotifyController) GetSubscribeTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": nc.wechatNotifyService.GetSubscribeTemplates()})
+}
+
+// RecordSubscribeConsent 小程序上报 wx.requestSubscribeMessage 的授权结果
+func (nc *NotifyController) RecordSubscribeConsent(c *gin.Context) {
+	var req model.SubscribeConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if err := nc.wechatNotifyService.RecordConsent(c.GetInt64("user_id"), &req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "记录订阅授权失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
+}
+
+// GetWechatMessages 订阅消息发送记录，支持按状态、场景、订单号、用户筛选
+func (nc *NotifyController) GetWechatMessages(c *gin.Context) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return
+	}
+	page, pageSize := posPageParams(c)
+	query := &model.WechatMessageQuery{
+		ShopID:   shopID,
+		Scene:    c.Query("scene"),
+		OrderNo:  c.Query("order_no"),
+		Page:     page,
+		PageSize: pageSize,
+	}
+	query.UserID, _ = strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if v := c.Query("status"); v != "" {
+		status, err := strconv.ParseInt(v, 10, 8)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "状态格式错误"})
+			return
+		}
+		s := int8(status)
+		query.Status = &s
+	}
+
+	msgs, total, err := nc.wechatNotifyService.GetMessages(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取发送记录失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": gin.H{
+		"list":      msgs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}})
+}
+
+// RetryWechatMessage 重发发送失败的订阅消息
+func (nc *NotifyController) RetryWechatMessage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的消息ID"})
+		return
+	}
+	msg, err := nc.wechatNotifyService.GetMessageByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "消息不存在"})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, msg.ShopID); !isValid {
+		return
+	}
+
+	if err := nc.wechatNotifyService.RetryMessage(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "重发失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "已加入重发队列"})
+}
+
+// GetSmsLogs 短信发送记录，支持按状态、场景、手机号、客户筛选
+func (nc *NotifyController) GetSmsLogs(c *gin.Context) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return
+	}
+	page, pageSize := posPageParams(c)
+	query := &model.SmsLogQuery{
+		ShopID:   shopID,
+		Scene:    c.Query("scene"),
+		Phone:    c.Query("phone"),
+		Page:     page,
+		PageSize: pageSize,
+	}
+	query.UserID, _ = strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if v := c.Query("status"); v != "" {
+		status, err := strconv.ParseInt(v, 10, 8)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "状态格式错误"})
+			return
+		}
+		s := int8(status)
+		query.Status = &s
+	}
+
+	logs, total, err := nc.smsService.GetLogs(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取短信记录失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": gin.H{
+		"list":      logs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}})
+}
+
+// SendOutboundSms 手动发送(补发)出库短信
+func (nc *NotifyController) SendOutboundSms(c *gin.Context) {
+	operationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "操作ID格式错误"})
+		return
+	}
+	operation, _, err := nc.stockService.GetStockOperationDetail(operationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "库存操作不存在"})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, operation.ShopID); !isValid {
+		return
+	}
+	smsLog, err := nc.smsService.SendOutboundDelivery(operationID, c.GetString("operator_name"))
+	nc.smsResult(c, smsLog, err)
+}
+
+// SendPaymentReminderSms 给客户发送欠款提醒短信(汇总全部未结清出库单)
+func (nc *NotifyController) SendPaymentReminderSms(c *gin.Context) {
+	var req model.SmsPaymentReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, req.ShopID); !isValid {
+		return
+	}
+	smsLog, err := nc.smsService.SendPaymentReminder(req.ShopID, req.UserID, c.GetString("operator_name"))
+	nc.smsResult(c, smsLog, err)
+}
+
+// SendMonthlyBalanceSms 给店铺后台客户发送月度对账短信，已发送过的客户不重复发送
+func (nc *NotifyController) SendMonthlyBalanceSms(c *gin.Context) {
+	var req model.SmsMonthlyBalanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, req.ShopID); !isValid {
+		return
+	}
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, -1, 0)
+	if req.Month != "" {
+		t, err := time.ParseInLocation("2006-01", req.Month, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "月份格式错误，应为 YYYY-MM"})
+			return
+		}
+		month = t
+	}
+
+	result, err := nc.smsService.SendMonthlyBalance(req.ShopID, month, c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "发送失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": result})
+}
+
+// GetPaymentReminderSetting 店铺欠款提醒设置
+func (nc *NotifyController) GetPaymentReminderSetting(c *gin.Context) {
+	shopID, _ := strconv.ParseInt(c.Query("shop_id"), 10, 64)
+	shopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+	if shopID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "请指定店铺"})
+		return
+	}
+	setting, err := nc.reminderService.GetSetting(shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取欠款提醒设置失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": setting})
+}
+
+// SavePaymentReminderSetting 保存店铺欠款提醒设置
+func (nc *NotifyController) SavePaymentReminderSetting(c *gin.Context) {
+	var req model.PaymentReminderSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, req.ShopID); !isValid {
+		return
+	}
+	setting, err := nc.reminderService.SaveSetting(&req, c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "保存失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "保存成功", "data": setting})
+}
+
+// RunPaymentReminder 立即检查店铺的超期欠款并发送提醒，已按当前账龄提醒过的客户不重复提醒
+func (nc *NotifyController) RunPaymentReminder(c *gin.Context) {
+	var req model.PaymentReminderRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, req.ShopID); !isValid {
+		return
+	}
+	result, err := nc.reminderService.RunShop(req.ShopID, c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "执行欠款提醒失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": result})
+}
+
+// GetPaymentReminders 欠款提醒记录，支持按客户、状态、提醒日期筛选
+func (nc *NotifyController) GetPaymentReminders(c *gin.Context) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return
+	}
+	page, pageSize := posPageParams(c)
+	query := &model.PaymentReminderQuery{
+		ShopID:   shopID,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	query.UserID, _ = strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if v := c.Query("status"); v != "" {
+		status, err := strconv.ParseInt(v, 10, 8)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "状态格式错误"})
+			return
+		}
+		s := int8(status)
+		query.Status = &s
+	}
+	if v := c.Query("start_date"); v != "" {
+		start, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "开始日期格式错误，应为 YYYY-MM-DD"})
+			return
+		}
+		query.StartTime = &start
+	}
+	if v := c.Query("end_date"); v != "" {
+		end, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "结束日期格式错误，应为 YYYY-MM-DD"})
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+		query.EndTime = &end
+	}
+
+	reminders, total, err := nc.reminderService.GetReminders(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取欠款提醒记录失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": gin.H{
+		"list":      reminders,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}})
+}
+
+// GetPaymentReminder 欠款提醒详情，含提醒的出库单明细
+func (nc *NotifyController) GetPaymentReminder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的提醒ID"})
+		return
+	}
+	reminder, err := nc.reminderService.GetReminderByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "提醒记录不存在"})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, reminder.ShopID); !isValid {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": reminder})
+}
+
+// smsResult 返回单条短信的发送结果，未发送或发送失败时 message 为原因
+func (nc *NotifyController) smsResult(c *gin.Context, smsLog *model.SmsLog, err error) {
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "发送失败: " + err.Error()})
+		return
+	}
+	if smsLog.Status != model.NotifyStatusSent {
+		c.JSON(http.StatusOK, gin.H{"code": -1, "message": "短信未发送: " + smsLog.Error, "data": smsLog})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "发送成功", "data": smsLog})
+}
@@ -3,6 +3,8 @@ package controller
 import (
 	"cmf/paint_proj/model"
 	"cmf/paint_proj/service"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -147,3 +149,88 @@ func (oc *OrderController) DeleteOrder(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "删除订单成功"})
 }
+
+// AdminGetOrderList 后台订单列表，超级管理员不传 shop_id 时查询全部店铺
+func (oc *OrderController) AdminGetOrderList(c *gin.Context) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return
+	}
+	page, pageSize := posPageParams(c)
+	status, _ := strconv.ParseInt(c.DefaultQuery("status", "0"), 10, 32)
+	userID, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	req := &model.AdminOrderListRequest{
+		ShopID:   shopID,
+		Status:   int32(status),
+		OrderNo:  c.Query("order_no"),
+		UserID:   userID,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	orders, total, err := oc.orderService.GetAdminOrderList(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取订单列表失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": gin.H{
+		"list":      orders,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}})
+}
+
+// AdminGetOrderDetail 后台订单详情
+func (oc *OrderController) AdminGetOrderDetail(c *gin.Context) {
+	order, ok := oc.adminOrder(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": order})
+}
+
+// ShipOrder 订单发货，通知用户(订阅消息)
+func (oc *OrderController) ShipOrder(c *gin.Context) {
+	oc.advanceOrder(c, oc.orderService.ShipOrder, "发货")
+}
+
+// ReadyForPickup 订单备货完成，通知用户到店自提(订阅消息)
+func (oc *OrderController) ReadyForPickup(c *gin.Context) {
+	oc.advanceOrder(c, oc.orderService.ReadyForPickup, "备货")
+}
+
+func (oc *OrderController) advanceOrder(c *gin.Context, advance func(*model.Order, int64, string, string) error, action string) {
+	var req model.ShipOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) { // 备注可不传
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	order, ok := oc.adminOrder(c)
+	if !ok {
+		return
+	}
+	if err := advance(order, c.GetInt64("operator_id"), c.GetString("operator_name"), req.Remark); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": action + "失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": action + "成功", "data": order})
+}
+
+// adminOrder 按路径参数获取订单并校验店铺权限
+func (oc *OrderController) adminOrder(c *gin.Context) (*model.Order, bool) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "订单ID格式错误"})
+		return nil, false
+	}
+	order, err := oc.orderService.GetOrderByID(orderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "订单不存在"})
+		return nil, false
+	}
+	if !c.GetBool("is_root") && order.ShopID != c.GetInt64("shop_id") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限操作该订单"})
+		return nil, false
+	}
+	return order, true
+}
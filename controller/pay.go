@@ -4,6 +4,7 @@ import (
 	"cmf/paint_proj/model"
 	"cmf/paint_proj/service"
 	"context"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -37,28 +38,23 @@ func (pc *PayController) PaymentData(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
-// PaymentCallback 支付回调
+// PaymentCallback 微信支付回调通知，验证签名并解密后才更新订单；应答格式按微信支付 APIv3 要求，失败时微信会重发
 func (pc *PayController) PaymentCallback(c *gin.Context) {
-	// 解析回调参数
-	var callbackReq model.PayCallbackReq
-	if err := c.ShouldBindJSON(&callbackReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误"})
+	req, err := pc.payService.ParsePaidNotify(c.Request.Context(), c.Request)
+	if err != nil {
+		log.Printf("微信支付回调通知验证失败: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"code": "FAIL", "message": "通知验证失败"})
 		return
 	}
 
-	// 调用服务处理回调
-	req := &model.PaidCallbackData{
-		OrderNo:       callbackReq.OrderNo,
-		PaymentNo:     callbackReq.PaymentNo,
-		PaymentType:   int32(callbackReq.PaymentType),
-		PaymentTime:   callbackReq.PaymentTime,
-		PaymentAmount: callbackReq.PaymentAmount,
-	}
-
-	if err := pc.payService.PaidCallback(c.Request.Context(), req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "处理回调失败"})
-		return
+	// 非支付成功的通知(如已关闭)只需应答
+	if req != nil {
+		if err := pc.payService.PaidCallback(c.Request.Context(), req); err != nil {
+			log.Printf("订单 %s 支付回调处理失败: %v", req.OrderNo, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"code": "FAIL", "message": "处理回调失败"})
+			return
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "success"})
+	c.JSON(http.StatusOK, gin.H{"code": "SUCCESS", "message": "成功"})
 }
@@ -0,0 +1,410 @@
+package controller
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PosController struct {
+	posService  service.PosService
+	shopService service.ShopService
+}
+
+func NewPosController(ps service.PosService, ss service.ShopService) *PosController {
+	return &PosController{posService: ps, shopService: ss}
+}
+
+// OpenShift 开班
+func (pc *PosController) OpenShift(c *gin.Context) {
+	var req model.PosOpenShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	shopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+
+	shift, err := pc.posService.OpenShift(shopID, &req, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "开班失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "开班成功", "data": shift})
+}
+
+// CloseShift 交班，返回班次收款汇总和现金差额
+func (pc *PosController) CloseShift(c *gin.Context) {
+	var req model.PosCloseShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	shopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+
+	summary, err := pc.posService.CloseShift(shopID, &req, c.GetInt64("operator_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "交班失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "交班成功", "data": summary})
+}
+
+// GetCurrentShift 当前班次实时汇总
+func (pc *PosController) GetCurrentShift(c *gin.Context) {
+	shopID, _ := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	shopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	summary, err := pc.posService.GetCurrentShift(shopID, c.GetInt64("operator_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": summary})
+}
+
+// GetShift 班次汇总(交班单)
+func (pc *PosController) GetShift(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的班次ID"})
+		return
+	}
+	summary, err := pc.posService.GetShiftSummary(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": err.Error()})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, summary.ShopID); !isValid {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": summary})
+}
+
+// GetShifts 班次列表
+func (pc *PosController) GetShifts(c *gin.Context) {
+	page, pageSize := posPageParams(c)
+	shopID, _ := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	operatorID, _ := strconv.ParseInt(c.DefaultQuery("operator_id", "0"), 10, 64)
+	shopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	shifts, total, err := pc.posService.GetShifts(page, pageSize, shopID, operatorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取班次列表失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":      shifts,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// OpenSale 开收银单
+func (pc *PosController) OpenSale(c *gin.Context) {
+	var req model.PosOpenSaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	shopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+
+	sale, err := pc.posService.OpenSale(shopID, &req, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "开单失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "开单成功", "data": sale})
+}
+
+// GetSales 收银单列表
+func (pc *PosController) GetSales(c *gin.Context) {
+	page, pageSize := posPageParams(c)
+	shopID, _ := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	status, _ := strconv.ParseInt(c.DefaultQuery("status", "0"), 10, 8)
+	shopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	sales, total, err := pc.posService.GetSales(page, pageSize, shopID, int8(status))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取收银单列表失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":      sales,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// GetSale 收银单详情
+func (pc *PosController) GetSale(c *gin.Context) {
+	sale, ok := pc.loadSale(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": sale})
+}
+
+// SetCustomer 更换收银单客户
+func (pc *PosController) SetCustomer(c *gin.Context) {
+	sale, ok := pc.loadSale(c)
+	if !ok {
+		return
+	}
+	var req model.PosSetCustomerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	sale, err := pc.posService.SetCustomer(sale.ID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "设置客户失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "设置成功", "data": sale})
+}
+
+// AddItem 收银单添加商品(扫码或选择商品)
+func (pc *PosController) AddItem(c *gin.Context) {
+	sale, ok := pc.loadSale(c)
+	if !ok {
+		return
+	}
+	var req model.PosAddItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	sale, err := pc.posService.AddItem(sale.ID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "添加商品失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "添加成功", "data": sale})
+}
+
+// UpdateItem 修改收银明细数量或单价
+func (pc *PosController) UpdateItem(c *gin.Context) {
+	sale, ok := pc.loadSale(c)
+	if !ok {
+		return
+	}
+	itemID, err := strconv.ParseInt(c.Param("item_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的明细ID"})
+		return
+	}
+	var req model.PosUpdateItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	sale, err = pc.posService.UpdateItem(sale.ID, itemID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "修改失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "修改成功", "data": sale})
+}
+
+// RemoveItem 删除收银明细
+func (pc *PosController) RemoveItem(c *gin.Context) {
+	sale, ok := pc.loadSale(c)
+	if !ok {
+		return
+	}
+	itemID, err := strconv.ParseInt(c.Param("item_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的明细ID"})
+		return
+	}
+
+	sale, err = pc.posService.RemoveItem(sale.ID, itemID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "删除失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "删除成功", "data": sale})
+}
+
+// Checkout 结账
+func (pc *PosController) Checkout(c *gin.Context) {
+	sale, ok := pc.loadSale(c)
+	if !ok {
+		return
+	}
+	var req model.PosCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	sale, err := pc.posService.Checkout(sale.ID, &req, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "结账失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "结账成功", "data": sale})
+}
+
+// CancelSale 取消收银单
+func (pc *PosController) CancelSale(c *gin.Context) {
+	sale, ok := pc.loadSale(c)
+	if !ok {
+		return
+	}
+	if err := pc.posService.CancelSale(sale.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "取消失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "取消成功"})
+}
+
+// GetReceipt 收银小票文本，width 为小票宽度(32:58mm,48:80mm)
+func (pc *PosController) GetReceipt(c *gin.Context) {
+	sale, ok := pc.loadSale(c)
+	if !ok {
+		return
+	}
+	width, _ := strconv.Atoi(c.DefaultQuery("width", strconv.Itoa(service.ReceiptWidth58)))
+	shop, err := pc.shopService.GetShopByID(sale.ShopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取店铺信息失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"sale_no": sale.SaleNo,
+			"width":   width,
+			"text":    service.BuildPosReceipt(shop, sale, width),
+		},
+	})
+}
+
+// loadSale 按路径参数获取收银单并校验店铺权限
+func (pc *PosController) loadSale(c *gin.Context) (*model.PosSale, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的收银单ID"})
+		return nil, false
+	}
+	sale, err := pc.posService.GetSale(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": err.Error()})
+		return nil, false
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, sale.ShopID); !isValid {
+		return nil, false
+	}
+	return sale, true
+}
+
+// GetCustomerPrices 客户协议价列表
+func (pc *PosController) GetCustomerPrices(c *gin.Context) {
+	shopID, _ := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	userID, err := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的客户ID"})
+		return
+	}
+	shopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	prices, err := pc.posService.GetCustomerPrices(shopID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取协议价失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": prices})
+}
+
+// SetCustomerPrice 设置客户协议价
+func (pc *PosController) SetCustomerPrice(c *gin.Context) {
+	var req model.SetCustomerPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	shopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+	req.ShopID = shopID
+
+	price, err := pc.posService.SetCustomerPrice(&req, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "设置协议价失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "设置成功", "data": price})
+}
+
+// DeleteCustomerPrice 删除客户协议价
+func (pc *PosController) DeleteCustomerPrice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的协议价ID"})
+		return
+	}
+	price, err := pc.posService.GetCustomerPriceByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "协议价不存在"})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, price.ShopID); !isValid {
+		return
+	}
+	if err := pc.posService.DeleteCustomerPrice(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "删除失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "删除成功"})
+}
+
+// posPageParams 解析分页参数
+func posPageParams(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	return page, pageSize
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PriceController struct {
+	priceService   service.PriceService
+	productService service.ProductService
+}
+
+func NewPriceController(s service.PriceService, ps service.ProductService) *PriceController {
+	return &PriceController{priceService: s, productService: ps}
+}
+
+// PreviewPriceAdjustment 预览批量调价结果（后台）
+func (pc *PriceController) PreviewPriceAdjustment(c *gin.Context) {
+	var req model.PriceAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	// 验证店铺权限
+	validShopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+	req.ShopID = validShopID
+
+	preview, err := pc.priceService.PreviewAdjustment(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "预览调价失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": preview})
+}
+
+// CreatePriceAdjustment 执行批量调价或创建定时调价单（后台）
+func (pc *PriceController) CreatePriceAdjustment(c *gin.Context) {
+	var req model.PriceAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	// 验证店铺权限
+	validShopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+	req.ShopID = validShopID
+
+	adjustment, preview, err := pc.priceService.CreateAdjustment(&req, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": err.Error()})
+		return
+	}
+
+	message := "调价成功"
+	if adjustment.Status == model.PriceAdjustmentPending {
+		message = "已创建定时调价单，将于 " + adjustment.EffectiveAt.Format("2006-01-02 15:04:05") + " 生效"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": message,
+		"data": gin.H{
+			"adjustment": adjustment,
+			"preview":    preview,
+		},
+	})
+}
+
+// GetPriceAdjustmentList 调价单列表（后台）
+func (pc *PriceController) GetPriceAdjustmentList(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	shopID, err := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	if err != nil {
+		shopID = 0
+	}
+	status, err := strconv.ParseInt(c.DefaultQuery("status", "0"), 10, 8)
+	if err != nil {
+		status = 0
+	}
+
+	// 验证店铺权限
+	validShopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	adjustments, total, err := pc.priceService.GetAdjustmentList(page, pageSize, validShopID, int8(status))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取调价单列表失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":      adjustments,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// CancelPriceAdjustment 取消待生效的调价单（后台）
+func (pc *PriceController) CancelPriceAdjustment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "调价单ID格式错误"})
+		return
+	}
+
+	adjustment, err := pc.priceService.GetAdjustmentByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "调价单不存在"})
+		return
+	}
+
+	// 验证店铺权限
+	if !c.GetBool("is_root") && adjustment.ShopID != c.GetInt64("shop_id") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限操作该调价单"})
+		return
+	}
+
+	if err := pc.priceService.CancelAdjustment(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "取消调价单失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "取消调价单成功"})
+}
+
+// GetPriceHistory 商品价格变动记录（后台）
+func (pc *PriceController) GetPriceHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "商品ID格式错误"})
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+
+	product, err := pc.productService.GetProductByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "商品不存在"})
+		return
+	}
+
+	// 验证店铺权限
+	if !c.GetBool("is_root") && product.ShopID != c.GetInt64("shop_id") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限查看该商品"})
+		return
+	}
+
+	histories, total, err := pc.priceService.GetPriceHistory(id, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取价格变动记录失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"product":   product,
+			"list":      histories,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/service"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrintController struct {
+	printService service.PrintService
+}
+
+func NewPrintController(ps service.PrintService) *PrintController {
+	return &PrintController{printService: ps}
+}
+
+// GetPrinters 打印机列表
+func (pc *PrintController) GetPrinters(c *gin.Context) {
+	shopID, _ := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	shopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	printers, err := pc.printService.GetPrinters(shopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取打印机列表失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": printers})
+}
+
+// AddPrinter 添加打印机，返回的 token 配置到打印机客户端
+func (pc *PrintController) AddPrinter(c *gin.Context) {
+	var req model.AddPrinterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	shopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+
+	printer, err := pc.printService.AddPrinter(shopID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "添加打印机失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "添加成功", "data": printer})
+}
+
+// EditPrinter 编辑打印机
+func (pc *PrintController) EditPrinter(c *gin.Context) {
+	printer, ok := pc.loadPrinter(c)
+	if !ok {
+		return
+	}
+	var req model.EditPrinterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	printer, err := pc.printService.EditPrinter(printer.ID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "编辑打印机失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "编辑成功", "data": printer})
+}
+
+// ResetPrinterToken 重置打印机令牌，返回新令牌
+func (pc *PrintController) ResetPrinterToken(c *gin.Context) {
+	printer, ok := pc.loadPrinter(c)
+	if !ok {
+		return
+	}
+
+	result, err := pc.printService.ResetPrinterToken(printer.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "重置令牌失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "重置成功", "data": result})
+}
+
+// DeletePrinter 删除打印机，未完成的任务退回店铺队列
+func (pc *PrintController) DeletePrinter(c *gin.Context) {
+	printer, ok := pc.loadPrinter(c)
+	if !ok {
+		return
+	}
+
+	if err := pc.printService.DeletePrinter(printer.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "删除打印机失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "删除成功"})
+}
+
+// CreateJob 手动打印(补打)订单、出库单或收银单
+func (pc *PrintController) CreateJob(c *gin.Context) {
+	var req model.CreatePrintJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	shopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+
+	job, err := pc.printService.CreateJob(shopID, &req, c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "创建打印任务失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "已加入打印队列", "data": job})
+}
+
+// GetJobs 打印任务列表
+func (pc *PrintController) GetJobs(c *gin.Context) {
+	page, pageSize := posPageParams(c)
+	shopID, _ := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	status, _ := strconv.ParseInt(c.DefaultQuery("status", "0"), 10, 8)
+	shopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	jobs, total, err := pc.printService.GetJobs(page, pageSize, shopID, int8(status))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取打印任务失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":      jobs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// RetryJob 重新打印
+func (pc *PrintController) RetryJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的打印任务ID"})
+		return
+	}
+	job, err := pc.printService.GetJobByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "打印任务不存在"})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, job.ShopID); !isValid {
+		return
+	}
+
+	if err := pc.printService.RetryJob(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "重新打印失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "已重新加入打印队列"})
+}
+
+// Preview 预览打印内容，返回文本排版和 base64 编码的 ESC/POS 指令
+func (pc *PrintController) Preview(c *gin.Context) {
+	sourceType := c.Query("source_type")
+	sourceID, err := strconv.ParseInt(c.Query("source_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的打印内容ID"})
+		return
+	}
+	width, _ := strconv.Atoi(c.DefaultQuery("width", "58"))
+
+	shopID, text, content, err := pc.printService.Preview(sourceType, sourceID, width)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": err.Error()})
+		return
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, shopID); !isValid {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"width":  service.ReceiptWidth(width),
+			"text":   text,
+			"escpos": base64.StdEncoding.EncodeToString(content),
+		},
+	})
+}
+
+// PollJob 打印机轮询领取任务，有任务时返回 ESC/POS 指令原文，没有任务时返回 204
+func (pc *PrintController) PollJob(c *gin.Context) {
+	printer, ok := pc.authPrinter(c)
+	if !ok {
+		return
+	}
+
+	job, err := pc.printService.ClaimJob(printer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "领取打印任务失败: " + err.Error()})
+		return
+	}
+	if job == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.Header("X-Print-Job-Id", strconv.FormatInt(job.ID, 10))
+	c.Header("X-Print-Copies", strconv.Itoa(job.Copies))
+	c.Data(http.StatusOK, "application/octet-stream", job.Content)
+}
+
+// ReportJob 打印机回报打印结果
+func (pc *PrintController) ReportJob(c *gin.Context) {
+	printer, ok := pc.authPrinter(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的打印任务ID"})
+		return
+	}
+	var req model.PrintJobResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	if err := pc.printService.ReportJob(printer, id, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "回报打印结果失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "ok"})
+}
+
+// loadPrinter 按路径参数获取打印机并校验店铺权限
+func (pc *PrintController) loadPrinter(c *gin.Context) (*model.Printer, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "无效的打印机ID"})
+		return nil, false
+	}
+	printer, err := pc.printService.GetPrinterByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "打印机不存在"})
+		return nil, false
+	}
+	if _, isValid := pkg.ValidateShopPermission(c, printer.ShopID); !isValid {
+		return nil, false
+	}
+	return printer, true
+}
+
+// authPrinter 打印机接口按令牌认证，令牌放在 X-Printer-Token 请求头或 token 参数中
+func (pc *PrintController) authPrinter(c *gin.Context) (*model.Printer, bool) {
+	token := c.GetHeader("X-Printer-Token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	printer, err := pc.printService.AuthPrinter(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": -1, "message": err.Error()})
+		return nil, false
+	}
+	return printer, true
+}
@@ -4,20 +4,39 @@ import (
 	"cmf/paint_proj/model"
 	"cmf/paint_proj/pkg"
 	"cmf/paint_proj/service"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ProductController struct {
 	productService service.ProductService
 	userService    service.UserService
 	shopService    service.ShopService
+	uploadService  service.UploadService
 }
 
-func NewProductController(s service.ProductService, us service.UserService, ss service.ShopService) *ProductController {
-	return &ProductController{productService: s, userService: us, shopService: ss}
+func NewProductController(s service.ProductService, us service.UserService, ss service.ShopService, ups service.UploadService) *ProductController {
+	return &ProductController{productService: s, userService: us, shopService: ss, uploadService: ups}
+}
+
+// uploadImage 上传图片并记录，记录失败不影响上传结果
+func (pc *ProductController) uploadImage(c *gin.Context) (string, error) {
+	fileURL, err := pkg.UploadImage(c)
+	if err != nil {
+		return "", err
+	}
+	if err := pc.uploadService.RecordUpload(fileURL, model.UploadUsageProduct, c.GetInt64("shop_id"), c.GetInt64("operator_id")); err != nil {
+		log.Printf("记录上传图片失败 %s: %v", fileURL, err)
+	}
+	return fileURL, nil
 }
 
 // GetProductList 获取商品列表
@@ -33,7 +52,7 @@ func (pc *ProductController) GetProductList(c *gin.Context) {
 	userID := c.GetInt64("user_id")
 	shopID := c.GetInt64("shop_id")
 
-	var categories []model.Category
+	var categories []*model.CategoryNode
 	var productMap map[int64][]model.ProductSimple
 	var err error
 
@@ -58,8 +77,82 @@ func (pc *ProductController) GetProductList(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// SearchProducts 小程序商品搜索：关键词(支持拼音首字母)、分类、价格区间、有货筛选，按价格或销量排序，游标分页
+func (pc *ProductController) SearchProducts(c *gin.Context) {
+	shopID := c.GetInt64("shop_id")
+	if shopID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "缺少店铺信息"})
+		return
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+	if pageSize > 50 {
+		pageSize = 50
+	}
+	categoryID, err := strconv.ParseInt(c.DefaultQuery("category_id", "0"), 10, 64)
+	if err != nil {
+		categoryID = 0
+	}
+
+	query := &model.ProductSearchQuery{
+		ShopID:     shopID,
+		Keyword:    strings.TrimSpace(c.Query("keyword")),
+		CategoryID: categoryID,
+		InStock:    c.Query("in_stock") == "1",
+		Sort:       c.Query("sort"),
+		Limit:      pageSize,
+	}
+	// 价格区间，单位元
+	if minPrice := c.Query("min_price"); minPrice != "" {
+		amount, err := model.ParseAmount(minPrice)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "最低价格式错误"})
+			return
+		}
+		query.MinPrice = &amount
+	}
+	if maxPrice := c.Query("max_price"); maxPrice != "" {
+		amount, err := model.ParseAmount(maxPrice)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "最高价格式错误"})
+			return
+		}
+		query.MaxPrice = &amount
+	}
+
+	result, err := pc.productService.SearchProducts(query, c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "搜索商品失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": result})
+}
+
+// GetProductDetail 小程序商品详情
+func (pc *ProductController) GetProductDetail(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "商品ID格式错误"})
+		return
+	}
+
+	detail, err := pc.productService.GetProductDetail(id, c.GetInt64("shop_id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "商品不存在或已下架"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取商品详情失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": detail})
+}
+
 func (pc *ProductController) UploadImageForAdmin(c *gin.Context) {
-	fileURL, err := pkg.UploadImage(c)
+	fileURL, err := pc.uploadImage(c)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": err.Error()})
 		return
@@ -149,7 +242,7 @@ func (pc *ProductController) AddProduct(c *gin.Context) {
 	}
 
 	// 检查商品名称是否已存在（在同一店铺内）
-	exists, err := pc.productService.CheckProductNameExists(req.Name)
+	exists, err := pc.productService.CheckProductNameExists(shopID, req.Name)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "检查商品名称失败: " + err.Error()})
 		return
@@ -158,6 +251,12 @@ func (pc *ProductController) AddProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": pkg.ErrProductNameExists})
 		return
 	}
+	if req.Barcode != "" {
+		if err := pc.productService.ValidateProductBarcode(shopID, req.Barcode, 0); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": err.Error()})
+			return
+		}
+	}
 
 	// 转换为完整的Product结构体
 	product := &model.Product{
@@ -175,9 +274,17 @@ func (pc *ProductController) AddProduct(c *gin.Context) {
 		ShippingCost: req.ShippingCost,
 		ProductCost:  req.ProductCost,
 		Stock:        req.Stock, // 库存初始化为0，由入库操作更新
+		Sku:          req.Sku,
+		Barcode:      req.Barcode,
 	}
 
-	if err := pc.productService.AddProduct(product); err != nil {
+	// 套装商品：库存由组件推算，需同时保存组件
+	if req.IsBundle == model.BundleYes {
+		if err := pc.productService.AddBundleProduct(product, req.BundleItems); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "添加套装失败: " + err.Error()})
+			return
+		}
+	} else if err := pc.productService.AddProduct(product); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "添加商品失败: " + err.Error()})
 		return
 	}
@@ -235,11 +342,15 @@ func (pc *ProductController) EditProduct(c *gin.Context) {
 	}
 
 	// 先获取现有商品信息，验证商品是否存在且有权限访问
-	_, err = pc.productService.GetProductByIDAndShop(id, shopID)
+	product, err := pc.productService.GetProductByIDAndShop(id, shopID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "商品不存在或无权限访问"})
 		return
 	}
+	if product.IsBundle == model.BundleYes && req.Stock > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "套装库存由组件推算，不能直接修改"})
+		return
+	}
 
 	// 构建更新字段映射，只更新前端传递的字段
 	updateData := make(map[string]interface{})
@@ -259,6 +370,19 @@ func (pc *ProductController) EditProduct(c *gin.Context) {
 	if req.Remark != "" {
 		updateData["remark"] = req.Remark
 	}
+	if req.Sku != "" {
+		updateData["sku"] = req.Sku
+	}
+	// 条码为空表示不修改，清空需传 clear_barcode
+	if req.ClearBarcode {
+		updateData["barcode"] = ""
+	} else if req.Barcode != "" {
+		if err := pc.productService.ValidateProductBarcode(shopID, req.Barcode, id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": err.Error()})
+			return
+		}
+		updateData["barcode"] = req.Barcode
+	}
 	// 如果没有需要更新的字段，直接返回成功
 	if len(updateData) == 0 {
 		c.JSON(http.StatusOK, gin.H{
@@ -269,7 +393,7 @@ func (pc *ProductController) EditProduct(c *gin.Context) {
 	}
 
 	// 执行更新
-	if err = pc.productService.UpdateProductFields(id, updateData); err != nil {
+	if err = pc.productService.UpdateProductFields(id, updateData, c.GetInt64("operator_id"), c.GetString("operator_name")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "编辑商品失败: " + err.Error()})
 		return
 	}
@@ -414,6 +538,7 @@ func (pc *ProductController) AddCategory(c *gin.Context) {
 
 	category := &model.Category{
 		Name:      req.Name,
+		ParentID:  req.ParentID,
 		SortOrder: req.SortOrder,
 		ShopID:    shopID,
 	}
@@ -452,6 +577,17 @@ func (pc *ProductController) EditCategory(c *gin.Context) {
 		return
 	}
 
+	// 分类不能跨店铺修改，否则其上下级分类会分属不同店铺
+	existing, err := pc.productService.GetCategoryByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "分类不存在"})
+		return
+	}
+	if existing.ShopID != shopID {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限编辑该分类"})
+		return
+	}
+
 	category := &model.Category{
 		ID:        id,
 		Name:      req.Name,
@@ -492,11 +628,468 @@ func (pc *ProductController) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	// 3. 删除分类
-	if err := pc.productService.DeleteCategory(id); err != nil {
+	// 3. 删除分类，有子分类或商品时需通过 target_id 指定转移到的分类
+	targetID, err := strconv.ParseInt(c.DefaultQuery("target_id", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "转移目标分类ID格式错误"})
+		return
+	}
+	if err := pc.productService.DeleteCategory(category, targetID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "删除分类失败: " + err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "删除分类成功"})
 }
+
+// GetCategoryTree 获取分类树（后台）
+func (pc *ProductController) GetCategoryTree(c *gin.Context) {
+	shopID, err := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	if err != nil {
+		shopID = 0
+	}
+
+	// 验证店铺权限
+	validShopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	tree, err := pc.productService.GetCategoryTree(validShopID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取分类树失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": tree})
+}
+
+// MoveCategory 移动分类到新的上级分类下（后台）
+func (pc *ProductController) MoveCategory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "分类ID格式错误"})
+		return
+	}
+
+	var req model.MoveCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	category, err := pc.productService.GetCategoryByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "分类不存在"})
+		return
+	}
+
+	// 验证店铺权限
+	if !c.GetBool("is_root") && category.ShopID != c.GetInt64("shop_id") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限操作该分类"})
+		return
+	}
+
+	if err := pc.productService.MoveCategory(category, req.ParentID, req.SortOrder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "移动分类失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "移动分类成功"})
+}
+
+// SortCategories 按传入顺序重排同级分类（后台）
+func (pc *ProductController) SortCategories(c *gin.Context) {
+	var req model.SortCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	// 验证店铺权限
+	shopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+	if shopID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "缺少店铺信息"})
+		return
+	}
+
+	if err := pc.productService.SortCategories(shopID, req.ParentID, req.IDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "分类排序失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "分类排序成功"})
+}
+
+// GetBundleItems 获取套装组件（后台）
+func (pc *ProductController) GetBundleItems(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "商品ID格式错误"})
+		return
+	}
+
+	// 1. 先查询套装信息
+	bundle, err := pc.productService.GetProductByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取商品信息失败: " + err.Error()})
+		return
+	}
+
+	// 2. 验证店铺权限
+	operatorShopID := c.GetInt64("shop_id")
+	isRoot := c.GetBool("is_root")
+
+	if !isRoot && bundle.ShopID != operatorShopID {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限查看该商品"})
+		return
+	}
+	if bundle.IsBundle != model.BundleYes {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "该商品不是套装"})
+		return
+	}
+
+	// 3. 获取组件
+	items, err := pc.productService.GetBundleItems(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取套装组件失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"bundle": bundle,
+			"items":  items,
+		},
+	})
+}
+
+// SetBundleItems 设置套装组件（后台）
+func (pc *ProductController) SetBundleItems(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "商品ID格式错误"})
+		return
+	}
+
+	var req model.SetBundleItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	// 1. 先查询套装信息
+	bundle, err := pc.productService.GetProductByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取商品信息失败: " + err.Error()})
+		return
+	}
+
+	// 2. 验证店铺权限
+	operatorShopID := c.GetInt64("shop_id")
+	isRoot := c.GetBool("is_root")
+
+	if !isRoot && bundle.ShopID != operatorShopID {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限编辑该商品"})
+		return
+	}
+
+	// 3. 替换组件
+	if err := pc.productService.SetBundleItems(id, req.Items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "设置套装组件失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "设置套装组件成功"})
+}
+
+// ImportProducts 批量导入商品（后台），支持 .xlsx/.csv
+func (pc *ProductController) ImportProducts(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "请上传导入文件"})
+		return
+	}
+
+	shopID, err := strconv.ParseInt(c.DefaultPostForm("shop_id", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "店铺ID格式错误"})
+		return
+	}
+	dryRun := c.DefaultPostForm("dry_run", "0") == "1"
+
+	// 验证店铺权限
+	validShopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+	if validShopID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "请指定导入的店铺"})
+		return
+	}
+
+	rows, err := pkg.ReadTableFile(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": err.Error()})
+		return
+	}
+
+	result, err := pc.productService.ImportProducts(validShopID, rows, dryRun, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "导入商品失败: " + err.Error()})
+		return
+	}
+	if result.Failed > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "存在校验失败的行，未导入任何数据", "data": result})
+		return
+	}
+
+	message := "导入商品成功"
+	if dryRun {
+		message = "校验通过"
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": message, "data": result})
+}
+
+// GetProductByBarcode 扫码查询商品（后台）
+func (pc *ProductController) GetProductByBarcode(c *gin.Context) {
+	shopID, err := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "店铺ID格式错误"})
+		return
+	}
+
+	// 验证店铺权限
+	validShopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+	if validShopID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "请指定店铺"})
+		return
+	}
+
+	product, err := pc.productService.GetProductByBarcode(validShopID, c.Param("code"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "未找到该条码对应的商品"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "查询商品失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": product})
+}
+
+// PrintBarcodeLabels 生成商品条码标签PDF（后台）
+func (pc *ProductController) PrintBarcodeLabels(c *gin.Context) {
+	var req model.BarcodeLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	// 验证店铺权限
+	validShopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+	if !isValid {
+		return
+	}
+	if validShopID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "请指定店铺"})
+		return
+	}
+
+	buf, err := pc.productService.PrintBarcodeLabels(validShopID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "生成条码标签失败: " + err.Error()})
+		return
+	}
+	pkg.SendPDF(c, fmt.Sprintf("条码标签_%s.pdf", time.Now().Format("20060102150405")), buf)
+}
+
+// CopyCatalog 将一个店铺的分类和商品复制到另一个店铺（仅超级管理员）
+func (pc *ProductController) CopyCatalog(c *gin.Context) {
+	if !c.GetBool("is_root") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "权限不足，需要超级管理员权限"})
+		return
+	}
+
+	var req model.CatalogCopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	for _, shopID := range []int64{req.SourceShopID, req.TargetShopID} {
+		if shop, err := pc.shopService.GetShopByID(shopID); err != nil || shop == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": fmt.Sprintf("店铺ID %d 不存在", shopID)})
+			return
+		}
+	}
+
+	report, err := pc.productService.CopyCatalog(&req, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": err.Error()})
+		return
+	}
+
+	message := "复制商品成功"
+	if req.DryRun {
+		message = "预览成功"
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": message, "data": report})
+}
+
+// GenerateImageVariants 为历史商品主图和图集补生成各规格缩放图（超级管理员），已生成的跳过
+// 未生成前小程序接口返回原图地址
+func (pc *ProductController) GenerateImageVariants(c *gin.Context) {
+	if !c.GetBool("is_root") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "权限不足，需要超级管理员权限"})
+		return
+	}
+
+	count, err := pc.productService.GenerateMissingImageVariants()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "生成图片规格图失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "生成成功", "data": gin.H{"count": count}})
+}
+
+// FillPinyinInitials 为未生成拼音首字母的历史商品补齐（超级管理员），用于小程序拼音搜索
+func (pc *ProductController) FillPinyinInitials(c *gin.Context) {
+	if !c.GetBool("is_root") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "权限不足，需要超级管理员权限"})
+		return
+	}
+
+	count, err := pc.productService.FillMissingPinyinInitials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "补齐拼音首字母失败: " + err.Error(), "data": gin.H{"count": count}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "补齐成功", "data": gin.H{"count": count}})
+}
+
+// ExportProducts 导出商品Excel（后台），筛选条件同商品列表
+func (pc *ProductController) ExportProducts(c *gin.Context) {
+	shopID, err := strconv.ParseInt(c.DefaultQuery("shop_id", "0"), 10, 64)
+	if err != nil {
+		shopID = 0
+	}
+	name := c.Query("name")
+
+	// 验证店铺权限
+	validShopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	if !isValid {
+		return
+	}
+
+	buf, err := pc.productService.ExportProducts(validShopID, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "导出商品失败: " + err.Error()})
+		return
+	}
+	pkg.SendXlsx(c, fmt.Sprintf("商品_%s.xlsx", time.Now().Format("20060102150405")), buf)
+}
+
+// getProductWithPermission 获取商品并校验店铺权限，失败时直接返回错误响应
+func (pc *ProductController) getProductWithPermission(c *gin.Context) (*model.Product, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "商品ID格式错误"})
+		return nil, false
+	}
+	product, err := pc.productService.GetProductByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "商品不存在"})
+		return nil, false
+	}
+	if !c.GetBool("is_root") && product.ShopID != c.GetInt64("shop_id") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限操作该商品"})
+		return nil, false
+	}
+	return product, true
+}
+
+// GetProductGallery 获取商品图集和详情（后台）
+func (pc *ProductController) GetProductGallery(c *gin.Context) {
+	product, ok := pc.getProductWithPermission(c)
+	if !ok {
+		return
+	}
+
+	images, detail, err := pc.productService.GetProductGalleryAndDetail(product.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取商品详情失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"product": product,
+			"images":  images,
+			"detail":  detail,
+		},
+	})
+}
+
+// UploadProductGalleryImage 上传图片并追加到商品图集（后台）
+func (pc *ProductController) UploadProductGalleryImage(c *gin.Context) {
+	product, ok := pc.getProductWithPermission(c)
+	if !ok {
+		return
+	}
+
+	fileURL, err := pc.uploadImage(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": err.Error()})
+		return
+	}
+	image, err := pc.productService.AddProductImage(product, fileURL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "保存图片失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": image})
+}
+
+// SetProductGallery 按顺序重设商品图集，用于排序和删除图片（后台）
+func (pc *ProductController) SetProductGallery(c *gin.Context) {
+	var req model.SetProductGalleryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	product, ok := pc.getProductWithPermission(c)
+	if !ok {
+		return
+	}
+
+	if err := pc.productService.SetProductGallery(product, req.Images); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "设置商品图集失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "设置商品图集成功"})
+}
+
+// SetProductDetail 设置商品图文详情和技术参数（后台）
+func (pc *ProductController) SetProductDetail(c *gin.Context) {
+	var req model.SetProductDetailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+	product, ok := pc.getProductWithPermission(c)
+	if !ok {
+		return
+	}
+
+	if err := pc.productService.SetProductDetail(product, &req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "设置商品详情失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "设置商品详情成功"})
+}
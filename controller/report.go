@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/service"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportController struct {
+	reportService service.ReportService
+}
+
+func NewReportController(rs service.ReportService) *ReportController {
+	return &ReportController{reportService: rs}
+}
+
+// GetSalesReport 销售利润报表
+func (rc *ReportController) GetSalesReport(c *gin.Context) {
+	query, ok := parseSalesReportQuery(c)
+	if !ok {
+		return
+	}
+
+	report, err := rc.reportService.GetSalesReport(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "获取销售报表失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": report})
+}
+
+// ExportSalesReport 导出销售利润报表Excel，参数同销售利润报表
+func (rc *ReportController) ExportSalesReport(c *gin.Context) {
+	query, ok := parseSalesReportQuery(c)
+	if !ok {
+		return
+	}
+
+	buf, err := rc.reportService.ExportSalesReport(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "导出销售报表失败: " + err.Error()})
+		return
+	}
+	pkg.SendXlsx(c, fmt.Sprintf("销售利润_%s_%s.xlsx", query.GroupBy, time.Now().Format("20060102150405")), buf)
+}
+
+// GetInventoryValuation 库存估值，as_of_date 默认为今天
+func (rc *ReportController) GetInventoryValuation(c *gin.Context) {
+	shopID, asOf, ok := parseInventoryValuationQuery(c)
+	if !ok {
+		return
+	}
+
+	valuation, err := rc.reportService.GetInventoryValuation(shopID, asOf)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "获取库存估值失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": valuation})
+}
+
+// ExportInventoryValuation 导出库存估值Excel，参数同库存估值
+func (rc *ReportController) ExportInventoryValuation(c *gin.Context) {
+	shopID, asOf, ok := parseInventoryValuationQuery(c)
+	if !ok {
+		return
+	}
+
+	buf, err := rc.reportService.ExportInventoryValuation(shopID, asOf)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "导出库存估值失败: " + err.Error()})
+		return
+	}
+	pkg.SendXlsx(c, fmt.Sprintf("库存估值_%s.xlsx", asOf.Format("20060102")), buf)
+}
+
+// GetStockMovementReport 库存收发存报表，按 month(YYYY-MM) 或 start_date、end_date 统计
+func (rc *ReportController) GetStockMovementReport(c *gin.Context) {
+	shopID, start, end, ok := parseStockMovementQuery(c)
+	if !ok {
+		return
+	}
+	productID, _ := strconv.ParseInt(c.Query("product_id"), 10, 64)
+
+	report, err := rc.reportService.GetStockMovementReport(shopID, start, end, productID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "获取收发存报表失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": report})
+}
+
+// ExportStockMovementReport 导出库存收发存Excel，参数同收发存报表
+func (rc *ReportController) ExportStockMovementReport(c *gin.Context) {
+	shopID, start, end, ok := parseStockMovementQuery(c)
+	if !ok {
+		return
+	}
+	productID, _ := strconv.ParseInt(c.Query("product_id"), 10, 64)
+
+	buf, err := rc.reportService.ExportStockMovementReport(shopID, start, end, productID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "导出收发存报表失败: " + err.Error()})
+		return
+	}
+	pkg.SendXlsx(c, fmt.Sprintf("收发存_%s_%s.xlsx", start.Format("20060102"), end.AddDate(0, 0, -1).Format("20060102")), buf)
+}
+
+// parseSalesReportQuery 解析报表参数：超级管理员不传 shop_id 时统计全部店铺，普通管理员只能统计自己的店铺
+// 日期默认为本月1日到今天
+func parseSalesReportQuery(c *gin.Context) (*model.SalesReportQuery, bool) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return nil, false
+	}
+
+	start, end, ok := parseReportDateRange(c)
+	if !ok {
+		return nil, false
+	}
+	return &model.SalesReportQuery{
+		ShopID:       shopID,
+		StartTime:    start,
+		EndTime:      end,
+		GroupBy:      c.DefaultQuery("group_by", model.ReportGroupDay),
+		OutboundType: queryInt8(c, "outbound_type"),
+	}, true
+}
+
+// parseReportDateRange 解析 start_date、end_date(YYYY-MM-DD)，返回 [开始日期0点, 结束日期次日0点)
+func parseReportDateRange(c *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+
+	if v := c.Query("start_date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "开始日期格式错误，应为 YYYY-MM-DD"})
+			return start, end, false
+		}
+		start = t
+	}
+	if v := c.Query("end_date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "结束日期格式错误，应为 YYYY-MM-DD"})
+			return start, end, false
+		}
+		end = t.AddDate(0, 0, 1)
+	}
+	return start, end, true
+}
+
+// parseReportShopID 超级管理员不传 shop_id 时统计全部店铺(返回0)，普通管理员只能统计自己的店铺
+func parseReportShopID(c *gin.Context) (int64, bool) {
+	shopID, _ := strconv.ParseInt(c.Query("shop_id"), 10, 64)
+	if shopID > 0 || !c.GetBool("is_root") {
+		return pkg.ValidateShopPermission(c, shopID)
+	}
+	return 0, true
+}
+
+// parseInventoryValuationQuery 解析库存估值参数，as_of_date(YYYY-MM-DD) 默认为今天
+func parseInventoryValuationQuery(c *gin.Context) (int64, time.Time, bool) {
+	now := time.Now()
+	asOf := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return 0, asOf, false
+	}
+	if v := c.Query("as_of_date"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "估值日期格式错误，应为 YYYY-MM-DD"})
+			return 0, asOf, false
+		}
+		asOf = t
+	}
+	return shopID, asOf, true
+}
+
+// parseStockMovementQuery 解析收发存参数：传 month(YYYY-MM) 时统计整月，否则同 parseReportDateRange
+func parseStockMovementQuery(c *gin.Context) (int64, time.Time, time.Time, bool) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return 0, time.Time{}, time.Time{}, false
+	}
+	if v := c.Query("month"); v != "" {
+		t, err := time.ParseInLocation("2006-01", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "月份格式错误，应为 YYYY-MM"})
+			return 0, time.Time{}, time.Time{}, false
+		}
+		return shopID, t, t.AddDate(0, 1, 0), true
+	}
+	start, end, ok := parseReportDateRange(c)
+	return shopID, start, end, ok
+}
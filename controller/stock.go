@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,6 +38,16 @@ func (sc *StockController) BatchInboundStock(c *gin.Context) {
 	}
 	req.ShopID = validShopID
 
+	// 扫码入库：按条码查找商品
+	for i := range req.Items {
+		productID, err := sc.resolveProductID(req.ShopID, req.Items[i].ProductID, req.Items[i].Barcode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "验证失败: " + err.Error()})
+			return
+		}
+		req.Items[i].ProductID = productID
+	}
+
 	// 验证请求
 	if err := sc.validateBatchInboundRequest(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "验证失败: " + err.Error()})
@@ -66,6 +78,16 @@ func (sc *StockController) BatchOutboundStock(c *gin.Context) {
 	}
 	req.ShopID = validShopID
 
+	// 扫码出库：按条码查找商品
+	for i := range req.Items {
+		productID, err := sc.resolveProductID(req.ShopID, req.Items[i].ProductID, req.Items[i].Barcode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "验证失败: " + err.Error()})
+			return
+		}
+		req.Items[i].ProductID = productID
+	}
+
 	// 验证请求
 	if err := sc.validateBatchOutboundRequest(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "验证失败: " + err.Error()})
@@ -81,64 +103,134 @@ func (sc *StockController) BatchOutboundStock(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "批量出库成功"})
 }
 
-// GetStockOperations 获取库存操作列表
+// GetStockOperations 获取库存操作列表，支持多条件筛选和排序，返回不含作废单据的合计
 func (sc *StockController) GetStockOperations(c *gin.Context) {
-	pageStr := c.DefaultQuery("page", "1")
-	pageSizeStr := c.DefaultQuery("page_size", "10")
-	typesStr := c.Query("types")    // 获取types查询参数
-	shopIDStr := c.Query("shop_id") // 获取shop_id查询参数
+	query, ok := parseStockOperationQuery(c)
+	if !ok {
+		return
+	}
 
-	page, err := strconv.Atoi(pageStr)
+	operations, total, summary, err := sc.stockService.SearchStockOperations(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取库存操作列表失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":      operations,
+			"total":     total,
+			"page":      query.Page,
+			"page_size": query.PageSize,
+			"summary":   summary,
+		},
+	})
+}
+
+// ExportStockOperations 导出库存操作Excel，筛选条件同库存操作列表
+func (sc *StockController) ExportStockOperations(c *gin.Context) {
+	query, ok := parseStockOperationQuery(c)
+	if !ok {
+		return
+	}
+
+	buf, err := sc.stockService.ExportStockOperations(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "导出库存操作失败: " + err.Error()})
+		return
+	}
+	pkg.SendXlsx(c, fmt.Sprintf("库存操作_%s.xlsx", time.Now().Format("20060102150405")), buf)
+}
+
+// parseStockOperationQuery 解析库存操作列表的筛选、排序和分页参数，并验证店铺权限
+func parseStockOperationQuery(c *gin.Context) (*model.StockOperationQuery, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
 	if err != nil || page < 1 {
 		page = 1
 	}
-
-	pageSize, err := strconv.Atoi(pageSizeStr)
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 	if err != nil || pageSize < 1 {
 		pageSize = 10
 	}
 
-	// 解析shop_id参数
-	var shopID int64
-	if shopIDStr != "" {
-		shopID, err = strconv.ParseInt(shopIDStr, 10, 64)
-		if err != nil {
-			shopID = 0
-		}
-	}
-
 	// 验证店铺权限
-	validShopID, isValid := pkg.ValidateShopPermission(c, shopID)
+	shopID, _ := strconv.ParseInt(c.Query("shop_id"), 10, 64)
+	shopID, isValid := pkg.ValidateShopPermission(c, shopID)
 	if !isValid {
-		return
+		return nil, false
 	}
-	shopID = validShopID
 
-	// 解析types参数
-	var types *int8
-	if typesStr != "" {
-		typesVal, err := strconv.ParseInt(typesStr, 10, 8)
-		if err == nil {
-			typesInt8 := int8(typesVal)
-			types = &typesInt8
+	query := &model.StockOperationQuery{
+		ShopID:      shopID,
+		UserName:    c.Query("user_name"),
+		Supplier:    c.Query("supplier"),
+		ProductName: c.Query("product_name"),
+		OperationNo: c.Query("operation_no"),
+		Page:        page,
+		PageSize:    pageSize,
+	}
+	query.UserID, _ = strconv.ParseInt(c.Query("user_id"), 10, 64)
+	query.OperatorID, _ = strconv.ParseInt(c.Query("operator_id"), 10, 64)
+	query.ProductID, _ = strconv.ParseInt(c.Query("product_id"), 10, 64)
+	query.Types = queryInt8(c, "types")
+	query.OutboundType = queryInt8(c, "outbound_type")
+	query.IsVoided = queryInt8(c, "is_voided")
+	if status := queryInt8(c, "payment_finish_status"); status != nil {
+		paymentStatus := model.PaymentStatusCode(*status)
+		query.PaymentFinishStatus = &paymentStatus
+	}
+
+	// 金额区间(元)
+	if amount, err := model.ParseAmount(c.Query("min_amount")); err == nil {
+		query.MinAmount = &amount
+	}
+	if amount, err := model.ParseAmount(c.Query("max_amount")); err == nil {
+		query.MaxAmount = &amount
+	}
+
+	// 日期区间，格式 2006-01-02，结束日期当天包含在内
+	if v := c.Query("start_date"); v != "" {
+		start, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "开始日期格式错误，应为 YYYY-MM-DD"})
+			return nil, false
+		}
+		query.StartTime = &start
+	}
+	if v := c.Query("end_date"); v != "" {
+		end, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "结束日期格式错误，应为 YYYY-MM-DD"})
+			return nil, false
+		}
+		end = end.AddDate(0, 0, 1)
+		query.EndTime = &end
+	}
+
+	// 排序：字段_asc 或 字段_desc，默认按操作时间倒序
+	query.SortField, query.SortDesc = model.StockOperationSortCreatedAt, true
+	if sort := c.Query("sort"); sort != "" {
+		switch {
+		case strings.HasSuffix(sort, "_asc"):
+			query.SortField, query.SortDesc = strings.TrimSuffix(sort, "_asc"), false
+		case strings.HasSuffix(sort, "_desc"):
+			query.SortField, query.SortDesc = strings.TrimSuffix(sort, "_desc"), true
+		default:
+			query.SortField = sort
 		}
 	}
+	return query, true
+}
 
-	operations, total, err := sc.stockService.GetStockOperationsByShop(page, pageSize, types, shopID)
+// queryInt8 解析可选的 int8 查询参数，未传或格式错误时返回 nil
+func queryInt8(c *gin.Context, key string) *int8 {
+	v, err := strconv.ParseInt(c.Query(key), 10, 8)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取库存操作列表失败: " + err.Error()})
-		return
+		return nil
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"code": 0,
-		"data": gin.H{
-			"list":      operations,
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-		},
-	})
+	i := int8(v)
+	return &i
 }
 
 // GetStockOperationDetail 获取库存操作详情
@@ -175,6 +267,170 @@ func (sc *StockController) GetStockOperationDetail(c *gin.Context) {
 	})
 }
 
+// GetDeliveryNote 打印出库单的送货单(PDF)，layout 为 a4(默认) 或 triplicate(241mm三联针式纸)
+func (sc *StockController) GetDeliveryNote(c *gin.Context) {
+	operationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "操作ID格式错误"})
+		return
+	}
+
+	note, err := sc.stockService.GetDeliveryNote(operationID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "生成送货单失败: " + err.Error()})
+		return
+	}
+
+	// 验证店铺权限
+	if !c.GetBool("is_root") && note.ShopID != c.GetInt64("shop_id") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限查看该库存操作"})
+		return
+	}
+
+	buf, err := service.RenderDeliveryNotePDF(note, c.DefaultQuery("layout", model.DeliveryNoteLayoutA4))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "生成送货单失败: " + err.Error()})
+		return
+	}
+	pkg.SendPDF(c, "送货单_"+note.NoteNo+".pdf", buf)
+}
+
+// VoidStockOperation 作废库存操作，生成反向变动库存的冲销单
+func (sc *StockController) VoidStockOperation(c *gin.Context) {
+	operationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "操作ID格式错误"})
+		return
+	}
+	var req model.VoidStockOperationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	// 验证店铺权限
+	operation, _, err := sc.stockService.GetStockOperationDetail(operationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": -1, "message": "库存操作不存在"})
+		return
+	}
+	if !c.GetBool("is_root") && operation.ShopID != c.GetInt64("shop_id") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "无权限操作该库存操作"})
+		return
+	}
+
+	reversal, err := sc.stockService.VoidStockOperation(operationID, &req, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "作废失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "作废成功", "data": reversal})
+}
+
+// GetProductStockHistory 商品库存变动记录，支持按操作类型、日期、操作人、购买者、单号筛选
+func (sc *StockController) GetProductStockHistory(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "商品ID格式错误"})
+		return
+	}
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	query := &model.StockLogQuery{
+		ShopID:      shopID,
+		ProductID:   productID,
+		Types:       queryInt8(c, "types"),
+		Operator:    c.Query("operator"),
+		BuyerName:   c.Query("buyer_name"),
+		OperationNo: c.Query("operation_no"),
+		Page:        page,
+		PageSize:    pageSize,
+	}
+	if c.Query("start_date") != "" || c.Query("end_date") != "" {
+		start, end, ok := parseReportDateRange(c)
+		if !ok {
+			return
+		}
+		if c.Query("start_date") != "" {
+			query.StartTime = &start
+		}
+		if c.Query("end_date") != "" {
+			query.EndTime = &end
+		}
+	}
+
+	logs, total, err := sc.stockService.GetProductStockHistory(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "获取库存变动记录失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":      logs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// CheckStockLedger 库存核对：按商品重放库存流水，返回库存不一致和流水有断点的商品
+func (sc *StockController) CheckStockLedger(c *gin.Context) {
+	shopID, ok := parseReportShopID(c)
+	if !ok {
+		return
+	}
+	productID, _ := strconv.ParseInt(c.Query("product_id"), 10, 64)
+
+	report, err := sc.stockService.CheckStockLedger(shopID, productID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "库存核对失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": report})
+}
+
+// RepairStockLedger 库存核对修复，confirm 为 true 时才生成库存调整单
+func (sc *StockController) RepairStockLedger(c *gin.Context) {
+	var req model.RepairStockLedgerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "参数错误: " + err.Error()})
+		return
+	}
+
+	// 验证店铺权限，超级管理员不传 shop_id 时修复全部店铺
+	if req.ShopID > 0 || !c.GetBool("is_root") {
+		validShopID, isValid := pkg.ValidateShopPermission(c, req.ShopID)
+		if !isValid {
+			return
+		}
+		req.ShopID = validShopID
+	}
+
+	result, err := sc.stockService.RepairStockLedger(&req, c.GetInt64("operator_id"), c.GetString("operator_name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": -1, "message": "库存修复失败: " + err.Error(), "data": result})
+		return
+	}
+	message := "核对完成，确认修复请传 confirm: true"
+	if req.Confirm {
+		message = fmt.Sprintf("修复成功，生成 %d 张库存调整单", len(result.Operations))
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "message": message, "data": result})
+}
+
 // SetOutboundPaymentStatus 更新出库单支付状态
 func (sc *StockController) SetOutboundPaymentStatus(c *gin.Context) {
 	var req model.UpdateOutboundPaymentStatusRequest
@@ -262,6 +518,21 @@ func (sc *StockController) validateUpdatePaymentStatusRequest(req *model.UpdateO
 	return nil
 }
 
+// resolveProductID 明细未传商品ID时按条码在店铺内查找商品
+func (sc *StockController) resolveProductID(shopID int64, productID int64, barcode string) (int64, error) {
+	if productID > 0 {
+		return productID, nil
+	}
+	if barcode == "" {
+		return 0, errors.New("商品ID和条码不能都为空")
+	}
+	product, err := sc.productService.GetProductByBarcode(shopID, barcode)
+	if err != nil {
+		return 0, fmt.Errorf("条码 %s 未找到对应商品", barcode)
+	}
+	return product.ID, nil
+}
+
 // validateBatchInboundRequest 验证批量入库请求
 func (sc *StockController) validateBatchInboundRequest(req *model.BatchInboundRequest) error {
 	if len(req.Items) == 0 {
@@ -274,10 +545,14 @@ func (sc *StockController) validateBatchInboundRequest(req *model.BatchInboundRe
 			return fmt.Errorf("商品ID %d 的入库数量必须大于0", item.ProductID)
 		}
 
-		_, err := sc.productService.GetProductByID(item.ProductID)
+		product, err := sc.productService.GetProductByID(item.ProductID)
 		if err != nil {
 			return fmt.Errorf("商品ID %d 不存在", item.ProductID)
 		}
+		// 套装库存由组件推算，请对组件商品入库
+		if product.IsBundle == model.BundleYes {
+			return fmt.Errorf("商品 %s 是套装，请对其组件商品入库", product.Name)
+		}
 	}
 
 	// 验证前端计算的总金额是否正确
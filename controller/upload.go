@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"cmf/paint_proj/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UploadController struct {
+	uploadService service.UploadService
+}
+
+func NewUploadController(s service.UploadService) *UploadController {
+	return &UploadController{uploadService: s}
+}
+
+// PreviewImageCleanup 预览待清理的未引用图片，不删除（需要超级管理员权限）
+func (uc *UploadController) PreviewImageCleanup(c *gin.Context) {
+	uc.cleanupImages(c, true)
+}
+
+// CleanupImages 立即清理未引用图片（需要超级管理员权限）
+func (uc *UploadController) CleanupImages(c *gin.Context) {
+	uc.cleanupImages(c, false)
+}
+
+func (uc *UploadController) cleanupImages(c *gin.Context, dryRun bool) {
+	// 图片存储为所有店铺共用，只允许超级管理员操作
+	if !c.GetBool("is_root") {
+		c.JSON(http.StatusForbidden, gin.H{"code": -1, "message": "权限不足，需要超级管理员权限"})
+		return
+	}
+
+	report, err := uc.uploadService.CleanupOrphanImages(dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": -1, "message": "清理未引用图片失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 0, "data": report})
+}
@@ -284,4 +284,395 @@ SET soi.shop_id = so.shop_id
 WHERE soi.shop_id IS NULL;
 
 -- 为product表的name字段添加索引，优化模糊查询性能
-ALTER TABLE product ADD INDEX idx_name (name);
\ No newline at end of file
+ALTER TABLE product ADD INDEX idx_name (name);
+
+-- 套装商品：product表添加套装标识
+ALTER TABLE product ADD COLUMN is_bundle TINYINT NOT NULL DEFAULT 0 COMMENT '是否套装(1:套装,0:普通商品)' AFTER shop_id;
+
+-- 创建套装组成表
+CREATE TABLE IF NOT EXISTS product_bundle_item (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    bundle_id BIGINT NOT NULL COMMENT '套装商品ID',
+    component_id BIGINT NOT NULL COMMENT '组件商品ID',
+    quantity INT NOT NULL COMMENT '每套所需组件数量',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    UNIQUE KEY uk_bundle_component (bundle_id, component_id),
+    INDEX idx_component_id (component_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='套装组成表';
+
+-- 为stock_operation_item表添加套装相关字段
+ALTER TABLE stock_operation_item
+    ADD COLUMN is_bundle TINYINT NOT NULL DEFAULT 0 COMMENT '是否套装明细(1:是,0:否)' AFTER unit,
+    ADD COLUMN bundle_id BIGINT NOT NULL DEFAULT 0 COMMENT '所属套装商品ID(套装组件明细时)' AFTER is_bundle;
+-- 商品批量导入导出：product表添加商品编码
+ALTER TABLE product ADD COLUMN sku VARCHAR(64) NOT NULL DEFAULT '' COMMENT '商品编码(店铺内唯一，可选)' AFTER name;
+ALTER TABLE product ADD INDEX idx_shop_sku (shop_id, sku);
+
+-- 批量调价：调价单表
+CREATE TABLE IF NOT EXISTS price_adjustment (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    category_id BIGINT NOT NULL DEFAULT 0 COMMENT '按分类调价时的分类ID',
+    supplier VARCHAR(100) NOT NULL DEFAULT '' COMMENT '按供货商调价时的供货商名称',
+    product_ids TEXT COMMENT '按商品调价时的商品ID(逗号分隔)',
+    mode TINYINT NOT NULL COMMENT '调价方式(1:按百分比,2:按固定金额)',
+    percent DECIMAL(8,2) NOT NULL DEFAULT 0 COMMENT '调整百分比',
+    delta BIGINT NOT NULL DEFAULT 0 COMMENT '调整金额(分，可为负)',
+    adjust_cost TINYINT NOT NULL DEFAULT 0 COMMENT '是否同时调整成本价(1:是,0:否)',
+    rounding TINYINT NOT NULL DEFAULT 0 COMMENT '取整规则(0:到分,1:到角,2:四舍五入到元,3:向上取整到元)',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态(1:待生效,2:已生效,3:已取消,4:执行失败)',
+    effective_at DATETIME NULL COMMENT '计划生效时间',
+    applied_at DATETIME NULL COMMENT '实际生效时间',
+    product_count INT NOT NULL DEFAULT 0 COMMENT '实际调价商品数',
+    fail_reason VARCHAR(500) NOT NULL DEFAULT '' COMMENT '失败原因',
+    operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '操作人',
+    operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID',
+    remark VARCHAR(255) NOT NULL DEFAULT '' COMMENT '备注',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_shop_status (shop_id, status),
+    INDEX idx_status_effective (status, effective_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='批量调价单表';
+
+-- 商品价格变动记录表
+CREATE TABLE IF NOT EXISTS product_price_history (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    product_id BIGINT NOT NULL COMMENT '商品ID',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    product_name VARCHAR(255) NOT NULL DEFAULT '' COMMENT '商品名称',
+    old_seller_price BIGINT NOT NULL DEFAULT 0 COMMENT '变动前售价(分)',
+    new_seller_price BIGINT NOT NULL DEFAULT 0 COMMENT '变动后售价(分)',
+    old_cost BIGINT NOT NULL DEFAULT 0 COMMENT '变动前成本价(分)',
+    new_cost BIGINT NOT NULL DEFAULT 0 COMMENT '变动后成本价(分)',
+    source TINYINT NOT NULL COMMENT '变动来源(1:批量调价,2:编辑商品,3:导入,4:入库进价变动,5:店铺间复制,6:作废入库单)',
+    adjustment_id BIGINT NOT NULL DEFAULT 0 COMMENT '关联调价单ID',
+    operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '操作人',
+    operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID',
+    remark VARCHAR(255) NOT NULL DEFAULT '' COMMENT '备注',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_product_id (product_id),
+    INDEX idx_adjustment_id (adjustment_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='商品价格变动记录表';
+
+-- 小程序商品搜索：product表添加名称拼音首字母(历史商品调用 /admin/product/pinyin/fill 补齐)
+ALTER TABLE product ADD COLUMN pinyin_initial VARCHAR(255) NOT NULL DEFAULT '' COMMENT '商品名称拼音首字母' AFTER name;
+-- 销量统计按店铺和类型过滤出库单
+ALTER TABLE stock_operation ADD INDEX idx_shop_types (shop_id, types);
+
+-- 商品图集表
+CREATE TABLE IF NOT EXISTS product_image (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    product_id BIGINT NOT NULL COMMENT '商品ID',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    url VARCHAR(500) NOT NULL COMMENT '图片地址',
+    sort_order INT NOT NULL DEFAULT 0 COMMENT '排序(从小到大)',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_product_sort (product_id, sort_order)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='商品图集表';
+
+-- 商品详情表(图文详情和技术参数)
+CREATE TABLE IF NOT EXISTS product_detail (
+    product_id BIGINT PRIMARY KEY COMMENT '商品ID',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    description MEDIUMTEXT COMMENT '图文详情(富文本HTML)',
+    coverage VARCHAR(100) NOT NULL DEFAULT '' COMMENT '涂布率',
+    drying_time VARCHAR(100) NOT NULL DEFAULT '' COMMENT '干燥时间',
+    voc VARCHAR(100) NOT NULL DEFAULT '' COMMENT 'VOC含量',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='商品详情表';
+
+-- 上传图片记录表(用于清理不再被引用的图片)
+CREATE TABLE IF NOT EXISTS upload_file (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    file_key VARCHAR(255) NOT NULL COMMENT '原图存储路径',
+    url VARCHAR(500) NOT NULL COMMENT '原图访问地址',
+    `usage` VARCHAR(20) NOT NULL DEFAULT 'product' COMMENT '图片用途(product:商品图片)，清理任务只清理能检查引用的用途',
+    shop_id BIGINT NOT NULL DEFAULT 0 COMMENT '上传人所属店铺ID',
+    operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '上传人ID',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '首次上传时间',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '最近上传时间',
+    UNIQUE KEY uk_file_key (file_key),
+    INDEX idx_updated_at (updated_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='上传图片记录表';
+
+-- 多级分类：category表添加上级分类ID
+ALTER TABLE category ADD COLUMN parent_id BIGINT NOT NULL DEFAULT 0 COMMENT '上级分类ID(0为一级分类)' AFTER name;
+ALTER TABLE category ADD INDEX idx_shop_parent (shop_id, parent_id);
+
+-- 商品条码：product表添加条码(EAN或自定义，店铺内唯一)，用于扫码查询和扫码出入库
+ALTER TABLE product ADD COLUMN barcode VARCHAR(32) NOT NULL DEFAULT '' COMMENT '条码(EAN或自定义)' AFTER sku;
+-- 空条码不参与唯一约束：生成列把空串转为 NULL，唯一索引允许多个 NULL
+-- 执行前先检查店铺内重复的条码并处理：SELECT shop_id, barcode, COUNT(*) FROM product WHERE barcode <> '' GROUP BY shop_id, barcode HAVING COUNT(*) > 1;
+ALTER TABLE product ADD COLUMN barcode_key VARCHAR(32) GENERATED ALWAYS AS (NULLIF(barcode, '')) VIRTUAL COMMENT '非空条码(唯一约束用)' AFTER barcode;
+ALTER TABLE product ADD UNIQUE INDEX idx_shop_barcode (shop_id, barcode_key);
+
+-- 客户协议价表(门店收银时按客户自动带出)
+CREATE TABLE IF NOT EXISTS customer_price (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    user_id BIGINT NOT NULL COMMENT '客户ID',
+    product_id BIGINT NOT NULL COMMENT '商品ID',
+    price BIGINT NOT NULL DEFAULT 0 COMMENT '协议价(分)',
+    operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '操作人',
+    operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_shop_user_product (shop_id, user_id, product_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='客户协议价表';
+
+-- 收银班次表
+CREATE TABLE IF NOT EXISTS pos_shift (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    operator_id BIGINT NOT NULL COMMENT '收银员ID',
+    operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '收银员',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态(1:当班,2:已交班)',
+    opening_cash BIGINT NOT NULL DEFAULT 0 COMMENT '备用金(分)',
+    sale_count INT NOT NULL DEFAULT 0 COMMENT '销售单数',
+    sales_amount BIGINT NOT NULL DEFAULT 0 COMMENT '销售总额(分)',
+    cash_sales BIGINT NOT NULL DEFAULT 0 COMMENT '现金收款(分，已扣除找零)',
+    wechat_sales BIGINT NOT NULL DEFAULT 0 COMMENT '微信收款(分)',
+    credit_sales BIGINT NOT NULL DEFAULT 0 COMMENT '挂账金额(分)',
+    expected_cash BIGINT NOT NULL DEFAULT 0 COMMENT '应有现金(分)',
+    counted_cash BIGINT NOT NULL DEFAULT 0 COMMENT '实点现金(分)',
+    difference BIGINT NOT NULL DEFAULT 0 COMMENT '差额(分，负数为短款)',
+    remark VARCHAR(255) NOT NULL DEFAULT '' COMMENT '交班备注',
+    opened_at TIMESTAMP NULL DEFAULT NULL COMMENT '开班时间',
+    closed_at TIMESTAMP NULL DEFAULT NULL COMMENT '交班时间',
+    INDEX idx_shop_operator_status (shop_id, operator_id, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='收银班次表';
+
+-- 门店收银单表
+CREATE TABLE IF NOT EXISTS pos_sale (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    sale_no VARCHAR(50) NOT NULL COMMENT '收银单号',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    shift_id BIGINT NOT NULL DEFAULT 0 COMMENT '结账所在班次ID',
+    operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '收银员ID',
+    operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '收银员',
+    user_id BIGINT NOT NULL DEFAULT 0 COMMENT '客户ID(0为散客)',
+    user_name VARCHAR(100) NOT NULL DEFAULT '' COMMENT '客户名称',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态(1:收银中,2:已结账,3:已取消)',
+    total_amount BIGINT NOT NULL DEFAULT 0 COMMENT '应收金额(分)',
+    paid_cash BIGINT NOT NULL DEFAULT 0 COMMENT '现金实收(分，含找零)',
+    paid_wechat BIGINT NOT NULL DEFAULT 0 COMMENT '微信收款(分)',
+    paid_credit BIGINT NOT NULL DEFAULT 0 COMMENT '挂账金额(分)',
+    change_amount BIGINT NOT NULL DEFAULT 0 COMMENT '找零(分)',
+    operation_id BIGINT NOT NULL DEFAULT 0 COMMENT '结账生成的出库单ID',
+    remark VARCHAR(255) NOT NULL DEFAULT '' COMMENT '备注',
+    created_at TIMESTAMP NULL DEFAULT NULL COMMENT '开单时间',
+    completed_at TIMESTAMP NULL DEFAULT NULL COMMENT '结账时间',
+    INDEX idx_shop_status (shop_id, status),
+    INDEX idx_shift_status (shift_id, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='门店收银单表';
+
+-- 门店收银明细表
+CREATE TABLE IF NOT EXISTS pos_sale_item (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键id',
+    sale_id BIGINT NOT NULL COMMENT '收银单ID',
+    product_id BIGINT NOT NULL COMMENT '商品ID',
+    product_name VARCHAR(255) NOT NULL DEFAULT '' COMMENT '商品名称',
+    specification VARCHAR(100) NOT NULL DEFAULT '' COMMENT '规格',
+    unit VARCHAR(20) NOT NULL DEFAULT '' COMMENT '单位',
+    quantity INT NOT NULL DEFAULT 0 COMMENT '数量',
+    unit_price BIGINT NOT NULL DEFAULT 0 COMMENT '单价(分)',
+    price_source TINYINT NOT NULL DEFAULT 1 COMMENT '单价来源(1:零售价,2:客户协议价,3:手工改价)',
+    total_price BIGINT NOT NULL DEFAULT 0 COMMENT '小计(分)',
+    INDEX idx_sale_id (sale_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='门店收银明细表';
+
+-- 小票打印机表
+CREATE TABLE IF NOT EXISTS printer (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键ID',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    name VARCHAR(50) NOT NULL DEFAULT '' COMMENT '打印机名称',
+    token VARCHAR(64) NOT NULL COMMENT '轮询令牌',
+    paper_width INT NOT NULL DEFAULT 58 COMMENT '纸宽(58/80mm)',
+    auto_print TINYINT NOT NULL DEFAULT 0 COMMENT '订单支付成功、收银结账时自动打印(1:是,0:否)',
+    is_active TINYINT NOT NULL DEFAULT 1 COMMENT '是否启用(1:启用,0:停用)',
+    last_seen_at TIMESTAMP NULL DEFAULT NULL COMMENT '最近轮询时间',
+    created_at TIMESTAMP NULL DEFAULT NULL COMMENT '创建时间',
+    UNIQUE KEY uk_token (token),
+    INDEX idx_shop_id (shop_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='小票打印机表';
+
+-- 打印任务表
+CREATE TABLE IF NOT EXISTS print_job (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键ID',
+    shop_id BIGINT NOT NULL COMMENT '关联店铺ID',
+    printer_id BIGINT NOT NULL DEFAULT 0 COMMENT '指定打印机ID(0为店铺任一打印机)',
+    source_type VARCHAR(20) NOT NULL COMMENT '打印内容类型(order,operation,pos_sale)',
+    source_id BIGINT NOT NULL COMMENT '打印内容ID',
+    source_no VARCHAR(50) NOT NULL DEFAULT '' COMMENT '打印内容单号',
+    copies INT NOT NULL DEFAULT 1 COMMENT '打印份数',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态(1:待打印,2:打印中,3:已打印,4:失败)',
+    attempts INT NOT NULL DEFAULT 0 COMMENT '已领取次数',
+    content MEDIUMBLOB NULL COMMENT '最近一次领取时生成的ESC/POS指令',
+    error VARCHAR(255) NOT NULL DEFAULT '' COMMENT '失败原因',
+    operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '创建人',
+    created_at TIMESTAMP NULL DEFAULT NULL COMMENT '创建时间',
+    claimed_at TIMESTAMP NULL DEFAULT NULL COMMENT '领取时间',
+    printed_at TIMESTAMP NULL DEFAULT NULL COMMENT '打印完成时间',
+    INDEX idx_shop_status_printer (shop_id, status, printer_id),
+    INDEX idx_source (source_type, source_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='打印任务表';
+
+-- 库存操作作废和冲销
+ALTER TABLE stock_operation
+    ADD COLUMN is_voided TINYINT NOT NULL DEFAULT 0 COMMENT '是否已作废(1:已作废,0:正常)',
+    ADD COLUMN void_reason VARCHAR(255) NOT NULL DEFAULT '' COMMENT '作废原因',
+    ADD COLUMN void_operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '作废操作人',
+    ADD COLUMN void_operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '作废操作人ID',
+    ADD COLUMN voided_at TIMESTAMP NULL DEFAULT NULL COMMENT '作废时间',
+    ADD COLUMN reversal_id BIGINT NOT NULL DEFAULT 0 COMMENT '冲销单ID(已作废时)',
+    ADD COLUMN reversal_of_id BIGINT NOT NULL DEFAULT 0 COMMENT '被冲销的原操作单ID(冲销单时)';
+
+-- 价格变动记录关联入库单(作废入库单时按入库单查找成本价变动)，按备注中的入库单号补齐已有记录
+ALTER TABLE product_price_history
+    ADD COLUMN operation_id BIGINT NOT NULL DEFAULT 0 COMMENT '关联库存操作单ID(入库进价变动、作废入库单时)' AFTER adjustment_id,
+    ADD INDEX idx_operation_id (operation_id);
+UPDATE product_price_history h
+    INNER JOIN stock_operation so ON h.remark = CONCAT('入库单 ', so.operation_no) AND so.types = 1
+SET h.operation_id = so.id
+WHERE h.source = 4 AND h.operation_id = 0;
+
+-- 库存操作列表按时间筛选和排序
+ALTER TABLE stock_operation ADD INDEX idx_shop_created (shop_id, created_at);
+
+-- 补齐小程序订单出库明细的店铺ID
+UPDATE stock_operation_item soi
+    INNER JOIN stock_operation so ON so.id = soi.operation_id
+SET soi.shop_id = so.shop_id
+WHERE soi.shop_id = 0 OR soi.shop_id IS NULL;
+
+-- 库存估值和收发存按商品、时间查询库存流水
+ALTER TABLE stock_operation_item ADD INDEX idx_product_created (product_id, created_at);
+
+-- 库存日志：随库存变动写入，补充店铺、操作单和购买者字段
+ALTER TABLE stock_log
+    CHANGE COLUMN type types TINYINT NOT NULL COMMENT '操作类型(1:入库,2:出库,3:退货,4:冲销,5:库存调整)',
+    ADD COLUMN shop_id BIGINT NOT NULL DEFAULT 0 COMMENT '关联店铺ID' AFTER id,
+    ADD COLUMN operation_id BIGINT NOT NULL DEFAULT 0 COMMENT '库存操作单ID' AFTER product_name,
+    ADD COLUMN operation_no VARCHAR(64) NOT NULL DEFAULT '' COMMENT '库存操作单号' AFTER operation_id,
+    ADD COLUMN operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID' AFTER operator,
+    ADD COLUMN buyer_name VARCHAR(255) NOT NULL DEFAULT '' COMMENT '购买者名称(出库时)' AFTER operator_type,
+    ADD COLUMN buyer_account VARCHAR(64) NOT NULL DEFAULT '' COMMENT '购买者账号(出库时)' AFTER buyer_name,
+    ADD COLUMN purchase_time TIMESTAMP NULL DEFAULT NULL COMMENT '购买时间(出库时)' AFTER buyer_account,
+    ADD INDEX idx_product_shop (product_id, shop_id);
+
+-- 微信订阅消息授权：每次同意可下发一条消息
+CREATE TABLE IF NOT EXISTS wechat_subscribe_consent (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键ID',
+    user_id BIGINT NOT NULL COMMENT '用户ID',
+    template_id VARCHAR(64) NOT NULL COMMENT '模板ID',
+    accept_count INT NOT NULL DEFAULT 0 COMMENT '剩余可下发次数',
+    last_result VARCHAR(16) NOT NULL DEFAULT '' COMMENT '最近一次授权结果(accept/reject/ban)',
+    created_at TIMESTAMP NULL DEFAULT NULL COMMENT '创建时间',
+    updated_at TIMESTAMP NULL DEFAULT NULL COMMENT '更新时间',
+    UNIQUE KEY uk_user_template (user_id, template_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='微信订阅消息授权表';
+
+-- 微信订阅消息发送记录
+CREATE TABLE IF NOT EXISTS wechat_subscribe_message (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键ID',
+    shop_id BIGINT NOT NULL DEFAULT 0 COMMENT '关联店铺ID',
+    user_id BIGINT NOT NULL DEFAULT 0 COMMENT '用户ID',
+    openid VARCHAR(64) NOT NULL DEFAULT '' COMMENT '用户openid',
+    order_id BIGINT NOT NULL DEFAULT 0 COMMENT '订单ID',
+    order_no VARCHAR(64) NOT NULL DEFAULT '' COMMENT '订单编号',
+    scene VARCHAR(32) NOT NULL DEFAULT '' COMMENT '通知场景(order_paid/order_shipped/order_pickup)',
+    template_id VARCHAR(64) NOT NULL DEFAULT '' COMMENT '模板ID',
+    content TEXT COMMENT '发送内容(JSON)',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态(1:待发送,2:已发送,3:失败,4:未发送)',
+    attempts INT NOT NULL DEFAULT 0 COMMENT '已发送次数',
+    error VARCHAR(500) NOT NULL DEFAULT '' COMMENT '失败或未发送原因',
+    next_retry_at TIMESTAMP NULL DEFAULT NULL COMMENT '下次重试时间',
+    created_at TIMESTAMP NULL DEFAULT NULL COMMENT '创建时间',
+    sent_at TIMESTAMP NULL DEFAULT NULL COMMENT '发送成功时间',
+    INDEX idx_shop_status (shop_id, status),
+    INDEX idx_status_retry (status, next_retry_at),
+    INDEX idx_order (order_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='微信订阅消息发送记录表';
+
+-- 短信发送记录(含限流统计和月度对账去重)
+CREATE TABLE IF NOT EXISTS sms_log (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键ID',
+    shop_id BIGINT NOT NULL DEFAULT 0 COMMENT '关联店铺ID',
+    user_id BIGINT NOT NULL DEFAULT 0 COMMENT '客户ID',
+    user_name VARCHAR(255) NOT NULL DEFAULT '' COMMENT '客户名称',
+    phone VARCHAR(20) NOT NULL DEFAULT '' COMMENT '手机号',
+    scene VARCHAR(32) NOT NULL DEFAULT '' COMMENT '通知场景(outbound_delivery/payment_reminder/monthly_balance)',
+    ref_no VARCHAR(64) NOT NULL DEFAULT '' COMMENT '关联单号(出库单号、对账月份等)',
+    provider VARCHAR(16) NOT NULL DEFAULT '' COMMENT '短信服务商',
+    template_code VARCHAR(64) NOT NULL DEFAULT '' COMMENT '模板编码',
+    params TEXT COMMENT '模板参数(JSON)',
+    content VARCHAR(500) NOT NULL DEFAULT '' COMMENT '短信内容',
+    status TINYINT NOT NULL DEFAULT 2 COMMENT '状态(2:已发送,3:失败,4:未发送)',
+    error VARCHAR(500) NOT NULL DEFAULT '' COMMENT '失败或未发送原因',
+    biz_id VARCHAR(64) NOT NULL DEFAULT '' COMMENT '服务商回执ID',
+    operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '操作人(自动发送为system)',
+    created_at TIMESTAMP NULL DEFAULT NULL COMMENT '发送时间',
+    INDEX idx_phone_status_created (phone, status, created_at),
+    INDEX idx_user_scene_ref (user_id, scene, ref_no),
+    INDEX idx_shop_created (shop_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='短信发送记录表';
+
+-- 欠款提醒设置表(每个店铺一条)
+CREATE TABLE IF NOT EXISTS payment_reminder_setting (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键ID',
+    shop_id BIGINT NOT NULL DEFAULT 0 COMMENT '店铺ID',
+    enabled TINYINT NOT NULL DEFAULT 0 COMMENT '是否启用(1:启用,0:停用)',
+    thresholds VARCHAR(100) NOT NULL DEFAULT '7,15,30' COMMENT '提醒账龄阈值(天)，逗号分隔',
+    send_hour INT NOT NULL DEFAULT 10 COMMENT '每天几点后发送(0-23)',
+    channels VARCHAR(32) NOT NULL DEFAULT 'wechat,sms' COMMENT '提醒渠道，逗号分隔(wechat,sms)',
+    min_amount BIGINT NOT NULL DEFAULT 0 COMMENT '最低提醒金额(分)',
+    updated_by VARCHAR(50) NOT NULL DEFAULT '' COMMENT '最后修改人',
+    updated_at TIMESTAMP NULL DEFAULT NULL COMMENT '更新时间',
+    UNIQUE KEY uk_shop (shop_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='欠款提醒设置表';
+
+-- 欠款提醒表(每次每个客户一条)
+CREATE TABLE IF NOT EXISTS payment_reminder (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键ID',
+    shop_id BIGINT NOT NULL DEFAULT 0 COMMENT '店铺ID',
+    user_id BIGINT NOT NULL DEFAULT 0 COMMENT '客户ID',
+    user_name VARCHAR(255) NOT NULL DEFAULT '' COMMENT '客户名称',
+    phone VARCHAR(20) NOT NULL DEFAULT '' COMMENT '客户手机号',
+    stage INT NOT NULL DEFAULT 0 COMMENT '触发的账龄阈值(天)',
+    operation_count INT NOT NULL DEFAULT 0 COMMENT '欠款出库单数',
+    total_amount BIGINT NOT NULL DEFAULT 0 COMMENT '欠款合计(分)',
+    oldest_at TIMESTAMP NULL DEFAULT NULL COMMENT '最早一笔出库时间',
+    oldest_operation_no VARCHAR(64) NOT NULL DEFAULT '' COMMENT '最早一笔出库单号',
+    status TINYINT NOT NULL DEFAULT 1 COMMENT '状态(1:发送中,2:已发送,3:失败,4:未发送)',
+    wechat_message_id BIGINT NOT NULL DEFAULT 0 COMMENT '订阅消息发送记录ID',
+    wechat_status TINYINT NOT NULL DEFAULT 0 COMMENT '订阅消息状态(0:未使用该渠道)',
+    sms_log_id BIGINT NOT NULL DEFAULT 0 COMMENT '短信发送记录ID',
+    sms_status TINYINT NOT NULL DEFAULT 0 COMMENT '短信状态(0:未使用该渠道)',
+    error VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '失败或未发送原因',
+    operator VARCHAR(50) NOT NULL DEFAULT '' COMMENT '操作人(定时任务为system)',
+    created_at TIMESTAMP NULL DEFAULT NULL COMMENT '提醒时间',
+    INDEX idx_shop_created (shop_id, created_at),
+    INDEX idx_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='欠款提醒表';
+
+-- 欠款提醒出库单明细表
+CREATE TABLE IF NOT EXISTS payment_reminder_item (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT '主键ID',
+    reminder_id BIGINT NOT NULL DEFAULT 0 COMMENT '欠款提醒ID',
+    user_id BIGINT NOT NULL DEFAULT 0 COMMENT '客户ID',
+    operation_id BIGINT NOT NULL DEFAULT 0 COMMENT '出库单ID',
+    operation_no VARCHAR(64) NOT NULL DEFAULT '' COMMENT '出库单号',
+    amount BIGINT NOT NULL DEFAULT 0 COMMENT '出库金额(分)',
+    operation_at TIMESTAMP NULL DEFAULT NULL COMMENT '出库时间',
+    age_days INT NOT NULL DEFAULT 0 COMMENT '账龄(天)',
+    stage INT NOT NULL DEFAULT 0 COMMENT '达到的账龄阈值(天)',
+    INDEX idx_reminder (reminder_id),
+    INDEX idx_operation (operation_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='欠款提醒出库单明细表';
+
+-- 出库单未结清金额：门店收银部分挂账时只有挂账部分计入欠款(看板未收款、对账短信、欠款提醒按此金额统计)
+ALTER TABLE stock_operation
+    ADD COLUMN unpaid_amount BIGINT NOT NULL DEFAULT 0 COMMENT '未结清金额(分)，后台出库为总金额，门店收银为挂账部分，已支付为0' AFTER payment_finish_time;
+UPDATE stock_operation so
+    LEFT JOIN pos_sale ps ON ps.operation_id = so.id
+    SET so.unpaid_amount = COALESCE(ps.paid_credit, so.total_amount)
+    WHERE so.types = 2 AND so.outbound_type <> 1 AND so.payment_finish_status = 1;
@@ -3,6 +3,7 @@ package main
 import (
 	"cmf/paint_proj/router"
 	"log"
+	"os"
 	"time"
 )
 
@@ -12,6 +13,11 @@ func init() {
 	time.Local = loc
 }
 func main() {
+	// 命令行子命令
+	if len(os.Args) > 1 && os.Args[1] == "stock-check" {
+		os.Exit(runStockCheck(os.Args[2:]))
+	}
+
 	r := router.SetupRouter()
 	// 启动服务
 
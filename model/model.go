@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,6 +25,10 @@ type Product struct {
 	Remark        string `json:"remark" gorm:"remark"`                   // 备注
 	IsOnShelf     int8   `json:"is_on_shelf" gorm:"is_on_shelf"`         // 是否上架(1:上架,0:下架)
 	ShopID        int64  `json:"shop_id" gorm:"shop_id"`                 // 关联店铺ID
+	IsBundle      int8   `json:"is_bundle" gorm:"is_bundle"`             // 是否套装(1:套装,0:普通商品) 套装库存由组件推算
+	Sku           string `json:"sku" gorm:"sku"`                         // 商品编码(店铺内唯一，可选)
+	Barcode       string `json:"barcode" gorm:"barcode"`                 // 条码(EAN或自定义，店铺内唯一，可选)
+	PinyinInitial string `json:"-" gorm:"pinyin_initial"`                // 商品名称拼音首字母(用于小程序搜索)
 }
 
 // TableName 表名称
@@ -30,11 +36,132 @@ func (*Product) TableName() string {
 	return "product"
 }
 
+// ProductBundleItem 套装组成表(一个套装由多个普通商品按数量组成)
+type ProductBundleItem struct {
+	ID          int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	BundleID    int64      `json:"bundle_id" gorm:"bundle_id"`            // 套装商品ID
+	ComponentID int64      `json:"component_id" gorm:"component_id"`      // 组件商品ID
+	Quantity    int        `json:"quantity" gorm:"quantity"`              // 每套所需组件数量
+	ShopID      int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	CreatedAt   *time.Time `json:"created_at" gorm:"created_at"`          // 创建时间
+}
+
+// TableName 表名称
+func (*ProductBundleItem) TableName() string {
+	return "product_bundle_item"
+}
+
+// ProductImage 商品图集表(按sort_order排序，第一张同步为商品主图)
+type ProductImage struct {
+	ID        int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ProductID int64      `json:"product_id" gorm:"product_id"`          // 商品ID
+	ShopID    int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	URL       string     `json:"url" gorm:"column:url"`                 // 图片地址
+	SortOrder int        `json:"sort_order" gorm:"sort_order"`          // 排序(从小到大)
+	CreatedAt *time.Time `json:"created_at" gorm:"created_at"`          // 创建时间
+}
+
+// TableName 表名称
+func (*ProductImage) TableName() string {
+	return "product_image"
+}
+
+// ProductDetail 商品详情表(图文描述和技术参数)
+type ProductDetail struct {
+	ProductID   int64      `json:"product_id" gorm:"column:product_id;primaryKey"` // 商品ID
+	ShopID      int64      `json:"shop_id" gorm:"shop_id"`                         // 关联店铺ID
+	Description string     `json:"description" gorm:"description"`                 // 图文详情(富文本HTML)
+	Coverage    string     `json:"coverage" gorm:"coverage"`                       // 涂布率(如 10-12㎡/L/遍)
+	DryingTime  string     `json:"drying_time" gorm:"drying_time"`                 // 干燥时间(如 表干30分钟，实干2小时)
+	VOC         string     `json:"voc" gorm:"column:voc"`                          // VOC含量(如 ≤30g/L)
+	UpdatedAt   *time.Time `json:"updated_at" gorm:"updated_at"`                   // 更新时间
+}
+
+// TableName 表名称
+func (*ProductDetail) TableName() string {
+	return "product_detail"
+}
+
+// UploadFile 上传图片记录表，用于清理不再被引用的图片
+type UploadFile struct {
+	ID         int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	FileKey    string     `json:"file_key" gorm:"file_key"`              // 原图存储路径(缩放图按规则推算)
+	Usage      string     `json:"usage" gorm:"usage"`                    // 图片用途，清理任务只清理能检查引用的用途
+	URL        string     `json:"url" gorm:"column:url"`                 // 原图访问地址
+	ShopID     int64      `json:"shop_id" gorm:"shop_id"`                // 上传人所属店铺ID
+	OperatorID int64      `json:"operator_id" gorm:"operator_id"`        // 上传人ID
+	CreatedAt  *time.Time `json:"created_at" gorm:"created_at"`          // 首次上传时间
+	UpdatedAt  *time.Time `json:"updated_at" gorm:"updated_at"`          // 最近上传时间(相同图片再次上传时刷新)
+}
+
+// 上传图片用途
+const (
+	UploadUsageProduct = "product" // 商品主图、图集、图文详情
+)
+
+// TableName 表名称
+func (*UploadFile) TableName() string {
+	return "upload_file"
+}
+
+// ProductPriceHistory 商品价格变动记录表
+type ProductPriceHistory struct {
+	ID             int64      `json:"id" gorm:"id,primaryKey;autoIncrement"`    // 主键ID
+	ProductID      int64      `json:"product_id" gorm:"product_id"`             // 商品ID
+	ShopID         int64      `json:"shop_id" gorm:"shop_id"`                   // 关联店铺ID
+	ProductName    string     `json:"product_name" gorm:"product_name"`         // 商品名称
+	OldSellerPrice Amount     `json:"old_seller_price" gorm:"old_seller_price"` // 变动前售价
+	NewSellerPrice Amount     `json:"new_seller_price" gorm:"new_seller_price"` // 变动后售价
+	OldCost        Amount     `json:"old_cost" gorm:"old_cost"`                 // 变动前成本价
+	NewCost        Amount     `json:"new_cost" gorm:"new_cost"`                 // 变动后成本价
+	Source         int8       `json:"source" gorm:"source"`                     // 变动来源(1:批量调价,2:编辑商品,3:导入,4:入库进价变动,5:店铺间复制,6:作废入库单)
+	AdjustmentID   int64      `json:"adjustment_id" gorm:"adjustment_id"`       // 关联调价单ID(批量调价时)
+	OperationID    int64      `json:"operation_id" gorm:"operation_id"`         // 关联库存操作单ID(入库进价变动、作废入库单时)
+	Operator       string     `json:"operator" gorm:"operator"`                 // 操作人
+	OperatorID     int64      `json:"operator_id" gorm:"operator_id"`           // 操作人ID
+	Remark         string     `json:"remark" gorm:"remark"`                     // 备注
+	CreatedAt      *time.Time `json:"created_at" gorm:"created_at"`             // 创建时间
+}
+
+// TableName 表名称
+func (*ProductPriceHistory) TableName() string {
+	return "product_price_history"
+}
+
+// PriceAdjustment 批量调价单表(立即生效或定时生效)
+type PriceAdjustment struct {
+	ID           int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ShopID       int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	CategoryID   int64      `json:"category_id" gorm:"category_id"`        // 按分类调价时的分类ID
+	Supplier     string     `json:"supplier" gorm:"supplier"`              // 按供货商调价时的供货商名称
+	ProductIDs   string     `json:"product_ids" gorm:"product_ids"`        // 按商品调价时的商品ID(逗号分隔)
+	Mode         int8       `json:"mode" gorm:"mode"`                      // 调价方式(1:按百分比,2:按固定金额)
+	Percent      float64    `json:"percent" gorm:"percent"`                // 调整百分比(10表示上调10%，-5表示下调5%)
+	Delta        Amount     `json:"delta" gorm:"delta"`                    // 调整金额(可为负)
+	AdjustCost   int8       `json:"adjust_cost" gorm:"adjust_cost"`        // 是否同时调整成本价(1:是,0:否)
+	Rounding     int8       `json:"rounding" gorm:"rounding"`              // 取整规则(0:到分,1:到角,2:四舍五入到元,3:向上取整到元)
+	Status       int8       `json:"status" gorm:"status"`                  // 状态(1:待生效,2:已生效,3:已取消,4:执行失败)
+	EffectiveAt  *time.Time `json:"effective_at" gorm:"effective_at"`      // 计划生效时间
+	AppliedAt    *time.Time `json:"applied_at" gorm:"applied_at"`          // 实际生效时间
+	ProductCount int        `json:"product_count" gorm:"product_count"`    // 实际调价商品数
+	FailReason   string     `json:"fail_reason" gorm:"fail_reason"`        // 失败原因
+	Operator     string     `json:"operator" gorm:"operator"`              // 操作人
+	OperatorID   int64      `json:"operator_id" gorm:"operator_id"`        // 操作人ID
+	Remark       string     `json:"remark" gorm:"remark"`                  // 备注
+	CreatedAt    *time.Time `json:"created_at" gorm:"created_at"`          // 创建时间
+}
+
+// TableName 表名称
+func (*PriceAdjustment) TableName() string {
+	return "price_adjustment"
+}
+
 // Category 商品分类表
 type Category struct {
 	ID        int64  `json:"id" gorm:"id,primaryKey;autoIncrement" ` // 分类ID
 	Name      string `json:"name" gorm:"name"`                       // 分类名称
-	SortOrder int64  `json:"sort_order" gorm:"sort_order"`           // 排序权重(数字越大越靠前)
+	ParentID  int64  `json:"parent_id" gorm:"parent_id"`             // 上级分类ID(0为一级分类)
+	SortOrder int64  `json:"sort_order" gorm:"sort_order"`           // 排序权重(同级分类中数字越大越靠前)
 	ShopID    int64  `json:"shop_id" gorm:"shop_id"`                 // 关联店铺ID
 }
 
@@ -101,6 +228,34 @@ const (
 	// 微信绑定状态
 	WechatBindNo  = 0 // 未绑定微信
 	WechatBindYes = 1 // 已绑定微信
+
+	// 套装标识
+	BundleNo  = 0 // 普通商品
+	BundleYes = 1 // 套装商品
+
+	// 调价方式
+	PriceAdjustModePercent = 1 // 按百分比
+	PriceAdjustModeFixed   = 2 // 按固定金额
+
+	// 调价取整规则
+	PriceRoundingCent     = 0 // 精确到分
+	PriceRoundingJiao     = 1 // 四舍五入到角
+	PriceRoundingYuan     = 2 // 四舍五入到元
+	PriceRoundingCeilYuan = 3 // 向上取整到元
+
+	// 调价单状态
+	PriceAdjustmentPending   = 1 // 待生效
+	PriceAdjustmentApplied   = 2 // 已生效
+	PriceAdjustmentCancelled = 3 // 已取消
+	PriceAdjustmentFailed    = 4 // 执行失败
+
+	// 价格变动来源
+	PriceSourceAdjustment  = 1 // 批量调价
+	PriceSourceEdit        = 2 // 编辑商品
+	PriceSourceImport      = 3 // 导入
+	PriceSourceInbound     = 4 // 入库进价变动
+	PriceSourceCopy        = 5 // 店铺间复制商品
+	PriceSourceInboundVoid = 6 // 作废入库单恢复成本价
 )
 
 // Order 订单表
@@ -212,6 +367,20 @@ func (a *Amount) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Yuan 转换为元（用于导出Excel等展示场景）
+func (a Amount) Yuan() float64 {
+	return float64(a) / 100
+}
+
+// ParseAmount 将以元为单位的字符串解析为金额(分)，如 "12.5" -> 1250
+func ParseAmount(s string) (Amount, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	return Amount(math.Round(f * 100)), nil
+}
+
 // Address undefined
 type Address struct {
 	ID             int64  `json:"id" gorm:"id"`
@@ -236,7 +405,7 @@ func (*Address) TableName() string {
 type StockOperation struct {
 	ID           int64  `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键id
 	OperationNo  string `json:"operation_no" gorm:"operation_no"`      // 操作单号
-	Types        int8   `json:"types" gorm:"types"`                    // 操作类型(1:入库,2:出库,3:退货)
+	Types        int8   `json:"types" gorm:"types"`                    // 操作类型(1:入库,2:出库,3:退货,4:冲销,5:库存调整)
 	OutboundType int8   `json:"outbound_type" gorm:"outbound_type"`    // 出库类型(1:小程序购买,2:admin后台操作)
 	Operator     string `json:"operator" gorm:"operator"`              // 操作人
 	OperatorID   int64  `json:"operator_id" gorm:"operator_id"`        // 操作人ID
@@ -251,10 +420,19 @@ type StockOperation struct {
 	TotalProfit         Amount            `json:"total_profit" gorm:"total_profit"`                   // 总利润
 	PaymentFinishStatus PaymentStatusCode `json:"payment_finish_status" gorm:"payment_finish_status"` // 支付完成状态(1:未支付,3:已支付)
 	PaymentFinishTime   *time.Time        `json:"payment_finish_time" gorm:"payment_finish_time"`     // 支付完成时间
+	UnpaidAmount        Amount            `json:"unpaid_amount" gorm:"unpaid_amount"`                 // 未结清金额(应收欠款：后台出库为总金额，门店收银为挂账部分，已支付为0)
 	Supplier            string            `json:"supplier" gorm:"supplier"`                           // 供货商
 	CreatedAt           *time.Time        `json:"created_at" gorm:"created_at"`                       // 创建时间
+	IsVoided            int8              `json:"is_voided" gorm:"is_voided"`                         // 是否已作废(1:已作废,0:正常)
+	VoidReason          string            `json:"void_reason" gorm:"void_reason"`                     // 作废原因
+	VoidOperator        string            `json:"void_operator" gorm:"void_operator"`                 // 作废操作人
+	VoidOperatorID      int64             `json:"void_operator_id" gorm:"void_operator_id"`           // 作废操作人ID
+	VoidedAt            *time.Time        `json:"voided_at" gorm:"voided_at"`                         // 作废时间
+	ReversalID          int64             `json:"reversal_id" gorm:"reversal_id"`                     // 冲销单ID(已作废时)
+	ReversalOfID        int64             `json:"reversal_of_id" gorm:"reversal_of_id"`               // 被冲销的原操作单ID(冲销单时)
 
 	Items []StockOperationItem `json:"items" gorm:"-"` // 关联的子表数据（不映射到数据库）
+	Buyer *User                `json:"-" gorm:"-"`     // 写库存日志时查询的购买者，同一操作单只查询一次（不映射到数据库）
 }
 
 // Supplier 供货商表
@@ -294,6 +472,8 @@ type StockOperationItem struct {
 	ProductName   string     `json:"product_name" gorm:"product_name"`   // 商品全名
 	Specification string     `json:"specification" gorm:"specification"` // 规格
 	Unit          string     `json:"unit" gorm:"unit"`                   // 单位 L/桶/套
+	IsBundle      int8       `json:"is_bundle" gorm:"is_bundle"`         // 是否套装明细(1:是,0:否) 套装明细只记金额和利润，不变动库存
+	BundleID      int64      `json:"bundle_id" gorm:"bundle_id"`         // 所属套装商品ID(套装组件明细时)
 	CreatedAt     *time.Time `json:"created_at" gorm:"created_at"`       // 创建时间
 
 }
@@ -303,12 +483,148 @@ func (*StockOperationItem) TableName() string {
 	return "stock_operation_item"
 }
 
-// 库存日志表（保留兼容性，后续可考虑迁移）
+// CustomerPrice 客户协议价(门店收银时按客户自动带出)
+type CustomerPrice struct {
+	ID         int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ShopID     int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	UserID     int64      `json:"user_id" gorm:"user_id"`                // 客户ID
+	ProductID  int64      `json:"product_id" gorm:"product_id"`          // 商品ID
+	Price      Amount     `json:"price" gorm:"price"`                    // 协议价
+	Operator   string     `json:"operator" gorm:"operator"`              // 操作人
+	OperatorID int64      `json:"operator_id" gorm:"operator_id"`        // 操作人ID
+	UpdatedAt  *time.Time `json:"updated_at" gorm:"updated_at"`          // 更新时间
+}
+
+// TableName 表名称
+func (*CustomerPrice) TableName() string {
+	return "customer_price"
+}
+
+// PosShift 收银班次(每个收银员在每个店铺同时只有一个未交班的班次)
+type PosShift struct {
+	ID           int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ShopID       int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	OperatorID   int64      `json:"operator_id" gorm:"operator_id"`        // 收银员ID
+	Operator     string     `json:"operator" gorm:"operator"`              // 收银员
+	Status       int8       `json:"status" gorm:"status"`                  // 状态(1:当班,2:已交班)
+	OpeningCash  Amount     `json:"opening_cash" gorm:"opening_cash"`      // 备用金
+	SaleCount    int        `json:"sale_count" gorm:"sale_count"`          // 销售单数
+	SalesAmount  Amount     `json:"sales_amount" gorm:"sales_amount"`      // 销售总额
+	CashSales    Amount     `json:"cash_sales" gorm:"cash_sales"`          // 现金收款(已扣除找零)
+	WechatSales  Amount     `json:"wechat_sales" gorm:"wechat_sales"`      // 微信收款
+	CreditSales  Amount     `json:"credit_sales" gorm:"credit_sales"`      // 挂账金额
+	ExpectedCash Amount     `json:"expected_cash" gorm:"expected_cash"`    // 应有现金=备用金+现金收款
+	CountedCash  Amount     `json:"counted_cash" gorm:"counted_cash"`      // 实点现金
+	Difference   Amount     `json:"difference" gorm:"difference"`          // 差额=实点-应有(负数为短款)
+	Remark       string     `json:"remark" gorm:"remark"`                  // 交班备注
+	OpenedAt     *time.Time `json:"opened_at" gorm:"opened_at"`            // 开班时间
+	ClosedAt     *time.Time `json:"closed_at" gorm:"closed_at"`            // 交班时间
+}
+
+// TableName 表名称
+func (*PosShift) TableName() string {
+	return "pos_shift"
+}
+
+// PosSale 门店收银单
+type PosSale struct {
+	ID           int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	SaleNo       string     `json:"sale_no" gorm:"sale_no"`                // 收银单号
+	ShopID       int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	ShiftID      int64      `json:"shift_id" gorm:"shift_id"`              // 结账所在班次ID
+	OperatorID   int64      `json:"operator_id" gorm:"operator_id"`        // 收银员ID
+	Operator     string     `json:"operator" gorm:"operator"`              // 收银员
+	UserID       int64      `json:"user_id" gorm:"user_id"`                // 客户ID(0为散客)
+	UserName     string     `json:"user_name" gorm:"user_name"`            // 客户名称
+	Status       int8       `json:"status" gorm:"status"`                  // 状态(1:收银中,2:已结账,3:已取消)
+	TotalAmount  Amount     `json:"total_amount" gorm:"total_amount"`      // 应收金额
+	PaidCash     Amount     `json:"paid_cash" gorm:"paid_cash"`            // 现金实收(含找零)
+	PaidWechat   Amount     `json:"paid_wechat" gorm:"paid_wechat"`        // 微信收款
+	PaidCredit   Amount     `json:"paid_credit" gorm:"paid_credit"`        // 挂账金额
+	ChangeAmount Amount     `json:"change_amount" gorm:"change_amount"`    // 找零
+	OperationID  int64      `json:"operation_id" gorm:"operation_id"`      // 结账生成的出库单ID
+	Remark       string     `json:"remark" gorm:"remark"`                  // 备注
+	CreatedAt    *time.Time `json:"created_at" gorm:"created_at"`          // 开单时间
+	CompletedAt  *time.Time `json:"completed_at" gorm:"completed_at"`      // 结账时间
+
+	Items []PosSaleItem `json:"items" gorm:"-"` // 收银明细
+}
+
+// TableName 表名称
+func (*PosSale) TableName() string {
+	return "pos_sale"
+}
+
+// PosSaleItem 门店收银明细
+type PosSaleItem struct {
+	ID            int64  `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	SaleID        int64  `json:"sale_id" gorm:"sale_id"`                // 收银单ID
+	ProductID     int64  `json:"product_id" gorm:"product_id"`          // 商品ID
+	ProductName   string `json:"product_name" gorm:"product_name"`      // 商品名称
+	Specification string `json:"specification" gorm:"specification"`    // 规格
+	Unit          string `json:"unit" gorm:"unit"`                      // 单位
+	Quantity      int    `json:"quantity" gorm:"quantity"`              // 数量
+	UnitPrice     Amount `json:"unit_price" gorm:"unit_price"`          // 单价
+	PriceSource   int8   `json:"price_source" gorm:"price_source"`      // 单价来源(1:零售价,2:客户协议价,3:手工改价)
+	TotalPrice    Amount `json:"total_price" gorm:"total_price"`        // 小计
+}
+
+// TableName 表名称
+func (*PosSaleItem) TableName() string {
+	return "pos_sale_item"
+}
+
+// Printer 小票打印机(云打印机或本地打印代理凭令牌轮询打印任务)
+type Printer struct {
+	ID         int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ShopID     int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	Name       string     `json:"name" gorm:"name"`                      // 打印机名称(如 前台、仓库)
+	Token      string     `json:"-" gorm:"token"`                        // 轮询令牌(只在添加和重置时返回)
+	PaperWidth int        `json:"paper_width" gorm:"paper_width"`        // 纸宽(58/80mm)
+	AutoPrint  int8       `json:"auto_print" gorm:"auto_print"`          // 订单支付成功、收银结账时自动打印(1:是,0:否)
+	IsActive   int8       `json:"is_active" gorm:"is_active"`            // 是否启用(1:启用,0:停用)
+	LastSeenAt *time.Time `json:"last_seen_at" gorm:"last_seen_at"`      // 最近轮询时间
+	CreatedAt  *time.Time `json:"created_at" gorm:"created_at"`          // 创建时间
+}
+
+// TableName 表名称
+func (*Printer) TableName() string {
+	return "printer"
+}
+
+// PrintJob 打印任务(按店铺排队，打印机轮询领取)
+type PrintJob struct {
+	ID         int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ShopID     int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	PrinterID  int64      `json:"printer_id" gorm:"printer_id"`          // 指定打印机ID(0为店铺任一打印机，领取后为实际打印机)
+	SourceType string     `json:"source_type" gorm:"source_type"`        // 打印内容类型(order:小程序订单,operation:出库单,pos_sale:收银单)
+	SourceID   int64      `json:"source_id" gorm:"source_id"`            // 打印内容ID
+	SourceNo   string     `json:"source_no" gorm:"source_no"`            // 打印内容单号
+	Copies     int        `json:"copies" gorm:"copies"`                  // 打印份数
+	Status     int8       `json:"status" gorm:"status"`                  // 状态(1:待打印,2:打印中,3:已打印,4:失败)
+	Attempts   int        `json:"attempts" gorm:"attempts"`              // 已领取次数
+	Content    []byte     `json:"-" gorm:"content"`                      // 最近一次领取时生成的 ESC/POS 指令
+	Error      string     `json:"error" gorm:"error"`                    // 失败原因
+	Operator   string     `json:"operator" gorm:"operator"`              // 创建人(自动打印为 system)
+	CreatedAt  *time.Time `json:"created_at" gorm:"created_at"`          // 创建时间
+	ClaimedAt  *time.Time `json:"claimed_at" gorm:"claimed_at"`          // 领取时间
+	PrintedAt  *time.Time `json:"printed_at" gorm:"printed_at"`          // 打印完成时间
+}
+
+// TableName 表名称
+func (*PrintJob) TableName() string {
+	return "print_job"
+}
+
+// 库存日志表：每次商品库存变动一条，与库存变动在同一事务中写入
 type StockLog struct {
 	ID           int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键id
+	ShopID       int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
 	ProductID    int64      `json:"product_id" gorm:"product_id"`          // 商品ID
 	ProductName  string     `json:"product_name" gorm:"product_name"`      // 商品名称
-	Types        int8       `json:"types" gorm:"types"`                    // 操作类型(1:入库,2:出库,3:退货)
+	OperationID  int64      `json:"operation_id" gorm:"operation_id"`      // 库存操作单ID
+	OperationNo  string     `json:"operation_no" gorm:"operation_no"`      // 库存操作单号
+	Types        int8       `json:"types" gorm:"types"`                    // 操作类型(1:入库,2:出库,3:退货,4:冲销,5:库存调整)
 	Quantity     int        `json:"quantity" gorm:"quantity"`              // 操作数量
 	BeforeStock  int        `json:"before_stock" gorm:"before_stock"`      // 操作前库存
 	AfterStock   int        `json:"after_stock" gorm:"after_stock"`        // 操作后库存
@@ -400,3 +716,133 @@ type AdminLoginResponse struct {
 	ShopList  []ShopSimple `json:"shop_list"`  // 店铺列表（超级管理员）
 	ExpiresIn int64        `json:"expires_in"` // Token 过期时间（秒）
 }
+
+// WechatSubscribeConsent 用户对订阅消息模板的授权：小程序每次授权(accept)可下发一条消息
+type WechatSubscribeConsent struct {
+	ID          int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	UserID      int64      `json:"user_id" gorm:"user_id"`                // 用户ID
+	TemplateID  string     `json:"template_id" gorm:"template_id"`        // 模板ID
+	AcceptCount int        `json:"accept_count" gorm:"accept_count"`      // 剩余可下发次数
+	LastResult  string     `json:"last_result" gorm:"last_result"`        // 最近一次授权结果(accept/reject/ban)
+	CreatedAt   *time.Time `json:"created_at" gorm:"created_at"`          // 创建时间
+	UpdatedAt   *time.Time `json:"updated_at" gorm:"updated_at"`          // 更新时间
+}
+
+// TableName 表名称
+func (*WechatSubscribeConsent) TableName() string {
+	return "wechat_subscribe_consent"
+}
+
+// WechatSubscribeMessage 订阅消息发送记录
+type WechatSubscribeMessage struct {
+	ID          int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ShopID      int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	UserID      int64      `json:"user_id" gorm:"user_id"`                // 用户ID
+	Openid      string     `json:"openid" gorm:"openid"`                  // 用户openid
+	OrderID     int64      `json:"order_id" gorm:"order_id"`              // 订单ID
+	OrderNo     string     `json:"order_no" gorm:"order_no"`              // 订单编号
+	Scene       string     `json:"scene" gorm:"scene"`                    // 通知场景(order_paid/order_shipped/order_pickup)
+	TemplateID  string     `json:"template_id" gorm:"template_id"`        // 模板ID
+	Content     string     `json:"content" gorm:"content"`                // 发送内容(JSON)
+	Status      int8       `json:"status" gorm:"status"`                  // 状态(1:待发送,2:已发送,3:失败,4:未发送)
+	Attempts    int        `json:"attempts" gorm:"attempts"`              // 已发送次数
+	Error       string     `json:"error" gorm:"error"`                    // 失败或未发送原因
+	NextRetryAt *time.Time `json:"next_retry_at" gorm:"next_retry_at"`    // 下次重试时间
+	CreatedAt   *time.Time `json:"created_at" gorm:"created_at"`          // 创建时间
+	SentAt      *time.Time `json:"sent_at" gorm:"sent_at"`                // 发送成功时间
+}
+
+// TableName 表名称
+func (*WechatSubscribeMessage) TableName() string {
+	return "wechat_subscribe_message"
+}
+
+// SmsLog 短信发送记录
+type SmsLog struct {
+	ID           int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ShopID       int64      `json:"shop_id" gorm:"shop_id"`                // 关联店铺ID
+	UserID       int64      `json:"user_id" gorm:"user_id"`                // 客户ID
+	UserName     string     `json:"user_name" gorm:"user_name"`            // 客户名称
+	Phone        string     `json:"phone" gorm:"phone"`                    // 手机号
+	Scene        string     `json:"scene" gorm:"scene"`                    // 通知场景(outbound_delivery/payment_reminder/monthly_balance)
+	RefNo        string     `json:"ref_no" gorm:"ref_no"`                  // 关联单号(出库单号、对账月份等)
+	Provider     string     `json:"provider" gorm:"provider"`              // 短信服务商
+	TemplateCode string     `json:"template_code" gorm:"template_code"`    // 模板编码
+	Params       string     `json:"params" gorm:"params"`                  // 模板参数(JSON)
+	Content      string     `json:"content" gorm:"content"`                // 短信内容
+	Status       int8       `json:"status" gorm:"status"`                  // 状态(2:已发送,3:失败,4:未发送)
+	Error        string     `json:"error" gorm:"error"`                    // 失败或未发送原因
+	BizID        string     `json:"biz_id" gorm:"biz_id"`                  // 服务商回执ID
+	Operator     string     `json:"operator" gorm:"operator"`              // 操作人(自动发送为 system)
+	CreatedAt    *time.Time `json:"created_at" gorm:"created_at"`          // 发送时间
+}
+
+// TableName 表名称
+func (*SmsLog) TableName() string {
+	return "sms_log"
+}
+
+// PaymentReminderSetting 店铺欠款提醒设置
+type PaymentReminderSetting struct {
+	ID         int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ShopID     int64      `json:"shop_id" gorm:"shop_id"`                // 店铺ID
+	Enabled    int8       `json:"enabled" gorm:"enabled"`                // 是否启用(1:启用,0:停用)
+	Thresholds string     `json:"thresholds" gorm:"thresholds"`          // 提醒账龄阈值(天)，逗号分隔，如 7,15,30
+	SendHour   int        `json:"send_hour" gorm:"send_hour"`            // 每天几点后发送(0-23)
+	Channels   string     `json:"channels" gorm:"channels"`              // 提醒渠道，逗号分隔(wechat,sms)
+	MinAmount  Amount     `json:"min_amount" gorm:"min_amount"`          // 欠款低于该金额的客户不提醒
+	UpdatedBy  string     `json:"updated_by" gorm:"updated_by"`          // 最后修改人
+	UpdatedAt  *time.Time `json:"updated_at" gorm:"updated_at"`          // 更新时间
+}
+
+// TableName 表名称
+func (*PaymentReminderSetting) TableName() string {
+	return "payment_reminder_setting"
+}
+
+// PaymentReminder 欠款提醒任务：每个客户一条，汇总超过账龄阈值的未结清出库单
+type PaymentReminder struct {
+	ID                int64      `json:"id" gorm:"id,primaryKey;autoIncrement"`          // 主键ID
+	ShopID            int64      `json:"shop_id" gorm:"shop_id"`                         // 店铺ID
+	UserID            int64      `json:"user_id" gorm:"user_id"`                         // 客户ID
+	UserName          string     `json:"user_name" gorm:"user_name"`                     // 客户名称
+	Phone             string     `json:"phone" gorm:"phone"`                             // 客户手机号
+	Stage             int        `json:"stage" gorm:"stage"`                             // 触发的账龄阈值(天)
+	OperationCount    int        `json:"operation_count" gorm:"operation_count"`         // 欠款出库单数
+	TotalAmount       Amount     `json:"total_amount" gorm:"total_amount"`               // 欠款合计
+	OldestAt          *time.Time `json:"oldest_at" gorm:"oldest_at"`                     // 最早一笔出库时间
+	OldestOperationNo string     `json:"oldest_operation_no" gorm:"oldest_operation_no"` // 最早一笔出库单号
+	Status            int8       `json:"status" gorm:"status"`                           // 状态(1:发送中,2:已发送,3:失败,4:未发送)
+	WechatMessageID   int64      `json:"wechat_message_id" gorm:"wechat_message_id"`     // 订阅消息发送记录ID
+	WechatStatus      int8       `json:"wechat_status" gorm:"wechat_status"`             // 订阅消息状态(0:未使用该渠道)
+	SmsLogID          int64      `json:"sms_log_id" gorm:"sms_log_id"`                   // 短信发送记录ID
+	SmsStatus         int8       `json:"sms_status" gorm:"sms_status"`                   // 短信状态(0:未使用该渠道)
+	Error             string     `json:"error" gorm:"error"`                             // 失败或未发送原因
+	Operator          string     `json:"operator" gorm:"operator"`                       // 操作人(定时任务为 system)
+	CreatedAt         *time.Time `json:"created_at" gorm:"created_at"`                   // 提醒时间
+
+	Items []PaymentReminderItem `json:"items,omitempty" gorm:"-"` // 欠款出库单明细
+}
+
+// TableName 表名称
+func (*PaymentReminder) TableName() string {
+	return "payment_reminder"
+}
+
+// PaymentReminderItem 欠款提醒包含的出库单
+type PaymentReminderItem struct {
+	ID          int64      `json:"id" gorm:"id,primaryKey;autoIncrement"` // 主键ID
+	ReminderID  int64      `json:"reminder_id" gorm:"reminder_id"`        // 欠款提醒ID
+	UserID      int64      `json:"user_id" gorm:"user_id"`                // 客户ID
+	OperationID int64      `json:"operation_id" gorm:"operation_id"`      // 出库单ID
+	OperationNo string     `json:"operation_no" gorm:"operation_no"`      // 出库单号
+	Amount      Amount     `json:"amount" gorm:"amount"`                  // 出库金额
+	OperationAt *time.Time `json:"operation_at" gorm:"operation_at"`      // 出库时间
+	AgeDays     int        `json:"age_days" gorm:"age_days"`              // 账龄(天)
+	Stage       int        `json:"stage" gorm:"stage"`                    // 达到的账龄阈值(天)
+}
+
+// TableName 表名称
+func (*PaymentReminderItem) TableName() string {
+	return "payment_reminder_item"
+}
@@ -9,14 +9,71 @@ type ProductSimple struct {
 	SellerPrice  Amount `json:"seller_price"`
 	CategoryID   int64  `json:"category_id"`
 	CategoryName string `json:"category_name"`
-	Image        string `json:"image"`
+	Image        string `json:"image"` // 列表图(JPEG)
+	Thumb        string `json:"thumb"` // 缩略图(JPEG)
 	Unit         string `json:"unit"`
 	Remark       string `json:"remark"`
 }
+
+// 小程序商品搜索排序方式
+const (
+	ProductSortDefault   = ""           // 默认(按商品ID)
+	ProductSortPriceAsc  = "price_asc"  // 价格从低到高
+	ProductSortPriceDesc = "price_desc" // 价格从高到低
+	ProductSortSales     = "sales_desc" // 销量从高到低
+)
+
+// 小程序商品搜索条件
+type ProductSearchQuery struct {
+	ShopID      int64   // 店铺ID
+	Keyword     string  // 关键词(匹配名称、规格、备注、名称拼音首字母)
+	CategoryID  int64   // 分类ID
+	CategoryIDs []int64 // 分类及其所有子分类ID，由服务层根据 CategoryID 展开
+	MinPrice    *Amount // 最低价
+	MaxPrice    *Amount // 最高价
+	InStock     bool    // 只看有货
+	Sort        string  // 排序方式
+	Limit       int     // 每页数量
+
+	// 游标：上一页最后一条记录的排序值和商品ID
+	CursorValue int64
+	CursorID    int64
+}
+
+// 小程序商品搜索结果项
+type ProductSearchItem struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	SellerPrice   Amount `json:"seller_price"`
+	CategoryID    int64  `json:"category_id"`
+	CategoryName  string `json:"category_name"`
+	Image         string `json:"image"` // 列表图(JPEG)
+	Thumb         string `json:"thumb"` // 缩略图(JPEG)
+	Unit          string `json:"unit"`
+	Specification string `json:"specification"`
+	Remark        string `json:"remark"`
+	Stock         int    `json:"stock"`
+	IsBundle      int8   `json:"is_bundle"`
+	SalesVolume   int64  `json:"sales_volume"` // 销量(出库数量合计)
+}
+
+// 小程序商品搜索响应
+type ProductSearchResponse struct {
+	List       []ProductSearchItem `json:"list"`
+	NextCursor string              `json:"next_cursor"` // 下一页游标，为空表示没有更多
+	HasMore    bool                `json:"has_more"`
+}
+
 type ProductListResponse struct {
-	Categories []Category                `json:"categories"`
+	Categories []*CategoryNode           `json:"categories"` // 分类树(只包含有上架商品的分支)
 	Products   map[int64][]ProductSimple `json:"products"`
 }
+
+// 分类树节点
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"` // 子分类(按排序权重)
+}
 type CartWithProduct struct {
 	Cart
 
@@ -57,20 +114,13 @@ type OrderCheckoutReq struct {
 type OrderNoReq struct {
 	OrderNo string `json:"order_no"` // 订单号
 }
-type PayCallbackReq struct {
-	OrderNo       string `json:"order_no"`
-	PaymentNo     string `json:"payment_no"`
-	PaymentType   int    `json:"payment_type"`
-	PaymentTime   int64  `json:"payment_time"`
-	PaymentAmount Amount `json:"payment_amount"`
-}
-
 type BuildPaymentParam struct {
 	Code    string `json:"code"`     // ，前端通过 wx.login() 获取临时 code，后端就可以使用这个 code 请求微信服务器获取 openid 和 session_key
 	OrderNo string `json:"order_no"` // 订单号
 	Total   Amount `json:"total"`    // 单位：分
 }
 
+// PaidCallbackData 验签解密后的支付成功通知
 type PaidCallbackData struct {
 	OrderNo       string
 	PaymentNo     string
@@ -145,6 +195,73 @@ type AddSimpleProductRequest struct {
 	ShippingCost  Amount `json:"shipping_cost"`                   // 运费成本
 	ProductCost   Amount `json:"product_cost"`                    // 货物成本
 	ShopID        int64  `json:"shop_id"`                         // 店铺ID（可选，从JWT token中获取）
+
+	IsBundle    int8                `json:"is_bundle"`    // 是否套装(1:套装,0:普通商品)
+	BundleItems []BundleItemRequest `json:"bundle_items"` // 套装组件（套装时必填）
+	Sku         string              `json:"sku"`          // 商品编码（可选）
+	Barcode     string              `json:"barcode"`      // 条码（可选，EAN或自定义）
+}
+
+// 套装组件请求项
+type BundleItemRequest struct {
+	ComponentID int64 `json:"component_id" binding:"required"` // 组件商品ID
+	Quantity    int   `json:"quantity" binding:"required"`     // 每套所需数量
+}
+
+// 设置套装组件请求
+type SetBundleItemsRequest struct {
+	Items []BundleItemRequest `json:"items" binding:"required"` // 套装组件列表
+}
+
+// 套装组件详情（含组件商品信息）
+type BundleItemDetail struct {
+	ProductBundleItem
+	ComponentName  string `json:"component_name"`  // 组件商品名称
+	Specification  string `json:"specification"`   // 组件规格
+	Unit           string `json:"unit"`            // 组件单位
+	ComponentStock int    `json:"component_stock"` // 组件当前库存
+	ComponentCost  Amount `json:"component_cost"`  // 组件成本价
+}
+
+// 设置商品图集请求（按顺序，第一张为主图；传空列表清空图集）
+type SetProductGalleryRequest struct {
+	Images []string `json:"images"` // 图片地址列表
+}
+
+// 设置商品详情请求
+type SetProductDetailRequest struct {
+	Description string `json:"description"` // 图文详情(富文本HTML)
+	Coverage    string `json:"coverage"`    // 涂布率
+	DryingTime  string `json:"drying_time"` // 干燥时间
+	VOC         string `json:"voc"`         // VOC含量
+}
+
+// 商品技术参数
+type ProductTechData struct {
+	Coverage   string `json:"coverage"`    // 涂布率
+	DryingTime string `json:"drying_time"` // 干燥时间
+	VOC        string `json:"voc"`         // VOC含量
+}
+
+// 小程序商品详情
+type ProductDetailResponse struct {
+	ID            int64              `json:"id"`
+	Name          string             `json:"name"`
+	SellerPrice   Amount             `json:"seller_price"`
+	CategoryID    int64              `json:"category_id"`
+	CategoryName  string             `json:"category_name"`
+	Unit          string             `json:"unit"`
+	Specification string             `json:"specification"`
+	Remark        string             `json:"remark"`
+	Images        []string           `json:"images"`       // 图集详情图(JPEG，有序)
+	RawImages     []string           `json:"raw_images"`   // 图集原图，用于点击预览
+	Description   string             `json:"description"`  // 图文详情(富文本HTML)
+	TechData      ProductTechData    `json:"tech_data"`    // 技术参数
+	Stock         int                `json:"stock"`        // 库存
+	StockText     string             `json:"stock_text"`   // 库存提示(现货充足/仅剩N件/暂时缺货)
+	IsBundle      int8               `json:"is_bundle"`    // 是否套装
+	BundleItems   []BundleItemDetail `json:"bundle_items"` // 套装组件(套装时)
+	Related       []ProductSimple    `json:"related"`      // 相关商品
 }
 
 // 编辑商品请求结构体
@@ -155,12 +272,178 @@ type EditProductRequest struct {
 	Remark        string `json:"remark"`        // 备注（可选）
 	Stock         int64  `json:"stock"`         // 库存
 	ShopID        int64  `json:"shop_id"`       // 店铺ID（可选，从JWT token中获取）
+	Sku           string `json:"sku"`           // 商品编码（可选）
+	Barcode       string `json:"barcode"`       // 条码（可选）
+	ClearBarcode  bool   `json:"clear_barcode"` // 清空条码(为 true 时忽略 barcode)
+
+}
+
+// 商品导入动作
+const (
+	ImportActionCreate = "create" // 新增
+	ImportActionUpdate = "update" // 更新
+	ImportActionError  = "error"  // 校验失败
+)
+
+// ProductImportRow 商品导入的单行结果
+type ProductImportRow struct {
+	Row          int      `json:"row"`           // 表格行号(从2开始，第1行为表头)
+	Action       string   `json:"action"`        // create/update/error
+	ProductID    int64    `json:"product_id"`    // 更新时的商品ID
+	Name         string   `json:"name"`          // 商品名称
+	Sku          string   `json:"sku"`           // 商品编码
+	CategoryName string   `json:"category_name"` // 分类名称
+	NewCategory  bool     `json:"new_category"`  // 是否会新建分类
+	Errors       []string `json:"errors"`        // 校验错误
+
+	Product *Product `json:"-"` // 待写入的商品数据
+}
+
+// ProductImportResult 商品导入结果
+type ProductImportResult struct {
+	DryRun  bool               `json:"dry_run"` // 是否仅校验
+	Total   int                `json:"total"`   // 数据行数
+	Created int                `json:"created"` // 新增数量
+	Updated int                `json:"updated"` // 更新数量
+	Failed  int                `json:"failed"`  // 校验失败数量
+	Rows    []ProductImportRow `json:"rows"`    // 每行结果
+}
+
+// 条码标签图形
+const (
+	LabelSymbolBarcode = "barcode" // 一维条码
+	LabelSymbolQR      = "qr"      // 二维码
+)
+
+// BarcodeLabelRequest 打印商品条码标签
+type BarcodeLabelRequest struct {
+	ShopID     int64   `json:"shop_id"`                        // 店铺ID
+	ProductIDs []int64 `json:"product_ids" binding:"required"` // 商品ID(按顺序排版)
+	Copies     int     `json:"copies"`                         // 每个商品打印的张数(默认1)
+	Symbol     string  `json:"symbol"`                         // 标签图形(barcode/qr，默认barcode)
+	Skip       int     `json:"skip"`                           // 跳过第一页已用掉的标签数(接着上次的标签纸打印)
+	Assign     bool    `json:"assign"`                         // 给没有条码的商品分配店内码并保存(默认不分配，没有条码时报错)
+}
+
+// 店铺间复制商品时同名商品的处理方式
+const (
+	CopyDuplicateSkip  = "skip"  // 跳过，保留目标店铺的商品
+	CopyDuplicateMerge = "merge" // 合并，用源商品的价格、图片等覆盖目标店铺的商品
+)
+
+// 店铺间复制的动作
+const (
+	CopyActionCreate = "create" // 新建
+	CopyActionMerge  = "merge"  // 合并到目标店铺的同名分类/商品
+	CopyActionSkip   = "skip"   // 跳过(目标店铺已有同名商品)
+)
+
+// CatalogCopyRequest 店铺间复制分类和商品
+type CatalogCopyRequest struct {
+	SourceShopID int64   `json:"source_shop_id" binding:"required"` // 源店铺ID
+	TargetShopID int64   `json:"target_shop_id" binding:"required"` // 目标店铺ID
+	All          bool    `json:"all"`                               // 复制源店铺的全部分类和商品
+	CategoryIDs  []int64 `json:"category_ids"`                      // 复制的分类(含子分类及其下的商品)
+	ProductIDs   []int64 `json:"product_ids"`                       // 复制的商品
+	WithStock    bool    `json:"with_stock"`                        // 新建的商品是否复制库存(默认库存为0)
+	OnDuplicate  string  `json:"on_duplicate"`                      // 同名商品处理方式(skip/merge，默认skip)
+	DryRun       bool    `json:"dry_run"`                           // 仅预览映射关系，不写入
+}
+
+// CatalogCopyCategory 分类映射
+type CatalogCopyCategory struct {
+	SourceID int64  `json:"source_id"` // 源分类ID
+	TargetID int64  `json:"target_id"` // 目标分类ID(预览时新建的分类为0)
+	Name     string `json:"name"`      // 分类名称
+	Path     string `json:"path"`      // 分类路径，如 内墙/墙面漆
+	Action   string `json:"action"`    // create/merge
+
+	SourceParentID int64     `json:"-"` // 源上级分类ID(按分类树，一级分类为0)
+	Category       *Category `json:"-"` // 源分类
+}
+
+// CatalogCopyProduct 商品映射
+type CatalogCopyProduct struct {
+	SourceID int64    `json:"source_id"` // 源商品ID
+	TargetID int64    `json:"target_id"` // 目标商品ID(预览时新建的商品为0)
+	Name     string   `json:"name"`      // 商品名称
+	Action   string   `json:"action"`    // create/merge/skip
+	Notes    []string `json:"notes"`     // 说明(如编码冲突未复制、作为套装组件一并复制)
+
+	Product     *Product            `json:"-"` // 源商品
+	Images      []ProductImage      `json:"-"` // 源商品图集
+	Detail      *ProductDetail      `json:"-"` // 源商品详情
+	BundleItems []ProductBundleItem `json:"-"` // 源套装组件(组件ID为源商品ID)
+}
+
+// CatalogCopyReport 店铺间复制结果
+type CatalogCopyReport struct {
+	DryRun            bool                  `json:"dry_run"`            // 是否仅预览
+	SourceShopID      int64                 `json:"source_shop_id"`     // 源店铺ID
+	TargetShopID      int64                 `json:"target_shop_id"`     // 目标店铺ID
+	CategoriesCreated int                   `json:"categories_created"` // 新建分类数
+	CategoriesMerged  int                   `json:"categories_merged"`  // 合并到已有分类数
+	ProductsCreated   int                   `json:"products_created"`   // 新建商品数
+	ProductsMerged    int                   `json:"products_merged"`    // 合并商品数
+	ProductsSkipped   int                   `json:"products_skipped"`   // 跳过商品数
+	Categories        []CatalogCopyCategory `json:"categories"`         // 分类映射(上级分类在前)
+	Products          []CatalogCopyProduct  `json:"products"`           // 商品映射
+}
+
+// 批量调价请求（分类、供货商、商品ID三选一）
+type PriceAdjustmentRequest struct {
+	ShopID      int64   `json:"shop_id"`                 // 店铺ID
+	CategoryID  int64   `json:"category_id"`             // 按分类调价
+	Supplier    string  `json:"supplier"`                // 按供货商调价(该供货商入库过的商品)
+	ProductIDs  []int64 `json:"product_ids"`             // 按商品调价
+	Mode        int8    `json:"mode" binding:"required"` // 调价方式(1:按百分比,2:按固定金额)
+	Percent     float64 `json:"percent"`                 // 调整百分比(mode=1时必填，10表示上调10%)
+	Delta       Amount  `json:"delta"`                   // 调整金额(mode=2时必填，可为负)
+	AdjustCost  int8    `json:"adjust_cost"`             // 是否同时调整成本价(1:是,0:否)
+	Rounding    int8    `json:"rounding"`                // 取整规则(0:到分,1:到角,2:四舍五入到元,3:向上取整到元)
+	EffectiveAt string  `json:"effective_at"`            // 生效时间(2006-01-02 15:04:05)，为空立即生效
+	Remark      string  `json:"remark"`                  // 备注
+}
+
+// 单个商品的调价预览
+type PriceChangeItem struct {
+	ProductID      int64  `json:"product_id"`       // 商品ID
+	ProductName    string `json:"product_name"`     // 商品名称
+	Specification  string `json:"specification"`    // 规格
+	Unit           string `json:"unit"`             // 单位
+	IsBundle       int8   `json:"is_bundle"`        // 是否套装(套装成本由组件推算，不调整成本价)
+	OldSellerPrice Amount `json:"old_seller_price"` // 调整前售价
+	NewSellerPrice Amount `json:"new_seller_price"` // 调整后售价
+	OldCost        Amount `json:"old_cost"`         // 调整前成本价
+	NewCost        Amount `json:"new_cost"`         // 调整后成本价
+	Changed        bool   `json:"changed"`          // 价格是否有变化
+	Warning        string `json:"warning"`          // 提示(如调整后售价低于成本价)
+
+	ShopID         int64  `json:"-"` // 店铺ID
+	NewProductCost Amount `json:"-"` // 调整后货物成本(成本价=运费成本+货物成本)
+}
 
+// 调价预览结果
+type PriceAdjustmentPreview struct {
+	Total   int               `json:"total"`   // 匹配商品数
+	Changed int               `json:"changed"` // 价格有变化的商品数
+	Items   []PriceChangeItem `json:"items"`   // 每个商品的调价明细
+}
+
+// 未引用图片清理结果
+type UploadCleanupReport struct {
+	DryRun     bool         `json:"dry_run"`    // 是否仅预览(不删除)
+	Before     time.Time    `json:"before"`     // 只清理最近上传时间早于该时间的图片
+	Checked    int          `json:"checked"`    // 超过保留期的上传记录数
+	Referenced int          `json:"referenced"` // 其中仍被引用的数量
+	Orphans    []UploadFile `json:"orphans"`    // 未被引用的图片(预览时为待删除，执行时为已删除)
+	Failed     []string     `json:"failed"`     // 删除失败的图片及原因
 }
 
 // 分类管理请求结构体
 type AddCategoryRequest struct {
 	Name      string `json:"name" binding:"required"` // 分类名称
+	ParentID  int64  `json:"parent_id"`               // 上级分类ID(0为一级分类)
 	SortOrder int64  `json:"sort_order"`              // 排序权重(数字越大越靠前)
 	ShopID    int64  `json:"shop_id"`                 // 店铺ID
 }
@@ -171,19 +454,195 @@ type EditCategoryRequest struct {
 	ShopID    int64  `json:"shop_id"`                 // 店铺ID
 }
 
+// 移动分类请求
+type MoveCategoryRequest struct {
+	ParentID  int64  `json:"parent_id"`  // 新的上级分类ID(0为移到一级)
+	SortOrder *int64 `json:"sort_order"` // 新的排序权重(不传则保持不变)
+}
+
+// 同级分类排序请求
+type SortCategoryRequest struct {
+	ShopID   int64   `json:"shop_id"`                // 店铺ID
+	ParentID int64   `json:"parent_id"`              // 上级分类ID(0为一级分类)
+	IDs      []int64 `json:"ids" binding:"required"` // 该层级全部分类ID，按显示顺序排列
+}
+
 // 库存操作类型常量
 const (
-	StockTypeInbound  = 1 // 入库
-	StockTypeOutbound = 2 // 出库
-	StockTypeReturn   = 3 // 退货
+	StockTypeInbound    = 1 // 入库
+	StockTypeOutbound   = 2 // 出库
+	StockTypeReturn     = 3 // 退货
+	StockTypeReversal   = 4 // 冲销(作废原操作单时生成，库存变动方向与原操作单相反)
+	StockTypeAdjustment = 5 // 库存调整(库存核对修复、编辑商品修改库存时生成，操作后库存即为调整后的库存)
 )
 
 // 出库类型常量
 const (
 	OutboundTypeMiniProgram = 1 // 小程序购买
 	OutboundTypeAdmin       = 2 // admin后台操作
+	OutboundTypePOS         = 3 // 门店收银
+)
+
+// 收银班次状态
+const (
+	PosShiftOpen   = 1 // 当班
+	PosShiftClosed = 2 // 已交班
+)
+
+// 收银单状态
+const (
+	PosSaleOpen      = 1 // 收银中
+	PosSaleCompleted = 2 // 已结账
+	PosSaleCancelled = 3 // 已取消
+)
+
+// 收银明细单价来源
+const (
+	PosPriceRetail   = 1 // 零售价
+	PosPriceCustomer = 2 // 客户协议价
+	PosPriceManual   = 3 // 手工改价
+)
+
+// 开班请求
+type PosOpenShiftRequest struct {
+	ShopID      int64  `json:"shop_id"`      // 店铺ID
+	OpeningCash Amount `json:"opening_cash"` // 备用金
+}
+
+// 交班请求
+type PosCloseShiftRequest struct {
+	ShopID      int64  `json:"shop_id"`                      // 店铺ID
+	CountedCash Amount `json:"counted_cash" binding:"min=0"` // 实点现金
+	Remark      string `json:"remark"`                       // 交班备注(如短款原因)
+}
+
+// 开收银单请求
+type PosOpenSaleRequest struct {
+	ShopID   int64  `json:"shop_id"`   // 店铺ID
+	UserID   int64  `json:"user_id"`   // 客户ID(不传为散客)
+	UserName string `json:"user_name"` // 客户名称(散客可填称呼，不填为"散客")
+	Remark   string `json:"remark"`    // 备注
+}
+
+// 设置收银单客户请求(更换客户后按新客户重新带出协议价，手工改价的明细不变)
+type PosSetCustomerRequest struct {
+	UserID   int64  `json:"user_id"`   // 客户ID(0为散客)
+	UserName string `json:"user_name"` // 客户名称
+}
+
+// 收银单添加商品请求(扫码或选择商品，已有该商品时累加数量)
+type PosAddItemRequest struct {
+	ProductID int64   `json:"product_id"` // 商品ID(与条码二选一)
+	Barcode   string  `json:"barcode"`    // 扫码得到的条码
+	Quantity  int     `json:"quantity"`   // 数量(默认1)
+	UnitPrice *Amount `json:"unit_price"` // 手工改价(不传按客户协议价或零售价)
+}
+
+// 修改收银明细请求
+type PosUpdateItemRequest struct {
+	Quantity  int     `json:"quantity" binding:"min=0"` // 数量(0为删除该明细)
+	UnitPrice *Amount `json:"unit_price"`               // 手工改价(不传保持原单价)
+}
+
+// 收银结账请求：现金+微信+挂账需覆盖应收金额，只有现金可以多收(找零)
+type PosCheckoutRequest struct {
+	PaidCash   Amount `json:"paid_cash" binding:"min=0"`   // 现金实收
+	PaidWechat Amount `json:"paid_wechat" binding:"min=0"` // 微信收款
+	PaidCredit Amount `json:"paid_credit" binding:"min=0"` // 挂账(仅限有客户ID的收银单)
+	Remark     string `json:"remark"`                      // 备注
+}
+
+// 设置客户协议价请求
+type SetCustomerPriceRequest struct {
+	ShopID    int64   `json:"shop_id"`                       // 店铺ID
+	UserID    int64   `json:"user_id" binding:"required"`    // 客户ID
+	ProductID int64   `json:"product_id" binding:"required"` // 商品ID
+	Price     *Amount `json:"price" binding:"required"`      // 协议价(可为0，赠品/样品)
+}
+
+// 收银班次汇总(当班时为实时数据)
+type PosShiftSummary struct {
+	PosShift
+	Sales []PosSale `json:"sales"` // 班次内已结账的收银单(不含明细)
+}
+
+// 打印内容类型
+const (
+	PrintSourceOrder     = "order"     // 小程序订单
+	PrintSourceOperation = "operation" // 出库单
+	PrintSourcePosSale   = "pos_sale"  // 门店收银单
 )
 
+// 打印任务状态
+const (
+	PrintJobPending  = 1 // 待打印
+	PrintJobPrinting = 2 // 打印中(已被打印机领取)
+	PrintJobDone     = 3 // 已打印
+	PrintJobFailed   = 4 // 失败(超过重试次数)
+)
+
+// PrinterWithToken 添加打印机、重置令牌时返回的打印机信息，含轮询令牌
+type PrinterWithToken struct {
+	Printer
+	Token string `json:"token"` // 轮询令牌，配置到打印机客户端
+}
+
+// 添加打印机请求
+type AddPrinterRequest struct {
+	ShopID     int64  `json:"shop_id"`                 // 店铺ID
+	Name       string `json:"name" binding:"required"` // 打印机名称
+	PaperWidth int    `json:"paper_width"`             // 纸宽(58/80mm，默认58)
+	AutoPrint  int8   `json:"auto_print"`              // 是否自动打印(1:是,0:否)
+}
+
+// 编辑打印机请求
+type EditPrinterRequest struct {
+	Name       string `json:"name"`        // 打印机名称
+	PaperWidth int    `json:"paper_width"` // 纸宽(58/80mm)
+	AutoPrint  *int8  `json:"auto_print"`  // 是否自动打印
+	IsActive   *int8  `json:"is_active"`   // 是否启用
+}
+
+// 创建打印任务请求(后台手动打印/补打)
+type CreatePrintJobRequest struct {
+	ShopID     int64  `json:"shop_id"`                        // 店铺ID
+	SourceType string `json:"source_type" binding:"required"` // 打印内容类型(order/operation/pos_sale)
+	SourceID   int64  `json:"source_id" binding:"required"`   // 订单ID/出库单ID/收银单ID
+	PrinterID  int64  `json:"printer_id"`                     // 指定打印机(不传为店铺任一打印机)
+	Copies     int    `json:"copies"`                         // 份数(默认1)
+}
+
+// 打印机回报打印结果请求
+type PrintJobResultRequest struct {
+	Success bool   `json:"success"` // 是否打印成功
+	Error   string `json:"error"`   // 失败原因
+}
+
+// 送货单版式
+const (
+	DeliveryNoteLayoutA4         = "a4"         // A4纸(激光/喷墨打印机)
+	DeliveryNoteLayoutTriplicate = "triplicate" // 241mm三联针式打印纸(二等分)
+)
+
+// DeliveryNote 送货单打印数据
+type DeliveryNote struct {
+	ShopID          int64                // 店铺ID
+	ShopName        string               // 店铺名称
+	ShopAddress     string               // 店铺地址
+	ShopPhone       string               // 店铺电话
+	NoteNo          string               // 送货单号(出库单号)
+	OrderNo         string               // 关联订单号(小程序购买时)
+	Date            time.Time            // 出库日期
+	CustomerName    string               // 客户/收货人名称
+	CustomerPhone   string               // 收货人电话
+	CustomerAddress string               // 收货地址
+	Operator        string               // 开单人
+	Remark          string               // 备注
+	Items           []StockOperationItem // 明细(套装只列套装本身，不列组件)
+	TotalQuantity   int                  // 合计数量
+	TotalAmount     Amount               // 合计金额
+}
+
 // 库存操作请求结构体
 type StockOperationRequest struct {
 	ProductID int64  `json:"product_id" binding:"required"` // 商品ID
@@ -204,7 +663,8 @@ type BatchInboundRequest struct {
 
 // 批量入库商品项
 type BatchInboundItem struct {
-	ProductID   int64  `json:"product_id" binding:"required"`   // 商品ID
+	ProductID   int64  `json:"product_id"`                      // 商品ID(与条码二选一)
+	Barcode     string `json:"barcode"`                         // 扫码得到的条码(代替商品ID)
 	Quantity    int    `json:"quantity" binding:"required"`     // 入库数量
 	ProductCost Amount `json:"product_cost" binding:"required"` // 货物成本（进价）
 	TotalPrice  Amount `json:"total_price" binding:"required"`  // 单个商品总价
@@ -226,7 +686,8 @@ type BatchOutboundRequest struct {
 
 // 批量出库商品项
 type BatchOutboundItem struct {
-	ProductID  int64  `json:"product_id" binding:"required"` // 商品ID
+	ProductID  int64  `json:"product_id"`                    // 商品ID(与条码二选一)
+	Barcode    string `json:"barcode"`                       // 扫码得到的条码(代替商品ID)
 	Quantity   int    `json:"quantity" binding:"required"`   // 出库数量
 	UnitPrice  Amount `json:"unit_price" binding:"required"` // 卖价
 	TotalPrice Amount `json:"total_price"`                   // 总金额（自动计算）
@@ -242,6 +703,50 @@ type UpdateOutboundPaymentStatusRequest struct {
 	ShopID              int64             `json:"shop_id"`                                  // 店铺ID
 }
 
+// 作废库存操作请求
+type VoidStockOperationRequest struct {
+	Reason string `json:"reason" binding:"required"` // 作废原因
+}
+
+// 库存操作列表可排序字段，sort 参数为 字段_asc 或 字段_desc，默认 created_at_desc
+const (
+	StockOperationSortCreatedAt     = "created_at"     // 操作时间
+	StockOperationSortTotalAmount   = "total_amount"   // 总金额
+	StockOperationSortTotalQuantity = "total_quantity" // 总数量
+	StockOperationSortTotalProfit   = "total_profit"   // 总利润
+)
+
+// 库存操作查询条件(后台列表和导出)
+type StockOperationQuery struct {
+	ShopID              int64              // 店铺ID
+	Types               *int8              // 操作类型
+	StartTime           *time.Time         // 操作时间起(含)
+	EndTime             *time.Time         // 操作时间止(不含)
+	UserID              int64              // 客户ID
+	UserName            string             // 客户名称(模糊匹配)
+	Supplier            string             // 供货商(模糊匹配)
+	OperatorID          int64              // 操作人ID
+	OutboundType        *int8              // 出库类型
+	PaymentFinishStatus *PaymentStatusCode // 支付完成状态
+	IsVoided            *int8              // 是否已作废
+	ProductID           int64              // 包含该商品的操作单
+	ProductName         string             // 包含名称匹配的商品的操作单(模糊匹配)
+	OperationNo         string             // 操作单号(模糊匹配)
+	MinAmount           *Amount            // 最低总金额
+	MaxAmount           *Amount            // 最高总金额
+	SortField           string             // 排序字段
+	SortDesc            bool               // 是否倒序
+	Page                int
+	PageSize            int
+}
+
+// 库存操作列表合计(不含已作废的操作单和冲销单)
+type StockOperationSummary struct {
+	TotalAmount   Amount `json:"total_amount"`   // 总金额
+	TotalQuantity int64  `json:"total_quantity"` // 总数量
+	TotalProfit   Amount `json:"total_profit"`   // 总利润
+}
+
 // 后台用户管理请求结构体
 type AdminUserAddRequest struct {
 	AdminDisplayName string `json:"admin_display_name" binding:"required"` // 后台显示的客户名称
@@ -319,3 +824,438 @@ type AdminUpdateAddressRequest struct {
 	Detail         string `json:"detail" binding:"required"`          // 详细地址
 	IsDefault      bool   `json:"is_default"`                         // 是否默认地址
 }
+
+// 销售报表分组方式
+const (
+	ReportGroupDay      = "day"      // 按日
+	ReportGroupWeek     = "week"     // 按周(ISO周)
+	ReportGroupMonth    = "month"    // 按月
+	ReportGroupProduct  = "product"  // 按商品
+	ReportGroupCategory = "category" // 按分类
+	ReportGroupCustomer = "customer" // 按客户
+	ReportGroupOperator = "operator" // 按操作人
+	ReportGroupShop     = "shop"     // 按店铺
+)
+
+// 销售报表查询条件
+type SalesReportQuery struct {
+	ShopID       int64     // 店铺ID(0为全部店铺，仅超级管理员)
+	StartTime    time.Time // 开始时间(含)
+	EndTime      time.Time // 结束时间(不含)
+	GroupBy      string    // 分组方式
+	OutboundType *int8     // 出库类型(不传为全部)
+}
+
+// 销售报表行
+type SalesReportRow struct {
+	Key            string  `json:"key"`             // 分组键(日期、商品ID、客户ID等)
+	Name           string  `json:"name"`            // 分组名称
+	Revenue        Amount  `json:"revenue"`         // 销售额
+	Cost           Amount  `json:"cost"`            // 成本
+	GrossProfit    Amount  `json:"gross_profit"`    // 毛利
+	Margin         float64 `json:"margin"`          // 毛利率(%)
+	Quantity       int64   `json:"quantity"`        // 销售数量
+	OperationCount int64   `json:"operation_count"` // 出库单数
+}
+
+// 销售报表
+type SalesReport struct {
+	GroupBy   string           `json:"group_by"`   // 分组方式
+	StartDate string           `json:"start_date"` // 开始日期
+	EndDate   string           `json:"end_date"`   // 结束日期
+	Rows      []SalesReportRow `json:"rows"`       // 明细行
+	Total     SalesReportRow   `json:"total"`      // 合计
+}
+
+// 库存估值行(某一时点的商品库存和成本)
+type InventoryValuationRow struct {
+	ShopID        int64  `json:"shop_id"`       // 店铺ID
+	ProductID     int64  `json:"product_id"`    // 商品ID
+	ProductName   string `json:"product_name"`  // 商品名称
+	Specification string `json:"specification"` // 规格
+	Unit          string `json:"unit"`          // 单位
+	Quantity      int64  `json:"quantity"`      // 库存数量
+	UnitCost      Amount `json:"unit_cost"`     // 单位成本(进价)
+	Value         Amount `json:"value"`         // 库存金额 = 数量 × 单位成本
+	NoLedger      bool   `json:"no_ledger"`     // 没有任何库存流水，数量为当前库存(仅供参考，不计入合计)
+}
+
+// 库存估值
+type InventoryValuation struct {
+	AsOfDate      string                  `json:"as_of_date"`     // 估值日期(当天结束时)
+	Rows          []InventoryValuationRow `json:"rows"`           // 商品明细
+	TotalQuantity int64                   `json:"total_quantity"` // 数量合计
+	TotalValue    Amount                  `json:"total_value"`    // 金额合计
+	NoLedgerRows  []InventoryValuationRow `json:"no_ledger_rows"` // 没有库存流水、无法推算的商品(当前库存，不计入合计)
+}
+
+// 商品某一时点的库存快照(由库存流水推算)
+type StockSnapshot struct {
+	ProductID int64 // 商品ID
+	Stock     int64 // 库存数量
+	UnitCost  Amount
+	HasLedger bool // 是否有库存流水，没有时库存无法推算，记为0
+}
+
+// 库存流水汇总(某期间内某商品某类操作的数量合计)
+type StockMovementSum struct {
+	ProductID int64 // 商品ID
+	Types     int8  // 操作类型
+	Quantity  int64 // 数量合计
+	Delta     int64 // 库存变动合计(操作后库存-操作前库存)
+}
+
+// 库存收发存行
+type StockMovementRow struct {
+	ShopID        int64  `json:"shop_id"`       // 店铺ID
+	ProductID     int64  `json:"product_id"`    // 商品ID
+	ProductName   string `json:"product_name"`  // 商品名称
+	Specification string `json:"specification"` // 规格
+	Unit          string `json:"unit"`          // 单位
+	Opening       int64  `json:"opening"`       // 期初库存
+	Inbound       int64  `json:"inbound"`       // 本期入库
+	Outbound      int64  `json:"outbound"`      // 本期出库
+	Returns       int64  `json:"returns"`       // 本期退货
+	Adjustments   int64  `json:"adjustments"`   // 本期冲销调整(正数为增加库存)
+	Other         int64  `json:"other"`         // 流水未记录的变动 = 期末 - (期初 + 入库 - 出库 + 退货 + 调整)
+	Closing       int64  `json:"closing"`       // 期末库存
+	OpeningValue  Amount `json:"opening_value"` // 期初金额
+	ClosingValue  Amount `json:"closing_value"` // 期末金额
+}
+
+// 库存收发存报表
+type StockMovementReport struct {
+	StartDate     string             `json:"start_date"`      // 开始日期
+	EndDate       string             `json:"end_date"`        // 结束日期
+	Rows          []StockMovementRow `json:"rows"`            // 商品明细
+	Total         StockMovementRow   `json:"total"`           // 合计
+	NoLedgerCount int                `json:"no_ledger_count"` // 没有库存流水、未列出的商品数
+}
+
+// 库存核对修复时以哪一方为准
+const (
+	StockRepairTrustProduct = "product" // 以商品当前库存为准，只补记调整流水
+	StockRepairTrustLedger  = "ledger"  // 以流水重放结果为准，同时修改商品库存
+)
+
+// 库存核对问题类型
+const (
+	StockLedgerBreakChain = "chain" // 操作前库存与上一条流水的操作后库存不一致
+	StockLedgerBreakDelta = "delta" // 操作前后库存之差与操作数量不一致
+)
+
+// 库存流水(库存核对时按商品重放)
+type StockLedgerEntry struct {
+	ItemID      int64      // 操作明细ID
+	OperationID int64      // 操作单ID
+	OperationNo string     // 操作单号
+	Types       int8       // 操作类型
+	ProductID   int64      // 商品ID
+	Quantity    int        // 操作数量
+	BeforeStock int        // 操作前库存
+	AfterStock  int        // 操作后库存
+	CreatedAt   *time.Time // 创建时间
+}
+
+// 库存流水断点
+type StockLedgerBreak struct {
+	Kind        string     `json:"kind"`         // 问题类型 chain/delta
+	ItemID      int64      `json:"item_id"`      // 操作明细ID
+	OperationID int64      `json:"operation_id"` // 操作单ID
+	OperationNo string     `json:"operation_no"` // 操作单号
+	Types       int8       `json:"types"`        // 操作类型
+	Expected    int        `json:"expected"`     // 应为(chain:上一条操作后库存，delta:按数量计算的操作后库存)
+	Actual      int        `json:"actual"`       // 实际(chain:本条操作前库存，delta:本条操作后库存)
+	CreatedAt   *time.Time `json:"created_at"`   // 操作时间
+}
+
+// 单个商品的库存核对结果
+type StockLedgerCheckRow struct {
+	ShopID        int64              `json:"shop_id"`        // 店铺ID
+	ProductID     int64              `json:"product_id"`     // 商品ID
+	ProductName   string             `json:"product_name"`   // 商品名称
+	ProductStock  int                `json:"product_stock"`  // 商品当前库存
+	ReplayedStock int                `json:"replayed_stock"` // 按流水数量重放得到的库存
+	LedgerStock   int                `json:"ledger_stock"`   // 最后一条流水的操作后库存
+	Diff          int                `json:"diff"`           // 商品当前库存 - 重放库存
+	ItemCount     int                `json:"item_count"`     // 核对的流水条数(自上次调整起)
+	LastItemID    int64              `json:"last_item_id"`   // 最后一条流水ID
+	Breaks        []StockLedgerBreak `json:"breaks"`         // 流水断点(自上次调整起)
+}
+
+// 库存核对报告
+type StockLedgerCheckReport struct {
+	CheckedAt       time.Time             `json:"checked_at"`       // 核对时间
+	CheckedProducts int                   `json:"checked_products"` // 核对的商品数(有流水的)
+	NoLedgerCount   int                   `json:"no_ledger_count"`  // 没有流水的商品数(未核对)
+	MismatchCount   int                   `json:"mismatch_count"`   // 库存与重放结果不一致的商品数
+	BrokenCount     int                   `json:"broken_count"`     // 流水有断点的商品数
+	Rows            []StockLedgerCheckRow `json:"rows"`             // 有问题的商品
+}
+
+// 库存核对修复请求
+type RepairStockLedgerRequest struct {
+	ShopID     int64   `json:"shop_id"`     // 店铺ID(超级管理员为0时修复全部店铺)
+	ProductIDs []int64 `json:"product_ids"` // 只修复这些商品(为空时修复全部有问题的商品)
+	Trust      string  `json:"trust"`       // 以哪一方为准 product(默认)/ledger
+	Confirm    bool    `json:"confirm"`     // 确认修复，为 false 时只返回核对结果
+	Remark     string  `json:"remark"`      // 备注
+}
+
+// 库存核对修复结果
+type RepairStockLedgerResult struct {
+	Check      *StockLedgerCheckReport `json:"check"`      // 修复前的核对结果
+	Operations []StockOperation        `json:"operations"` // 生成的库存调整单(每个店铺一张)
+}
+
+// 商品库存变动记录查询条件
+type StockLogQuery struct {
+	ShopID      int64      // 店铺ID
+	ProductID   int64      // 商品ID
+	Types       *int8      // 操作类型
+	StartTime   *time.Time // 开始时间(含)
+	EndTime     *time.Time // 结束时间(不含)
+	Operator    string     // 操作人(模糊)
+	BuyerName   string     // 购买者名称(模糊)
+	OperationNo string     // 库存操作单号或订单号
+	Page        int        // 页码
+	PageSize    int        // 每页数量
+}
+
+// 首页看板：订单状态数量
+type DashboardOrderCount struct {
+	Status OrderStatusCode `json:"status"` // 订单状态
+	Name   string          `json:"name"`   // 状态名称
+	Count  int64           `json:"count"`  // 订单数
+}
+
+// 首页看板：今日或本月汇总
+type DashboardPeriod struct {
+	StartDate  string                `json:"start_date"`  // 开始日期
+	EndDate    string                `json:"end_date"`    // 结束日期
+	Sales      SalesReportRow        `json:"sales"`       // 销售额、成本、毛利(口径同销售利润报表)
+	OrderTotal int64                 `json:"order_total"` // 小程序订单数
+	Orders     []DashboardOrderCount `json:"orders"`      // 小程序订单按状态的数量
+	NewUsers   int64                 `json:"new_users"`   // 新增客户数
+}
+
+// 首页看板：未收款出库单(后台出库和门店收银挂账)
+type DashboardUnpaid struct {
+	OperationCount int64      `json:"operation_count"` // 未收款出库单数
+	CustomerCount  int64      `json:"customer_count"`  // 涉及客户数
+	TotalAmount    Amount     `json:"total_amount"`    // 未收款金额(未结清金额合计，门店收银只计挂账部分)
+	OldestAt       *time.Time `json:"oldest_at"`       // 最早一张的出库时间
+}
+
+// 首页看板
+type Dashboard struct {
+	ShopID            int64            `json:"shop_id"`             // 店铺ID(0为全部店铺)
+	GeneratedAt       time.Time        `json:"generated_at"`        // 统计时间(缓存期内相同)
+	Today             DashboardPeriod  `json:"today"`               // 今日
+	Month             DashboardPeriod  `json:"month"`               // 本月
+	Unpaid            DashboardUnpaid  `json:"unpaid"`              // 未收款出库单
+	LowStockThreshold int              `json:"low_stock_threshold"` // 低库存阈值
+	LowStockCount     int64            `json:"low_stock_count"`     // 上架商品中库存不高于阈值的数量
+	TopProducts       []SalesReportRow `json:"top_products"`        // 本月销售额前10的商品
+}
+
+// 内部事件类型(pkg.Events)
+const (
+	EventOrderCreated   = "order.created"   // 小程序新订单
+	EventOrderPaid      = "order.paid"      // 订单支付成功
+	EventOrderCancelled = "order.cancelled" // 订单已取消
+	EventOrderRefund    = "order.refund"    // 订单已取消等非待付款状态时收到支付，需退款
+	EventOrderShipped   = "order.shipped"   // 订单已发货
+	EventOrderPickup    = "order.pickup"    // 订单已备货待自提
+	EventStockLow       = "stock.low"       // 商品库存降到低库存阈值
+	EventStockOutbound  = "stock.outbound"  // 后台出库
+)
+
+// 订单事件数据
+type OrderEvent struct {
+	OrderID       int64             `json:"order_id"`         // 订单ID
+	OrderNo       string            `json:"order_no"`         // 订单编号
+	UserID        int64             `json:"user_id"`          // 用户ID
+	ShopID        int64             `json:"shop_id"`          // 店铺ID
+	TotalAmount   Amount            `json:"total_amount"`     // 订单总金额
+	PaymentAmount Amount            `json:"payment_amount"`   // 实付金额
+	OrderStatus   OrderStatusCode   `json:"order_status"`     // 订单状态
+	PaymentStatus PaymentStatusCode `json:"payment_status"`   // 支付状态
+	ReceiverName  string            `json:"receiver_name"`    // 收货人
+	ReceiverPhone string            `json:"receiver_phone"`   // 收货人电话
+	ItemCount     int               `json:"item_count"`       // 商品种数
+	CreatedAt     *time.Time        `json:"created_at"`       // 下单时间
+	Remark        string            `json:"remark,omitempty"` // 发货/备货备注
+}
+
+// 后台出库事件数据
+type StockOutboundEvent struct {
+	OperationID   int64  `json:"operation_id"`   // 出库单ID
+	OperationNo   string `json:"operation_no"`   // 出库单号
+	UserID        int64  `json:"user_id"`        // 客户ID
+	UserName      string `json:"user_name"`      // 客户名称
+	TotalAmount   Amount `json:"total_amount"`   // 总金额
+	TotalQuantity int    `json:"total_quantity"` // 总数量
+}
+
+// 低库存事件数据
+type StockLowEvent struct {
+	ProductID   int64  `json:"product_id"`   // 商品ID
+	ProductName string `json:"product_name"` // 商品名称
+	Stock       int    `json:"stock"`        // 当前库存
+	Threshold   int    `json:"threshold"`    // 低库存阈值
+	OperationNo string `json:"operation_no"` // 引起库存下降的操作单号
+}
+
+// 订单通知场景(对应 config.yaml 中 wechat.subscribe_templates 的键)
+const (
+	NotifySceneOrderPaid    = "order_paid"    // 支付成功
+	NotifySceneOrderShipped = "order_shipped" // 已发货
+	NotifySceneOrderPickup  = "order_pickup"  // 已备货待自提
+
+	NotifyScenePaymentReminder = "payment_reminder" // 欠款提醒
+)
+
+// 消息发送状态
+const (
+	NotifyStatusPending = 1 // 待发送(含等待重试)
+	NotifyStatusSent    = 2 // 已发送
+	NotifyStatusFailed  = 3 // 失败(不再重试)
+	NotifyStatusSkipped = 4 // 未发送(用户未订阅、未绑定等)
+)
+
+// 订阅消息授权结果(wx.requestSubscribeMessage 返回值)
+const (
+	SubscribeResultAccept = "accept" // 同意
+	SubscribeResultReject = "reject" // 拒绝
+	SubscribeResultBan    = "ban"    // 已被后台封禁或用户关闭了订阅消息
+)
+
+// 小程序可订阅的消息模板
+type SubscribeTemplate struct {
+	Scene      string `json:"scene"`       // 通知场景
+	TemplateID string `json:"template_id"` // 模板ID
+}
+
+// 记录订阅消息授权请求，results 直接传 wx.requestSubscribeMessage 的返回结果(模板ID => accept/reject/ban)
+type SubscribeConsentRequest struct {
+	Results map[string]string `json:"results" binding:"required"`
+}
+
+// 订阅消息发送记录查询条件
+type WechatMessageQuery struct {
+	ShopID   int64  // 店铺ID
+	Status   *int8  // 状态
+	Scene    string // 通知场景
+	OrderNo  string // 订单编号
+	UserID   int64  // 用户ID
+	Page     int    // 页码
+	PageSize int    // 每页数量
+}
+
+// 后台订单列表查询条件
+type AdminOrderListRequest struct {
+	ShopID   int64  // 店铺ID，0为全部店铺(超级管理员)
+	Status   int32  // 订单状态
+	OrderNo  string // 订单编号
+	UserID   int64  // 用户ID
+	Page     int    // 页码
+	PageSize int    // 每页数量
+}
+
+// 后台订单发货/备货请求
+type ShipOrderRequest struct {
+	Remark string `json:"remark"` // 备注(如物流公司和单号、自提地址)
+}
+
+// 短信通知场景(对应 config.yaml 中 sms.templates 的键)
+const (
+	SmsSceneOutboundDelivery = "outbound_delivery" // 出库送货确认
+	SmsScenePaymentReminder  = "payment_reminder"  // 欠款催收提醒
+	SmsSceneMonthlyBalance   = "monthly_balance"   // 月度对账
+)
+
+// 短信发送记录查询条件
+type SmsLogQuery struct {
+	ShopID   int64  // 店铺ID
+	Status   *int8  // 状态
+	Scene    string // 通知场景
+	Phone    string // 手机号
+	UserID   int64  // 客户ID
+	Page     int    // 页码
+	PageSize int    // 每页数量
+}
+
+// 发送欠款提醒短信请求
+type SmsPaymentReminderRequest struct {
+	ShopID int64 `json:"shop_id" binding:"required"` // 店铺ID
+	UserID int64 `json:"user_id" binding:"required"` // 客户ID
+}
+
+// 发送月度对账短信请求
+type SmsMonthlyBalanceRequest struct {
+	ShopID int64  `json:"shop_id" binding:"required"` // 店铺ID
+	Month  string `json:"month"`                      // 对账月份(YYYY-MM)，默认上月
+}
+
+// 客户月度对账数据
+type CustomerMonthlyBalance struct {
+	UserID       int64  `json:"user_id"`       // 客户ID
+	UserName     string `json:"user_name"`     // 客户名称
+	MobilePhone  string `json:"mobile_phone"`  // 手机号
+	MonthCount   int    `json:"month_count"`   // 当月出库笔数
+	MonthAmount  Amount `json:"month_amount"`  // 当月出库金额
+	UnpaidCount  int    `json:"unpaid_count"`  // 未结清笔数
+	UnpaidAmount Amount `json:"unpaid_amount"` // 未结清金额
+}
+
+// 批量发送短信结果
+type SmsBatchResult struct {
+	Total   int `json:"total"`   // 客户数
+	Sent    int `json:"sent"`    // 已发送
+	Skipped int `json:"skipped"` // 未发送(已发过、限流、无手机号等)
+	Failed  int `json:"failed"`  // 失败
+}
+
+// 欠款提醒渠道
+const (
+	ReminderChannelWechat = "wechat" // 小程序订阅消息(需客户绑定微信并订阅)
+	ReminderChannelSms    = "sms"    // 短信(后台客户)
+)
+
+// 保存欠款提醒设置请求
+type PaymentReminderSettingRequest struct {
+	ShopID     int64    `json:"shop_id" binding:"required"` // 店铺ID
+	Enabled    int8     `json:"enabled"`                    // 是否启用(1:启用,0:停用)
+	Thresholds []int    `json:"thresholds"`                 // 账龄阈值(天)，如 [7, 15, 30]
+	SendHour   int      `json:"send_hour"`                  // 每天几点后发送(0-23)
+	Channels   []string `json:"channels"`                   // 提醒渠道(wechat/sms)
+	MinAmount  string   `json:"min_amount"`                 // 最低提醒金额(元)
+}
+
+// 立即执行欠款提醒请求
+type PaymentReminderRunRequest struct {
+	ShopID int64 `json:"shop_id" binding:"required"` // 店铺ID
+}
+
+// 欠款提醒记录查询条件
+type PaymentReminderQuery struct {
+	ShopID    int64      // 店铺ID
+	UserID    int64      // 客户ID
+	Status    *int8      // 状态
+	StartTime *time.Time // 提醒时间起
+	EndTime   *time.Time // 提醒时间止(不含)
+	Page      int        // 页码
+	PageSize  int        // 每页数量
+}
+
+// 执行欠款提醒结果
+type PaymentReminderRunResult struct {
+	Customers int                `json:"customers"` // 有超期欠款的客户数
+	Reminded  int                `json:"reminded"`  // 本次生成提醒的客户数(已按当前账龄提醒过的不重复提醒)
+	Sent      int                `json:"sent"`      // 已发送(任一渠道发送成功)
+	Pending   int                `json:"pending"`   // 订阅消息发送失败等待重试
+	Failed    int                `json:"failed"`    // 失败
+	Skipped   int                `json:"skipped"`   // 没有可用渠道等未发送
+	Reminders []*PaymentReminder `json:"reminders"` // 本次生成的提醒
+}
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+)
+
+// BarcodeMaxLength 条码最大长度
+const BarcodeMaxLength = 32
+
+// ValidateBarcode 校验条码格式：8位或13位纯数字按 EAN-8/EAN-13 校验校验位，
+// 其余为自定义条码(Code128)，只能包含字母、数字和常见符号
+func ValidateBarcode(code string) error {
+	if code == "" {
+		return errors.New("条码不能为空")
+	}
+	if len(code) > BarcodeMaxLength {
+		return fmt.Errorf("条码长度不能超过%d位", BarcodeMaxLength)
+	}
+	for _, r := range code {
+		if r < '!' || r > '~' {
+			return errors.New("条码只能包含字母、数字和常见符号")
+		}
+	}
+	if isDigits(code) && (len(code) == 8 || len(code) == 13) && !IsEAN(code) {
+		return fmt.Errorf("EAN条码 %s 校验位错误", code)
+	}
+	return nil
+}
+
+// IsEAN 是否为校验位正确的 EAN-8/EAN-13 条码
+func IsEAN(code string) bool {
+	if !isDigits(code) || (len(code) != 8 && len(code) != 13) {
+		return false
+	}
+	return EANCheckDigit(code[:len(code)-1]) == code[len(code)-1]
+}
+
+// EANCheckDigit 计算 EAN 条码的校验位，digits 为不含校验位的数字
+func EANCheckDigit(digits string) byte {
+	sum := 0
+	// 从右往左，奇数位乘3
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			n *= 3
+		}
+		sum += n
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// InternalBarcode 为没有条码的商品生成店内码：EAN-13 的店内流通码段(2开头) + 商品ID
+func InternalBarcode(productID int64) string {
+	digits := fmt.Sprintf("2%011d", productID)
+	return digits + string(EANCheckDigit(digits))
+}
+
+// EncodeBarcode 生成条码图形，EAN 条码按 EAN 编码，其余按 Code128 编码
+func EncodeBarcode(code string) (barcode.Barcode, error) {
+	if IsEAN(code) {
+		return ean.Encode(code)
+	}
+	return code128.Encode(code)
+}
+
+// EncodeQRCode 生成二维码图形
+func EncodeQRCode(content string) (barcode.Barcode, error) {
+	return qr.Encode(content, qr.M, qr.Auto)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -4,6 +4,7 @@ package pkg
 const (
 	OrderPrefix = "MAOCAI" // 订单前缀
 	StockPrefix = "STOCK"  // 库存前缀
+	PosPrefix   = "POS"    // 门店收银单前缀
 
 	MchID    = "540657616"
 	SerialNo = "你的证书序列号"
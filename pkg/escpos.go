@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"bytes"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// ESC/POS 对齐方式
+const (
+	EscPosAlignLeft   byte = 0
+	EscPosAlignCenter byte = 1
+	EscPosAlignRight  byte = 2
+)
+
+// EscPos ESC/POS 热敏打印指令构造器，中文按 GBK 编码(国产热敏打印机默认字符集)
+type EscPos struct {
+	buf     bytes.Buffer
+	encoder *encoding.Encoder
+}
+
+// NewEscPos 创建指令流，开头为初始化打印机和进入汉字模式
+func NewEscPos() *EscPos {
+	p := &EscPos{encoder: simplifiedchinese.GBK.NewEncoder()}
+	p.buf.Write([]byte{0x1B, 0x40}) // ESC @ 初始化
+	p.buf.Write([]byte{0x1C, 0x26}) // FS & 进入汉字模式
+	return p
+}
+
+// Align 设置对齐方式 ESC a n
+func (p *EscPos) Align(align byte) *EscPos {
+	p.buf.Write([]byte{0x1B, 0x61, align})
+	return p
+}
+
+// Bold 设置加粗 ESC E n
+func (p *EscPos) Bold(on bool) *EscPos {
+	p.buf.Write([]byte{0x1B, 0x45, escPosBool(on)})
+	return p
+}
+
+// Size 设置字符倍数(1-8) GS ! n
+func (p *EscPos) Size(width, height int) *EscPos {
+	p.buf.Write([]byte{0x1D, 0x21, byte((width-1)<<4 | (height - 1))})
+	return p
+}
+
+// Text 输出文字(不换行)，GBK 无法表示的字符(如 emoji)逐个输出为 ?
+func (p *EscPos) Text(s string) *EscPos {
+	if encoded, err := p.encoder.String(s); err == nil {
+		p.buf.WriteString(encoded)
+		return p
+	}
+	for _, r := range s {
+		encoded, err := p.encoder.String(string(r))
+		if err != nil {
+			encoded = "?"
+		}
+		p.buf.WriteString(encoded)
+	}
+	return p
+}
+
+// Line 输出一行文字
+func (p *EscPos) Line(s string) *EscPos {
+	return p.Text(s).Newline()
+}
+
+// Newline 换行 LF
+func (p *EscPos) Newline() *EscPos {
+	p.buf.WriteByte(0x0A)
+	return p
+}
+
+// Feed 走纸 n 行 ESC d n
+func (p *EscPos) Feed(lines int) *EscPos {
+	p.buf.Write([]byte{0x1B, 0x64, byte(lines)})
+	return p
+}
+
+// Cut 走纸并半切 GS V 66 0
+func (p *EscPos) Cut() *EscPos {
+	p.buf.Write([]byte{0x1D, 0x56, 0x42, 0x00})
+	return p
+}
+
+// OpenDrawer 弹出钱箱 ESC p 0 t1 t2
+func (p *EscPos) OpenDrawer() *EscPos {
+	p.buf.Write([]byte{0x1B, 0x70, 0x00, 0x19, 0xFA})
+	return p
+}
+
+// Bytes 指令流内容
+func (p *EscPos) Bytes() []byte {
+	return p.buf.Bytes()
+}
+
+func escPosBool(on bool) byte {
+	if on {
+		return 1
+	}
+	return 0
+}
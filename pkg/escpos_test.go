@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"bytes"
+	"testing"
+)
+
+// escPosInit NewEscPos 开头的初始化和汉字模式指令
+var escPosInit = []byte{0x1B, 0x40, 0x1C, 0x26}
+
+func TestEscPosCommands(t *testing.T) {
+	cases := []struct {
+		name  string
+		build func(p *EscPos)
+		want  []byte
+	}{
+		{"初始化", func(p *EscPos) {}, nil},
+		{"居中", func(p *EscPos) { p.Align(EscPosAlignCenter) }, []byte{0x1B, 0x61, 0x01}},
+		{"右对齐", func(p *EscPos) { p.Align(EscPosAlignRight) }, []byte{0x1B, 0x61, 0x02}},
+		{"加粗", func(p *EscPos) { p.Bold(true).Bold(false) }, []byte{0x1B, 0x45, 0x01, 0x1B, 0x45, 0x00}},
+		{"倍高倍宽", func(p *EscPos) { p.Size(2, 2) }, []byte{0x1D, 0x21, 0x11}},
+		{"倍宽", func(p *EscPos) { p.Size(2, 1) }, []byte{0x1D, 0x21, 0x10}},
+		{"正常大小", func(p *EscPos) { p.Size(1, 1) }, []byte{0x1D, 0x21, 0x00}},
+		{"走纸", func(p *EscPos) { p.Feed(3) }, []byte{0x1B, 0x64, 0x03}},
+		{"切纸", func(p *EscPos) { p.Cut() }, []byte{0x1D, 0x56, 0x42, 0x00}},
+		{"钱箱", func(p *EscPos) { p.OpenDrawer() }, []byte{0x1B, 0x70, 0x00, 0x19, 0xFA}},
+		{"英文行", func(p *EscPos) { p.Line("No.12") }, []byte("No.12\n")},
+	}
+	for _, tc := range cases {
+		p := NewEscPos()
+		tc.build(p)
+		want := append(append([]byte{}, escPosInit...), tc.want...)
+		if got := p.Bytes(); !bytes.Equal(got, want) {
+			t.Errorf("%s: got % X, want % X", tc.name, got, want)
+		}
+	}
+}
+
+func TestEscPosTextGBK(t *testing.T) {
+	p := NewEscPos()
+	p.Text("合计:¥12")
+	// 合 = BA CF，计 = BC C6
+	got := p.Bytes()[len(escPosInit):]
+	want := []byte{0xBA, 0xCF, 0xBC, 0xC6, ':'}
+	if !bytes.HasPrefix(got, want) {
+		t.Fatalf("got % X, want prefix % X", got, want)
+	}
+	if !bytes.HasSuffix(got, []byte("12")) {
+		t.Fatalf("got % X, want suffix 12", got)
+	}
+}
+
+func TestEscPosUnsupportedCharacter(t *testing.T) {
+	p := NewEscPos()
+	p.Text("a😀b")
+	got := p.Bytes()[len(escPosInit):]
+	if !bytes.Equal(got, []byte("a?b")) {
+		t.Fatalf("GBK 无法表示的字符应输出为 ?，got % X", got)
+	}
+}
+
+func TestEscPosReceiptSequence(t *testing.T) {
+	p := NewEscPos()
+	p.Align(EscPosAlignCenter).Bold(true).Size(2, 2).Line("小票").Size(1, 1).Bold(false).
+		Align(EscPosAlignLeft).Line("A").Feed(2).Cut()
+	want := append(append([]byte{}, escPosInit...),
+		0x1B, 0x61, 0x01,
+		0x1B, 0x45, 0x01,
+		0x1D, 0x21, 0x11,
+		0xD0, 0xA1, 0xC6, 0xB1, 0x0A, // 小票
+		0x1D, 0x21, 0x00,
+		0x1B, 0x45, 0x00,
+		0x1B, 0x61, 0x00,
+		'A', 0x0A,
+		0x1B, 0x64, 0x02,
+		0x1D, 0x56, 0x42, 0x00,
+	)
+	if got := p.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("got % X\nwant % X", got, want)
+	}
+}
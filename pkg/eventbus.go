@@ -0,0 +1,149 @@
+package pkg
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventHistorySize 保留最近的事件数，供断线重连的订阅者补发
+const eventHistorySize = 200
+
+// Event 内部事件，由业务服务发布，后台推送、消息通知等订阅方按类型和店铺消费
+type Event struct {
+	ID     int64       `json:"id"`      // 事件ID(进程内递增)
+	Type   string      `json:"type"`    // 事件类型，见 model.Event* 常量
+	ShopID int64       `json:"shop_id"` // 店铺ID
+	Time   time.Time   `json:"time"`    // 发生时间
+	Data   interface{} `json:"data"`    // 事件数据
+}
+
+type eventSubscriber struct {
+	name    string
+	ch      chan Event
+	filter  func(Event) bool
+	dropped int64 // 累计丢弃的事件数
+}
+
+// eventHandler 同步处理方，在发布方的协程中处理事件，不会丢弃
+type eventHandler struct {
+	name   string
+	filter func(Event) bool
+	fn     func(Event)
+}
+
+// EventBus 进程内事件总线：同步处理方在发布时依次执行；异步订阅方不阻塞发布，处理不过来时丢弃该订阅方的事件
+type EventBus struct {
+	mu       sync.RWMutex
+	nextID   int64
+	nextSub  int64
+	subs     map[int64]*eventSubscriber
+	handlers []eventHandler
+	history  []Event
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[int64]*eventSubscriber)}
+}
+
+// Events 全局事件总线
+var Events = NewEventBus()
+
+// PublishEvent 向全局事件总线发布事件
+func PublishEvent(eventType string, shopID int64, data interface{}) Event {
+	return Events.Publish(eventType, shopID, data)
+}
+
+// Publish 发布事件，返回带ID的事件
+func (b *EventBus) Publish(eventType string, shopID int64, data interface{}) Event {
+	b.mu.Lock()
+	b.nextID++
+	event := Event{ID: b.nextID, Type: eventType, ShopID: shopID, Time: time.Now(), Data: data}
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+	handlers := b.handlers
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		if handler.filter == nil || handler.filter(event) {
+			handleEvent(handler, event)
+		}
+	}
+
+	// 持有读锁投递，避免与取消订阅时关闭通道并发
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			dropped := atomic.AddInt64(&sub.dropped, 1)
+			log.Printf("事件订阅方 %s 处理不过来，丢弃事件 %d(%s，店铺%d)，累计丢弃 %d 个", sub.name, event.ID, event.Type, event.ShopID, dropped)
+		}
+	}
+	return event
+}
+
+// Handle 注册同步处理方：事件在发布方的协程中处理，不会因处理不过来而丢弃，适合需要可靠落库的订阅方；
+// fn 应尽快返回(如只写入数据库，耗时的外部调用另起协程)，filter 为 nil 时处理全部事件
+func (b *EventBus) Handle(name string, filter func(Event) bool, fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// 复制后追加，发布时持有的旧切片不受影响
+	handlers := make([]eventHandler, len(b.handlers), len(b.handlers)+1)
+	copy(handlers, b.handlers)
+	b.handlers = append(handlers, eventHandler{name: name, filter: filter, fn: fn})
+}
+
+// handleEvent 执行同步处理方，panic 只记录日志，不影响发布方
+func handleEvent(handler eventHandler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("事件处理方 %s 处理事件 %d(%s)异常: %v", handler.name, event.ID, event.Type, r)
+		}
+	}()
+	handler.fn(event)
+}
+
+// Subscribe 订阅事件，name 用于日志中标识订阅方，filter 为 nil 时接收全部事件；返回的 cancel 用于取消订阅并关闭通道
+func (b *EventBus) Subscribe(name string, buffer int, filter func(Event) bool) (<-chan Event, func()) {
+	sub := &eventSubscriber{name: name, ch: make(chan Event, buffer), filter: filter}
+	b.mu.Lock()
+	b.nextSub++
+	id := b.nextSub
+	b.subs[id] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, id)
+			close(sub.ch)
+			b.mu.Unlock()
+			if dropped := atomic.LoadInt64(&sub.dropped); dropped > 0 {
+				log.Printf("事件订阅方 %s 取消订阅，共丢弃 %d 个事件", sub.name, dropped)
+			}
+		})
+	}
+	return sub.ch, cancel
+}
+
+// Since 返回ID大于 afterID 且满足 filter 的近期事件，用于断线重连后补发
+func (b *EventBus) Since(afterID int64, filter func(Event) bool) []Event {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var events []Event
+	for _, event := range b.history {
+		if event.ID > afterID && (filter == nil || filter(event)) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const XlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// ReadTableFile 读取上传的表格文件(.xlsx/.csv)，返回第一个sheet的所有行（含表头）
+func ReadTableFile(file *multipart.FileHeader) ([][]string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, errors.New("打开文件失败:" + err.Error())
+	}
+	defer src.Close()
+
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".xlsx":
+		f, err := excelize.OpenReader(src)
+		if err != nil {
+			return nil, errors.New("解析Excel失败:" + err.Error())
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, errors.New("Excel中没有工作表")
+		}
+		rows, err := f.GetRows(sheets[0])
+		if err != nil {
+			return nil, errors.New("读取Excel失败:" + err.Error())
+		}
+		return rows, nil
+	case ".csv":
+		data, err := io.ReadAll(src)
+		if err != nil {
+			return nil, errors.New("读取CSV失败:" + err.Error())
+		}
+		// 去掉Excel另存为CSV时带的BOM
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, errors.New("解析CSV失败:" + err.Error())
+		}
+		return rows, nil
+	default:
+		return nil, errors.New("仅支持 .xlsx 或 .csv 文件")
+	}
+}
+
+// WriteXlsx 生成单个sheet的xlsx文件
+func WriteXlsx(sheetName string, headers []string, rows [][]interface{}) (*bytes.Buffer, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName("Sheet1", sheetName); err != nil {
+		return nil, err
+	}
+
+	headerRow := make([]interface{}, 0, len(headers))
+	for _, h := range headers {
+		headerRow = append(headerRow, h)
+	}
+	if err := f.SetSheetRow(sheetName, "A1", &headerRow); err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.SetSheetRow(sheetName, cell, &row); err != nil {
+			return nil, err
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("生成Excel失败: %w", err)
+	}
+	return buf, nil
+}
+
+// SendXlsx 以附件形式返回xlsx文件
+func SendXlsx(c *gin.Context, filename string, buf *bytes.Buffer) {
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(filename))
+	c.Data(http.StatusOK, XlsxContentType, buf.Bytes())
+}
@@ -0,0 +1,246 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // 解码上传的 WebP 图片
+)
+
+// 图片规格
+const (
+	ImageVariantThumb  = "thumb"  // 缩略图，购物车、订单等小图
+	ImageVariantList   = "list"   // 列表图，商品列表、搜索结果
+	ImageVariantDetail = "detail" // 详情图，商品详情页
+)
+
+// 图片格式
+const (
+	ImageFormatJPEG = "jpg"
+)
+
+// 各规格的最大边长(像素)，原图小于该尺寸时不放大
+var imageVariantSizes = []struct {
+	name string
+	size int
+}{
+	{ImageVariantThumb, 200},
+	{ImageVariantList, 480},
+	{ImageVariantDetail, 1080},
+}
+
+const (
+	imageOriginalQuality = 92 // 原图重新编码的JPEG质量
+	imageVariantQuality  = 82 // 缩放图的JPEG质量
+)
+
+// imageObject 待保存的图片对象
+type imageObject struct {
+	key         string
+	data        []byte
+	contentType string
+}
+
+// ImageVariantKey 图片某个规格的存储路径，如 uploads/ab/abcd.png -> uploads/ab/abcd_list.jpg
+func ImageVariantKey(key string, variant string, format string) string {
+	return UploadKeyBase(key) + "_" + variant + "." + format
+}
+
+// ImageVariantURL 图片某个规格的访问地址，非本存储的图片(外链)或规格图不存在(未补生成的历史图片)时返回原图地址
+func ImageVariantURL(url string, variant string, format string) string {
+	key, ok := StorageKeyFromURL(url)
+	if !ok {
+		return url
+	}
+	variantKey := ImageVariantKey(key, variant, format)
+	if !imageObjectExists(variantKey) {
+		return url
+	}
+	return DefaultStorage.URL(variantKey)
+}
+
+// 不存在的规格图缓存时长，过期后重新检查(可能已补生成)
+const imageMissingCacheTTL = 10 * time.Minute
+
+// imageExistsCache 规格图是否存在的缓存，避免每次拼接地址都请求存储：key => 检查时间(不存在)或零值(存在)
+var imageExistsCache sync.Map
+
+// imageObjectExists 规格图是否存在，存在的结果一直缓存，不存在的结果缓存 imageMissingCacheTTL
+// 检查失败时按不存在处理(返回原图地址)，不缓存
+func imageObjectExists(key string) bool {
+	if v, ok := imageExistsCache.Load(key); ok {
+		checkedAt := v.(time.Time)
+		if checkedAt.IsZero() {
+			return true
+		}
+		if time.Since(checkedAt) < imageMissingCacheTTL {
+			return false
+		}
+	}
+	exists, err := DefaultStorage.Exists(key)
+	if err != nil {
+		return false
+	}
+	if exists {
+		imageExistsCache.Store(key, time.Time{})
+	} else {
+		imageExistsCache.Store(key, time.Now())
+	}
+	return exists
+}
+
+// putImageObjects 保存图片对象并标记为存在
+func putImageObjects(objects []imageObject) error {
+	for _, object := range objects {
+		if _, err := DefaultStorage.Put(object.key, object.data, object.contentType); err != nil {
+			return err
+		}
+		imageExistsCache.Store(object.key, time.Time{})
+	}
+	return nil
+}
+
+// DeleteImageObjects 删除原图及各规格缩放图
+func DeleteImageObjects(key string) error {
+	if DefaultStorage == nil {
+		return errors.New("存储未初始化")
+	}
+	for _, objectKey := range ImageObjectKeys(key) {
+		if err := DefaultStorage.Delete(objectKey); err != nil {
+			return err
+		}
+		imageExistsCache.Delete(objectKey)
+	}
+	return nil
+}
+
+// ImageObjectKeys 图片原图及各规格缩放图的存储路径
+func ImageObjectKeys(key string) []string {
+	keys := []string{key}
+	for _, variant := range imageVariantSizes {
+		keys = append(keys, ImageVariantKey(key, variant.name, ImageFormatJPEG))
+	}
+	return keys
+}
+
+// uploadKeyPattern SaveImage 生成的存储路径(不含扩展名和规格后缀)
+var uploadKeyPattern = regexp.MustCompile(`uploads/[0-9a-f]{2}/[0-9a-f]{64}`)
+
+// UploadKeyBase 上传图片存储路径去掉扩展名后的部分，原图和各规格缩放图相同
+func UploadKeyBase(key string) string {
+	return strings.TrimSuffix(key, path.Ext(key))
+}
+
+// ImageKeyBasesInText 提取文本(图片地址、富文本等)中引用的上传图片，返回 UploadKeyBase 的集合
+// 只按存储路径匹配，不受访问域名变化影响，引用缩放图也算引用了原图
+func ImageKeyBasesInText(text string) []string {
+	return uploadKeyPattern.FindAllString(text, -1)
+}
+
+// StorageKeyFromURL 从访问地址解析存储路径，不属于当前存储时返回 false
+func StorageKeyFromURL(url string) (string, bool) {
+	if DefaultStorage == nil || url == "" {
+		return "", false
+	}
+	prefix := DefaultStorage.URL("")
+	if !strings.HasPrefix(url, prefix) || len(url) == len(prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, prefix), true
+}
+
+// processImage 处理上传的图片：按EXIF方向摆正后重新编码(去掉EXIF等元数据)，并生成各规格的JPEG图片
+// GIF 原图保持不变(可能是动图)，缩放图取第一帧
+func processImage(key string, data []byte, contentType string) ([]imageObject, error) {
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, errors.New("图片解析失败:" + err.Error())
+	}
+	opaque := isOpaque(img)
+
+	// 1. 原图
+	original := imageObject{key: key, data: data, contentType: contentType}
+	switch {
+	case contentType == "image/gif":
+	case contentType == "image/png" || !opaque:
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, errors.New("图片编码失败:" + err.Error())
+		}
+		original.data, original.contentType = buf.Bytes(), "image/png"
+		original.key = strings.TrimSuffix(key, path.Ext(key)) + ".png"
+	default:
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageOriginalQuality}); err != nil {
+			return nil, errors.New("图片编码失败:" + err.Error())
+		}
+		original.data, original.contentType = buf.Bytes(), "image/jpeg"
+		original.key = strings.TrimSuffix(key, path.Ext(key)) + ".jpg"
+	}
+	objects := []imageObject{original}
+
+	// 2. 各规格缩放图，JPEG不支持透明，透明图片铺白底
+	for _, variant := range imageVariantSizes {
+		resized := imaging.Fit(img, variant.size, variant.size, imaging.Lanczos)
+
+		variantImg := image.Image(resized)
+		if !opaque {
+			background := imaging.New(resized.Rect.Dx(), resized.Rect.Dy(), color.White)
+			variantImg = imaging.Overlay(background, resized, image.Pt(0, 0), 1.0)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, variantImg, &jpeg.Options{Quality: imageVariantQuality}); err != nil {
+			return nil, errors.New("图片编码失败:" + err.Error())
+		}
+		objects = append(objects, imageObject{
+			key:         ImageVariantKey(original.key, variant.name, ImageFormatJPEG),
+			data:        buf.Bytes(),
+			contentType: "image/jpeg",
+		})
+	}
+	return objects, nil
+}
+
+// isOpaque 图片是否不含透明像素
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
+
+// GenerateImageVariants 为已存储的图片补生成各规格图片(如改造前上传的图片)，已生成过的跳过
+// 原图保持不变，返回是否新生成
+func GenerateImageVariants(url string) (bool, error) {
+	key, ok := StorageKeyFromURL(url)
+	if !ok {
+		return false, nil
+	}
+	exists, err := DefaultStorage.Exists(ImageVariantKey(key, ImageVariantThumb, ImageFormatJPEG))
+	if err != nil || exists {
+		return false, err
+	}
+	data, err := DefaultStorage.Get(key)
+	if err != nil {
+		return false, err
+	}
+	objects, err := processImage(key, data, http.DetectContentType(data))
+	if err != nil {
+		return false, err
+	}
+	if err := putImageObjects(objects[1:]); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestImageVariantURLFallsBackWhenMissing(t *testing.T) {
+	saved := DefaultStorage
+	defer func() { DefaultStorage = saved }()
+	DefaultStorage = NewMemoryStorage("http://127.0.0.1/files/")
+
+	// 历史图片：只有原图，没有规格图
+	legacyKey := "uploads/00/" + string(bytes.Repeat([]byte("0"), 64)) + ".jpg"
+	legacyURL, err := DefaultStorage.Put(legacyKey, []byte("legacy"), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ImageVariantURL(legacyURL, ImageVariantList, ImageFormatJPEG); got != legacyURL {
+		t.Fatalf("规格图不存在时应返回原图地址, got %s", got)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	img.SetNRGBA(1, 1, color.NRGBA{255, 0, 0, 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	url, err := SaveImage(buf.Bytes(), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := StorageKeyFromURL(url)
+	want := DefaultStorage.URL(ImageVariantKey(key, ImageVariantList, ImageFormatJPEG))
+	if got := ImageVariantURL(url, ImageVariantList, ImageFormatJPEG); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	if err := DeleteImageObjects(key); err != nil {
+		t.Fatal(err)
+	}
+	if got := ImageVariantURL(url, ImageVariantList, ImageFormatJPEG); got != url {
+		t.Fatalf("删除后应返回原图地址, got %s", got)
+	}
+}
+
+func TestProcessImageVariantFormats(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	objects, err := processImage("uploads/ab/abcd.png", buf.Bytes(), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1+len(imageVariantSizes) {
+		t.Fatalf("生成了 %d 个对象, want 原图和 %d 个规格图", len(objects), len(imageVariantSizes))
+	}
+	for i, object := range objects[1:] {
+		variant := imageVariantSizes[i]
+		if want := ImageVariantKey("uploads/ab/abcd.png", variant.name, ImageFormatJPEG); object.key != want || object.contentType != "image/jpeg" {
+			t.Fatalf("got %s %s, want %s image/jpeg", object.key, object.contentType, want)
+		}
+		decoded, err := jpeg.Decode(bytes.NewReader(object.data))
+		if err != nil {
+			t.Fatalf("%s 不是有效的JPEG: %v", object.key, err)
+		}
+		if size := decoded.Bounds().Dx(); size > variant.size {
+			t.Fatalf("%s 宽度 %d 超过 %d", object.key, size, variant.size)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package pkg
+
+import (
+	"bytes"
+	"cmf/paint_proj/configs"
+	"errors"
+	"image/color"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/boombuler/barcode"
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// PDFFontFamily PDF 中使用的中文字体名称
+const PDFFontFamily = "cjk"
+
+var (
+	pdfFontMu   sync.Mutex
+	pdfFontData []byte
+)
+
+// loadPDFFont 读取配置的中文字体文件(首次读取后缓存)
+func loadPDFFont() ([]byte, error) {
+	pdfFontMu.Lock()
+	defer pdfFontMu.Unlock()
+	if pdfFontData != nil {
+		return pdfFontData, nil
+	}
+	if configs.Cfg == nil || configs.Cfg.Pdf.FontPath == "" {
+		return nil, errors.New("未配置PDF中文字体(pdf.font_path)")
+	}
+	data, err := os.ReadFile(configs.Cfg.Pdf.FontPath)
+	if err != nil {
+		return nil, errors.New("读取PDF中文字体失败: " + err.Error())
+	}
+	pdfFontData = data
+	return data, nil
+}
+
+// InitPDFFont 启动时加载并校验配置的中文字体，字体缺失时尽早提示；之后生成PDF时会重新读取，补上字体文件后无需重启
+func InitPDFFont() error {
+	_, err := NewPDF("P", gofpdf.SizeType{Wd: 210, Ht: 297})
+	return err
+}
+
+// NewPDF 创建以毫米为单位的PDF文档并加载中文字体，字体需为 TrueType(.ttf) 格式
+func NewPDF(orientation string, size gofpdf.SizeType) (*gofpdf.Fpdf, error) {
+	font, err := loadPDFFont()
+	if err != nil {
+		return nil, err
+	}
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{OrientationStr: orientation, UnitStr: "mm", Size: size})
+	pdf.AddUTF8FontFromBytes(PDFFontFamily, "", font)
+	if err := pdf.Error(); err != nil {
+		return nil, errors.New("加载PDF中文字体失败: " + err.Error())
+	}
+	pdf.SetFont(PDFFontFamily, "", 10)
+	return pdf, nil
+}
+
+// OutputPDF 输出PDF内容
+func OutputPDF(pdf *gofpdf.Fpdf) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// SendPDF 返回PDF文件(浏览器内直接预览打印)
+func SendPDF(c *gin.Context, filename string, buf *bytes.Buffer) {
+	c.Header("Content-Disposition", "inline; filename*=UTF-8''"+url.PathEscape(filename))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// FitPDFText 按宽度截断文字，超出时以省略号结尾
+func FitPDFText(pdf *gofpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// DrawPDFBarcode 以矢量方式绘制条码或二维码，条码按模块拉伸到 w×h 区域内
+func DrawPDFBarcode(pdf *gofpdf.Fpdf, bc barcode.Barcode, x, y, w, h float64) {
+	bounds := bc.Bounds()
+	cols, rows := bounds.Dx(), bounds.Dy()
+	if cols == 0 || rows == 0 {
+		return
+	}
+	moduleW := w / float64(cols)
+	moduleH := h / float64(rows)
+	pdf.SetFillColor(0, 0, 0)
+	for row := 0; row < rows; row++ {
+		// 连续的黑色模块合并为一个矩形
+		for col := 0; col < cols; {
+			if !isBlack(bc.At(bounds.Min.X+col, bounds.Min.Y+row)) {
+				col++
+				continue
+			}
+			start := col
+			for col < cols && isBlack(bc.At(bounds.Min.X+col, bounds.Min.Y+row)) {
+				col++
+			}
+			pdf.Rect(x+float64(start)*moduleW, y+float64(row)*moduleH, float64(col-start)*moduleW, moduleH, "F")
+		}
+	}
+}
+
+func isBlack(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 0x18000
+}
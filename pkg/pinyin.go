@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// GB2312一级汉字按拼音排序，每个首字母对应的起始区位码
+var pinyinBoundaries = []struct {
+	code   int
+	letter byte
+}{
+	{0xB0A1, 'A'}, {0xB0C5, 'B'}, {0xB2C1, 'C'}, {0xB4EE, 'D'}, {0xB6EA, 'E'}, {0xB7A2, 'F'},
+	{0xB8C1, 'G'}, {0xB9FE, 'H'}, {0xBBF7, 'J'}, {0xBFA6, 'K'}, {0xC0AC, 'L'}, {0xC2E8, 'M'},
+	{0xC4C3, 'N'}, {0xC5B6, 'O'}, {0xC5BE, 'P'}, {0xC6DA, 'Q'}, {0xC8BB, 'R'}, {0xC8F6, 'S'},
+	{0xCBFA, 'T'}, {0xCDDA, 'W'}, {0xCEF4, 'X'}, {0xD1B9, 'Y'}, {0xD4D1, 'Z'},
+}
+
+// 一级汉字的结束区位码
+const pinyinLastCode = 0xD7F9
+
+// GB2312二级汉字(按部首排序，无法按区位码推算)及多音字的常用读音补充
+var pinyinOverrides = map[rune]byte{
+	'涞': 'L', '酯': 'Z', '缇': 'T', '琪': 'Q', '珂': 'K', // 二级汉字
+	'调': 'T', // 多音字：调色、调和漆
+}
+
+// PinyinInitials 获取字符串的拼音首字母(大写)，如 "立邦净味120" -> "LBJW120"
+// 字母和数字原样保留(转为大写)，其他字符忽略；无法识别的汉字忽略
+func PinyinInitials(s string) string {
+	encoder := simplifiedchinese.GBK.NewEncoder()
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r < unicode.MaxASCII:
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				sb.WriteRune(unicode.ToUpper(r))
+			}
+		case unicode.Is(unicode.Han, r):
+			if letter, ok := pinyinOverrides[r]; ok {
+				sb.WriteByte(letter)
+				continue
+			}
+			encoded, err := encoder.String(string(r))
+			if err != nil || len(encoded) != 2 {
+				continue
+			}
+			code := int(encoded[0])<<8 | int(encoded[1])
+			if code < pinyinBoundaries[0].code || code > pinyinLastCode {
+				continue
+			}
+			for i := len(pinyinBoundaries) - 1; i >= 0; i-- {
+				if code >= pinyinBoundaries[i].code {
+					sb.WriteByte(pinyinBoundaries[i].letter)
+					break
+				}
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,25 @@
+package pkg
+
+import "testing"
+
+func TestPinyinInitials(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"立邦净味120", "LBJW120"},
+		{"多乐士 Dulux 5L", "DLSDULUX5L"},
+		{"调和漆", "THQ"},              // 多音字按常用读音 tiao
+		{"调色", "TS"},                // 多音字
+		{"醇酸酯胶漆", "CSZJQ"},          // 二级汉字 酯
+		{"涞水缇琪珂", "LSTQK"},          // 二级汉字补充表
+		{"防水(K11)，柔韧型", "FSK11RRX"}, // 标点符号忽略
+		{"氽", ""},                   // 未收录的二级汉字忽略
+		{"", ""},
+	}
+	for _, tc := range cases {
+		if got := PinyinInitials(tc.in); got != tc.want {
+			t.Errorf("PinyinInitials(%q) got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package pkg
+
+import "strings"
+
+var (
+	rmbDigits     = []string{"零", "壹", "贰", "叁", "肆", "伍", "陆", "柒", "捌", "玖"}
+	rmbUnits      = []string{"", "拾", "佰", "仟"}
+	rmbGroupUnits = []string{"", "万", "亿", "万亿"}
+)
+
+// RMBUpper 金额(分)转为票据用的中文大写，如 10203.05 -> 壹万零贰佰零叁元零伍分，
+// 100.00 -> 壹佰元整，12.30 -> 壹拾贰元叁角
+func RMBUpper(cents int64) string {
+	if cents == 0 {
+		return "零元整"
+	}
+	var b strings.Builder
+	if cents < 0 {
+		b.WriteString("负")
+		cents = -cents
+	}
+	yuan := cents / 100
+	jiao := cents / 10 % 10
+	fen := cents % 10
+
+	if yuan > 0 {
+		b.WriteString(rmbInteger(yuan))
+		b.WriteString("元")
+	}
+	switch {
+	case jiao == 0 && fen == 0:
+		b.WriteString("整")
+	case fen == 0:
+		b.WriteString(rmbDigits[jiao] + "角")
+	case jiao == 0:
+		if yuan > 0 {
+			b.WriteString("零")
+		}
+		b.WriteString(rmbDigits[fen] + "分")
+	default:
+		b.WriteString(rmbDigits[jiao] + "角" + rmbDigits[fen] + "分")
+	}
+	return b.String()
+}
+
+// rmbInteger 整数部分转中文大写，按万、亿分节，节内和节间的连续零只写一个"零"
+func rmbInteger(n int64) string {
+	var groups []int64
+	for n > 0 {
+		groups = append(groups, n%10000)
+		n /= 10000
+	}
+	var b strings.Builder
+	zero := false // 高位已输出且之后出现了零
+	for i := len(groups) - 1; i >= 0; i-- {
+		group := groups[i]
+		if group == 0 {
+			zero = b.Len() > 0
+			continue
+		}
+		if b.Len() > 0 && (zero || group < 1000) {
+			b.WriteString("零")
+		}
+		zero = false
+		groupZero := false
+		for pos := 3; pos >= 0; pos-- {
+			d := group / pow10(pos) % 10
+			if d == 0 {
+				groupZero = groupZero || group/pow10(pos+1) > 0
+				continue
+			}
+			if groupZero {
+				b.WriteString("零")
+				groupZero = false
+			}
+			b.WriteString(rmbDigits[d] + rmbUnits[pos])
+		}
+		b.WriteString(rmbGroupUnits[i])
+	}
+	return b.String()
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,32 @@
+package pkg
+
+import "testing"
+
+func TestRMBUpper(t *testing.T) {
+	cases := []struct {
+		cents int64
+		want  string
+	}{
+		{0, "零元整"},
+		{5, "伍分"},
+		{50, "伍角"},
+		{1005, "壹拾元零伍分"},
+		{1230, "壹拾贰元叁角"},
+		{10000, "壹佰元整"},
+		{100100, "壹仟零壹元整"},
+		{1020305, "壹万零贰佰零叁元零伍分"},
+		{10010000, "壹拾万零壹佰元整"},
+		{100000000, "壹佰万元整"},
+		{10000000000, "壹亿元整"},
+		{10001000000, "壹亿零壹万元整"},
+		{10000000100, "壹亿零壹元整"},
+		{12000050000, "壹亿贰仟万零伍佰元整"},
+		{-1230, "负壹拾贰元叁角"},
+		{-5, "负伍分"},
+	}
+	for _, tc := range cases {
+		if got := RMBUpper(tc.cents); got != tc.want {
+			t.Errorf("RMBUpper(%d) got %s, want %s", tc.cents, got, tc.want)
+		}
+	}
+}
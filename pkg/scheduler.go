@@ -0,0 +1,30 @@
+package pkg
+
+import (
+	"log"
+	"time"
+)
+
+// RunEvery 启动后台定时任务，每隔 interval 执行一次 fn
+// 单次执行返回错误或 panic 只记录日志(以 name 标识任务)，不影响下次执行
+func RunEvery(name string, interval time.Duration, fn func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runScheduledTask(name, fn)
+		}
+	}()
+}
+
+// runScheduledTask 执行一次定时任务，捕获 panic
+func runScheduledTask(name string, fn func() error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%s异常: %v", name, r)
+		}
+	}()
+	if err := fn(); err != nil {
+		log.Printf("%s执行失败: %v", name, err)
+	}
+}
@@ -0,0 +1,285 @@
+package pkg
+
+import (
+	"bytes"
+	"cmf/paint_proj/configs"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/gin-gonic/gin"
+)
+
+// 存储方式
+const (
+	StorageDriverOSS    = "oss"
+	StorageDriverLocal  = "local"
+	StorageDriverMemory = "memory"
+)
+
+// ErrObjectNotFound 对象不存在
+var ErrObjectNotFound = errors.New("文件不存在")
+
+// Storage 对象存储接口，key 为对象路径(如 uploads/ab/abcdef.png)
+type Storage interface {
+	Put(key string, data []byte, contentType string) (string, error) // 保存对象，返回访问URL
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	Exists(key string) (bool, error)
+	URL(key string) string // 对象的访问URL
+}
+
+// DefaultStorage 全局存储，由 InitStorage 根据配置初始化
+var DefaultStorage Storage
+
+// InitStorage 根据配置初始化全局存储
+func InitStorage(cfg *configs.Config) error {
+	storageCfg := cfg.Storage
+	switch storageCfg.Driver {
+	case "", StorageDriverOSS:
+		s, err := NewOSSStorage(cfg.Oss)
+		if err != nil {
+			return err
+		}
+		DefaultStorage = s
+	case StorageDriverLocal:
+		s, err := NewLocalStorage(storageCfg.LocalDir, storageURLBase(storageCfg))
+		if err != nil {
+			return err
+		}
+		DefaultStorage = s
+	case StorageDriverMemory:
+		DefaultStorage = NewMemoryStorage(storageURLBase(storageCfg))
+	default:
+		return fmt.Errorf("不支持的存储方式: %s", storageCfg.Driver)
+	}
+	return nil
+}
+
+// storageURLBase 本地/内存存储的访问地址前缀
+func storageURLBase(cfg configs.StorageConfig) string {
+	return strings.TrimRight(cfg.BaseURL, "/") + StorageURLPrefix(cfg)
+}
+
+// StorageURLPrefix 本地/内存存储的静态访问路由前缀，默认 /static
+func StorageURLPrefix(cfg configs.StorageConfig) string {
+	prefix := strings.TrimRight(cfg.URLPrefix, "/")
+	if prefix == "" {
+		prefix = "/static"
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// ossStorage 阿里云OSS存储，bucket 在初始化时创建并复用
+type ossStorage struct {
+	bucket  *oss.Bucket
+	urlBase string
+}
+
+// NewOSSStorage 创建阿里云OSS存储
+func NewOSSStorage(cfg configs.OssConfig) (Storage, error) {
+	client, err := oss.New(cfg.Endpoint, cfg.AccessKeyID, cfg.AccessKeySecret)
+	if err != nil {
+		return nil, errors.New("连接OSS失败:" + err.Error())
+	}
+	bucket, err := client.Bucket(cfg.BucketName)
+	if err != nil {
+		return nil, errors.New("获取Bucket失败:" + err.Error())
+	}
+	endpointSuffix := strings.TrimPrefix(strings.TrimPrefix(cfg.Endpoint, "https://"), "http://")
+	return &ossStorage{
+		bucket:  bucket,
+		urlBase: fmt.Sprintf("https://%s.%s", cfg.BucketName, endpointSuffix),
+	}, nil
+}
+
+func (s *ossStorage) Put(key string, data []byte, contentType string) (string, error) {
+	if err := s.bucket.PutObject(key, bytes.NewReader(data), oss.ContentType(contentType)); err != nil {
+		return "", errors.New("上传失败:" + err.Error())
+	}
+	return s.URL(key), nil
+}
+
+func (s *ossStorage) Get(key string) ([]byte, error) {
+	body, err := s.bucket.GetObject(key)
+	if err != nil {
+		var serviceErr oss.ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.StatusCode == 404 {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (s *ossStorage) Delete(key string) error {
+	return s.bucket.DeleteObject(key)
+}
+
+func (s *ossStorage) Exists(key string) (bool, error) {
+	return s.bucket.IsObjectExist(key)
+}
+
+func (s *ossStorage) URL(key string) string {
+	return s.urlBase + "/" + key
+}
+
+// localStorage 本地磁盘存储，通过静态路由访问
+type localStorage struct {
+	dir     string
+	urlBase string
+}
+
+// NewLocalStorage 创建本地磁盘存储
+func NewLocalStorage(dir string, urlBase string) (Storage, error) {
+	if dir == "" {
+		return nil, errors.New("本地存储目录未配置")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建本地存储目录失败: %v", err)
+	}
+	return &localStorage{dir: dir, urlBase: urlBase}, nil
+}
+
+// path 对象在磁盘上的路径，防止 key 跳出存储目录
+func (s *localStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" {
+		return "", errors.New("文件路径不能为空")
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *localStorage) Put(key string, data []byte, contentType string) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("创建目录失败: %v", err)
+	}
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", fmt.Errorf("保存文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("保存文件失败: %v", err)
+	}
+	return s.URL(key), nil
+}
+
+func (s *localStorage) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+func (s *localStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *localStorage) Exists(key string) (bool, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *localStorage) URL(key string) string {
+	return s.urlBase + "/" + key
+}
+
+// memoryStorage 内存存储，重启后数据丢失，仅用于开发和测试
+type memoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+	urlBase string
+}
+
+// NewMemoryStorage 创建内存存储
+func NewMemoryStorage(urlBase string) Storage {
+	return &memoryStorage{objects: make(map[string][]byte), urlBase: urlBase}
+}
+
+func (s *memoryStorage) Put(key string, data []byte, contentType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = append([]byte(nil), data...)
+	return s.URL(key), nil
+}
+
+func (s *memoryStorage) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *memoryStorage) Exists(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.objects[key]
+	return ok, nil
+}
+
+func (s *memoryStorage) URL(key string) string {
+	return s.urlBase + "/" + key
+}
+
+// RegisterStorageRoutes 本地/内存存储时注册静态访问路由
+func RegisterStorageRoutes(r *gin.Engine, cfg configs.StorageConfig) {
+	prefix := StorageURLPrefix(cfg)
+	switch s := DefaultStorage.(type) {
+	case *localStorage:
+		r.Static(prefix, s.dir)
+	case *memoryStorage:
+		r.GET(prefix+"/*key", func(c *gin.Context) {
+			data, err := s.Get(strings.TrimPrefix(c.Param("key"), "/"))
+			if err != nil {
+				c.Status(http.StatusNotFound)
+				return
+			}
+			c.Data(http.StatusOK, http.DetectContentType(data), data)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// AdminTicketTTL 一次性票据有效期，换取后需立即使用
+const AdminTicketTTL = 30 * time.Second
+
+// AdminTicket 一次性票据携带的管理员身份
+type AdminTicket struct {
+	OperatorID   int64
+	OperatorName string
+	ShopID       int64
+	IsRoot       bool
+	ExpiresAt    time.Time
+}
+
+// 浏览器 EventSource 不能设置请求头，只能把凭证放在查询参数里，而查询参数会写进访问日志；
+// 用短期、只能使用一次的票据代替长期有效的 JWT，日志中的票据已失效
+var adminTickets = struct {
+	sync.Mutex
+	m map[string]AdminTicket
+}{m: make(map[string]AdminTicket)}
+
+// IssueAdminTicket 为已登录的管理员签发一次性票据
+func IssueAdminTicket(operatorID int64, operatorName string, shopID int64, isRoot bool) (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	ticket := hex.EncodeToString(buf)
+	now := time.Now()
+	expiresAt := now.Add(AdminTicketTTL)
+
+	adminTickets.Lock()
+	defer adminTickets.Unlock()
+	// 顺带清理过期未使用的票据
+	for key, t := range adminTickets.m {
+		if now.After(t.ExpiresAt) {
+			delete(adminTickets.m, key)
+		}
+	}
+	adminTickets.m[ticket] = AdminTicket{
+		OperatorID:   operatorID,
+		OperatorName: operatorName,
+		ShopID:       shopID,
+		IsRoot:       isRoot,
+		ExpiresAt:    expiresAt,
+	}
+	return ticket, expiresAt, nil
+}
+
+// ConsumeAdminTicket 使用一次性票据，票据不存在、已使用或已过期时返回 false
+func ConsumeAdminTicket(ticket string) (AdminTicket, bool) {
+	adminTickets.Lock()
+	defer adminTickets.Unlock()
+	t, ok := adminTickets.m[ticket]
+	if !ok {
+		return AdminTicket{}, false
+	}
+	delete(adminTickets.m, ticket)
+	if time.Now().After(t.ExpiresAt) {
+		return AdminTicket{}, false
+	}
+	return t, true
+}
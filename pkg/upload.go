@@ -2,15 +2,28 @@ package pkg
 
 import (
 	"cmf/paint_proj/configs"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"io"
+	"mime/multipart"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
-	"path/filepath"
-	"strings"
-	"time"
 )
 
+// 允许上传的图片类型及对应扩展名
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// 默认上传图片大小上限(MB)
+const defaultMaxImageSizeMB = 5
+
 func UploadImage(c *gin.Context) (string, error) {
 	// 读取文件
 	file, err := c.FormFile("file")
@@ -18,42 +31,67 @@ func UploadImage(c *gin.Context) (string, error) {
 		return "", errors.New("获取文件失败:" + err.Error())
 	}
 
-	// 打开上传文件
-	src, err := file.Open()
+	data, contentType, err := ReadImageFile(file)
 	if err != nil {
-		return "", errors.New("打开文件失败:" + err.Error())
+		return "", err
 	}
-	defer src.Close()
+	return SaveImage(data, contentType)
+}
 
-	// OSS 配置
-	endpoint := configs.Cfg.Oss.Endpoint
-	accessKeyId := configs.Cfg.Oss.AccessKeyID
-	accessKeySecret := configs.Cfg.Oss.AccessKeySecret
-	bucketName := configs.Cfg.Oss.BucketName
-	endpointSuffix := strings.TrimPrefix(endpoint, "https://")
+// maxImageSize 上传图片大小上限(字节)
+func maxImageSize() int64 {
+	sizeMB := int64(defaultMaxImageSizeMB)
+	if configs.Cfg != nil && configs.Cfg.Storage.MaxSizeMB > 0 {
+		sizeMB = configs.Cfg.Storage.MaxSizeMB
+	}
+	return sizeMB << 20
+}
 
-	// 创建 OSS 客户端
-	client, err := oss.New(endpoint, accessKeyId, accessKeySecret)
+// ReadImageFile 读取上传的图片，按文件内容校验类型和大小
+func ReadImageFile(file *multipart.FileHeader) ([]byte, string, error) {
+	maxSize := maxImageSize()
+	if file.Size > maxSize {
+		return nil, "", fmt.Errorf("图片大小不能超过%dMB", maxSize>>20)
+	}
+
+	// 打开上传文件
+	src, err := file.Open()
 	if err != nil {
-		return "", errors.New("连接OSS失败:" + err.Error())
+		return nil, "", errors.New("打开文件失败:" + err.Error())
 	}
+	defer src.Close()
 
-	// 获取 bucket
-	bucket, err := client.Bucket(bucketName)
+	data, err := io.ReadAll(io.LimitReader(src, maxSize+1))
 	if err != nil {
-		return "", errors.New("获取Bucket失败:" + err.Error())
+		return nil, "", errors.New("读取文件失败:" + err.Error())
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", fmt.Errorf("图片大小不能超过%dMB", maxSize>>20)
 	}
 
-	// 构造 OSS 文件路径
-	filename := fmt.Sprintf("uploads/%d%s", time.Now().Unix(), filepath.Ext(file.Filename))
+	// 不信任文件扩展名和客户端传的类型，按内容识别
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		return nil, "", fmt.Errorf("不支持的文件类型: %s，仅支持 jpg/png/gif/webp 图片", contentType)
+	}
+	return data, contentType, nil
+}
 
-	// 上传到 OSS
-	err = bucket.PutObject(filename, src)
+// SaveImage 保存图片及其各规格缩放图，按内容哈希命名，相同图片只存一份，返回原图地址
+func SaveImage(data []byte, contentType string) (string, error) {
+	if DefaultStorage == nil {
+		return "", errors.New("存储未初始化")
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	key := fmt.Sprintf("uploads/%s/%s%s", hash[:2], hash, allowedImageTypes[contentType])
+	objects, err := processImage(key, data, contentType)
 	if err != nil {
-		return "", errors.New("上传失败:" + err.Error())
+		return "", err
 	}
-	// 返回图片 URL
-
-	fileURL := fmt.Sprintf("https://%s.%s/%s", bucketName, endpointSuffix, filename)
-	return fileURL, nil
+	// 先保存缩放图，最后保存原图，原图存在即表示各规格都已生成
+	if err := putImageObjects(objects[1:]); err != nil {
+		return "", err
+	}
+	return DefaultStorage.Put(objects[0].key, objects[0].data, objects[0].contentType)
 }
@@ -6,6 +6,9 @@ import (
 	"fmt"
 
 	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/auth/verifiers"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/downloader"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/notify"
 	"github.com/wechatpay-apiv3/wechatpay-go/core/option"
 )
 
@@ -21,3 +24,16 @@ func InitWechatPayClient(mchID, mchSerialNo, apiV3Key string, privateKey *rsa.Pr
 	}
 	return client, nil
 }
+
+// NewWechatPayNotifyHandler 微信支付回调通知处理器：用平台证书验证通知签名，用 APIv3 密钥解密通知内容
+// 平台证书由证书下载器自动下载和更新，与 InitWechatPayClient 共用同一个下载器
+func NewWechatPayNotifyHandler(ctx context.Context, mchID, mchSerialNo, apiV3Key string, privateKey *rsa.PrivateKey) (*notify.Handler, error) {
+	mgr := downloader.MgrInstance()
+	if !mgr.HasDownloader(ctx, mchID) {
+		if err := mgr.RegisterDownloaderWithPrivateKey(ctx, privateKey, mchSerialNo, mchID, apiV3Key); err != nil {
+			return nil, fmt.Errorf("下载微信支付平台证书失败: %w", err)
+		}
+	}
+	verifier := verifiers.NewSHA256WithRSAVerifier(mgr.GetCertificateVisitor(mchID))
+	return notify.NewNotifyHandler(apiV3Key, verifier), nil
+}
@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WechatAPIBaseURL 微信服务端接口地址，测试时可在配置中换成本地模拟服务
+const WechatAPIBaseURL = "https://api.weixin.qq.com"
+
+// 订阅消息相关的微信错误码
+const (
+	WechatErrTokenInvalid  = 40001 // access_token 无效
+	WechatErrTokenExpired  = 42001 // access_token 过期
+	WechatErrTokenMissing  = 41001 // 缺少 access_token
+	WechatErrUserRefused   = 43101 // 用户拒绝接受消息(未订阅或订阅次数已用完)
+	WechatErrTemplateParam = 47003 // 模板参数不准确
+	WechatErrSystemBusy    = -1    // 系统繁忙
+)
+
+// WechatError 微信接口返回的错误
+type WechatError struct {
+	Code int
+	Msg  string
+}
+
+func (e *WechatError) Error() string {
+	return fmt.Sprintf("微信接口错误: %d - %s", e.Code, e.Msg)
+}
+
+// TokenInvalid access_token 无效或过期，刷新后可重试
+func (e *WechatError) TokenInvalid() bool {
+	return e.Code == WechatErrTokenInvalid || e.Code == WechatErrTokenExpired || e.Code == WechatErrTokenMissing
+}
+
+// SubscribeValue 订阅消息模板字段值
+type SubscribeValue struct {
+	Value string `json:"value"`
+}
+
+// SubscribeMessage 小程序订阅消息
+type SubscribeMessage struct {
+	ToUser           string                    `json:"touser"`                      // 用户openid
+	TemplateID       string                    `json:"template_id"`                 // 模板ID
+	Page             string                    `json:"page,omitempty"`              // 点击消息跳转的小程序页面
+	MiniprogramState string                    `json:"miniprogram_state,omitempty"` // developer/trial/formal
+	Lang             string                    `json:"lang,omitempty"`              // 语言，默认 zh_CN
+	Data             map[string]SubscribeValue `json:"data"`                        // 模板字段
+}
+
+// WechatAPI 微信服务端接口，便于测试时替换为本地模拟实现
+type WechatAPI interface {
+	GetAccessToken() (token string, expiresIn int, err error)
+	SendSubscribeMessage(accessToken string, msg *SubscribeMessage) error
+}
+
+type wechatHTTPAPI struct {
+	baseURL   string
+	appID     string
+	appSecret string
+	client    *http.Client
+}
+
+// NewWechatAPI 创建微信服务端接口客户端，baseURL 为空时使用微信正式地址
+func NewWechatAPI(baseURL, appID, appSecret string) WechatAPI {
+	if baseURL == "" {
+		baseURL = WechatAPIBaseURL
+	}
+	return &wechatHTTPAPI{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		appID:     appID,
+		appSecret: appSecret,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type wechatResp struct {
+	ErrCode     int    `json:"errcode"`
+	ErrMsg      string `json:"errmsg"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// GetAccessToken 获取接口调用凭证
+func (w *wechatHTTPAPI) GetAccessToken() (string, int, error) {
+	query := url.Values{}
+	query.Set("grant_type", "client_credential")
+	query.Set("appid", w.appID)
+	query.Set("secret", w.appSecret)
+	resp, err := w.client.Get(w.baseURL + "/cgi-bin/token?" + query.Encode())
+	if err != nil {
+		return "", 0, fmt.Errorf("请求微信接口失败: %w", err)
+	}
+	result, err := decodeWechatResp(resp)
+	if err != nil {
+		return "", 0, err
+	}
+	return result.AccessToken, result.ExpiresIn, nil
+}
+
+// SendSubscribeMessage 发送订阅消息
+func (w *wechatHTTPAPI) SendSubscribeMessage(accessToken string, msg *SubscribeMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.baseURL+"/cgi-bin/message/subscribe/send?access_token="+url.QueryEscape(accessToken),
+		"application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("请求微信接口失败: %w", err)
+	}
+	_, err = decodeWechatResp(resp)
+	return err
+}
+
+func decodeWechatResp(resp *http.Response) (*wechatResp, error) {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("微信接口返回 HTTP %d", resp.StatusCode)
+	}
+	var result wechatResp
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析微信返回数据失败: %w", err)
+	}
+	if result.ErrCode != 0 {
+		return nil, &WechatError{Code: result.ErrCode, Msg: result.ErrMsg}
+	}
+	return &result, nil
+}
+
+// WechatTokenCache 缓存 access_token，过期前5分钟刷新；并发获取时只请求一次
+type WechatTokenCache struct {
+	api      WechatAPI
+	mu       sync.Mutex
+	token    string
+	expireAt time.Time
+}
+
+func NewWechatTokenCache(api WechatAPI) *WechatTokenCache {
+	return &WechatTokenCache{api: api}
+}
+
+// Token 获取 access_token，force 为 true 时忽略缓存重新获取(如微信返回 token 无效)
+func (c *WechatTokenCache) Token(force bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !force && c.token != "" && time.Now().Before(c.expireAt) {
+		return c.token, nil
+	}
+	token, expiresIn, err := c.api.GetAccessToken()
+	if err != nil {
+		return "", err
+	}
+	ttl := time.Duration(expiresIn)*time.Second - 5*time.Minute
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	c.token = token
+	c.expireAt = time.Now().Add(ttl)
+	return c.token, nil
+}
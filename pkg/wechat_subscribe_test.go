@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newWechatTestServer 模拟微信 /cgi-bin/token，每次返回新的 token，并统计请求次数
+func newWechatTestServer(t *testing.T, expiresIn int) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cgi-bin/token" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("appid") != "wx-app" || r.URL.Query().Get("secret") != "wx-secret" {
+			fmt.Fprint(w, `{"errcode":40013,"errmsg":"invalid appid"}`)
+			return
+		}
+		n := atomic.AddInt32(&calls, 1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":%d}`, n, expiresIn)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestWechatTokenCacheReusesToken(t *testing.T) {
+	server, calls := newWechatTestServer(t, 7200)
+	cache := NewWechatTokenCache(NewWechatAPI(server.URL, "wx-app", "wx-secret"))
+
+	for i := 0; i < 3; i++ {
+		token, err := cache.Token(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token-1" {
+			t.Fatalf("第%d次获取 got %s, want token-1", i+1, token)
+		}
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Fatalf("缓存有效期内请求了 %d 次微信接口, want 1", n)
+	}
+
+	// 强制刷新时重新获取，之后使用新 token
+	token, err := cache.Token(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token-2" {
+		t.Fatalf("强制刷新 got %s, want token-2", token)
+	}
+	if token, _ = cache.Token(false); token != "token-2" {
+		t.Fatalf("刷新后 got %s, want token-2", token)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Fatalf("请求了 %d 次微信接口, want 2", n)
+	}
+}
+
+func TestWechatTokenCacheShortExpiry(t *testing.T) {
+	// 有效期不足5分钟时按1分钟缓存，不会每次都请求
+	server, calls := newWechatTestServer(t, 60)
+	cache := NewWechatTokenCache(NewWechatAPI(server.URL, "wx-app", "wx-secret"))
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Token(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Fatalf("请求了 %d 次微信接口, want 1", n)
+	}
+}
+
+func TestWechatAPIError(t *testing.T) {
+	server, _ := newWechatTestServer(t, 7200)
+	_, _, err := NewWechatAPI(server.URL, "wx-app", "wrong").GetAccessToken()
+	var wxErr *WechatError
+	if !errors.As(err, &wxErr) || wxErr.Code != 40013 {
+		t.Fatalf("got %v, want 微信错误码 40013", err)
+	}
+	if wxErr.TokenInvalid() {
+		t.Fatal("40013 不是 token 失效")
+	}
+	for _, code := range []int{WechatErrTokenInvalid, WechatErrTokenExpired, WechatErrTokenMissing} {
+		if !(&WechatError{Code: code}).TokenInvalid() {
+			t.Fatalf("%d 应为 token 失效", code)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"cmf/paint_proj/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DashboardRepository interface {
+	CountOrdersByStatus(shopID int64, start, end time.Time) ([]model.DashboardOrderCount, error) // 期间内小程序订单按状态计数
+	CountNewUsers(shopID int64, start, end time.Time) (int64, error)                             // 期间内新增客户数
+	GetUnpaidOutboundSummary(shopID int64) (*model.DashboardUnpaid, error)                       // 未收款出库单汇总
+	CountLowStockProducts(shopID int64, threshold int) (int64, error)                            // 低库存上架商品数
+}
+
+type dashboardRepository struct {
+	db *gorm.DB
+}
+
+func NewDashboardRepository(db *gorm.DB) DashboardRepository {
+	return &dashboardRepository{db: db}
+}
+
+// CountOrdersByStatus 期间内创建的小程序订单按状态计数(不含已删除)
+func (r *dashboardRepository) CountOrdersByStatus(shopID int64, start, end time.Time) ([]model.DashboardOrderCount, error) {
+	var counts []model.DashboardOrderCount
+	db := r.db.Model(&model.Order{}).
+		Select("order_status AS status, COUNT(*) AS count").
+		Where("deleted_at IS NULL AND created_at >= ? AND created_at < ?", start, end)
+	if shopID > 0 {
+		db = db.Where("shop_id = ?", shopID)
+	}
+	err := db.Group("order_status").Order("order_status").Scan(&counts).Error
+	return counts, err
+}
+
+// CountNewUsers 期间内新增客户数
+func (r *dashboardRepository) CountNewUsers(shopID int64, start, end time.Time) (int64, error) {
+	var count int64
+	db := r.db.Model(&model.User{}).Where("created_at >= ? AND created_at < ?", start, end)
+	if shopID > 0 {
+		db = db.Where("shop_id = ?", shopID)
+	}
+	err := db.Count(&count).Error
+	return count, err
+}
+
+// GetUnpaidOutboundSummary 未收款出库单汇总：后台出库和门店收银挂账，不含已作废的和小程序订单(小程序未支付即待付款订单)
+// 金额按未结清金额统计，门店收银只计挂账部分
+func (r *dashboardRepository) GetUnpaidOutboundSummary(shopID int64) (*model.DashboardUnpaid, error) {
+	var summary model.DashboardUnpaid
+	db := r.db.Model(&model.StockOperation{}).
+		Select("COUNT(*) AS operation_count, COUNT(DISTINCT user_id) AS customer_count, "+
+			"CAST(COALESCE(SUM(unpaid_amount), 0) AS SIGNED) AS total_amount, MIN(created_at) AS oldest_at").
+		Where("types = ? AND is_voided = 0 AND outbound_type <> ? AND payment_finish_status = ?",
+			model.StockTypeOutbound, model.OutboundTypeMiniProgram, model.PaymentStatusUnpaid)
+	if shopID > 0 {
+		db = db.Where("shop_id = ?", shopID)
+	}
+	err := db.Scan(&summary).Error
+	return &summary, err
+}
+
+// CountLowStockProducts 上架的非套装商品中库存不高于阈值的数量
+func (r *dashboardRepository) CountLowStockProducts(shopID int64, threshold int) (int64, error) {
+	var count int64
+	db := r.db.Model(&model.Product{}).
+		Where("is_bundle = ? AND is_on_shelf = 1 AND stock <= ?", model.BundleNo, threshold)
+	if shopID > 0 {
+		db = db.Where("shop_id = ?", shopID)
+	}
+	err := db.Count(&count).Error
+	return count, err
+}
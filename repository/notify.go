@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"cmf/paint_proj/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotifyRepository interface {
+	// 订阅消息授权
+	AddConsent(userID int64, templateID string, result string) error
+	ConsumeConsent(userID int64, templateID string) (bool, error) // 扣减一次授权，没有剩余次数时返回 false
+
+	// 订阅消息发送记录
+	CreateWechatMessage(msg *model.WechatSubscribeMessage) error
+	UpdateWechatMessage(id int64, fields map[string]interface{}) error
+	GetWechatMessageByID(id int64) (*model.WechatSubscribeMessage, error)
+	GetWechatMessages(query *model.WechatMessageQuery) ([]model.WechatSubscribeMessage, int64, error)
+	GetDueWechatMessages(now time.Time, limit int) ([]model.WechatSubscribeMessage, error) // 到期待重试的消息
+
+	// 短信发送记录
+	CreateSmsLog(smsLog *model.SmsLog) error
+	CountSentSms(phone string, since time.Time) (int64, error)         // 手机号自 since 以来已发送的条数(限流)
+	HasSentSms(userID int64, scene string, refNo string) (bool, error) // 是否已给客户发送过同一单据的短信(去重)
+	GetSmsLogs(query *model.SmsLogQuery) ([]model.SmsLog, int64, error)
+
+	// 欠款和对账
+	GetUnpaidOutbounds(shopID, userID int64, before time.Time) ([]model.StockOperation, error)     // 未结清的后台出库单，before 为零值时不限时间
+	GetMonthlyBalances(shopID int64, start, end time.Time) ([]model.CustomerMonthlyBalance, error) // 后台客户的当月提货和未结清汇总
+
+	// 欠款提醒
+	GetPaymentReminderSetting(shopID int64) (*model.PaymentReminderSetting, error) // 未设置时返回 nil
+	SavePaymentReminderSetting(setting *model.PaymentReminderSetting) error
+	GetEnabledPaymentReminderSettings() ([]model.PaymentReminderSetting, error)
+	GetRemindedStages(operationIDs []int64, since time.Time) (map[int64]int, error) // 出库单已提醒过的最高账龄阈值(发送成功或发送中，以及 since 之后的失败、未发送)
+	CreatePaymentReminder(reminder *model.PaymentReminder) error                    // 创建提醒及其出库单明细
+	GetPaymentReminders(query *model.PaymentReminderQuery) ([]model.PaymentReminder, int64, error)
+	GetPaymentReminderByID(id int64) (*model.PaymentReminder, error) // 含出库单明细
+}
+
+type notifyRepository struct {
+	db *gorm.DB
+}
+
+func NewNotifyRepository(db *gorm.DB) NotifyRepository {
+	return &notifyRepository{db: db}
+}
+
+// AddConsent 记录一次授权结果：同意时可下发次数加一，拒绝不改变剩余次数，封禁时清零
+func (r *notifyRepository) AddConsent(userID int64, templateID string, result string) error {
+	now := time.Now()
+	consent := &model.WechatSubscribeConsent{
+		UserID:     userID,
+		TemplateID: templateID,
+		LastResult: result,
+		CreatedAt:  &now,
+		UpdatedAt:  &now,
+	}
+	updates := map[string]interface{}{
+		"last_result": result,
+		"updated_at":  &now,
+	}
+	switch result {
+	case model.SubscribeResultAccept:
+		consent.AcceptCount = 1
+		updates["accept_count"] = gorm.Expr("accept_count + 1")
+	case model.SubscribeResultBan:
+		updates["accept_count"] = 0
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "template_id"}},
+		DoUpdates: clause.Assignments(updates),
+	}).Create(consent).Error
+}
+
+// ConsumeConsent 发送前扣减一次授权，按剩余次数条件更新，并发发送时不会超发
+func (r *notifyRepository) ConsumeConsent(userID int64, templateID string) (bool, error) {
+	result := r.db.Model(&model.WechatSubscribeConsent{}).
+		Where("user_id = ? AND template_id = ? AND accept_count > 0", userID, templateID).
+		Updates(map[string]interface{}{
+			"accept_count": gorm.Expr("accept_count - 1"),
+			"updated_at":   time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CreateWechatMessage 创建订阅消息发送记录
+func (r *notifyRepository) CreateWechatMessage(msg *model.WechatSubscribeMessage) error {
+	return r.db.Create(msg).Error
+}
+
+// UpdateWechatMessage 更新订阅消息发送记录
+func (r *notifyRepository) UpdateWechatMessage(id int64, fields map[string]interface{}) error {
+	return r.db.Model(&model.WechatSubscribeMessage{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// GetWechatMessageByID 根据ID获取订阅消息发送记录
+func (r *notifyRepository) GetWechatMessageByID(id int64) (*model.WechatSubscribeMessage, error) {
+	var msg model.WechatSubscribeMessage
+	err := r.db.First(&msg, id).Error
+	return &msg, err
+}
+
+// GetWechatMessages 订阅消息发送记录列表，按时间倒序
+func (r *notifyRepository) GetWechatMessages(query *model.WechatMessageQuery) ([]model.WechatSubscribeMessage, int64, error) {
+	db := r.db.Model(&model.WechatSubscribeMessage{})
+	if query.ShopID > 0 {
+		db = db.Where("shop_id = ?", query.ShopID)
+	}
+	if query.Status != nil {
+		db = db.Where("status = ?", *query.Status)
+	}
+	if query.Scene != "" {
+		db = db.Where("scene = ?", query.Scene)
+	}
+	if query.OrderNo != "" {
+		db = db.Where("order_no = ?", query.OrderNo)
+	}
+	if query.UserID > 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var msgs []model.WechatSubscribeMessage
+	err := db.Order("id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&msgs).Error
+	return msgs, total, err
+}
+
+// GetDueWechatMessages 获取到了重试时间的待发送消息
+func (r *notifyRepository) GetDueWechatMessages(now time.Time, limit int) ([]model.WechatSubscribeMessage, error) {
+	var msgs []model.WechatSubscribeMessage
+	err := r.db.Where("status = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ?", model.NotifyStatusPending, now).
+		Order("id asc").
+		Limit(limit).
+		Find(&msgs).Error
+	return msgs, err
+}
+
+// CreateSmsLog 记录短信发送结果
+func (r *notifyRepository) CreateSmsLog(smsLog *model.SmsLog) error {
+	return r.db.Create(smsLog).Error
+}
+
+// CountSentSms 手机号自 since 以来发送成功的短信条数
+func (r *notifyRepository) CountSentSms(phone string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.SmsLog{}).
+		Where("phone = ? AND status = ? AND created_at >= ?", phone, model.NotifyStatusSent, since).
+		Count(&count).Error
+	return count, err
+}
+
+// HasSentSms 是否已给客户发送成功过同一场景、同一单据的短信
+func (r *notifyRepository) HasSentSms(userID int64, scene string, refNo string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.SmsLog{}).
+		Where("user_id = ? AND scene = ? AND ref_no = ? AND status = ?", userID, scene, refNo, model.NotifyStatusSent).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetSmsLogs 短信发送记录列表，按时间倒序
+func (r *notifyRepository) GetSmsLogs(query *model.SmsLogQuery) ([]model.SmsLog, int64, error) {
+	db := r.db.Model(&model.SmsLog{})
+	if query.ShopID > 0 {
+		db = db.Where("shop_id = ?", query.ShopID)
+	}
+	if query.Status != nil {
+		db = db.Where("status = ?", *query.Status)
+	}
+	if query.Scene != "" {
+		db = db.Where("scene = ?", query.Scene)
+	}
+	if query.Phone != "" {
+		db = db.Where("phone = ?", query.Phone)
+	}
+	if query.UserID > 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []model.SmsLog
+	err := db.Order("id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&logs).Error
+	return logs, total, err
+}
+
+// unpaidOutboundQuery 未结清的出库单：后台出库和门店收银挂账，不含已作废的和小程序订单
+func (r *notifyRepository) unpaidOutboundQuery() *gorm.DB {
+	return r.db.Model(&model.StockOperation{}).
+		Where("types = ? AND is_voided = 0 AND outbound_type <> ? AND payment_finish_status = ?",
+			model.StockTypeOutbound, model.OutboundTypeMiniProgram, model.PaymentStatusUnpaid)
+}
+
+// GetUnpaidOutbounds 客户未结清的出库单，按出库时间排序；userID 为0时查询店铺全部客户
+func (r *notifyRepository) GetUnpaidOutbounds(shopID, userID int64, before time.Time) ([]model.StockOperation, error) {
+	db := r.unpaidOutboundQuery().Where("shop_id = ? AND user_id > 0", shopID)
+	if userID > 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	if !before.IsZero() {
+		db = db.Where("created_at < ?", before)
+	}
+	var operations []model.StockOperation
+	err := db.Order("user_id asc, created_at asc, id asc").Find(&operations).Error
+	return operations, err
+}
+
+// GetMonthlyBalances 店铺后台客户(有手机号)的当月出库汇总和目前未结清汇总(按未结清金额，收银只计挂账部分)，只返回有提货或有欠款的客户
+func (r *notifyRepository) GetMonthlyBalances(shopID int64, start, end time.Time) ([]model.CustomerMonthlyBalance, error) {
+	var rows []model.CustomerMonthlyBalance
+	unpaid := fmt.Sprintf("so.payment_finish_status = %d", model.PaymentStatusUnpaid)
+	err := r.db.Table("stock_operation AS so").
+		Select("u.id AS user_id, COALESCE(NULLIF(u.admin_display_name, ''), MAX(so.user_name)) AS user_name, u.mobile_phone, "+
+			"SUM(CASE WHEN so.created_at >= ? THEN 1 ELSE 0 END) AS month_count, "+
+			"CAST(COALESCE(SUM(CASE WHEN so.created_at >= ? THEN so.total_amount ELSE 0 END), 0) AS SIGNED) AS month_amount, "+
+			"SUM(CASE WHEN "+unpaid+" THEN 1 ELSE 0 END) AS unpaid_count, "+
+			"CAST(COALESCE(SUM(CASE WHEN "+unpaid+" THEN so.unpaid_amount ELSE 0 END), 0) AS SIGNED) AS unpaid_amount",
+			start, start).
+		Joins("JOIN user u ON u.id = so.user_id").
+		Where("so.shop_id = ? AND so.types = ? AND so.is_voided = 0 AND so.outbound_type <> ? AND so.created_at < ?",
+			shopID, model.StockTypeOutbound, model.OutboundTypeMiniProgram, end).
+		Where("(so.created_at >= ? OR "+unpaid+")", start).
+		Where("u.source = ? AND u.mobile_phone <> ''", model.UserSourceAdmin).
+		Group("u.id, u.admin_display_name, u.mobile_phone").
+		Order("u.id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetPaymentReminderSetting 店铺欠款提醒设置，未设置时返回 nil
+func (r *notifyRepository) GetPaymentReminderSetting(shopID int64) (*model.PaymentReminderSetting, error) {
+	var setting model.PaymentReminderSetting
+	err := r.db.Where("shop_id = ?", shopID).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// SavePaymentReminderSetting 保存店铺欠款提醒设置(每个店铺一条)
+func (r *notifyRepository) SavePaymentReminderSetting(setting *model.PaymentReminderSetting) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "shop_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "thresholds", "send_hour", "channels", "min_amount", "updated_by", "updated_at"}),
+	}).Create(setting).Error
+}
+
+// GetEnabledPaymentReminderSettings 已启用欠款提醒的店铺设置
+func (r *notifyRepository) GetEnabledPaymentReminderSettings() ([]model.PaymentReminderSetting, error) {
+	var settings []model.PaymentReminderSetting
+	err := r.db.Where("enabled = 1").Order("shop_id").Find(&settings).Error
+	return settings, err
+}
+
+// GetRemindedStages 出库单已提醒过的最高账龄阈值，用于同一阈值不重复提醒；
+// 只有渠道发送成功或发送中的提醒计入，失败和未发送的提醒只在 since 之后计入(之后会重新提醒)
+func (r *notifyRepository) GetRemindedStages(operationIDs []int64, since time.Time) (map[int64]int, error) {
+	stages := make(map[int64]int)
+	if len(operationIDs) == 0 {
+		return stages, nil
+	}
+	var rows []struct {
+		OperationID int64
+		Stage       int
+	}
+	err := r.db.Table("payment_reminder_item pri").
+		Select("pri.operation_id, MAX(pri.stage) AS stage").
+		Joins("INNER JOIN payment_reminder pr ON pr.id = pri.reminder_id").
+		Where("pri.operation_id IN ?", operationIDs).
+		Where("pr.status IN ? OR pr.created_at >= ?", []int8{model.NotifyStatusSent, model.NotifyStatusPending}, since).
+		Group("pri.operation_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		stages[row.OperationID] = row.Stage
+	}
+	return stages, nil
+}
+
+// CreatePaymentReminder 在事务中创建欠款提醒和出库单明细
+func (r *notifyRepository) CreatePaymentReminder(reminder *model.PaymentReminder) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(reminder).Error; err != nil {
+			return err
+		}
+		for i := range reminder.Items {
+			reminder.Items[i].ReminderID = reminder.ID
+		}
+		if len(reminder.Items) == 0 {
+			return nil
+		}
+		return tx.Create(&reminder.Items).Error
+	})
+}
+
+// paymentReminderColumns 提醒记录字段，订阅消息状态取发送记录的最新状态(失败后可能已重试成功)
+const paymentReminderColumns = "payment_reminder.id, payment_reminder.shop_id, payment_reminder.user_id, payment_reminder.user_name, " +
+	"payment_reminder.phone, payment_reminder.stage, payment_reminder.operation_count, payment_reminder.total_amount, " +
+	"payment_reminder.oldest_at, payment_reminder.oldest_operation_no, payment_reminder.status, payment_reminder.wechat_message_id, " +
+	"COALESCE(wm.status, payment_reminder.wechat_status) AS wechat_status, payment_reminder.sms_log_id, payment_reminder.sms_status, " +
+	"payment_reminder.error, payment_reminder.operator, payment_reminder.created_at"
+
+// GetPaymentReminders 欠款提醒记录，按时间倒序
+func (r *notifyRepository) GetPaymentReminders(query *model.PaymentReminderQuery) ([]model.PaymentReminder, int64, error) {
+	db := r.db.Model(&model.PaymentReminder{})
+	if query.ShopID > 0 {
+		db = db.Where("payment_reminder.shop_id = ?", query.ShopID)
+	}
+	if query.UserID > 0 {
+		db = db.Where("payment_reminder.user_id = ?", query.UserID)
+	}
+	if query.Status != nil {
+		db = db.Where("payment_reminder.status = ?", *query.Status)
+	}
+	if query.StartTime != nil {
+		db = db.Where("payment_reminder.created_at >= ?", *query.StartTime)
+	}
+	if query.EndTime != nil {
+		db = db.Where("payment_reminder.created_at < ?", *query.EndTime)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var reminders []model.PaymentReminder
+	err := db.Select(paymentReminderColumns).
+		Joins("LEFT JOIN wechat_subscribe_message wm ON wm.id = payment_reminder.wechat_message_id").
+		Order("payment_reminder.id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Scan(&reminders).Error
+	return reminders, total, err
+}
+
+// GetPaymentReminderByID 欠款提醒详情，含出库单明细
+func (r *notifyRepository) GetPaymentReminderByID(id int64) (*model.PaymentReminder, error) {
+	var reminder model.PaymentReminder
+	err := r.db.Model(&model.PaymentReminder{}).
+		Select(paymentReminderColumns).
+		Joins("LEFT JOIN wechat_subscribe_message wm ON wm.id = payment_reminder.wechat_message_id").
+		Where("payment_reminder.id = ?", id).
+		Take(&reminder).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.Where("reminder_id = ?", id).Order("operation_at asc, id asc").Find(&reminder.Items).Error
+	return &reminder, err
+}
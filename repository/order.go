@@ -2,15 +2,21 @@ package repository
 
 import (
 	"cmf/paint_proj/model"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository interface {
 	GetOrderList(req *model.OrderListRequest) ([]model.Order, int64, error)
 	GetOrderByNo(userID int64, shopID int64, orderNo string) (*model.Order, error)
 	GetOrderByOrderNo(orderNo string) (*model.Order, error)
+	GetOrderByID(orderID int64) (*model.Order, error)
+	MarkOrderPaid(order *model.Order, log *model.OrderLog) (paid bool, refund bool, err error) // 待付款订单更新为已付款；订单已取消等非待付款状态时记录支付并标记退款中(refund 为 true)；重复通知时都返回 false
+	GetAdminOrderList(req *model.AdminOrderListRequest) ([]model.Order, int64, error)
+	UpdateOrderStatus(order *model.Order, from, to model.OrderStatusCode, log *model.OrderLog) (bool, error) // 按当前状态条件更新，状态已变化时返回 false
 
 	DeleteOrder(orderID int64, order *model.Order, orderLog *model.OrderLog) error
 	CancelOrder(userID int64, order *model.Order, orderLog *model.OrderLog) error
@@ -70,6 +76,127 @@ func (or *orderRepository) GetOrderByOrderNo(orderNo string) (*model.Order, erro
 	}
 	return &order, nil
 }
+
+// GetOrderByID 根据ID获取订单
+func (or *orderRepository) GetOrderByID(orderID int64) (*model.Order, error) {
+	var order model.Order
+	err := or.db.Model(&model.Order{}).Where("id = ?", orderID).First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// MarkOrderPaid 订单支付成功：更新订单支付信息和状态、订单出库单的支付状态，并记录订单日志。
+// 只有待付款的订单才改为已付款；用户已取消等情况下收到支付时不改订单状态，记录支付信息并将支付状态标记为退款中，订单日志记录冲突
+func (or *orderRepository) MarkOrderPaid(order *model.Order, log *model.OrderLog) (paid bool, refund bool, err error) {
+	err = or.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Order{}).
+			Where("id = ? AND order_status = ? AND payment_status <> ?", order.ID, model.OrderStatusPendingPayment, model.PaymentStatusPaid).
+			Updates(map[string]interface{}{
+				"payment_status": model.PaymentStatusPaid,
+				"order_status":   model.OrderStatusPaymentSuccess,
+				"payment_type":   order.PaymentType,
+				"payment_time":   order.PaymentTime,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return or.markPaidRefund(tx, order, log, &refund)
+		}
+		paid = true
+
+		if err := tx.Model(&model.StockOperation{}).
+			Where("id IN (?)", tx.Model(&model.StockOperationItem{}).Select("operation_id").Where("order_id = ?", order.ID)).
+			Updates(map[string]interface{}{
+				"payment_finish_status": model.PaymentStatusPaid,
+				"payment_finish_time":   order.PaymentTime,
+				"unpaid_amount":         0,
+			}).Error; err != nil {
+			return err
+		}
+
+		log.OrderId = order.ID
+		return tx.Model(&model.OrderLog{}).Create(log).Error
+	})
+	return paid, refund, err
+}
+
+// markPaidRefund 订单不是待付款状态时收到支付：已付款、退款中、已退款视为重复通知不处理；
+// 否则记录支付方式和时间，支付状态改为退款中，订单状态保持不变，并在订单日志中记录冲突
+func (or *orderRepository) markPaidRefund(tx *gorm.DB, order *model.Order, log *model.OrderLog, refund *bool) error {
+	var current model.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", order.ID).First(&current).Error; err != nil {
+		return err
+	}
+	switch current.PaymentStatus {
+	case model.PaymentStatusPaid, model.PaymentStatusRefunding, model.PaymentStatusRefunded:
+		return nil
+	}
+	if err := tx.Model(&model.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+		"payment_status": model.PaymentStatusRefunding,
+		"payment_type":   order.PaymentType,
+		"payment_time":   order.PaymentTime,
+	}).Error; err != nil {
+		return err
+	}
+	*refund = true
+	order.OrderStatus = current.OrderStatus
+
+	log.OrderId = order.ID
+	log.Action = "pay_refund_required"
+	log.Content = fmt.Sprintf("订单状态为 %d(非待付款)时收到支付，订单状态不变，需退款；%s", current.OrderStatus, log.Content)
+	return tx.Model(&model.OrderLog{}).Create(log).Error
+}
+
+// GetAdminOrderList 后台订单列表，shopID 为0时查询全部店铺
+func (or *orderRepository) GetAdminOrderList(req *model.AdminOrderListRequest) ([]model.Order, int64, error) {
+	db := or.db.Model(&model.Order{}).Where("deleted_at IS NULL")
+	if req.ShopID > 0 {
+		db = db.Where("shop_id = ?", req.ShopID)
+	}
+	if req.Status > 0 {
+		db = db.Where("order_status = ?", req.Status)
+	}
+	if req.OrderNo != "" {
+		db = db.Where("order_no = ?", req.OrderNo)
+	}
+	if req.UserID > 0 {
+		db = db.Where("user_id = ?", req.UserID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	orders := make([]model.Order, 0)
+	err := db.Order("id desc").Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize).Find(&orders).Error
+	return orders, total, err
+}
+
+// UpdateOrderStatus 订单状态流转(如发货、备货待自提)：只更新处于 from 状态的订单，并记录订单日志
+func (or *orderRepository) UpdateOrderStatus(order *model.Order, from, to model.OrderStatusCode, log *model.OrderLog) (bool, error) {
+	updated := false
+	err := or.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Order{}).
+			Where("id = ? AND order_status = ?", order.ID, from).
+			Updates(map[string]interface{}{
+				"order_status": to,
+				"updated_at":   time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		updated = true
+		log.OrderId = order.ID
+		return tx.Model(&model.OrderLog{}).Create(log).Error
+	})
+	return updated, err
+}
 func (or *orderRepository) DeleteOrder(userID int64, order *model.Order, orderLog *model.OrderLog) error {
 	err := or.db.Transaction(func(tx *gorm.DB) error {
 		// 1.更新订单状态
@@ -141,20 +268,21 @@ func (or *orderRepository) ProcessCheckoutTransaction(order *model.Order, operat
 			return err
 		}
 
-		// 4. 处理库存出库和创建子表记录
+		// 4. 处理库存出库，再按实际库存创建子表记录
 		for _, item := range operationItems {
-			// 更新库存（出库为负数）
-			if err := tx.Model(&model.Product{}).
-				Where("id = ?", item.ProductID).
-				Update("stock", gorm.Expr("stock - ?", item.Quantity)).Error; err != nil {
-				return err
-			}
-
-			// 设置关联ID并创建子表记录
+			// 设置关联ID
 			item.OperationID = operation.ID
+			item.ShopID = operation.ShopID
 			item.OrderID = order.ID
 			item.OrderNo = order.OrderNo
 
+			// 更新库存并记录库存日志（出库为负数，套装明细不变动库存，由组件明细扣减）
+			if item.IsBundle != model.BundleYes {
+				if err := changeStockWithLog(tx, operation, &item, -item.Quantity); err != nil {
+					return err
+				}
+			}
+
 			if err := tx.Create(&item).Error; err != nil {
 				return err
 			}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"cmf/paint_proj/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PosRepository interface {
+	// 收银班次
+	CreateShift(shift *model.PosShift) error
+	GetShiftByID(id int64) (*model.PosShift, error)
+	GetOpenShift(shopID int64, operatorID int64) (*model.PosShift, error)
+	GetShiftTotals(shiftID int64) (*model.PosShift, error) // 汇总班次内已结账收银单的金额
+	CloseShift(shift *model.PosShift) error
+	GetShifts(page, pageSize int, shopID int64, operatorID int64) ([]model.PosShift, int64, error)
+
+	// 收银单
+	CreateSale(sale *model.PosSale) error
+	GetSaleByID(id int64) (*model.PosSale, error)
+	GetSaleItems(saleID int64) ([]model.PosSaleItem, error)
+	GetSales(page, pageSize int, shopID int64, status int8) ([]model.PosSale, int64, error)
+	GetSalesByShift(shiftID int64) ([]model.PosSale, error)
+	UpdateSale(id int64, fields map[string]interface{}) error
+	SaveSaleItems(saleID int64, items []model.PosSaleItem, deleteIDs []int64, totalAmount model.Amount) error // 保存明细并更新应收金额
+	CompleteSale(sale *model.PosSale, operation *model.StockOperation) error                                  // 生成出库单并结账
+	CancelSale(id int64) error
+
+	// 客户协议价
+	GetCustomerPrices(shopID int64, userID int64) ([]model.CustomerPrice, error)
+	GetCustomerPriceByID(id int64) (*model.CustomerPrice, error)
+	SaveCustomerPrice(price *model.CustomerPrice) error
+	DeleteCustomerPrice(id int64) error
+}
+
+type posRepository struct {
+	db *gorm.DB
+}
+
+func NewPosRepository(db *gorm.DB) PosRepository {
+	return &posRepository{db: db}
+}
+
+// CreateShift 开班
+func (r *posRepository) CreateShift(shift *model.PosShift) error {
+	return r.db.Create(shift).Error
+}
+
+// GetShiftByID 根据ID获取班次
+func (r *posRepository) GetShiftByID(id int64) (*model.PosShift, error) {
+	var shift model.PosShift
+	err := r.db.First(&shift, id).Error
+	return &shift, err
+}
+
+// GetOpenShift 获取收银员在店铺当班的班次，没有时返回 gorm.ErrRecordNotFound
+func (r *posRepository) GetOpenShift(shopID int64, operatorID int64) (*model.PosShift, error) {
+	var shift model.PosShift
+	err := r.db.Where("shop_id = ? AND operator_id = ? AND status = ?", shopID, operatorID, model.PosShiftOpen).
+		Order("id desc").First(&shift).Error
+	return &shift, err
+}
+
+// GetShiftTotals 汇总班次内已结账收银单的金额，现金收款已扣除找零
+func (r *posRepository) GetShiftTotals(shiftID int64) (*model.PosShift, error) {
+	var totals model.PosShift
+	err := r.db.Model(&model.PosSale{}).
+		Select("COUNT(*) AS sale_count, COALESCE(SUM(total_amount), 0) AS sales_amount, "+
+			"COALESCE(SUM(paid_cash - change_amount), 0) AS cash_sales, "+
+			"COALESCE(SUM(paid_wechat), 0) AS wechat_sales, COALESCE(SUM(paid_credit), 0) AS credit_sales").
+		Where("shift_id = ? AND status = ?", shiftID, model.PosSaleCompleted).
+		Scan(&totals).Error
+	return &totals, err
+}
+
+// CloseShift 交班，保存汇总和点钞结果
+func (r *posRepository) CloseShift(shift *model.PosShift) error {
+	result := r.db.Model(&model.PosShift{}).
+		Where("id = ? AND status = ?", shift.ID, model.PosShiftOpen).
+		Updates(map[string]interface{}{
+			"status":        model.PosShiftClosed,
+			"sale_count":    shift.SaleCount,
+			"sales_amount":  shift.SalesAmount,
+			"cash_sales":    shift.CashSales,
+			"wechat_sales":  shift.WechatSales,
+			"credit_sales":  shift.CreditSales,
+			"expected_cash": shift.ExpectedCash,
+			"counted_cash":  shift.CountedCash,
+			"difference":    shift.Difference,
+			"remark":        shift.Remark,
+			"closed_at":     shift.ClosedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("班次已交班")
+	}
+	return nil
+}
+
+// GetShifts 班次列表，operatorID 为0时不按收银员筛选
+func (r *posRepository) GetShifts(page, pageSize int, shopID int64, operatorID int64) ([]model.PosShift, int64, error) {
+	var shifts []model.PosShift
+	var total int64
+	query := r.db.Model(&model.PosShift{})
+	if shopID > 0 {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if operatorID > 0 {
+		query = query.Where("operator_id = ?", operatorID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&shifts).Error
+	return shifts, total, err
+}
+
+// CreateSale 开收银单
+func (r *posRepository) CreateSale(sale *model.PosSale) error {
+	return r.db.Create(sale).Error
+}
+
+// GetSaleByID 根据ID获取收银单(不含明细)
+func (r *posRepository) GetSaleByID(id int64) (*model.PosSale, error) {
+	var sale model.PosSale
+	err := r.db.First(&sale, id).Error
+	return &sale, err
+}
+
+// GetSaleItems 获取收银明细
+func (r *posRepository) GetSaleItems(saleID int64) ([]model.PosSaleItem, error) {
+	var items []model.PosSaleItem
+	err := r.db.Where("sale_id = ?", saleID).Order("id asc").Find(&items).Error
+	return items, err
+}
+
+// GetSales 收银单列表，status 为0时不按状态筛选
+func (r *posRepository) GetSales(page, pageSize int, shopID int64, status int8) ([]model.PosSale, int64, error) {
+	var sales []model.PosSale
+	var total int64
+	query := r.db.Model(&model.PosSale{})
+	if shopID > 0 {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if status > 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&sales).Error
+	return sales, total, err
+}
+
+// GetSalesByShift 获取班次内已结账的收银单
+func (r *posRepository) GetSalesByShift(shiftID int64) ([]model.PosSale, error) {
+	var sales []model.PosSale
+	err := r.db.Where("shift_id = ? AND status = ?", shiftID, model.PosSaleCompleted).Order("id asc").Find(&sales).Error
+	return sales, err
+}
+
+// UpdateSale 更新收银中的收银单
+func (r *posRepository) UpdateSale(id int64, fields map[string]interface{}) error {
+	return r.db.Model(&model.PosSale{}).Where("id = ? AND status = ?", id, model.PosSaleOpen).Updates(fields).Error
+}
+
+// SaveSaleItems 新增或更新收银明细、删除明细，并更新收银单应收金额
+func (r *posRepository) SaveSaleItems(saleID int64, items []model.PosSaleItem, deleteIDs []int64, totalAmount model.Amount) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.PosSale{}).Where("id = ? AND status = ?", saleID, model.PosSaleOpen).
+			Update("total_amount", totalAmount)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 金额未变化时 RowsAffected 也为0，需区分收银单是否仍在收银中
+			var count int64
+			if err := tx.Model(&model.PosSale{}).Where("id = ? AND status = ?", saleID, model.PosSaleOpen).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return errors.New("收银单已结账或已取消")
+			}
+		}
+		if len(deleteIDs) > 0 {
+			if err := tx.Where("sale_id = ? AND id IN ?", saleID, deleteIDs).Delete(&model.PosSaleItem{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range items {
+			items[i].SaleID = saleID
+			if err := tx.Save(&items[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CompleteSale 结账：生成出库单(扣减库存)并更新收银单的收款信息
+func (r *posRepository) CompleteSale(sale *model.PosSale, operation *model.StockOperation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定商品后校验库存，与扣减在同一事务中，并发结账不会超卖
+		if err := lockAndCheckStock(tx, operation.Items); err != nil {
+			return err
+		}
+		if err := createOutboundOperation(tx, operation); err != nil {
+			return err
+		}
+		sale.OperationID = operation.ID
+		result := tx.Model(&model.PosSale{}).
+			Where("id = ? AND status = ?", sale.ID, model.PosSaleOpen).
+			Updates(map[string]interface{}{
+				"status":        model.PosSaleCompleted,
+				"shift_id":      sale.ShiftID,
+				"operator_id":   sale.OperatorID,
+				"operator":      sale.Operator,
+				"total_amount":  sale.TotalAmount,
+				"paid_cash":     sale.PaidCash,
+				"paid_wechat":   sale.PaidWechat,
+				"paid_credit":   sale.PaidCredit,
+				"change_amount": sale.ChangeAmount,
+				"operation_id":  sale.OperationID,
+				"remark":        sale.Remark,
+				"completed_at":  sale.CompletedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("收银单已结账或已取消")
+		}
+		return nil
+	})
+}
+
+// CancelSale 取消收银中的收银单
+func (r *posRepository) CancelSale(id int64) error {
+	result := r.db.Model(&model.PosSale{}).Where("id = ? AND status = ?", id, model.PosSaleOpen).
+		Update("status", model.PosSaleCancelled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("收银单已结账或已取消")
+	}
+	return nil
+}
+
+// GetCustomerPrices 获取客户在店铺的协议价
+func (r *posRepository) GetCustomerPrices(shopID int64, userID int64) ([]model.CustomerPrice, error) {
+	var prices []model.CustomerPrice
+	err := r.db.Where("shop_id = ? AND user_id = ?", shopID, userID).Order("product_id asc").Find(&prices).Error
+	return prices, err
+}
+
+// GetCustomerPriceByID 根据ID获取协议价
+func (r *posRepository) GetCustomerPriceByID(id int64) (*model.CustomerPrice, error) {
+	var price model.CustomerPrice
+	err := r.db.First(&price, id).Error
+	return &price, err
+}
+
+// SaveCustomerPrice 新增或更新客户某个商品的协议价
+func (r *posRepository) SaveCustomerPrice(price *model.CustomerPrice) error {
+	now := time.Now()
+	price.UpdatedAt = &now
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "shop_id"}, {Name: "user_id"}, {Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "operator", "operator_id", "updated_at"}),
+	}).Create(price).Error
+}
+
+// DeleteCustomerPrice 删除协议价
+func (r *posRepository) DeleteCustomerPrice(id int64) error {
+	return r.db.Delete(&model.CustomerPrice{}, id).Error
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"cmf/paint_proj/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PriceRepository interface {
+	GetProductsForAdjustment(shopID int64, categoryIDs []int64, supplier string, productIDs []int64) ([]model.Product, error) // 查询调价范围内的商品
+	ApplyAdjustment(adjustment *model.PriceAdjustment, productIDs []int64, compute PriceChangeFunc) error                     // 执行调价(事务)
+	CreateAdjustment(adjustment *model.PriceAdjustment) error
+	GetAdjustmentByID(id int64) (*model.PriceAdjustment, error)
+	GetAdjustmentList(offset, limit int, shopID int64, status int8) ([]model.PriceAdjustment, int64, error)
+	GetDueAdjustments(now time.Time) ([]model.PriceAdjustment, error) // 获取到期待生效的调价单
+	UpdateAdjustmentStatus(id int64, fromStatus int8, toStatus int8, failReason string) (bool, error)
+	GetPriceHistory(productID int64, offset, limit int) ([]model.ProductPriceHistory, int64, error)
+}
+
+// PriceChangeFunc 按锁定后的商品计算需要调价的商品及调价结果
+type PriceChangeFunc func(products []model.Product) ([]model.PriceChangeItem, error)
+
+type priceRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceRepository(db *gorm.DB) PriceRepository {
+	return &priceRepository{db: db}
+}
+
+// createPriceHistory 写入价格变动记录，供商品编辑、导入、入库、调价等事务复用
+func createPriceHistory(tx *gorm.DB, histories []model.ProductPriceHistory) error {
+	if len(histories) == 0 {
+		return nil
+	}
+	return tx.Create(&histories).Error
+}
+
+// GetProductsForAdjustment 查询调价范围内的商品，分类、供货商、商品ID按传入的条件筛选
+func (pr *priceRepository) GetProductsForAdjustment(shopID int64, categoryIDs []int64, supplier string, productIDs []int64) ([]model.Product, error) {
+	var products []model.Product
+	query := pr.db.Model(&model.Product{}).Where("shop_id = ?", shopID)
+	if len(categoryIDs) > 0 {
+		query = query.Where("category_id IN ?", categoryIDs)
+	}
+	if supplier != "" {
+		// 该供货商在本店入库过的商品
+		subQuery := pr.db.Table("stock_operation_item soi").
+			Select("DISTINCT soi.product_id").
+			Joins("INNER JOIN stock_operation so ON soi.operation_id = so.id").
+			Where("so.types = ? AND so.supplier = ? AND so.shop_id = ?", model.StockTypeInbound, supplier, shopID)
+		query = query.Where("id IN (?)", subQuery)
+	}
+	if len(productIDs) > 0 {
+		query = query.Where("id IN ?", productIDs)
+	}
+	if err := query.Order("id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ApplyAdjustment 执行调价：按商品ID顺序锁定商品，由 compute 按锁定后的价格重新计算，再更新商品价格并写入价格变动记录，
+// 计算之后的编辑、入库等改价不会被覆盖；调价单ID为0时新建一条已生效的调价单，否则将待生效的调价单置为已生效（防止重复执行）
+func (pr *priceRepository) ApplyAdjustment(adjustment *model.PriceAdjustment, productIDs []int64, compute PriceChangeFunc) error {
+	return pr.db.Transaction(func(tx *gorm.DB) error {
+		// 1. 锁定商品并重新计算
+		var products []model.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", productIDs).
+			Order("id asc").
+			Find(&products).Error; err != nil {
+			return err
+		}
+		items, err := compute(products)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		adjustment.Status = model.PriceAdjustmentApplied
+		adjustment.AppliedAt = &now
+		adjustment.ProductCount = len(items)
+
+		// 2. 创建或锁定调价单
+		if adjustment.ID == 0 {
+			if err := tx.Create(adjustment).Error; err != nil {
+				return err
+			}
+		} else {
+			result := tx.Model(&model.PriceAdjustment{}).
+				Where("id = ? AND status = ?", adjustment.ID, model.PriceAdjustmentPending).
+				Updates(map[string]interface{}{
+					"status":        model.PriceAdjustmentApplied,
+					"applied_at":    now,
+					"product_count": len(items),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("调价单已执行或已取消")
+			}
+		}
+
+		// 3. 更新商品价格
+		histories := make([]model.ProductPriceHistory, 0, len(items))
+		for _, item := range items {
+			fields := map[string]interface{}{"seller_price": item.NewSellerPrice}
+			if item.NewCost != item.OldCost {
+				fields["cost"] = item.NewCost
+				fields["product_cost"] = item.NewProductCost
+			}
+			if err := tx.Model(&model.Product{}).Where("id = ?", item.ProductID).Updates(fields).Error; err != nil {
+				return err
+			}
+			histories = append(histories, model.ProductPriceHistory{
+				ProductID:      item.ProductID,
+				ShopID:         item.ShopID,
+				ProductName:    item.ProductName,
+				OldSellerPrice: item.OldSellerPrice,
+				NewSellerPrice: item.NewSellerPrice,
+				OldCost:        item.OldCost,
+				NewCost:        item.NewCost,
+				Source:         model.PriceSourceAdjustment,
+				AdjustmentID:   adjustment.ID,
+				Operator:       adjustment.Operator,
+				OperatorID:     adjustment.OperatorID,
+				Remark:         adjustment.Remark,
+			})
+		}
+
+		// 4. 写入价格变动记录
+		return createPriceHistory(tx, histories)
+	})
+}
+
+// CreateAdjustment 创建调价单（定时生效）
+func (pr *priceRepository) CreateAdjustment(adjustment *model.PriceAdjustment) error {
+	return pr.db.Create(adjustment).Error
+}
+
+// GetAdjustmentByID 根据ID获取调价单
+func (pr *priceRepository) GetAdjustmentByID(id int64) (*model.PriceAdjustment, error) {
+	var adjustment model.PriceAdjustment
+	if err := pr.db.Where("id = ?", id).First(&adjustment).Error; err != nil {
+		return nil, err
+	}
+	return &adjustment, nil
+}
+
+// GetAdjustmentList 分页获取调价单列表，shopID、status为0时不筛选
+func (pr *priceRepository) GetAdjustmentList(offset, limit int, shopID int64, status int8) ([]model.PriceAdjustment, int64, error) {
+	var adjustments []model.PriceAdjustment
+	var total int64
+
+	query := pr.db.Model(&model.PriceAdjustment{})
+	if shopID > 0 {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if status > 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&adjustments).Error; err != nil {
+		return nil, 0, err
+	}
+	return adjustments, total, nil
+}
+
+// GetDueAdjustments 获取到期待生效的调价单
+func (pr *priceRepository) GetDueAdjustments(now time.Time) ([]model.PriceAdjustment, error) {
+	var adjustments []model.PriceAdjustment
+	if err := pr.db.Where("status = ? AND effective_at <= ?", model.PriceAdjustmentPending, now).
+		Order("effective_at, id").
+		Find(&adjustments).Error; err != nil {
+		return nil, err
+	}
+	return adjustments, nil
+}
+
+// UpdateAdjustmentStatus 按原状态更新调价单状态，返回是否更新成功
+func (pr *priceRepository) UpdateAdjustmentStatus(id int64, fromStatus int8, toStatus int8, failReason string) (bool, error) {
+	result := pr.db.Model(&model.PriceAdjustment{}).
+		Where("id = ? AND status = ?", id, fromStatus).
+		Updates(map[string]interface{}{
+			"status":      toStatus,
+			"fail_reason": failReason,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetPriceHistory 分页获取商品价格变动记录
+func (pr *priceRepository) GetPriceHistory(productID int64, offset, limit int) ([]model.ProductPriceHistory, int64, error) {
+	var histories []model.ProductPriceHistory
+	var total int64
+
+	query := pr.db.Model(&model.ProductPriceHistory{}).Where("product_id = ?", productID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&histories).Error; err != nil {
+		return nil, 0, err
+	}
+	return histories, total, nil
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"cmf/paint_proj/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PrintRepository interface {
+	// 打印机
+	CreatePrinter(printer *model.Printer) error
+	GetPrinterByID(id int64) (*model.Printer, error)
+	GetPrinterByToken(token string) (*model.Printer, error)
+	GetPrinters(shopID int64) ([]model.Printer, error)
+	GetAutoPrinters(shopID int64) ([]model.Printer, error)
+	UpdatePrinter(id int64, fields map[string]interface{}) error
+	DeletePrinter(id int64) error
+
+	// 打印任务
+	CreateJob(job *model.PrintJob) error
+	GetJobByID(id int64) (*model.PrintJob, error)
+	GetJobs(page, pageSize int, shopID int64, status int8) ([]model.PrintJob, int64, error)
+	ClaimNextJob(printer *model.Printer, staleBefore time.Time, maxAttempts int) (*model.PrintJob, error) // 领取下一个待打印任务，没有时返回 nil
+	SaveJobContent(id int64, content []byte) error
+	FinishJob(id int64, printerID int64, fields map[string]interface{}) error
+	RetryJob(id int64) error
+}
+
+type printRepository struct {
+	db *gorm.DB
+}
+
+func NewPrintRepository(db *gorm.DB) PrintRepository {
+	return &printRepository{db: db}
+}
+
+// CreatePrinter 添加打印机
+func (r *printRepository) CreatePrinter(printer *model.Printer) error {
+	return r.db.Create(printer).Error
+}
+
+// GetPrinterByID 根据ID获取打印机
+func (r *printRepository) GetPrinterByID(id int64) (*model.Printer, error) {
+	var printer model.Printer
+	err := r.db.First(&printer, id).Error
+	return &printer, err
+}
+
+// GetPrinterByToken 根据轮询令牌获取启用的打印机
+func (r *printRepository) GetPrinterByToken(token string) (*model.Printer, error) {
+	var printer model.Printer
+	err := r.db.Where("token = ? AND is_active = 1", token).First(&printer).Error
+	return &printer, err
+}
+
+// GetPrinters 店铺的打印机列表，shopID 为0时返回全部
+func (r *printRepository) GetPrinters(shopID int64) ([]model.Printer, error) {
+	var printers []model.Printer
+	query := r.db.Model(&model.Printer{})
+	if shopID > 0 {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	err := query.Order("id asc").Find(&printers).Error
+	return printers, err
+}
+
+// GetAutoPrinters 店铺启用且开启自动打印的打印机
+func (r *printRepository) GetAutoPrinters(shopID int64) ([]model.Printer, error) {
+	var printers []model.Printer
+	err := r.db.Where("shop_id = ? AND is_active = 1 AND auto_print = 1", shopID).Order("id asc").Find(&printers).Error
+	return printers, err
+}
+
+// UpdatePrinter 更新打印机
+func (r *printRepository) UpdatePrinter(id int64, fields map[string]interface{}) error {
+	return r.db.Model(&model.Printer{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// DeletePrinter 删除打印机，指定该打印机的待打印任务改为店铺任一打印机打印
+func (r *printRepository) DeletePrinter(id int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.PrintJob{}).
+			Where("printer_id = ? AND status IN ?", id, []int8{model.PrintJobPending, model.PrintJobPrinting}).
+			Updates(map[string]interface{}{"printer_id": 0, "status": model.PrintJobPending}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Printer{}, id).Error
+	})
+}
+
+// CreateJob 创建打印任务
+func (r *printRepository) CreateJob(job *model.PrintJob) error {
+	return r.db.Create(job).Error
+}
+
+// GetJobByID 根据ID获取打印任务
+func (r *printRepository) GetJobByID(id int64) (*model.PrintJob, error) {
+	var job model.PrintJob
+	err := r.db.First(&job, id).Error
+	return &job, err
+}
+
+// GetJobs 打印任务列表(不含指令内容)，status 为0时不按状态筛选
+func (r *printRepository) GetJobs(page, pageSize int, shopID int64, status int8) ([]model.PrintJob, int64, error) {
+	var jobs []model.PrintJob
+	var total int64
+	query := r.db.Model(&model.PrintJob{})
+	if shopID > 0 {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if status > 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Omit("content").Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// ClaimNextJob 打印机领取店铺下一个任务：待打印的任务，或领取后超时未回报且未超过重试次数的任务
+// 超时未回报且已达到重试次数的任务先标记为失败，不会一直停留在打印中
+func (r *printRepository) ClaimNextJob(printer *model.Printer, staleBefore time.Time, maxAttempts int) (*model.PrintJob, error) {
+	var job model.PrintJob
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.PrintJob{}).
+			Where("shop_id = ? AND status = ? AND claimed_at < ? AND attempts >= ?",
+				printer.ShopID, model.PrintJobPrinting, staleBefore, maxAttempts).
+			Updates(map[string]interface{}{
+				"status": model.PrintJobFailed,
+				"error":  "打印机超时未回报结果，已超过重试次数",
+			}).Error; err != nil {
+			return err
+		}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Omit("content").
+			Where("shop_id = ? AND printer_id IN ?", printer.ShopID, []int64{0, printer.ID}).
+			Where("(status = ? OR (status = ? AND claimed_at < ? AND attempts < ?))",
+				model.PrintJobPending, model.PrintJobPrinting, staleBefore, maxAttempts).
+			Order("id asc").First(&job).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		job.PrinterID = printer.ID
+		job.Status = model.PrintJobPrinting
+		job.Attempts++
+		job.ClaimedAt = &now
+		return tx.Model(&model.PrintJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"printer_id": job.PrinterID,
+			"status":     job.Status,
+			"attempts":   job.Attempts,
+			"claimed_at": job.ClaimedAt,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// SaveJobContent 保存领取时生成的 ESC/POS 指令
+func (r *printRepository) SaveJobContent(id int64, content []byte) error {
+	return r.db.Model(&model.PrintJob{}).Where("id = ?", id).Update("content", content).Error
+}
+
+// FinishJob 打印机回报打印结果，只能回报自己领取的打印中任务
+func (r *printRepository) FinishJob(id int64, printerID int64, fields map[string]interface{}) error {
+	result := r.db.Model(&model.PrintJob{}).
+		Where("id = ? AND printer_id = ? AND status = ?", id, printerID, model.PrintJobPrinting).
+		Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("打印任务不存在或未被该打印机领取")
+	}
+	return nil
+}
+
+// RetryJob 重新打印：任务重置为待打印并清零重试次数
+func (r *printRepository) RetryJob(id int64) error {
+	return r.db.Model(&model.PrintJob{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":   model.PrintJobPending,
+		"attempts": 0,
+		"error":    "",
+	}).Error
+}
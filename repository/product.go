@@ -3,17 +3,18 @@ package repository
 import (
 	"cmf/paint_proj/model"
 	"errors"
+	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepository interface {
-	GetProductCategory() ([]model.Category, map[int64]string, error)                   //  从product表查分类
-	GetProductCategoryByShop(shopID int64) ([]model.Category, map[int64]string, error) //  根据店铺从product表查分类
-	GetAllCategories() ([]model.Category, error)                                       //  获取所有分类
-	GetCategoriesByShop(shopID int64) ([]model.Category, error)                        //  根据店铺获取分类
-	GetAllProduct() ([]model.Product, error)                                           //  获取所有商品
-	GetAllProductByShop(shopID int64) ([]model.Product, error)                         //  根据店铺获取所有商品
+	GetAllCategories() ([]model.Category, error)                //  获取所有分类
+	GetCategoriesByShop(shopID int64) ([]model.Category, error) //  根据店铺获取分类
+	GetAllProduct() ([]model.Product, error)                    //  获取所有商品
+	GetAllProductByShop(shopID int64) ([]model.Product, error)  //  根据店铺获取所有商品
 
 	GetByID(productID int64) (*model.Product, error)
 	GetByIDAndShop(productID int64, shopID int64) (*model.Product, error) // 根据ID和店铺获取商品
@@ -25,15 +26,45 @@ type ProductRepository interface {
 
 	Create(product *model.Product) error
 	Update(product *model.Product) error
-	UpdateFields(id int64, fields map[string]interface{}) error
+	UpdateFields(id int64, fields map[string]interface{}, history *model.ProductPriceHistory, adjustment *model.StockOperation) error // history不为空时同时写入价格变动记录，adjustment不为空时同时生成库存调整单
 	Delete(id int64) error
-	CheckNameExists(name string, excludeID ...int64) (bool, error)
+	CheckNameExists(shopID int64, name string, excludeID ...int64) (bool, error)
 
 	// 分类管理方法
 	CreateCategory(category *model.Category) error
 	UpdateCategory(category *model.Category) error
-	DeleteCategory(id int64) error
+	DeleteCategory(id int64, targetID int64) error
 	GetCategoryByID(id int64) (*model.Category, error)
+	MoveCategory(id int64, parentID int64, sortOrder *int64) error
+	UpdateCategorySortOrders(sortOrders map[int64]int64) error
+
+	// 套装管理方法
+	CreateBundle(product *model.Product, items []model.ProductBundleItem) error
+	ReplaceBundleItems(bundleID int64, items []model.ProductBundleItem) error
+	GetBundleItems(bundleID int64) ([]model.ProductBundleItem, error)
+	GetBundleItemsByBundleIDs(bundleIDs []int64) ([]model.ProductBundleItem, error)
+	GetBundleIDsByComponentID(componentID int64) ([]int64, error)
+
+	// 导入导出
+	GetByNameAndShop(name string, shopID int64) (*model.Product, error)
+	GetBySkuAndShop(sku string, shopID int64) (*model.Product, error)
+	GetByBarcodeAndShop(barcode string, shopID int64) (*model.Product, error)
+	GetAllForExport(shopID int64, name string) ([]model.Product, error)
+	ImportProducts(shopID int64, rows []model.ProductImportRow, inbound *model.StockOperation) error  // inbound 为期初入库单主表信息
+	CopyCatalog(report *model.CatalogCopyReport, withStock bool, inbound *model.StockOperation) error // 店铺间复制分类和商品，复制的库存通过期初入库单写入
+
+	// 小程序搜索
+	SearchProducts(query *model.ProductSearchQuery) ([]model.ProductSearchItem, error)
+	GetWithoutPinyinInitial() ([]model.Product, error) // 获取未生成拼音首字母的商品
+
+	// 商品图集和详情
+	GetProductImages(productID int64) ([]model.ProductImage, error)
+	AddProductImage(image *model.ProductImage) error
+	ReplaceProductImages(productID int64, images []model.ProductImage) error
+	GetProductDetail(productID int64) (*model.ProductDetail, error)
+	SaveProductDetail(detail *model.ProductDetail) error
+	GetRelatedProducts(shopID int64, categoryID int64, excludeIDs []int64, limit int) ([]model.Product, error) // 同分类的上架商品
+	GetAllImageURLs() ([]string, error)                                                                        // 商品主图和图集的所有图片地址(去重)
 }
 
 type productRepository struct {
@@ -44,40 +75,6 @@ func NewProductRepository(db *gorm.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-// GetProductCategory  从product表查分类
-func (p *productRepository) GetProductCategory() ([]model.Category, map[int64]string, error) {
-	var categories []model.Category
-	if err := p.db.Table("product p").
-		Select("distinct p.category_id as id,c.name").
-		Joins("INNER JOIN category c ON p.category_id = c.id").
-		Where("p.is_on_shelf = ?", 1).
-		Scan(&categories).Error; err != nil {
-		return nil, nil, err
-	}
-	var categoryMap = make(map[int64]string)
-	for _, category := range categories {
-		categoryMap[category.ID] = category.Name
-	}
-	return categories, categoryMap, nil
-}
-
-// GetProductCategoryByShop  根据店铺从product表查分类
-func (p *productRepository) GetProductCategoryByShop(shopID int64) ([]model.Category, map[int64]string, error) {
-	var categories []model.Category
-	if err := p.db.Table("product p").
-		Select("distinct p.category_id as id,c.name").
-		Joins("INNER JOIN category c ON p.category_id = c.id").
-		Where("p.is_on_shelf = ? AND p.shop_id = ?", 1, shopID).
-		Scan(&categories).Error; err != nil {
-		return nil, nil, err
-	}
-	var categoryMap = make(map[int64]string)
-	for _, category := range categories {
-		categoryMap[category.ID] = category.Name
-	}
-	return categories, categoryMap, nil
-}
-
 // GetAllCategories 获取所有分类
 func (p *productRepository) GetAllCategories() ([]model.Category, error) {
 	var categories []model.Category
@@ -199,12 +196,42 @@ func (p *productRepository) Update(product *model.Product) error {
 	return p.db.Model(&model.Product{}).Where("id = ?", product.ID).Updates(product).Error
 }
 
-func (p *productRepository) UpdateFields(id int64, fields map[string]interface{}) error {
-	return p.db.Model(&model.Product{}).Where("id = ?", id).Updates(fields).Error
+func (p *productRepository) UpdateFields(id int64, fields map[string]interface{}, history *model.ProductPriceHistory, adjustment *model.StockOperation) error {
+	if history == nil && adjustment == nil {
+		return p.db.Model(&model.Product{}).Where("id = ?", id).Updates(fields).Error
+	}
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if len(fields) > 0 {
+			if err := tx.Model(&model.Product{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+				return err
+			}
+		}
+		if history != nil {
+			if err := createPriceHistory(tx, []model.ProductPriceHistory{*history}); err != nil {
+				return err
+			}
+		}
+		if adjustment != nil {
+			return postTargetStockAdjustment(tx, adjustment)
+		}
+		return nil
+	})
 }
 
 func (p *productRepository) Delete(id int64) error {
-	return p.db.Delete(&model.Product{}, id).Error
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		// 一并删除套装组件配置、图集和详情
+		if err := tx.Where("bundle_id = ?", id).Delete(&model.ProductBundleItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ?", id).Delete(&model.ProductImage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ?", id).Delete(&model.ProductDetail{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Product{}, id).Error
+	})
 }
 
 // 分类管理方法实现
@@ -216,16 +243,29 @@ func (p *productRepository) UpdateCategory(category *model.Category) error {
 	return p.db.Model(&model.Category{}).Where("id = ?", category.ID).Updates(category).Error
 }
 
-func (p *productRepository) DeleteCategory(id int64) error {
-	// 检查是否有商品使用此分类
-	var count int64
-	if err := p.db.Model(&model.Product{}).Where("category_id = ?", id).Count(&count).Error; err != nil {
-		return err
-	}
-	if count > 0 {
-		return errors.New("该分类下还有商品，无法删除")
-	}
-	return p.db.Delete(&model.Category{}, id).Error
+// DeleteCategory 删除分类，有子分类或商品时需指定转移到的分类(targetID)，子分类和商品一并转移
+func (p *productRepository) DeleteCategory(id int64, targetID int64) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		var childCount, productCount int64
+		if err := tx.Model(&model.Category{}).Where("parent_id = ?", id).Count(&childCount).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Product{}).Where("category_id = ?", id).Count(&productCount).Error; err != nil {
+			return err
+		}
+		if childCount > 0 || productCount > 0 {
+			if targetID <= 0 {
+				return fmt.Errorf("该分类下还有%d个子分类、%d个商品，请指定转移到的分类", childCount, productCount)
+			}
+			if err := tx.Model(&model.Category{}).Where("parent_id = ?", id).Update("parent_id", targetID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Product{}).Where("category_id = ?", id).Update("category_id", targetID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&model.Category{}, id).Error
+	})
 }
 
 func (p *productRepository) GetCategoryByID(id int64) (*model.Category, error) {
@@ -234,10 +274,31 @@ func (p *productRepository) GetCategoryByID(id int64) (*model.Category, error) {
 	return &category, err
 }
 
-// CheckNameExists 检查商品名称是否已存在
-func (p *productRepository) CheckNameExists(name string, excludeID ...int64) (bool, error) {
+// MoveCategory 修改分类的上级分类，sortOrder 不为空时同时修改排序权重
+func (p *productRepository) MoveCategory(id int64, parentID int64, sortOrder *int64) error {
+	fields := map[string]interface{}{"parent_id": parentID}
+	if sortOrder != nil {
+		fields["sort_order"] = *sortOrder
+	}
+	return p.db.Model(&model.Category{}).Where("id = ?", id).Updates(fields).Error
+}
+
+// UpdateCategorySortOrders 批量修改分类排序权重
+func (p *productRepository) UpdateCategorySortOrders(sortOrders map[int64]int64) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		for id, sortOrder := range sortOrders {
+			if err := tx.Model(&model.Category{}).Where("id = ?", id).Update("sort_order", sortOrder).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CheckNameExists 检查商品名称在店铺内是否已存在
+func (p *productRepository) CheckNameExists(shopID int64, name string, excludeID ...int64) (bool, error) {
 	var count int64
-	query := p.db.Model(&model.Product{}).Where("name = ?", name)
+	query := p.db.Model(&model.Product{}).Where("name = ? AND shop_id = ?", name, shopID)
 
 	// 如果提供了excludeID，则排除该ID（用于编辑时检查）
 	if len(excludeID) > 0 && excludeID[0] > 0 {
@@ -250,3 +311,506 @@ func (p *productRepository) CheckNameExists(name string, excludeID ...int64) (bo
 
 	return count > 0, nil
 }
+
+// CreateBundle 创建套装商品及其组件
+func (p *productRepository) CreateBundle(product *model.Product, items []model.ProductBundleItem) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].BundleID = product.ID
+			items[i].ShopID = product.ShopID
+		}
+		if len(items) > 0 {
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceBundleItems 替换套装组件
+func (p *productRepository) ReplaceBundleItems(bundleID int64, items []model.ProductBundleItem) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_id = ?", bundleID).Delete(&model.ProductBundleItem{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].BundleID = bundleID
+		}
+		if len(items) > 0 {
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetBundleItems 获取套装组件
+func (p *productRepository) GetBundleItems(bundleID int64) ([]model.ProductBundleItem, error) {
+	var items []model.ProductBundleItem
+	err := p.db.Model(&model.ProductBundleItem{}).Where("bundle_id = ?", bundleID).Order("id asc").Find(&items).Error
+	return items, err
+}
+
+// GetBundleItemsByBundleIDs 批量获取套装组件
+func (p *productRepository) GetBundleItemsByBundleIDs(bundleIDs []int64) ([]model.ProductBundleItem, error) {
+	var items []model.ProductBundleItem
+	if len(bundleIDs) == 0 {
+		return items, nil
+	}
+	err := p.db.Model(&model.ProductBundleItem{}).Where("bundle_id in ?", bundleIDs).Order("id asc").Find(&items).Error
+	return items, err
+}
+
+// GetBundleIDsByComponentID 获取使用了该商品作为组件的套装ID
+func (p *productRepository) GetBundleIDsByComponentID(componentID int64) ([]int64, error) {
+	var ids []int64
+	err := p.db.Model(&model.ProductBundleItem{}).Where("component_id = ?", componentID).Distinct().Pluck("bundle_id", &ids).Error
+	return ids, err
+}
+
+// GetByNameAndShop 根据名称和店铺获取商品
+func (p *productRepository) GetByNameAndShop(name string, shopID int64) (*model.Product, error) {
+	var product model.Product
+	err := p.db.Model(&model.Product{}).Where("name = ? AND shop_id = ?", name, shopID).First(&product).Error
+	return &product, err
+}
+
+// GetBySkuAndShop 根据商品编码和店铺获取商品
+func (p *productRepository) GetBySkuAndShop(sku string, shopID int64) (*model.Product, error) {
+	var product model.Product
+	err := p.db.Model(&model.Product{}).Where("sku = ? AND shop_id = ?", sku, shopID).First(&product).Error
+	return &product, err
+}
+
+// GetByBarcodeAndShop 根据条码和店铺获取商品
+func (p *productRepository) GetByBarcodeAndShop(barcode string, shopID int64) (*model.Product, error) {
+	var product model.Product
+	err := p.db.Model(&model.Product{}).Where("barcode = ? AND shop_id = ?", barcode, shopID).First(&product).Error
+	return &product, err
+}
+
+// GetAllForExport 获取导出用的商品列表（与后台商品列表相同的筛选条件，不分页）
+func (p *productRepository) GetAllForExport(shopID int64, name string) ([]model.Product, error) {
+	var products []model.Product
+	query := p.db.Model(&model.Product{})
+	if shopID > 0 {
+		query = query.Where("shop_id = ?", shopID)
+	}
+	if name != "" {
+		query = query.Where("name LIKE ?", "%"+name+"%")
+	}
+	err := query.Order("category_id asc, id asc").Find(&products).Error
+	return products, err
+}
+
+// categoryIDByPath 按分类路径(如 内墙/乳胶漆)逐级查找分类，缺失的层级在上级分类下创建；cache 缓存路径对应的分类ID
+func categoryIDByPath(tx *gorm.DB, shopID int64, path string, cache map[string]int64) (int64, error) {
+	var parentID int64
+	names := strings.Split(path, "/")
+	for i, name := range names {
+		prefix := strings.Join(names[:i+1], "/")
+		if id, ok := cache[prefix]; ok {
+			parentID = id
+			continue
+		}
+		var category model.Category
+		err := tx.Model(&model.Category{}).
+			Where("shop_id = ? AND parent_id = ? AND name = ?", shopID, parentID, name).
+			Order("id asc").
+			First(&category).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			category = model.Category{Name: name, ParentID: parentID, ShopID: shopID}
+			err = tx.Create(&category).Error
+		}
+		if err != nil {
+			return 0, err
+		}
+		cache[prefix] = category.ID
+		parentID = category.ID
+	}
+	return parentID, nil
+}
+
+// ImportProducts 导入商品：按分类路径创建缺失的分类，新增或更新商品；新增商品的库存通过期初入库单写入
+func (p *productRepository) ImportProducts(shopID int64, rows []model.ProductImportRow, inbound *model.StockOperation) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		categoryIDs := make(map[string]int64)
+		for _, row := range rows {
+			// 1. 获取或创建分类
+			categoryID, err := categoryIDByPath(tx, shopID, row.CategoryName, categoryIDs)
+			if err != nil {
+				return err
+			}
+
+			// 2. 新增或更新商品
+			product := row.Product
+			product.CategoryId = categoryID
+			product.ShopID = shopID
+			if row.Action == model.ImportActionCreate {
+				stock := product.Stock
+				product.Stock = 0
+				if err := tx.Create(product).Error; err != nil {
+					return err
+				}
+				product.Stock = stock
+				if stock > 0 {
+					inbound.Items = append(inbound.Items, openingStockItem(product, stock))
+				}
+				continue
+			}
+			var old model.Product
+			if err := tx.Select("seller_price, cost").Where("id = ?", row.ProductID).First(&old).Error; err != nil {
+				return err
+			}
+			// 更新时不修改库存，库存只能通过入库/出库变动
+			if err := tx.Model(&model.Product{}).Where("id = ?", row.ProductID).Updates(map[string]interface{}{
+				"name":           product.Name,
+				"pinyin_initial": product.PinyinInitial,
+				"sku":            product.Sku,
+				"barcode":        product.Barcode,
+				"category_id":    product.CategoryId,
+				"seller_price":   product.SellerPrice,
+				"cost":           product.Cost,
+				"shipping_cost":  product.ShippingCost,
+				"product_cost":   product.ProductCost,
+				"specification":  product.Specification,
+				"unit":           product.Unit,
+				"is_on_shelf":    product.IsOnShelf,
+				"image":          product.Image,
+				"remark":         product.Remark,
+			}).Error; err != nil {
+				return err
+			}
+
+			// 3. 价格有变化时记录价格变动
+			if old.SellerPrice != product.SellerPrice || old.Cost != product.Cost {
+				if err := createPriceHistory(tx, []model.ProductPriceHistory{{
+					ProductID:      row.ProductID,
+					ShopID:         shopID,
+					ProductName:    product.Name,
+					OldSellerPrice: old.SellerPrice,
+					NewSellerPrice: product.SellerPrice,
+					OldCost:        old.Cost,
+					NewCost:        product.Cost,
+					Source:         model.PriceSourceImport,
+					Operator:       inbound.Operator,
+					OperatorID:     inbound.OperatorID,
+				}}); err != nil {
+					return err
+				}
+			}
+		}
+		return postOpeningStock(tx, inbound)
+	})
+}
+
+// CopyCatalog 按复制计划写入目标店铺：新建分类和商品，合并同名商品，复制图集、详情和套装组件
+// 执行后回填计划中新建分类和商品的目标ID
+func (p *productRepository) CopyCatalog(report *model.CatalogCopyReport, withStock bool, inbound *model.StockOperation) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		shopID := report.TargetShopID
+		operator, operatorID := inbound.Operator, inbound.OperatorID
+
+		// 1. 分类：上级分类在前，新建的分类挂到映射后的上级分类下
+		categoryIDs := make(map[int64]int64)
+		for i := range report.Categories {
+			entry := &report.Categories[i]
+			if entry.Action == model.CopyActionCreate {
+				category := model.Category{
+					Name:      entry.Category.Name,
+					ParentID:  categoryIDs[entry.SourceParentID],
+					SortOrder: entry.Category.SortOrder,
+					ShopID:    shopID,
+				}
+				if err := tx.Create(&category).Error; err != nil {
+					return err
+				}
+				entry.TargetID = category.ID
+			}
+			categoryIDs[entry.SourceID] = entry.TargetID
+		}
+
+		// 2. 商品：新建时库存为0，withStock 时复制的库存记入期初入库单；合并时保留目标商品的分类、库存、编码和上架状态
+		productIDs := make(map[int64]int64)
+		for i := range report.Products {
+			entry := &report.Products[i]
+			source := entry.Product
+			switch entry.Action {
+			case model.CopyActionCreate:
+				product := *source
+				product.ID = 0
+				product.ShopID = shopID
+				product.CategoryId = categoryIDs[source.CategoryId]
+				product.Stock = 0
+				if err := tx.Create(&product).Error; err != nil {
+					return err
+				}
+				entry.TargetID = product.ID
+				if withStock && source.Stock > 0 && source.IsBundle != model.BundleYes {
+					inbound.Items = append(inbound.Items, openingStockItem(&product, source.Stock))
+				}
+			case model.CopyActionMerge:
+				var old model.Product
+				if err := tx.Where("id = ?", entry.TargetID).First(&old).Error; err != nil {
+					return err
+				}
+				fields := map[string]interface{}{
+					"seller_price":  source.SellerPrice,
+					"cost":          source.Cost,
+					"shipping_cost": source.ShippingCost,
+					"product_cost":  source.ProductCost,
+					"specification": source.Specification,
+					"unit":          source.Unit,
+					"remark":        source.Remark,
+				}
+				if source.Image != "" {
+					fields["image"] = source.Image
+				}
+				if err := tx.Model(&model.Product{}).Where("id = ?", entry.TargetID).Updates(fields).Error; err != nil {
+					return err
+				}
+				if old.SellerPrice != source.SellerPrice || old.Cost != source.Cost {
+					if err := createPriceHistory(tx, []model.ProductPriceHistory{{
+						ProductID:      entry.TargetID,
+						ShopID:         shopID,
+						ProductName:    old.Name,
+						OldSellerPrice: old.SellerPrice,
+						NewSellerPrice: source.SellerPrice,
+						OldCost:        old.Cost,
+						NewCost:        source.Cost,
+						Source:         model.PriceSourceCopy,
+						Operator:       operator,
+						OperatorID:     operatorID,
+						Remark:         fmt.Sprintf("从店铺%d复制", report.SourceShopID),
+					}}); err != nil {
+						return err
+					}
+				}
+			}
+			productIDs[entry.SourceID] = entry.TargetID
+		}
+
+		// 3. 图集、详情和套装组件(组件可能排在套装之后，需在所有商品写入后处理)
+		for _, entry := range report.Products {
+			if entry.Action == model.CopyActionSkip {
+				continue
+			}
+			if len(entry.Images) > 0 {
+				if err := tx.Where("product_id = ?", entry.TargetID).Delete(&model.ProductImage{}).Error; err != nil {
+					return err
+				}
+				images := make([]model.ProductImage, 0, len(entry.Images))
+				for _, image := range entry.Images {
+					images = append(images, model.ProductImage{
+						ProductID: entry.TargetID,
+						ShopID:    shopID,
+						URL:       image.URL,
+						SortOrder: image.SortOrder,
+					})
+				}
+				if err := tx.Create(&images).Error; err != nil {
+					return err
+				}
+			}
+			if entry.Detail != nil {
+				detail := *entry.Detail
+				detail.ProductID = entry.TargetID
+				detail.ShopID = shopID
+				detail.UpdatedAt = nil
+				if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&detail).Error; err != nil {
+					return err
+				}
+			}
+			if entry.Product.IsBundle == model.BundleYes {
+				if err := tx.Where("bundle_id = ?", entry.TargetID).Delete(&model.ProductBundleItem{}).Error; err != nil {
+					return err
+				}
+				items := make([]model.ProductBundleItem, 0, len(entry.BundleItems))
+				for _, item := range entry.BundleItems {
+					items = append(items, model.ProductBundleItem{
+						BundleID:    entry.TargetID,
+						ComponentID: productIDs[item.ComponentID],
+						Quantity:    item.Quantity,
+						ShopID:      shopID,
+					})
+				}
+				if len(items) > 0 {
+					if err := tx.Create(&items).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		// 4. 复制的库存
+		return postOpeningStock(tx, inbound)
+	})
+}
+
+// SearchProducts 小程序商品搜索（游标分页），销量取自出库明细
+func (p *productRepository) SearchProducts(query *model.ProductSearchQuery) ([]model.ProductSearchItem, error) {
+	// 1. 店铺内各商品的销量：出库明细数量合计，不含已作废的出库单和套装拆出的组件明细
+	salesQuery := p.db.Table("stock_operation_item soi").
+		Select("soi.product_id, SUM(soi.quantity) AS sales_volume").
+		Joins("INNER JOIN stock_operation so ON so.id = soi.operation_id").
+		Where("so.types = ? AND so.shop_id = ? AND so.is_voided = 0 AND soi.bundle_id = 0", model.StockTypeOutbound, query.ShopID).
+		Group("soi.product_id")
+
+	// 2. 商品筛选条件
+	inner := p.db.Table("product p").
+		Select("p.id, p.name, p.seller_price, p.category_id, p.image, p.unit, p.specification, p.remark, p.stock, p.is_bundle, "+
+			"COALESCE(s.sales_volume, 0) AS sales_volume").
+		Joins("LEFT JOIN (?) s ON s.product_id = p.id", salesQuery).
+		Where("p.shop_id = ? AND p.is_on_shelf = ?", query.ShopID, 1)
+	if query.Keyword != "" {
+		like := "%" + escapeLike(query.Keyword) + "%"
+		initialLike := "%" + escapeLike(strings.ToUpper(query.Keyword)) + "%"
+		inner = inner.Where("p.name LIKE ? OR p.specification LIKE ? OR p.remark LIKE ? OR p.pinyin_initial LIKE ?",
+			like, like, like, initialLike)
+	}
+	if len(query.CategoryIDs) > 0 {
+		inner = inner.Where("p.category_id IN ?", query.CategoryIDs)
+	}
+	if query.MinPrice != nil {
+		inner = inner.Where("p.seller_price >= ?", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		inner = inner.Where("p.seller_price <= ?", *query.MaxPrice)
+	}
+	if query.InStock {
+		// 套装库存由组件推算，在服务层过滤
+		inner = inner.Where("p.is_bundle = ? OR p.stock > 0", model.BundleYes)
+	}
+
+	// 3. 排序和游标
+	outer := p.db.Table("(?) AS t", inner)
+	hasCursor := query.CursorID > 0
+	switch query.Sort {
+	case model.ProductSortPriceAsc:
+		if hasCursor {
+			outer = outer.Where("t.seller_price > ? OR (t.seller_price = ? AND t.id > ?)", query.CursorValue, query.CursorValue, query.CursorID)
+		}
+		outer = outer.Order("t.seller_price ASC, t.id ASC")
+	case model.ProductSortPriceDesc:
+		if hasCursor {
+			outer = outer.Where("t.seller_price < ? OR (t.seller_price = ? AND t.id > ?)", query.CursorValue, query.CursorValue, query.CursorID)
+		}
+		outer = outer.Order("t.seller_price DESC, t.id ASC")
+	case model.ProductSortSales:
+		if hasCursor {
+			outer = outer.Where("t.sales_volume < ? OR (t.sales_volume = ? AND t.id > ?)", query.CursorValue, query.CursorValue, query.CursorID)
+		}
+		outer = outer.Order("t.sales_volume DESC, t.id ASC")
+	default:
+		if hasCursor {
+			outer = outer.Where("t.id > ?", query.CursorID)
+		}
+		outer = outer.Order("t.id ASC")
+	}
+
+	var items []model.ProductSearchItem
+	if err := outer.Limit(query.Limit).Scan(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// escapeLike 转义LIKE中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+// GetWithoutPinyinInitial 获取未生成拼音首字母的商品
+func (p *productRepository) GetWithoutPinyinInitial() ([]model.Product, error) {
+	var products []model.Product
+	if err := p.db.Select("id, name").Where("pinyin_initial = ? OR pinyin_initial IS NULL", "").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// GetProductImages 获取商品图集（按排序）
+func (p *productRepository) GetProductImages(productID int64) ([]model.ProductImage, error) {
+	var images []model.ProductImage
+	err := p.db.Where("product_id = ?", productID).Order("sort_order asc, id asc").Find(&images).Error
+	return images, err
+}
+
+// AddProductImage 追加一张图片到图集末尾，商品没有主图时同步为主图
+func (p *productRepository) AddProductImage(image *model.ProductImage) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		var maxSort int
+		if err := tx.Model(&model.ProductImage{}).
+			Select("COALESCE(MAX(sort_order), 0)").
+			Where("product_id = ?", image.ProductID).
+			Scan(&maxSort).Error; err != nil {
+			return err
+		}
+		image.SortOrder = maxSort + 1
+		if err := tx.Create(image).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Product{}).
+			Where("id = ? AND (image = '' OR image IS NULL)", image.ProductID).
+			Update("image", image.URL).Error
+	})
+}
+
+// ReplaceProductImages 按顺序重设图集，第一张同步为商品主图
+func (p *productRepository) ReplaceProductImages(productID int64, images []model.ProductImage) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&model.ProductImage{}).Error; err != nil {
+			return err
+		}
+		if len(images) == 0 {
+			return nil
+		}
+		for i := range images {
+			images[i].ProductID = productID
+			images[i].SortOrder = i + 1
+		}
+		if err := tx.Create(&images).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Product{}).Where("id = ?", productID).Update("image", images[0].URL).Error
+	})
+}
+
+// GetProductDetail 获取商品详情，未设置时返回 gorm.ErrRecordNotFound
+func (p *productRepository) GetProductDetail(productID int64) (*model.ProductDetail, error) {
+	var detail model.ProductDetail
+	if err := p.db.Where("product_id = ?", productID).First(&detail).Error; err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
+
+// SaveProductDetail 新增或更新商品详情
+func (p *productRepository) SaveProductDetail(detail *model.ProductDetail) error {
+	return p.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(detail).Error
+}
+
+// GetRelatedProducts 获取同分类的上架商品
+func (p *productRepository) GetRelatedProducts(shopID int64, categoryID int64, excludeIDs []int64, limit int) ([]model.Product, error) {
+	var products []model.Product
+	query := p.db.Model(&model.Product{}).
+		Where("shop_id = ? AND category_id = ? AND is_on_shelf = ?", shopID, categoryID, 1)
+	if len(excludeIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludeIDs)
+	}
+	err := query.Order("id desc").Limit(limit).Find(&products).Error
+	return products, err
+}
+
+// GetAllImageURLs 获取商品主图和图集的所有图片地址(去重)
+func (p *productRepository) GetAllImageURLs() ([]string, error) {
+	var urls []string
+	err := p.db.Raw("SELECT image FROM product WHERE image <> '' UNION SELECT url FROM product_image WHERE url <> ''").
+		Scan(&urls).Error
+	return urls, err
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"cmf/paint_proj/model"
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReportRepository interface {
+	GetSalesReport(query *model.SalesReportQuery) ([]model.SalesReportRow, error)     // 按分组方式汇总销售
+	GetSalesReportTotal(query *model.SalesReportQuery) (*model.SalesReportRow, error) // 销售合计
+
+	// 库存估值和收发存
+	GetInventoryProducts(shopID int64) ([]model.Product, error)                                // 参与库存估值的商品(不含套装)
+	GetStockSnapshots(shopID int64, asOf time.Time) (map[int64]*model.StockSnapshot, error)    // 由库存流水推算 asOf 时点的库存和进价
+	GetStockMovementSums(shopID int64, start, end time.Time) ([]model.StockMovementSum, error) // 期间内按商品和操作类型汇总库存流水
+}
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+// 销售明细口径：
+// 1. 出库单中的商品明细和套装明细，不含套装拆出的组件明细(组件不单独计销售额)
+// 2. 不含已作废的出库单；小程序订单出库单只统计已支付的
+// 3. 销售额取明细总价(未填时为单价*数量)，成本为 单价*数量-利润
+// 4. 早期小程序订单明细未记录利润，按商品当前成本价估算
+var (
+	salesRevenueExpr = "CASE WHEN soi.total_price <> 0 THEN soi.total_price ELSE soi.unit_price * soi.quantity END"
+	salesCostExpr    = "CASE WHEN so.outbound_type = " + strconv.Itoa(model.OutboundTypeMiniProgram) + " AND soi.is_bundle = 0 AND soi.profit = 0 AND soi.product_cost = 0 " +
+		"THEN COALESCE(p.cost, 0) * soi.quantity ELSE soi.unit_price * soi.quantity - soi.profit END"
+	salesSumSelect = "CAST(COALESCE(SUM(" + salesRevenueExpr + "), 0) AS SIGNED) AS revenue, " +
+		"CAST(COALESCE(SUM(" + salesCostExpr + "), 0) AS SIGNED) AS cost, " +
+		"CAST(COALESCE(SUM(soi.quantity), 0) AS SIGNED) AS quantity, " +
+		"COUNT(DISTINCT so.id) AS operation_count"
+)
+
+// salesReportGroups 各分组方式的分组键和名称
+var salesReportGroups = map[string]struct{ key, name string }{
+	model.ReportGroupDay:      {"DATE_FORMAT(so.created_at, '%Y-%m-%d')", "DATE_FORMAT(so.created_at, '%Y-%m-%d')"},
+	model.ReportGroupWeek:     {"DATE_FORMAT(so.created_at, '%x-W%v')", "DATE_FORMAT(so.created_at, '%x-W%v')"},
+	model.ReportGroupMonth:    {"DATE_FORMAT(so.created_at, '%Y-%m')", "DATE_FORMAT(so.created_at, '%Y-%m')"},
+	model.ReportGroupProduct:  {"CAST(soi.product_id AS CHAR)", "MAX(soi.product_name)"},
+	model.ReportGroupCategory: {"CAST(COALESCE(p.category_id, 0) AS CHAR)", "MAX(c.name)"},
+	model.ReportGroupCustomer: {"CAST(so.user_id AS CHAR)", "MAX(so.user_name)"},
+	model.ReportGroupOperator: {"CONCAT(so.operator_type, ':', so.operator_id)", "MAX(so.operator)"},
+	model.ReportGroupShop:     {"CAST(so.shop_id AS CHAR)", "MAX(sh.name)"},
+}
+
+// salesReportBase 销售明细查询
+func (r *reportRepository) salesReportBase(query *model.SalesReportQuery) *gorm.DB {
+	db := r.db.Table("stock_operation_item soi").
+		Joins("INNER JOIN stock_operation so ON so.id = soi.operation_id").
+		Joins("LEFT JOIN product p ON p.id = soi.product_id").
+		Where("so.types = ? AND so.is_voided = 0 AND soi.bundle_id = 0", model.StockTypeOutbound).
+		Where("(so.outbound_type <> ? OR so.payment_finish_status = ?)", model.OutboundTypeMiniProgram, model.PaymentStatusPaid).
+		Where("so.created_at >= ? AND so.created_at < ?", query.StartTime, query.EndTime)
+	if query.ShopID > 0 {
+		db = db.Where("so.shop_id = ?", query.ShopID)
+	}
+	if query.OutboundType != nil {
+		db = db.Where("so.outbound_type = ?", *query.OutboundType)
+	}
+	return db
+}
+
+// GetSalesReport 按分组方式汇总销售额、成本和数量，毛利和毛利率由服务层计算
+func (r *reportRepository) GetSalesReport(query *model.SalesReportQuery) ([]model.SalesReportRow, error) {
+	group, ok := salesReportGroups[query.GroupBy]
+	if !ok {
+		return nil, errors.New("不支持的分组方式")
+	}
+	db := r.salesReportBase(query)
+	switch query.GroupBy {
+	case model.ReportGroupCategory:
+		db = db.Joins("LEFT JOIN category c ON c.id = p.category_id")
+	case model.ReportGroupShop:
+		db = db.Joins("LEFT JOIN shop sh ON sh.id = so.shop_id")
+	}
+
+	var rows []model.SalesReportRow
+	err := db.Select(group.key + " AS `key`, COALESCE(" + group.name + ", '') AS name, " + salesSumSelect).
+		Group("`key`").
+		Order("`key`").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// GetSalesReportTotal 销售合计
+func (r *reportRepository) GetSalesReportTotal(query *model.SalesReportQuery) (*model.SalesReportRow, error) {
+	var total model.SalesReportRow
+	err := r.salesReportBase(query).Select(salesSumSelect).Scan(&total).Error
+	return &total, err
+}
+
+// GetInventoryProducts 参与库存估值的商品，套装库存由组件推算，不单独估值
+func (r *reportRepository) GetInventoryProducts(shopID int64) ([]model.Product, error) {
+	var products []model.Product
+	db := r.db.Model(&model.Product{}).
+		Select("id, name, specification, unit, shop_id, stock, product_cost").
+		Where("is_bundle = ?", model.BundleNo)
+	if shopID > 0 {
+		db = db.Where("shop_id = ?", shopID)
+	}
+	err := db.Order("shop_id, id").Find(&products).Error
+	return products, err
+}
+
+// stockLedger 库存流水(不含不变动库存的套装明细)
+func (r *reportRepository) stockLedger(shopID int64) *gorm.DB {
+	db := r.db.Table("stock_operation_item").Where("is_bundle = 0")
+	if shopID > 0 {
+		db = db.Where("shop_id = ?", shopID)
+	}
+	return db
+}
+
+// GetStockSnapshots 由库存流水推算 asOf 时点(不含)的库存和进价：
+// 1. 库存取 asOf 之前最后一条流水的操作后库存；之前没有流水时取 asOf 之后第一条流水的操作前库存
+// 2. 进价取 asOf 之前最后一张未作废入库单的进价
+// 没有任何流水的商品不在结果中
+func (r *reportRepository) GetStockSnapshots(shopID int64, asOf time.Time) (map[int64]*model.StockSnapshot, error) {
+	type stockRow struct {
+		ProductID int64
+		Stock     int64
+	}
+	snapshots := make(map[int64]*model.StockSnapshot)
+
+	// 1. asOf 之后第一条流水的操作前库存
+	var after []stockRow
+	firstAfter := r.stockLedger(shopID).Select("MIN(id)").Where("created_at >= ?", asOf).Group("product_id")
+	if err := r.db.Table("stock_operation_item").
+		Select("product_id, before_stock AS stock").
+		Where("id IN (?)", firstAfter).
+		Scan(&after).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range after {
+		snapshots[row.ProductID] = &model.StockSnapshot{ProductID: row.ProductID, Stock: row.Stock, HasLedger: true}
+	}
+
+	// 2. asOf 之前最后一条流水的操作后库存(优先)
+	var before []stockRow
+	lastBefore := r.stockLedger(shopID).Select("MAX(id)").Where("created_at < ?", asOf).Group("product_id")
+	if err := r.db.Table("stock_operation_item").
+		Select("product_id, after_stock AS stock").
+		Where("id IN (?)", lastBefore).
+		Scan(&before).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range before {
+		snapshots[row.ProductID] = &model.StockSnapshot{ProductID: row.ProductID, Stock: row.Stock, HasLedger: true}
+	}
+
+	// 3. asOf 之前最后一张入库单的进价
+	var costs []struct {
+		ProductID   int64
+		ProductCost model.Amount
+	}
+	lastInbound := r.db.Table("stock_operation_item soi").
+		Select("MAX(soi.id)").
+		Joins("INNER JOIN stock_operation so ON so.id = soi.operation_id").
+		Where("so.types = ? AND so.is_voided = 0 AND soi.product_cost > 0 AND soi.created_at < ?", model.StockTypeInbound, asOf).
+		Group("soi.product_id")
+	if shopID > 0 {
+		lastInbound = lastInbound.Where("so.shop_id = ?", shopID)
+	}
+	if err := r.db.Table("stock_operation_item").
+		Select("product_id, product_cost").
+		Where("id IN (?)", lastInbound).
+		Scan(&costs).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range costs {
+		if snapshot, ok := snapshots[row.ProductID]; ok {
+			snapshot.UnitCost = row.ProductCost
+		}
+	}
+	return snapshots, nil
+}
+
+// GetStockMovementSums 期间内按商品和操作类型汇总库存流水数量和库存变动
+func (r *reportRepository) GetStockMovementSums(shopID int64, start, end time.Time) ([]model.StockMovementSum, error) {
+	var sums []model.StockMovementSum
+	db := r.db.Table("stock_operation_item soi").
+		Select("soi.product_id, so.types, CAST(SUM(soi.quantity) AS SIGNED) AS quantity, CAST(SUM(soi.after_stock - soi.before_stock) AS SIGNED) AS delta").
+		Joins("INNER JOIN stock_operation so ON so.id = soi.operation_id").
+		Where("soi.is_bundle = 0 AND soi.created_at >= ? AND soi.created_at < ?", start, end)
+	if shopID > 0 {
+		db = db.Where("so.shop_id = ?", shopID)
+	}
+	err := db.Group("soi.product_id, so.types").Scan(&sums).Error
+	return sums, err
+}
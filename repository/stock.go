@@ -2,9 +2,12 @@ package repository
 
 import (
 	"cmf/paint_proj/model"
+	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type StockRepository interface {
@@ -21,6 +24,10 @@ type StockRepository interface {
 	GetStockOperationItems(operationID int64) ([]model.StockOperationItem, error)
 	GetStockOperationItemsByOrderID(orderID int64) ([]model.StockOperationItem, error)
 	GetStockOperationItemsByShop(page, pageSize int, shopID int64, productID *int64) ([]model.StockOperationItem, int64, error)
+	SearchStockOperations(query *model.StockOperationQuery) ([]model.StockOperation, int64, error)
+	GetStockOperationsForExport(query *model.StockOperationQuery, limit int) ([]model.StockOperation, error)
+	GetStockOperationItemsByOperationIDs(operationIDs []int64) ([]model.StockOperationItem, error)
+	GetStockOperationsSummary(query *model.StockOperationQuery) (*model.StockOperationSummary, error)
 
 	// 更新出库单支付完成状态
 	UpdateOutboundPaymentStatus(operationID int64, paymentFinishStatus model.PaymentStatusCode, paymentFinishTime *time.Time) error
@@ -31,6 +38,15 @@ type StockRepository interface {
 	// 事务处理
 	ProcessOutboundTransaction(operation *model.StockOperation) error
 	ProcessInboundTransaction(operation *model.StockOperation) error
+	VoidStockOperation(original *model.StockOperation, reversal *model.StockOperation) error // 作废原操作单并生成冲销单
+
+	// 库存核对
+	GetStockLedgerProducts(shopID, productID int64) ([]model.Product, error)                                                 // 参与核对的商品(不含套装)
+	GetStockLedgerEntries(shopID, productID int64) ([]model.StockLedgerEntry, error)                                         // 按商品、明细ID顺序的库存流水
+	PostStockAdjustment(operation *model.StockOperation, checks map[int64]model.StockLedgerCheckRow, updateStock bool) error // 生成库存调整单
+
+	// 库存日志
+	GetStockLogs(query *model.StockLogQuery) ([]model.StockLog, int64, error)
 }
 
 type stockRepository struct {
@@ -181,33 +197,282 @@ func (sr *stockRepository) GetStockOperationItemsByShop(page, pageSize int, shop
 	return items, total, nil
 }
 
+// stockOperationFilter 按查询条件筛选库存操作主表
+func (sr *stockRepository) stockOperationFilter(query *model.StockOperationQuery) *gorm.DB {
+	db := sr.db.Model(&model.StockOperation{}).Where("shop_id = ?", query.ShopID)
+	if query.Types != nil {
+		db = db.Where("types = ?", *query.Types)
+	}
+	if query.StartTime != nil {
+		db = db.Where("created_at >= ?", *query.StartTime)
+	}
+	if query.EndTime != nil {
+		db = db.Where("created_at < ?", *query.EndTime)
+	}
+	if query.UserID > 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.UserName != "" {
+		db = db.Where("user_name LIKE ?", "%"+query.UserName+"%")
+	}
+	if query.Supplier != "" {
+		db = db.Where("supplier LIKE ?", "%"+query.Supplier+"%")
+	}
+	if query.OperatorID > 0 {
+		db = db.Where("operator_id = ?", query.OperatorID)
+	}
+	if query.OutboundType != nil {
+		db = db.Where("outbound_type = ?", *query.OutboundType)
+	}
+	if query.PaymentFinishStatus != nil {
+		db = db.Where("payment_finish_status = ?", *query.PaymentFinishStatus)
+	}
+	if query.IsVoided != nil {
+		db = db.Where("is_voided = ?", *query.IsVoided)
+	}
+	if query.OperationNo != "" {
+		db = db.Where("operation_no LIKE ?", "%"+query.OperationNo+"%")
+	}
+	if query.MinAmount != nil {
+		db = db.Where("total_amount >= ?", *query.MinAmount)
+	}
+	if query.MaxAmount != nil {
+		db = db.Where("total_amount <= ?", *query.MaxAmount)
+	}
+	// 按商品筛选：明细中包含该商品的操作单
+	if query.ProductID > 0 || query.ProductName != "" {
+		items := sr.db.Model(&model.StockOperationItem{}).Select("operation_id")
+		if query.ProductID > 0 {
+			items = items.Where("product_id = ?", query.ProductID)
+		}
+		if query.ProductName != "" {
+			items = items.Where("product_name LIKE ?", "%"+query.ProductName+"%")
+		}
+		db = db.Where("id IN (?)", items)
+	}
+	return db
+}
+
+// stockOperationOrder 排序条件，排序字段相同时按ID保持稳定顺序
+func stockOperationOrder(query *model.StockOperationQuery) string {
+	field := model.StockOperationSortCreatedAt
+	switch query.SortField {
+	case model.StockOperationSortTotalAmount, model.StockOperationSortTotalQuantity, model.StockOperationSortTotalProfit:
+		field = query.SortField
+	}
+	if query.SortDesc {
+		return field + " DESC, id DESC"
+	}
+	return field + " ASC, id ASC"
+}
+
+// SearchStockOperations 按条件分页查询库存操作主表
+func (sr *stockRepository) SearchStockOperations(query *model.StockOperationQuery) ([]model.StockOperation, int64, error) {
+	var operations []model.StockOperation
+	var total int64
+
+	if err := sr.stockOperationFilter(query).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (query.Page - 1) * query.PageSize
+	if err := sr.stockOperationFilter(query).
+		Order(stockOperationOrder(query)).
+		Offset(offset).
+		Limit(query.PageSize).
+		Find(&operations).Error; err != nil {
+		return nil, 0, err
+	}
+	return operations, total, nil
+}
+
+// GetStockOperationsForExport 按条件查询导出的库存操作主表，最多 limit 条
+func (sr *stockRepository) GetStockOperationsForExport(query *model.StockOperationQuery, limit int) ([]model.StockOperation, error) {
+	var operations []model.StockOperation
+	err := sr.stockOperationFilter(query).
+		Order(stockOperationOrder(query)).
+		Limit(limit).
+		Find(&operations).Error
+	return operations, err
+}
+
+// GetStockOperationItemsByOperationIDs 批量获取库存操作子表记录
+func (sr *stockRepository) GetStockOperationItemsByOperationIDs(operationIDs []int64) ([]model.StockOperationItem, error) {
+	var items []model.StockOperationItem
+	if len(operationIDs) == 0 {
+		return items, nil
+	}
+	err := sr.db.Model(&model.StockOperationItem{}).
+		Where("operation_id IN ?", operationIDs).
+		Order("operation_id, id").
+		Find(&items).Error
+	return items, err
+}
+
+// GetStockOperationsSummary 按条件合计库存操作，不含已作废的操作单和冲销单
+func (sr *stockRepository) GetStockOperationsSummary(query *model.StockOperationQuery) (*model.StockOperationSummary, error) {
+	var summary model.StockOperationSummary
+	err := sr.stockOperationFilter(query).
+		Select("COALESCE(SUM(total_amount), 0) AS total_amount, COALESCE(SUM(total_quantity), 0) AS total_quantity, COALESCE(SUM(total_profit), 0) AS total_profit").
+		Where("is_voided = 0 AND types <> ?", model.StockTypeReversal).
+		Scan(&summary).Error
+	return &summary, err
+}
+
 // ProcessOutboundTransaction 处理出库事务：创建主表记录、子表记录、更新库存
 func (sr *stockRepository) ProcessOutboundTransaction(operation *model.StockOperation) error {
 	return sr.db.Transaction(func(tx *gorm.DB) error {
-		// 1. 创建主表记录
-		if err := tx.Create(operation).Error; err != nil {
+		return createOutboundOperation(tx, operation)
+	})
+}
+
+// createOutboundOperation 在事务中创建出库单主表、子表记录并扣减库存
+func createOutboundOperation(tx *gorm.DB, operation *model.StockOperation) error {
+	// 1. 创建主表记录
+	if err := tx.Create(operation).Error; err != nil {
+		return err
+	}
+
+	// 2. 更新库存并记录库存日志（套装明细不变动库存，由组件明细扣减）
+	for i := range operation.Items {
+		item := &operation.Items[i]
+		item.OperationID = operation.ID
+		item.CreatedAt = operation.CreatedAt
+		if item.IsBundle == model.BundleYes {
+			continue
+		}
+		if err := changeStockWithLog(tx, operation, item, -item.Quantity); err != nil {
 			return err
 		}
+	}
 
-		// 2. 创建子表记录
-		for i := range operation.Items {
-			operation.Items[i].OperationID = operation.ID
-			operation.Items[i].CreatedAt = operation.CreatedAt
+	// 3. 创建子表记录(操作前后库存为事务中的实际库存)
+	return tx.Create(&operation.Items).Error
+}
+
+// lockAndCheckStock 在事务中锁定出库明细涉及的商品(按ID顺序加锁，避免死锁)并校验库存是否足够
+// 套装明细不占库存，由组件明细校验；同一商品的多条明细合并校验
+func lockAndCheckStock(tx *gorm.DB, items []model.StockOperationItem) error {
+	quantities := make(map[int64]int)
+	var ids []int64
+	for _, item := range items {
+		if item.IsBundle == model.BundleYes {
+			continue
 		}
-		if err := tx.Create(&operation.Items).Error; err != nil {
-			return err
+		if _, ok := quantities[item.ProductID]; !ok {
+			ids = append(ids, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var products []model.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id, name, stock").
+		Where("id IN ?", ids).
+		Order("id asc").
+		Find(&products).Error; err != nil {
+		return err
+	}
+	for _, product := range products {
+		if product.Stock < quantities[product.ID] {
+			return fmt.Errorf("商品 %s 库存不足，当前库存: %d，需要出库: %d", product.Name, product.Stock, quantities[product.ID])
 		}
+	}
+	if len(products) != len(ids) {
+		return errors.New("出库商品不存在")
+	}
+	return nil
+}
 
-		// 3. 更新库存
-		for _, item := range operation.Items {
-			if err := tx.Model(&model.Product{}).
-				Where("id = ?", item.ProductID).
-				Update("stock", gorm.Expr("stock - ?", item.Quantity)).Error; err != nil {
-				return err
-			}
+// openingStockItem 新建商品期初库存的入库明细
+func openingStockItem(product *model.Product, quantity int) model.StockOperationItem {
+	return model.StockOperationItem{
+		ProductID:     product.ID,
+		Quantity:      quantity,
+		ProductCost:   product.ProductCost,
+		ProductName:   product.Name,
+		Specification: product.Specification,
+		Unit:          product.Unit,
+		Remark:        "期初库存",
+	}
+}
+
+// postTargetStockAdjustment 在事务中锁定商品，按明细的操作后库存(目标库存)生成库存调整单，
+// 库存和库存日志经 changeStockWithLog 写入；库存没有变化的明细不记录，全部没有变化时不生成调整单
+func postTargetStockAdjustment(tx *gorm.DB, operation *model.StockOperation) error {
+	items := make([]model.StockOperationItem, 0, len(operation.Items))
+	deltas := make([]int, 0, len(operation.Items))
+	for _, item := range operation.Items {
+		var product model.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, stock").
+			Where("id = ?", item.ProductID).
+			First(&product).Error; err != nil {
+			return err
+		}
+		delta := item.AfterStock - product.Stock
+		if delta == 0 {
+			continue
+		}
+		item.ShopID = operation.ShopID
+		item.Quantity = delta
+		if delta < 0 {
+			item.Quantity = -delta
 		}
+		items = append(items, item)
+		deltas = append(deltas, delta)
+	}
+	if len(items) == 0 {
 		return nil
-	})
+	}
+	operation.Types = model.StockTypeAdjustment
+	operation.Items = items
+	operation.TotalQuantity = 0
+	for _, item := range items {
+		operation.TotalQuantity += item.Quantity
+	}
+	if err := tx.Create(operation).Error; err != nil {
+		return err
+	}
+	for i := range operation.Items {
+		operation.Items[i].OperationID = operation.ID
+		operation.Items[i].CreatedAt = operation.CreatedAt
+		if err := changeStockWithLog(tx, operation, &operation.Items[i], deltas[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Create(&operation.Items).Error
+}
+
+// postOpeningStock 在事务中为以库存0新建的商品生成期初入库单，库存和库存日志经 changeStockWithLog 写入，
+// 流水从0开始，库存核对和库存估值可以完整重放；不调整成本价。没有明细时不生成入库单
+func postOpeningStock(tx *gorm.DB, operation *model.StockOperation) error {
+	if len(operation.Items) == 0 {
+		return nil
+	}
+	operation.Types = model.StockTypeInbound
+	operation.TotalQuantity, operation.TotalAmount = 0, 0
+	for i := range operation.Items {
+		item := &operation.Items[i]
+		item.ShopID = operation.ShopID
+		item.TotalPrice = model.Amount(int64(item.ProductCost) * int64(item.Quantity))
+		operation.TotalQuantity += item.Quantity
+		operation.TotalAmount += item.TotalPrice
+	}
+	if err := tx.Create(operation).Error; err != nil {
+		return err
+	}
+	for i := range operation.Items {
+		item := &operation.Items[i]
+		item.OperationID = operation.ID
+		item.CreatedAt = operation.CreatedAt
+		if err := changeStockWithLog(tx, operation, item, item.Quantity); err != nil {
+			return err
+		}
+	}
+	return tx.Create(&operation.Items).Error
 }
 
 // ProcessInboundTransaction 处理入库事务：创建主表记录、子表记录、更新库存和成本价
@@ -218,27 +483,19 @@ func (sr *stockRepository) ProcessInboundTransaction(operation *model.StockOpera
 			return err
 		}
 
-		// 2. 创建子表记录
+		// 2. 更新库存和成本价
 		for i := range operation.Items {
-			operation.Items[i].OperationID = operation.ID
-		}
-		if err := tx.Create(&operation.Items).Error; err != nil {
-			return err
-		}
-
-		// 3. 更新库存和成本价
-		for _, item := range operation.Items {
-			// 3.1 更新库存
-			if err := tx.Model(&model.Product{}).
-				Where("id = ?", item.ProductID).
-				Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+			item := &operation.Items[i]
+			item.OperationID = operation.ID
+			// 2.1 更新库存并记录库存日志
+			if err := changeStockWithLog(tx, operation, item, item.Quantity); err != nil {
 				return err
 			}
 
 			// 检查是否需要更新成本价
 			var product model.Product
 			if err := tx.Model(&model.Product{}).
-				Select("cost, name, shipping_cost, product_cost").
+				Select("cost, name, seller_price, shipping_cost, product_cost").
 				Where("id = ?", item.ProductID).
 				First(&product).Error; err != nil {
 				return err
@@ -257,23 +514,47 @@ func (sr *stockRepository) ProcessInboundTransaction(operation *model.StockOpera
 					}).Error; err != nil {
 					return err
 				}
+
+				// 记录成本价变动
+				if err := createPriceHistory(tx, []model.ProductPriceHistory{{
+					ProductID:      item.ProductID,
+					ShopID:         operation.ShopID,
+					ProductName:    product.Name,
+					OldSellerPrice: product.SellerPrice,
+					NewSellerPrice: product.SellerPrice,
+					OldCost:        product.Cost,
+					NewCost:        newCost,
+					Source:         model.PriceSourceInbound,
+					OperationID:    operation.ID,
+					Operator:       operation.Operator,
+					OperatorID:     operation.OperatorID,
+					Remark:         "入库单 " + operation.OperationNo,
+				}}); err != nil {
+					return err
+				}
 			}
 		}
 
-		return nil
+		// 3. 创建子表记录(操作前后库存为事务中的实际库存)
+		return tx.Create(&operation.Items).Error
 	})
 }
 
-// UpdateOutboundPaymentStatus 更新出库单支付完成状态
+// UpdateOutboundPaymentStatus 更新出库单支付完成状态，同时更新未结清金额
+// 改回未支付时，门店收银单恢复为挂账金额，其他出库单恢复为总金额
 func (sr *stockRepository) UpdateOutboundPaymentStatus(operationID int64, paymentFinishStatus model.PaymentStatusCode, paymentFinishTime *time.Time) error {
 	updates := map[string]interface{}{
 		"payment_finish_status": paymentFinishStatus,
+		"unpaid_amount":         0,
 	}
 
 	// 如果支付完成状态为已支付，则设置支付完成时间
 	if paymentFinishStatus == model.PaymentStatusPaid && paymentFinishTime != nil {
 		updates["payment_finish_time"] = paymentFinishTime
 	}
+	if paymentFinishStatus == model.PaymentStatusUnpaid {
+		updates["unpaid_amount"] = gorm.Expr("COALESCE((SELECT ps.paid_credit FROM pos_sale ps WHERE ps.operation_id = stock_operation.id LIMIT 1), total_amount)")
+	}
 
 	return sr.db.Model(&model.StockOperation{}).
 		Where("id = ?", operationID).
@@ -286,3 +567,303 @@ func (sr *stockRepository) GetSupplierList() ([]*model.Supplier, error) {
 	err := sr.db.Model(&model.Supplier{}).Find(&suppliers).Error
 	return suppliers, err
 }
+
+// VoidStockOperation 作废库存操作：在事务中创建冲销单、反向变动库存并标记原操作单已作废
+// 冲销会减少库存的商品，需保证原操作单之后任一时点的库存扣除冲销数量后不为负
+func (sr *stockRepository) VoidStockOperation(original *model.StockOperation, reversal *model.StockOperation) error {
+	return sr.db.Transaction(func(tx *gorm.DB) error {
+		// 1. 冲销单每个商品的库存变动(套装明细不变动库存)
+		deltas := make(map[int64]int)
+		var productIDs []int64
+		for _, item := range reversal.Items {
+			if item.IsBundle == model.BundleYes {
+				continue
+			}
+			if _, ok := deltas[item.ProductID]; !ok {
+				productIDs = append(productIDs, item.ProductID)
+			}
+			deltas[item.ProductID] += stockReversalDelta(original.Types, item.Quantity)
+		}
+
+		// 2. 按商品ID顺序锁定商品并检查库存
+		var products []model.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, name, stock").
+			Where("id IN ?", productIDs).
+			Order("id asc").
+			Find(&products).Error; err != nil {
+			return err
+		}
+		for _, product := range products {
+			delta := deltas[product.ID]
+			if delta >= 0 {
+				continue
+			}
+			lowest, covered, err := lowestStockAfter(tx, product.ID, original.ID, product.Stock)
+			if err != nil {
+				return err
+			}
+			if !covered {
+				return fmt.Errorf("商品 %s 在原操作单之后有未记录库存日志的库存变动(库存日志启用前)，无法确认冲销后库存不为负，不能作废", product.Name)
+			}
+			if lowest+delta < 0 {
+				return fmt.Errorf("商品 %s 在原操作单之后的最低库存为 %d，冲销 %d 后库存为负，不能作废", product.Name, lowest, -delta)
+			}
+		}
+
+		// 作废入库单时恢复该入库单修改前的成本价
+		if original.Types == model.StockTypeInbound {
+			if err := restoreInboundCost(tx, original, reversal, products); err != nil {
+				return err
+			}
+		}
+
+		// 3. 创建冲销单主表，反向变动库存并记录库存日志，再按实际库存创建子表记录
+		if err := tx.Create(reversal).Error; err != nil {
+			return err
+		}
+		for i := range reversal.Items {
+			item := &reversal.Items[i]
+			item.OperationID = reversal.ID
+			item.CreatedAt = reversal.CreatedAt
+			if item.IsBundle == model.BundleYes {
+				continue
+			}
+			if err := changeStockWithLog(tx, reversal, item, stockReversalDelta(original.Types, item.Quantity)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&reversal.Items).Error; err != nil {
+			return err
+		}
+
+		// 4. 标记原操作单已作废(并发作废时只有一个成功)
+		result := tx.Model(&model.StockOperation{}).
+			Where("id = ? AND is_voided = 0", original.ID).
+			Updates(map[string]interface{}{
+				"is_voided":        1,
+				"void_reason":      original.VoidReason,
+				"void_operator":    original.VoidOperator,
+				"void_operator_id": original.VoidOperatorID,
+				"voided_at":        original.VoidedAt,
+				"reversal_id":      reversal.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("该库存操作已作废")
+		}
+		original.IsVoided = 1
+		original.ReversalID = reversal.ID
+		return nil
+	})
+}
+
+// stockReversalDelta 冲销明细的库存变动：与原操作单方向相反
+func stockReversalDelta(originalTypes int8, quantity int) int {
+	if originalTypes == model.StockTypeOutbound {
+		return quantity
+	}
+	return -quantity
+}
+
+// lowestStockAfter 原操作单之后商品的最低库存：从已锁定的当前库存出发，按库存日志的实际变动量倒推之后每个时点的库存，取最小值
+// 库存日志启用前的操作单没有日志：原操作单没有该商品的日志且之后有库存变动时，日志不完整，covered 返回 false
+func lowestStockAfter(tx *gorm.DB, productID int64, operationID int64, currentStock int) (lowest int, covered bool, err error) {
+	var logged int64
+	if err := tx.Model(&model.StockLog{}).
+		Where("product_id = ? AND operation_id = ?", productID, operationID).
+		Count(&logged).Error; err != nil {
+		return 0, false, err
+	}
+	if logged == 0 {
+		var later int64
+		if err := tx.Model(&model.StockOperationItem{}).
+			Where("product_id = ? AND operation_id > ? AND is_bundle = 0", productID, operationID).
+			Count(&later).Error; err != nil {
+			return 0, false, err
+		}
+		if later > 0 {
+			return 0, false, nil
+		}
+	}
+
+	var logs []model.StockLog
+	if err := tx.Model(&model.StockLog{}).
+		Select("id, before_stock, after_stock").
+		Where("product_id = ? AND operation_id > ?", productID, operationID).
+		Order("id desc").
+		Find(&logs).Error; err != nil {
+		return 0, false, err
+	}
+	stock := currentStock
+	lowest = currentStock
+	for _, log := range logs {
+		stock -= log.AfterStock - log.BeforeStock
+		if stock < lowest {
+			lowest = stock
+		}
+	}
+	return lowest, true, nil
+}
+
+// restoreInboundCost 作废入库单时恢复商品进价和成本价：
+// 该入库单修改过成本价的商品，若之后成本价没有再变动则恢复为入库前的成本价并记录价格变动，否则不能作废
+func restoreInboundCost(tx *gorm.DB, original *model.StockOperation, reversal *model.StockOperation, products []model.Product) error {
+	for _, locked := range products {
+		var histories []model.ProductPriceHistory
+		if err := tx.Where("product_id = ? AND source = ? AND operation_id = ?", locked.ID, model.PriceSourceInbound, original.ID).
+			Order("id asc").
+			Find(&histories).Error; err != nil {
+			return err
+		}
+		if len(histories) == 0 {
+			continue
+		}
+		first, last := histories[0], histories[len(histories)-1]
+
+		var product model.Product
+		if err := tx.Select("id, name, cost, seller_price, shipping_cost").
+			Where("id = ?", locked.ID).
+			First(&product).Error; err != nil {
+			return err
+		}
+		// 该入库单之后其他来源修改过成本价(入库、编辑、导入等)
+		var later int64
+		if err := tx.Model(&model.ProductPriceHistory{}).
+			Where("product_id = ? AND id > ? AND old_cost <> new_cost AND NOT (source = ? AND operation_id = ?)",
+				locked.ID, first.ID, model.PriceSourceInbound, original.ID).
+			Count(&later).Error; err != nil {
+			return err
+		}
+		if later > 0 || product.Cost != last.NewCost {
+			return fmt.Errorf("商品 %s 的成本价在该入库单之后已变动，不能作废", product.Name)
+		}
+
+		if err := tx.Model(&model.Product{}).Where("id = ?", product.ID).
+			Updates(map[string]interface{}{
+				"product_cost": first.OldCost - product.ShippingCost,
+				"cost":         first.OldCost,
+			}).Error; err != nil {
+			return err
+		}
+		if err := createPriceHistory(tx, []model.ProductPriceHistory{{
+			ProductID:      product.ID,
+			ShopID:         original.ShopID,
+			ProductName:    product.Name,
+			OldSellerPrice: product.SellerPrice,
+			NewSellerPrice: product.SellerPrice,
+			OldCost:        product.Cost,
+			NewCost:        first.OldCost,
+			Source:         model.PriceSourceInboundVoid,
+			OperationID:    original.ID,
+			Operator:       reversal.Operator,
+			OperatorID:     reversal.OperatorID,
+			Remark:         "作废入库单 " + original.OperationNo,
+		}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetStockLedgerProducts 参与库存核对的商品，套装明细不变动库存，不参与核对
+func (sr *stockRepository) GetStockLedgerProducts(shopID, productID int64) ([]model.Product, error) {
+	var products []model.Product
+	db := sr.db.Model(&model.Product{}).
+		Select("id, name, specification, unit, shop_id, stock").
+		Where("is_bundle = ?", model.BundleNo)
+	if shopID > 0 {
+		db = db.Where("shop_id = ?", shopID)
+	}
+	if productID > 0 {
+		db = db.Where("id = ?", productID)
+	}
+	err := db.Order("shop_id, id").Find(&products).Error
+	return products, err
+}
+
+// GetStockLedgerEntries 按商品、明细ID顺序返回库存流水(不含套装明细)
+func (sr *stockRepository) GetStockLedgerEntries(shopID, productID int64) ([]model.StockLedgerEntry, error) {
+	var entries []model.StockLedgerEntry
+	db := sr.db.Table("stock_operation_item soi").
+		Select("soi.id AS item_id, soi.operation_id, so.operation_no, so.types, soi.product_id, soi.quantity, soi.before_stock, soi.after_stock, soi.created_at").
+		Joins("INNER JOIN stock_operation so ON so.id = soi.operation_id").
+		Where("soi.is_bundle = 0")
+	if shopID > 0 {
+		db = db.Where("so.shop_id = ?", shopID)
+	}
+	if productID > 0 {
+		db = db.Where("soi.product_id = ?", productID)
+	}
+	err := db.Order("soi.product_id, soi.id").Scan(&entries).Error
+	return entries, err
+}
+
+// PostStockAdjustment 生成库存调整单：锁定商品后确认核对以来库存和流水都没有变动，
+// 再创建调整单；updateStock 为 true 时同时把商品库存改为调整后的库存
+func (sr *stockRepository) PostStockAdjustment(operation *model.StockOperation, checks map[int64]model.StockLedgerCheckRow, updateStock bool) error {
+	return sr.db.Transaction(func(tx *gorm.DB) error {
+		productIDs := make([]int64, 0, len(operation.Items))
+		for _, item := range operation.Items {
+			productIDs = append(productIDs, item.ProductID)
+		}
+
+		// 1. 锁定商品，确认核对后库存和流水没有变动
+		var products []model.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, name, stock").
+			Where("id IN ?", productIDs).
+			Find(&products).Error; err != nil {
+			return err
+		}
+		if len(products) != len(productIDs) {
+			return errors.New("部分商品不存在")
+		}
+		for _, product := range products {
+			check := checks[product.ID]
+			var newer int64
+			if err := tx.Model(&model.StockOperationItem{}).
+				Where("product_id = ? AND id > ? AND is_bundle = 0", product.ID, check.LastItemID).
+				Count(&newer).Error; err != nil {
+				return err
+			}
+			if product.Stock != check.ProductStock || newer > 0 {
+				return fmt.Errorf("商品 %s 在核对后有新的库存变动，请重新核对", product.Name)
+			}
+		}
+
+		// 2. 创建调整单主表、子表记录
+		if err := tx.Create(operation).Error; err != nil {
+			return err
+		}
+		for i := range operation.Items {
+			operation.Items[i].OperationID = operation.ID
+			operation.Items[i].CreatedAt = operation.CreatedAt
+		}
+		if err := tx.Create(&operation.Items).Error; err != nil {
+			return err
+		}
+
+		// 3. 以流水为准时修改商品库存并记录库存日志
+		if !updateStock {
+			return nil
+		}
+		for i := range operation.Items {
+			item := &operation.Items[i]
+			if err := tx.Model(&model.Product{}).
+				Where("id = ?", item.ProductID).
+				Update("stock", item.AfterStock).Error; err != nil {
+				return err
+			}
+			if item.AfterStock == checks[item.ProductID].ProductStock {
+				continue
+			}
+			if err := createStockLog(tx, operation, item, checks[item.ProductID].ProductStock, item.AfterStock); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
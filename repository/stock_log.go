@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"cmf/paint_proj/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// changeStockWithLog 在事务中按 delta 变动商品库存并写入库存日志，供入库、出库、下单、冲销等事务复用
+// 变动后在同一事务中读取库存(行锁已由 UPDATE 持有)，明细和日志的操作前后库存不受并发影响；
+// 明细的操作前后库存在这里设置，调用方在变动库存之后再创建明细记录
+func changeStockWithLog(tx *gorm.DB, operation *model.StockOperation, item *model.StockOperationItem, delta int) error {
+	if err := tx.Model(&model.Product{}).
+		Where("id = ?", item.ProductID).
+		Update("stock", gorm.Expr("stock + ?", delta)).Error; err != nil {
+		return err
+	}
+	var after int
+	if err := tx.Model(&model.Product{}).Select("stock").Where("id = ?", item.ProductID).Scan(&after).Error; err != nil {
+		return err
+	}
+	item.BeforeStock = after - delta
+	item.AfterStock = after
+	return createStockLog(tx, operation, item, item.BeforeStock, item.AfterStock)
+}
+
+// createStockLog 写入一条库存日志，出库时记录购买者和购买时间(购买者缓存在操作单上，每张操作单只查询一次)
+func createStockLog(tx *gorm.DB, operation *model.StockOperation, item *model.StockOperationItem, before, after int) error {
+	quantity := after - before
+	if quantity < 0 {
+		quantity = -quantity
+	}
+	now := time.Now()
+	log := &model.StockLog{
+		ShopID:       operation.ShopID,
+		ProductID:    item.ProductID,
+		ProductName:  item.ProductName,
+		OperationID:  operation.ID,
+		OperationNo:  operation.OperationNo,
+		Types:        operation.Types,
+		Quantity:     quantity,
+		BeforeStock:  before,
+		AfterStock:   after,
+		OrderNo:      item.OrderNo,
+		Remark:       operation.Remark,
+		Operator:     operation.Operator,
+		OperatorID:   operation.OperatorID,
+		OperatorType: operation.OperatorType,
+		CreatedAt:    &now,
+	}
+	if operation.Types == model.StockTypeOutbound {
+		log.BuyerName = operation.UserName
+		log.PurchaseTime = operation.CreatedAt
+		if log.PurchaseTime == nil {
+			log.PurchaseTime = &now
+		}
+		if operation.UserID > 0 {
+			if operation.Buyer == nil || operation.Buyer.ID != operation.UserID {
+				var user model.User
+				if err := tx.Select("id, nickname, mobile_phone, admin_display_name, wechat_display_name").
+					Where("id = ?", operation.UserID).
+					Limit(1).Find(&user).Error; err != nil {
+					return err
+				}
+				user.ID = operation.UserID
+				operation.Buyer = &user
+			}
+			user := operation.Buyer
+			log.BuyerAccount = user.MobilePhone
+			if operation.OutboundType == model.OutboundTypeMiniProgram {
+				log.BuyerName = firstNonEmpty(user.WechatDisplayName, user.Nickname, user.AdminDisplayName, operation.UserName)
+			}
+		}
+	}
+	return tx.Create(log).Error
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// GetStockLogs 分页查询库存日志，按时间倒序
+func (sr *stockRepository) GetStockLogs(query *model.StockLogQuery) ([]model.StockLog, int64, error) {
+	db := sr.db.Model(&model.StockLog{}).Where("product_id = ?", query.ProductID)
+	if query.ShopID > 0 {
+		db = db.Where("shop_id = ?", query.ShopID)
+	}
+	if query.Types != nil {
+		db = db.Where("types = ?", *query.Types)
+	}
+	if query.StartTime != nil {
+		db = db.Where("created_at >= ?", *query.StartTime)
+	}
+	if query.EndTime != nil {
+		db = db.Where("created_at < ?", *query.EndTime)
+	}
+	if query.Operator != "" {
+		db = db.Where("operator LIKE ?", "%"+query.Operator+"%")
+	}
+	if query.BuyerName != "" {
+		db = db.Where("buyer_name LIKE ?", "%"+query.BuyerName+"%")
+	}
+	if query.OperationNo != "" {
+		db = db.Where("(operation_no = ? OR order_no = ?)", query.OperationNo, query.OperationNo)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []model.StockLog
+	err := db.Order("id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&logs).Error
+	return logs, total, err
+}
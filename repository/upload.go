@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"cmf/paint_proj/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UploadRepository interface {
+	SaveUploadFile(file *model.UploadFile) error                                     // 新增上传记录，相同图片再次上传时刷新最近上传时间
+	GetUploadFilesBefore(usage string, before time.Time) ([]model.UploadFile, error) // 获取某用途最近上传时间早于指定时间的记录
+	DeleteUploadFile(id int64, before time.Time) (bool, error)                       // 删除上传记录(期间被重新上传的不删除)
+	GetProductImageReferences() ([]string, error)                                    // 可能引用商品图片的内容：商品主图、图集、图文详情
+}
+
+type uploadRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadRepository(db *gorm.DB) UploadRepository {
+	return &uploadRepository{db: db}
+}
+
+// SaveUploadFile 新增上传记录，file_key 已存在时刷新地址和最近上传时间
+func (ur *uploadRepository) SaveUploadFile(file *model.UploadFile) error {
+	return ur.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"url", "updated_at"}),
+	}).Create(file).Error
+}
+
+// GetUploadFilesBefore 获取某用途最近上传时间早于指定时间的记录
+func (ur *uploadRepository) GetUploadFilesBefore(usage string, before time.Time) ([]model.UploadFile, error) {
+	var files []model.UploadFile
+	err := ur.db.Where("`usage` = ? AND updated_at < ?", usage, before).Order("id asc").Find(&files).Error
+	return files, err
+}
+
+// DeleteUploadFile 删除上传记录，检查期间被重新上传(最近上传时间已刷新)的不删除
+func (ur *uploadRepository) DeleteUploadFile(id int64, before time.Time) (bool, error) {
+	result := ur.db.Where("id = ? AND updated_at < ?", id, before).Delete(&model.UploadFile{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetProductImageReferences 获取可能引用商品图片的内容：商品主图、图集地址、图文详情
+// 商品图片用途的上传只会保存到这些字段；其他用途(如分类图标、店铺图片)需使用新的用途并补充对应的引用检查后才能清理
+func (ur *uploadRepository) GetProductImageReferences() ([]string, error) {
+	var refs []string
+	err := ur.db.Raw(`SELECT image FROM product WHERE image <> ''
+		UNION ALL SELECT url FROM product_image WHERE url <> ''
+		UNION ALL SELECT description FROM product_detail WHERE description <> ''`).
+		Scan(&refs).Error
+	return refs, err
+}
@@ -4,8 +4,11 @@ import (
 	"cmf/paint_proj/auth"
 	"cmf/paint_proj/configs"
 	"cmf/paint_proj/controller"
+	"cmf/paint_proj/pkg"
 	"cmf/paint_proj/repository"
 	"cmf/paint_proj/service"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,6 +23,14 @@ func SetupRouter() *gin.Engine {
 	}
 	// 1.2 初始化配置文件，放在全局的Cfg
 	configs.InitConfig()
+	// 1.3 初始化文件存储(oss/local/memory)
+	if err := pkg.InitStorage(configs.Cfg); err != nil {
+		log.Fatalf("初始化文件存储失败: %v", err)
+	}
+	// 1.4 加载PDF中文字体(条码标签、送货单)，字体缺失只影响PDF接口，不影响服务启动
+	if err := pkg.InitPDFFont(); err != nil {
+		log.Printf("警告: %v，条码标签和送货单PDF暂不可用，请在 config.yaml 的 pdf.font_path 配置 TrueType(.ttf) 中文字体文件路径", err)
+	}
 
 	// 2.添加CORS中间件
 	r.Use(func(c *gin.Context) {
@@ -32,6 +43,8 @@ func SetupRouter() *gin.Engine {
 		}
 		c.Next()
 	})
+	// 2.1 本地和内存存储注册静态访问路由(在CORS之后注册，图片响应同样带跨域头)
+	pkg.RegisterStorageRoutes(r, configs.Cfg.Storage)
 
 	// 3.初始化仓储层
 	cartRepo := repository.NewCartRepository(db)
@@ -42,21 +55,53 @@ func SetupRouter() *gin.Engine {
 	stockRepo := repository.NewStockRepository(db)
 	shopRepo := repository.NewShopRepository(db)
 	operatorRepo := repository.NewOperatorRepository(db)
+	priceRepo := repository.NewPriceRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
+	posRepo := repository.NewPosRepository(db)
+	printRepo := repository.NewPrintRepository(db)
+	reportRepo := repository.NewReportRepository(db)
+	dashboardRepo := repository.NewDashboardRepository(db)
+	notifyRepo := repository.NewNotifyRepository(db)
 
 	// 4.初始化服务层
 	cartService := service.NewCartService(cartRepo, productRepo, userRepo)
 	productService := service.NewProductService(productRepo)
 	orderService := service.NewOrderService(orderRepo, cartRepo, productRepo, addressRepo, stockRepo, userRepo)
-	payService := service.NewPayService(orderRepo, cartRepo, productRepo)
+	payService := service.NewPayService(orderRepo, cartRepo, productRepo, printRepo)
 	userService := service.NewUserService(userRepo, shopRepo)
 	addressService := service.NewAddressService(addressRepo)
-	stockService := service.NewStockService(stockRepo, productRepo)
+	stockService := service.NewStockService(stockRepo, productRepo, shopRepo, orderRepo, userRepo, addressRepo)
 	shopService := service.NewShopService(shopRepo)
 	operatorService := service.NewOperatorService(operatorRepo, shopRepo)
+	priceService := service.NewPriceService(priceRepo, productRepo)
+	uploadService := service.NewUploadService(uploadRepo)
+	posService := service.NewPosService(posRepo, productRepo, userRepo, printRepo)
+	printService := service.NewPrintService(printRepo, orderRepo, stockRepo, posRepo, shopRepo)
+	reportService := service.NewReportService(reportRepo)
+	dashboardService := service.NewDashboardService(reportRepo, dashboardRepo)
+	wechatAPI := pkg.NewWechatAPI(configs.Cfg.Wechat.APIBaseURL, configs.Cfg.Wechat.AppID, configs.Cfg.Wechat.AppSecret)
+	wechatNotifyService := service.NewWechatNotifyService(notifyRepo, orderRepo, userRepo, stockRepo, shopRepo, wechatAPI)
+	smsProvider, err := pkg.NewSmsProvider(configs.Cfg.Sms)
+	if err != nil {
+		log.Fatalf("初始化短信服务失败: %v", err)
+	}
+	smsService := service.NewSmsService(notifyRepo, stockRepo, userRepo, shopRepo, smsProvider)
+	paymentReminderService := service.NewPaymentReminderService(notifyRepo, userRepo, wechatNotifyService, smsService)
+
+	// 4.1 启动定时调价任务
+	priceService.StartScheduler(time.Minute)
+	// 4.2 启动未引用图片清理任务
+	uploadService.StartCleanupJob(6 * time.Hour)
+	// 4.3 订单状态变化时发送微信订阅消息，并定时重发失败的消息
+	wechatNotifyService.Start()
+	// 4.4 后台出库后给后台客户发送出库短信，每月发送对账短信
+	smsService.StartJobs()
+	// 4.5 每天按店铺设置的账龄阈值给未结清出库单的客户发送欠款提醒
+	paymentReminderService.StartScheduler(time.Hour)
 
 	// 5. 初始化控制器
 	cartController := controller.NewCartController(cartService)
-	productController := controller.NewProductController(productService, userService, shopService)
+	productController := controller.NewProductController(productService, userService, shopService, uploadService)
 	orderController := controller.NewOrderController(orderService)
 	payController := controller.NewPayController(payService)
 	userController := controller.NewUserController(userService, shopService)
@@ -64,6 +109,14 @@ func SetupRouter() *gin.Engine {
 	stockController := controller.NewStockController(stockService, productService)
 	shopController := controller.NewShopController(shopService)
 	operatorController := controller.NewOperatorController(operatorService)
+	priceController := controller.NewPriceController(priceService, productService)
+	uploadController := controller.NewUploadController(uploadService)
+	posController := controller.NewPosController(posService, shopService)
+	printController := controller.NewPrintController(printService)
+	reportController := controller.NewReportController(reportService)
+	dashboardController := controller.NewDashboardController(dashboardService)
+	eventController := controller.NewEventController()
+	notifyController := controller.NewNotifyController(wechatNotifyService, smsService, stockService, paymentReminderService)
 
 	// API路由 供微信小程序用
 	api := r.Group("/api")
@@ -78,6 +131,8 @@ func SetupRouter() *gin.Engine {
 		productGroup := api.Group("/product", auth.AuthMiddleware())
 		{
 			productGroup.GET("/list", productController.GetProductList)
+			productGroup.GET("/search", productController.SearchProducts) // 商品搜索(游标分页)
+			productGroup.GET("/:id", productController.GetProductDetail)  // 商品详情
 		}
 		cartGroup := api.Group("/cart", auth.AuthMiddleware())
 		{
@@ -101,6 +156,11 @@ func SetupRouter() *gin.Engine {
 			payGroup.POST("/data", auth.AuthMiddleware(), payController.PaymentData)
 			payGroup.POST("/callback", payController.PaymentCallback)
 		}
+		notifyGroup := api.Group("/notify", auth.AuthMiddleware())
+		{
+			notifyGroup.GET("/templates", notifyController.GetSubscribeTemplates)   // 可订阅的消息模板
+			notifyGroup.POST("/subscribe", notifyController.RecordSubscribeConsent) // 上报订阅授权结果
+		}
 		addressGroup := api.Group("/address", auth.AuthMiddleware())
 		{
 			addressGroup.GET("/list", addressController.GetAddressList)
@@ -110,6 +170,13 @@ func SetupRouter() *gin.Engine {
 			addressGroup.DELETE("/delete/:id", addressController.DeleteAddress)
 		}
 	}
+	// 打印机路由 供门店小票打印机轮询（按打印机令牌认证）
+	printerGroup := r.Group("/printer")
+	{
+		printerGroup.GET("/job", printController.PollJob)               // 领取打印任务(ESC/POS指令)
+		printerGroup.POST("/job/:id/result", printController.ReportJob) // 回报打印结果
+	}
+
 	// Admin路由 供Web后台管理系统
 	admin := r.Group("/admin")
 	{
@@ -119,6 +186,10 @@ func SetupRouter() *gin.Engine {
 		// 店铺接口（无需token验证）
 		admin.GET("/shop/list", shopController.GetShopList) // 获取店铺列表
 
+		// 实时事件推送(SSE)，EventSource 不能设置请求头，可先用 token 换取一次性票据，通过 ticket 参数传递
+		admin.GET("/events", auth.AdminTicketMiddleware("ticket"), eventController.Stream)
+		admin.POST("/events/ticket", auth.AdminAuthMiddleware(), eventController.Ticket)
+
 		// 需要认证的管理接口
 		adminAuth := admin.Group("", auth.AdminAuthMiddleware())
 		{
@@ -137,11 +208,35 @@ func SetupRouter() *gin.Engine {
 				productGroup.POST("/add", productController.AddProduct)
 				productGroup.PUT("/edit/:id", productController.EditProduct)
 				productGroup.DELETE("/del/:id", productController.DeleteProduct)
+				productGroup.GET("/bundle/:id/items", productController.GetBundleItems)       // 获取套装组件
+				productGroup.PUT("/bundle/:id/items", productController.SetBundleItems)       // 设置套装组件
+				productGroup.POST("/import", productController.ImportProducts)                // 批量导入商品(xlsx/csv)
+				productGroup.GET("/export", productController.ExportProducts)                 // 导出商品Excel
+				productGroup.POST("/copy", productController.CopyCatalog)                     // 店铺间复制分类和商品(超级管理员)
+				productGroup.POST("/image/variants", productController.GenerateImageVariants) // 为历史商品图片补生成规格图(超级管理员)
+				productGroup.POST("/pinyin/fill", productController.FillPinyinInitials)       // 为历史商品补齐拼音首字母(超级管理员)
+
+				productGroup.GET("/barcode/:code", productController.GetProductByBarcode)  // 扫码查询商品
+				productGroup.POST("/barcode/labels", productController.PrintBarcodeLabels) // 打印条码标签(PDF)
+
+				productGroup.GET("/gallery/:id", productController.GetProductGallery)                 // 获取商品图集和详情
+				productGroup.POST("/gallery/:id/upload", productController.UploadProductGalleryImage) // 上传图片到商品图集
+				productGroup.PUT("/gallery/:id", productController.SetProductGallery)                 // 重设商品图集(排序/删除)
+				productGroup.PUT("/detail/:id", productController.SetProductDetail)                   // 设置商品图文详情和技术参数
+
+				productGroup.POST("/price/preview", priceController.PreviewPriceAdjustment)              // 预览批量调价
+				productGroup.POST("/price/adjust", priceController.CreatePriceAdjustment)                // 批量调价(立即或定时生效)
+				productGroup.GET("/price/adjustments", priceController.GetPriceAdjustmentList)           // 调价单列表
+				productGroup.POST("/price/adjustment/cancel/:id", priceController.CancelPriceAdjustment) // 取消定时调价单
+				productGroup.GET("/price/history/:id", priceController.GetPriceHistory)                  // 商品价格变动记录
 
 				productGroup.GET("/categories", productController.GetCategories)           // 获取所有分类
 				productGroup.POST("/category/add", productController.AddCategory)          // 新增分类
 				productGroup.PUT("/category/edit/:id", productController.EditCategory)     // 编辑分类
-				productGroup.DELETE("/category/del/:id", productController.DeleteCategory) // 删除分类
+				productGroup.DELETE("/category/del/:id", productController.DeleteCategory) // 删除分类(有子分类或商品时需传 target_id)
+				productGroup.GET("/category/tree", productController.GetCategoryTree)      // 获取分类树
+				productGroup.PUT("/category/move/:id", productController.MoveCategory)     // 移动分类
+				productGroup.PUT("/category/sort", productController.SortCategories)       // 同级分类排序
 			}
 
 			stockGroup := adminAuth.Group("/stock")
@@ -153,6 +248,104 @@ func SetupRouter() *gin.Engine {
 				stockGroup.GET("/operation/:id", stockController.GetStockOperationDetail)        // 库存操作详情
 				stockGroup.GET("/items", stockController.GetStockOperationItems)                 // 库存操作明细列表
 				stockGroup.GET("/suppliers", stockController.GetSupplierList)                    // 获取供货商列表
+
+				stockGroup.GET("/operation/:id/delivery-note", stockController.GetDeliveryNote) // 打印送货单(PDF)
+				stockGroup.POST("/operation/:id/void", stockController.VoidStockOperation)      // 作废库存操作(生成冲销单)
+				stockGroup.GET("/operations/export", stockController.ExportStockOperations)     // 导出库存操作Excel
+
+				stockGroup.GET("/ledger/check", stockController.CheckStockLedger)    // 库存核对
+				stockGroup.POST("/ledger/repair", stockController.RepairStockLedger) // 库存核对修复(生成库存调整单)
+
+				stockGroup.GET("/product/:id/history", stockController.GetProductStockHistory) // 商品库存变动记录
+			}
+
+			// 门店收银
+			posGroup := adminAuth.Group("/pos")
+			{
+				posGroup.POST("/shift/open", posController.OpenShift)         // 开班
+				posGroup.POST("/shift/close", posController.CloseShift)       // 交班(现金对账)
+				posGroup.GET("/shift/current", posController.GetCurrentShift) // 当前班次汇总
+				posGroup.GET("/shift/:id", posController.GetShift)            // 班次汇总(交班单)
+				posGroup.GET("/shifts", posController.GetShifts)              // 班次列表
+
+				posGroup.POST("/sale/open", posController.OpenSale)                  // 开收银单
+				posGroup.GET("/sales", posController.GetSales)                       // 收银单列表
+				posGroup.GET("/sale/:id", posController.GetSale)                     // 收银单详情
+				posGroup.PUT("/sale/:id/customer", posController.SetCustomer)        // 更换客户(重新带出协议价)
+				posGroup.POST("/sale/:id/item", posController.AddItem)               // 添加商品(扫码)
+				posGroup.PUT("/sale/:id/item/:item_id", posController.UpdateItem)    // 修改明细数量或单价
+				posGroup.DELETE("/sale/:id/item/:item_id", posController.RemoveItem) // 删除明细
+				posGroup.POST("/sale/:id/checkout", posController.Checkout)          // 结账(现金/微信/挂账)
+				posGroup.POST("/sale/:id/cancel", posController.CancelSale)          // 取消收银单
+				posGroup.GET("/sale/:id/receipt", posController.GetReceipt)          // 收银小票
+
+				posGroup.GET("/customer-prices", posController.GetCustomerPrices)         // 客户协议价列表
+				posGroup.POST("/customer-price", posController.SetCustomerPrice)          // 设置客户协议价
+				posGroup.DELETE("/customer-price/:id", posController.DeleteCustomerPrice) // 删除客户协议价
+			}
+
+			// 小票打印
+			printGroup := adminAuth.Group("/print")
+			{
+				printGroup.GET("/printers", printController.GetPrinters)                       // 打印机列表
+				printGroup.POST("/printer/add", printController.AddPrinter)                    // 添加打印机
+				printGroup.PUT("/printer/edit/:id", printController.EditPrinter)               // 编辑打印机
+				printGroup.DELETE("/printer/del/:id", printController.DeletePrinter)           // 删除打印机
+				printGroup.POST("/printer/:id/reset-token", printController.ResetPrinterToken) // 重置打印机令牌
+
+				printGroup.POST("/job", printController.CreateJob)          // 手动打印(补打)
+				printGroup.GET("/jobs", printController.GetJobs)            // 打印任务列表
+				printGroup.POST("/job/:id/retry", printController.RetryJob) // 重新打印
+				printGroup.GET("/preview", printController.Preview)         // 预览打印内容
+			}
+
+			// 首页看板
+			adminAuth.GET("/dashboard", dashboardController.GetDashboard)
+
+			// 订单管理
+			orderGroup := adminAuth.Group("/order")
+			{
+				orderGroup.GET("/list", orderController.AdminGetOrderList)     // 订单列表
+				orderGroup.GET("/:id", orderController.AdminGetOrderDetail)    // 订单详情
+				orderGroup.POST("/:id/ship", orderController.ShipOrder)        // 发货
+				orderGroup.POST("/:id/pickup", orderController.ReadyForPickup) // 备货完成待自提
+			}
+
+			// 消息通知
+			notifyGroup := adminAuth.Group("/notify")
+			{
+				notifyGroup.GET("/wechat/messages", notifyController.GetWechatMessages)            // 订阅消息发送记录
+				notifyGroup.POST("/wechat/message/:id/retry", notifyController.RetryWechatMessage) // 重发失败的订阅消息
+
+				notifyGroup.GET("/sms/logs", notifyController.GetSmsLogs)                          // 短信发送记录
+				notifyGroup.POST("/sms/outbound/:id", notifyController.SendOutboundSms)            // 补发出库短信
+				notifyGroup.POST("/sms/payment-reminder", notifyController.SendPaymentReminderSms) // 发送欠款提醒短信
+				notifyGroup.POST("/sms/monthly-balance", notifyController.SendMonthlyBalanceSms)   // 发送月度对账短信
+
+				notifyGroup.GET("/payment-reminder/setting", notifyController.GetPaymentReminderSetting)  // 欠款提醒设置
+				notifyGroup.PUT("/payment-reminder/setting", notifyController.SavePaymentReminderSetting) // 保存欠款提醒设置
+				notifyGroup.POST("/payment-reminder/run", notifyController.RunPaymentReminder)            // 立即执行欠款提醒
+				notifyGroup.GET("/payment-reminders", notifyController.GetPaymentReminders)               // 欠款提醒记录
+				notifyGroup.GET("/payment-reminder/:id", notifyController.GetPaymentReminder)             // 欠款提醒详情
+			}
+
+			// 经营报表
+			reportGroup := adminAuth.Group("/report")
+			{
+				reportGroup.GET("/sales", reportController.GetSalesReport)           // 销售利润报表
+				reportGroup.GET("/sales/export", reportController.ExportSalesReport) // 导出销售利润报表Excel
+
+				reportGroup.GET("/inventory", reportController.GetInventoryValuation)                 // 库存估值
+				reportGroup.GET("/inventory/export", reportController.ExportInventoryValuation)       // 导出库存估值Excel
+				reportGroup.GET("/stock-movement", reportController.GetStockMovementReport)           // 库存收发存报表
+				reportGroup.GET("/stock-movement/export", reportController.ExportStockMovementReport) // 导出收发存报表Excel
+			}
+
+			// 图片清理（需要超级管理员权限）
+			uploadGroup := adminAuth.Group("/upload")
+			{
+				uploadGroup.GET("/cleanup", uploadController.PreviewImageCleanup) // 预览待清理的未引用图片
+				uploadGroup.POST("/cleanup", uploadController.CleanupImages)      // 立即清理未引用图片
 			}
 
 			userGroup := adminAuth.Group("/user")
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/repository"
+	"errors"
+	"fmt"
+)
+
+// bundleComponent 套装组件及其商品信息
+type bundleComponent struct {
+	item    model.ProductBundleItem
+	product model.Product
+}
+
+// loadBundleComponents 加载套装组件及组件商品信息
+func loadBundleComponents(productRepo repository.ProductRepository, bundleID int64) ([]bundleComponent, error) {
+	items, err := productRepo.GetBundleItems(bundleID)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("套装ID %d 未配置组件", bundleID)
+	}
+
+	componentIDs := make([]int64, 0, len(items))
+	for _, item := range items {
+		componentIDs = append(componentIDs, item.ComponentID)
+	}
+	products, err := productRepo.GetByIDs(componentIDs)
+	if err != nil {
+		return nil, err
+	}
+	productMap := make(map[int64]model.Product)
+	for _, p := range products {
+		productMap[p.ID] = p
+	}
+
+	components := make([]bundleComponent, 0, len(items))
+	for _, item := range items {
+		product, exists := productMap[item.ComponentID]
+		if !exists {
+			return nil, fmt.Errorf("套装ID %d 的组件商品ID %d 不存在", bundleID, item.ComponentID)
+		}
+		components = append(components, bundleComponent{item: item, product: product})
+	}
+	return components, nil
+}
+
+// bundleAvailableStock 根据组件库存推算套装可售数量（取各组件可组成套数的最小值）
+func bundleAvailableStock(components []bundleComponent) int {
+	if len(components) == 0 {
+		return 0
+	}
+	available := -1
+	for _, c := range components {
+		if c.item.Quantity <= 0 {
+			continue
+		}
+		n := c.product.Stock / c.item.Quantity
+		if n < 0 {
+			n = 0
+		}
+		if available < 0 || n < available {
+			available = n
+		}
+	}
+	if available < 0 {
+		return 0
+	}
+	return available
+}
+
+// bundleCost 根据组件计算一套的成本价和货物成本
+func bundleCost(components []bundleComponent) (cost model.Amount, productCost model.Amount) {
+	for _, c := range components {
+		cost += model.Amount(int64(c.product.Cost) * int64(c.item.Quantity))
+		productCost += model.Amount(int64(c.product.ProductCost) * int64(c.item.Quantity))
+	}
+	return cost, productCost
+}
+
+// fillBundleStock 为套装商品填充由组件推算的库存和成本，组件配置和组件商品各批量查询一次
+func fillBundleStock(productRepo repository.ProductRepository, products []model.Product) {
+	var bundleIDs []int64
+	for i := range products {
+		if products[i].IsBundle == model.BundleYes {
+			bundleIDs = append(bundleIDs, products[i].ID)
+		}
+	}
+	if len(bundleIDs) == 0 {
+		return
+	}
+
+	componentsByBundle := make(map[int64][]bundleComponent)
+	items, err := productRepo.GetBundleItemsByBundleIDs(bundleIDs)
+	if err == nil && len(items) > 0 {
+		componentIDs := make([]int64, 0, len(items))
+		for _, item := range items {
+			componentIDs = append(componentIDs, item.ComponentID)
+		}
+		var componentProducts []model.Product
+		componentProducts, err = productRepo.GetByIDs(componentIDs)
+		productMap := make(map[int64]model.Product, len(componentProducts))
+		for _, p := range componentProducts {
+			productMap[p.ID] = p
+		}
+		missing := make(map[int64]bool)
+		for _, item := range items {
+			product, exists := productMap[item.ComponentID]
+			if !exists {
+				missing[item.BundleID] = true
+				continue
+			}
+			componentsByBundle[item.BundleID] = append(componentsByBundle[item.BundleID], bundleComponent{item: item, product: product})
+		}
+		// 组件商品缺失的套装按未配置组件处理
+		for bundleID := range missing {
+			delete(componentsByBundle, bundleID)
+		}
+	}
+
+	for i := range products {
+		if products[i].IsBundle != model.BundleYes {
+			continue
+		}
+		components := componentsByBundle[products[i].ID]
+		if err != nil || len(components) == 0 {
+			// 未配置组件的套装不可售
+			products[i].Stock = 0
+			continue
+		}
+		products[i].Stock = bundleAvailableStock(components)
+		products[i].Cost, products[i].ProductCost = bundleCost(components)
+	}
+}
+
+// buildBundleOutboundItems 构建套装出库明细
+// 返回一行套装明细（记录金额、利润，不变动库存）和各组件明细（变动组件库存），以及该套装的利润
+// 组件明细的单价、总价为0：售价只记在套装明细上，销售额、送货单和小票按明细汇总金额时不会重复计算
+func buildBundleOutboundItems(productRepo repository.ProductRepository, bundle *model.Product, quantity int, unitPrice model.Amount, totalPrice model.Amount, remark string) ([]model.StockOperationItem, model.Amount, error) {
+	components, err := loadBundleComponents(productRepo, bundle.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	available := bundleAvailableStock(components)
+	if available < quantity {
+		return nil, 0, fmt.Errorf("套装 %s 库存不足，当前可售: %d，需要出库: %d", bundle.Name, available, quantity)
+	}
+
+	// 利润按组件成本计算：(卖价 - 组件成本之和) * 数量
+	cost, productCost := bundleCost(components)
+	profit := model.Amount((int64(unitPrice) - int64(cost)) * int64(quantity))
+
+	items := make([]model.StockOperationItem, 0, len(components)+1)
+	items = append(items, model.StockOperationItem{
+		ProductID:     bundle.ID,
+		Quantity:      quantity,
+		UnitPrice:     unitPrice,
+		TotalPrice:    totalPrice,
+		BeforeStock:   available,
+		AfterStock:    available - quantity,
+		ProductCost:   productCost,
+		Profit:        profit,
+		ProductName:   bundle.Name,
+		Specification: bundle.Specification,
+		Unit:          bundle.Unit,
+		Remark:        remark,
+		IsBundle:      model.BundleYes,
+	})
+
+	for _, c := range components {
+		componentQuantity := c.item.Quantity * quantity
+		items = append(items, model.StockOperationItem{
+			ProductID:     c.product.ID,
+			Quantity:      componentQuantity, // 不记单价和总价，见函数注释；操作前后库存在出库事务中按实际库存记录
+			ProductCost:   c.product.ProductCost,
+			ProductName:   c.product.Name,
+			Specification: c.product.Specification,
+			Unit:          c.product.Unit,
+			Remark:        fmt.Sprintf("套装[%s]组件", bundle.Name),
+			BundleID:      bundle.ID,
+		})
+	}
+	return items, profit, nil
+}
+
+// buildBundleItems 校验并构建套装组件记录
+func buildBundleItems(productRepo repository.ProductRepository, bundleID int64, shopID int64, reqItems []model.BundleItemRequest) ([]model.ProductBundleItem, error) {
+	if len(reqItems) == 0 {
+		return nil, errors.New("套装组件不能为空")
+	}
+
+	seen := make(map[int64]bool)
+	items := make([]model.ProductBundleItem, 0, len(reqItems))
+	for _, reqItem := range reqItems {
+		if reqItem.Quantity <= 0 {
+			return nil, fmt.Errorf("组件商品ID %d 的数量必须大于0", reqItem.ComponentID)
+		}
+		if bundleID > 0 && reqItem.ComponentID == bundleID {
+			return nil, errors.New("套装不能包含自身")
+		}
+		if seen[reqItem.ComponentID] {
+			return nil, fmt.Errorf("组件商品ID %d 重复", reqItem.ComponentID)
+		}
+		seen[reqItem.ComponentID] = true
+
+		component, err := productRepo.GetByID(reqItem.ComponentID)
+		if err != nil {
+			return nil, fmt.Errorf("组件商品ID %d 不存在", reqItem.ComponentID)
+		}
+		if component.ShopID != shopID {
+			return nil, fmt.Errorf("组件商品 %s 不属于当前店铺", component.Name)
+		}
+		if component.IsBundle == model.BundleYes {
+			return nil, fmt.Errorf("组件商品 %s 是套装，不支持套装嵌套", component.Name)
+		}
+
+		items = append(items, model.ProductBundleItem{
+			BundleID:    bundleID,
+			ComponentID: reqItem.ComponentID,
+			Quantity:    reqItem.Quantity,
+			ShopID:      shopID,
+		})
+	}
+	return items, nil
+}
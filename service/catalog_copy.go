@@ -0,0 +1,316 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// categoryKey 目标店铺中分类的匹配键：同一上级分类下的同名分类
+type categoryKey struct {
+	parentID int64
+	name     string
+}
+
+// CopyCatalog 将源店铺选中的分类和商品复制到目标店铺
+// 分类按分类树逐级匹配目标店铺同一上级下的同名分类，有则合并，没有则新建；
+// 商品按名称匹配目标店铺的商品，同名时按 on_duplicate 跳过或合并。dryRun 时只返回映射关系
+func (ps *productService) CopyCatalog(req *model.CatalogCopyRequest, operatorID int64, operator string) (*model.CatalogCopyReport, error) {
+	if req.SourceShopID == req.TargetShopID {
+		return nil, errors.New("源店铺和目标店铺不能相同")
+	}
+	onDuplicate := req.OnDuplicate
+	if onDuplicate == "" {
+		onDuplicate = model.CopyDuplicateSkip
+	}
+	if onDuplicate != model.CopyDuplicateSkip && onDuplicate != model.CopyDuplicateMerge {
+		return nil, errors.New("同名商品处理方式只能为 skip 或 merge")
+	}
+	if !req.All && len(req.CategoryIDs) == 0 && len(req.ProductIDs) == 0 {
+		return nil, errors.New("请选择要复制的分类或商品")
+	}
+
+	// 1. 源店铺的分类和商品
+	sourceCategories, err := ps.productRepo.GetCategoriesByShop(req.SourceShopID)
+	if err != nil {
+		return nil, err
+	}
+	sourceProducts, err := ps.productRepo.GetAllForExport(req.SourceShopID, "")
+	if err != nil {
+		return nil, err
+	}
+	categoryMap := make(map[int64]model.Category, len(sourceCategories))
+	for _, category := range sourceCategories {
+		categoryMap[category.ID] = category
+	}
+	productMap := make(map[int64]model.Product, len(sourceProducts))
+	for _, product := range sourceProducts {
+		productMap[product.ID] = product
+	}
+
+	// 2. 选中的商品：全部、选中分类(含子分类)下的商品、指定的商品
+	selectedCategories := make(map[int64]bool)
+	for _, id := range req.CategoryIDs {
+		if _, ok := categoryMap[id]; !ok {
+			return nil, fmt.Errorf("分类ID %d 不属于源店铺", id)
+		}
+		for _, descendantID := range categoryDescendantIDs(sourceCategories, id) {
+			selectedCategories[descendantID] = true
+		}
+	}
+	if req.All {
+		for _, category := range sourceCategories {
+			selectedCategories[category.ID] = true
+		}
+	}
+	selectedProducts := make(map[int64]bool)
+	for _, id := range req.ProductIDs {
+		if _, ok := productMap[id]; !ok {
+			return nil, fmt.Errorf("商品ID %d 不属于源店铺", id)
+		}
+		selectedProducts[id] = true
+	}
+	for _, product := range sourceProducts {
+		if req.All || selectedCategories[product.CategoryId] {
+			selectedProducts[product.ID] = true
+		}
+	}
+
+	// 3. 套装的组件一并复制(组件也可能是套装，直到没有新增的组件为止)
+	bundleItems := make(map[int64][]model.ProductBundleItem)
+	componentOf := make(map[int64]string)
+	for {
+		var bundleIDs []int64
+		for id := range selectedProducts {
+			if _, loaded := bundleItems[id]; !loaded && productMap[id].IsBundle == model.BundleYes {
+				bundleIDs = append(bundleIDs, id)
+			}
+		}
+		if len(bundleIDs) == 0 {
+			break
+		}
+		items, err := ps.productRepo.GetBundleItemsByBundleIDs(bundleIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range bundleIDs {
+			bundleItems[id] = []model.ProductBundleItem{}
+		}
+		for _, item := range items {
+			bundleItems[item.BundleID] = append(bundleItems[item.BundleID], item)
+			if _, ok := productMap[item.ComponentID]; !ok {
+				return nil, fmt.Errorf("套装 %s 的组件商品ID %d 不在源店铺中", productMap[item.BundleID].Name, item.ComponentID)
+			}
+			if !selectedProducts[item.ComponentID] {
+				selectedProducts[item.ComponentID] = true
+				componentOf[item.ComponentID] = productMap[item.BundleID].Name
+			}
+		}
+	}
+
+	// 4. 涉及的分类：选中的分类、商品所在分类及它们的上级分类(保持层级)
+	parentOf := make(map[int64]int64)
+	var walk func(nodes []*model.CategoryNode, parentID int64)
+	walk = func(nodes []*model.CategoryNode, parentID int64) {
+		for _, node := range nodes {
+			parentOf[node.ID] = parentID
+			walk(node.Children, node.ID)
+		}
+	}
+	sourceTree := buildCategoryTree(sourceCategories)
+	walk(sourceTree, 0)
+
+	neededCategories := make(map[int64]bool)
+	markNeeded := func(id int64) {
+		for id != 0 && !neededCategories[id] {
+			neededCategories[id] = true
+			id = parentOf[id]
+		}
+	}
+	for id := range selectedCategories {
+		markNeeded(id)
+	}
+	for _, product := range sourceProducts {
+		if !selectedProducts[product.ID] {
+			continue
+		}
+		if _, ok := categoryMap[product.CategoryId]; !ok {
+			return nil, fmt.Errorf("商品 %s 的分类ID %d 不存在", product.Name, product.CategoryId)
+		}
+		markNeeded(product.CategoryId)
+	}
+
+	report := &model.CatalogCopyReport{
+		DryRun:       req.DryRun,
+		SourceShopID: req.SourceShopID,
+		TargetShopID: req.TargetShopID,
+		Categories:   []model.CatalogCopyCategory{},
+		Products:     []model.CatalogCopyProduct{},
+	}
+
+	// 5. 分类映射：按分类树先序遍历，上级分类在前；上级分类为新建时下级分类也需新建
+	targetCategories, err := ps.productRepo.GetCategoriesByShop(req.TargetShopID)
+	if err != nil {
+		return nil, err
+	}
+	targetKeys := make(map[categoryKey]int64)
+	var walkTarget func(nodes []*model.CategoryNode, parentID int64)
+	walkTarget = func(nodes []*model.CategoryNode, parentID int64) {
+		for _, node := range nodes {
+			key := categoryKey{parentID: parentID, name: node.Name}
+			if _, exists := targetKeys[key]; !exists {
+				targetKeys[key] = node.ID
+			}
+			walkTarget(node.Children, node.ID)
+		}
+	}
+	walkTarget(buildCategoryTree(targetCategories), 0)
+
+	mappedCategories := make(map[int64]int64) // 源分类ID -> 目标分类ID(新建的为0)
+	var mapCategories func(nodes []*model.CategoryNode, parentID int64, parentNew bool, path []string)
+	mapCategories = func(nodes []*model.CategoryNode, parentID int64, parentNew bool, path []string) {
+		for _, node := range nodes {
+			if !neededCategories[node.ID] {
+				continue
+			}
+			category := node.Category
+			nodePath := append(append([]string{}, path...), category.Name)
+			entry := model.CatalogCopyCategory{
+				SourceID:       category.ID,
+				Name:           category.Name,
+				Path:           strings.Join(nodePath, "/"),
+				Action:         model.CopyActionCreate,
+				SourceParentID: parentID,
+				Category:       &category,
+			}
+			if !parentNew {
+				if targetID, ok := targetKeys[categoryKey{parentID: mappedCategories[parentID], name: category.Name}]; ok {
+					entry.TargetID = targetID
+					entry.Action = model.CopyActionMerge
+				}
+			}
+			if entry.Action == model.CopyActionCreate {
+				report.CategoriesCreated++
+			} else {
+				report.CategoriesMerged++
+			}
+			mappedCategories[category.ID] = entry.TargetID
+			report.Categories = append(report.Categories, entry)
+			mapCategories(node.Children, category.ID, entry.Action == model.CopyActionCreate, nodePath)
+		}
+	}
+	mapCategories(sourceTree, 0, false, nil)
+
+	// 6. 商品映射：按名称匹配目标店铺的商品，新建的商品编码、条码在目标店铺已被使用时不复制
+	targetProducts, err := ps.productRepo.GetAllForExport(req.TargetShopID, "")
+	if err != nil {
+		return nil, err
+	}
+	targetByName := make(map[string]model.Product, len(targetProducts))
+	targetSkus := make(map[string]bool)
+	targetBarcodes := make(map[string]bool)
+	for _, product := range targetProducts {
+		targetByName[product.Name] = product
+		if product.Sku != "" {
+			targetSkus[product.Sku] = true
+		}
+		if product.Barcode != "" {
+			targetBarcodes[product.Barcode] = true
+		}
+	}
+	for _, source := range sourceProducts {
+		if !selectedProducts[source.ID] {
+			continue
+		}
+		product := source
+		entry := model.CatalogCopyProduct{
+			SourceID:    source.ID,
+			Name:        source.Name,
+			Action:      model.CopyActionCreate,
+			Notes:       []string{},
+			Product:     &product,
+			BundleItems: bundleItems[source.ID],
+		}
+		if bundleName, ok := componentOf[source.ID]; ok {
+			entry.Notes = append(entry.Notes, fmt.Sprintf("作为套装 %s 的组件一并复制", bundleName))
+		}
+
+		if existing, ok := targetByName[source.Name]; ok {
+			entry.TargetID = existing.ID
+			switch {
+			case onDuplicate == model.CopyDuplicateSkip:
+				entry.Action = model.CopyActionSkip
+				entry.Notes = append(entry.Notes, "目标店铺已有同名商品")
+			case existing.IsBundle != source.IsBundle:
+				entry.Action = model.CopyActionSkip
+				entry.Notes = append(entry.Notes, "目标店铺的同名商品套装类型不一致，未合并")
+			default:
+				entry.Action = model.CopyActionMerge
+			}
+		} else {
+			if product.Sku != "" {
+				if targetSkus[product.Sku] {
+					entry.Notes = append(entry.Notes, fmt.Sprintf("商品编码 %s 在目标店铺已被使用，未复制编码", product.Sku))
+					product.Sku = ""
+				} else {
+					targetSkus[product.Sku] = true
+				}
+			}
+			if product.Barcode != "" {
+				if targetBarcodes[product.Barcode] {
+					entry.Notes = append(entry.Notes, fmt.Sprintf("条码 %s 在目标店铺已被使用，未复制条码", product.Barcode))
+					product.Barcode = ""
+				} else {
+					targetBarcodes[product.Barcode] = true
+				}
+			}
+		}
+
+		switch entry.Action {
+		case model.CopyActionCreate:
+			report.ProductsCreated++
+		case model.CopyActionMerge:
+			report.ProductsMerged++
+		default:
+			report.ProductsSkipped++
+		}
+		report.Products = append(report.Products, entry)
+	}
+
+	if req.DryRun {
+		return report, nil
+	}
+
+	// 7. 加载图集和详情后写入
+	for i := range report.Products {
+		entry := &report.Products[i]
+		if entry.Action == model.CopyActionSkip {
+			continue
+		}
+		if entry.Images, err = ps.productRepo.GetProductImages(entry.SourceID); err != nil {
+			return nil, err
+		}
+		detail, err := ps.productRepo.GetProductDetail(entry.SourceID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		entry.Detail = detail
+	}
+	// 复制的库存通过目标店铺的期初入库单写入，保证库存流水和库存日志完整
+	inbound := &model.StockOperation{
+		OperationNo:  pkg.GenerateOrderNo(pkg.StockPrefix, operatorID),
+		Operator:     operator,
+		OperatorID:   operatorID,
+		OperatorType: model.OperatorTypeAdmin,
+		ShopID:       report.TargetShopID,
+		Remark:       fmt.Sprintf("从店铺%d复制商品期初库存", report.SourceShopID),
+	}
+	if err := ps.productRepo.CopyCatalog(report, req.WithStock, inbound); err != nil {
+		return nil, fmt.Errorf("复制商品失败: %v", err)
+	}
+	return report, nil
+}
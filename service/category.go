@@ -0,0 +1,236 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// buildCategoryTree 将分类列表组装为分类树，保持列表中的顺序(排序权重降序)
+// 上级分类不存在或不属于同一店铺的分类作为一级分类
+func buildCategoryTree(categories []model.Category) []*model.CategoryNode {
+	nodes := make(map[int64]*model.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &model.CategoryNode{Category: category, Children: []*model.CategoryNode{}}
+	}
+	roots := []*model.CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		parent, ok := nodes[category.ParentID]
+		if !ok || parent.ShopID != category.ShopID || category.ParentID == category.ID {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	return roots
+}
+
+// categoryPaths 各分类的完整路径，如 内墙/乳胶漆；上级分类不存在或不属于同一店铺时从该分类开始
+func categoryPaths(categories []model.Category) map[int64]string {
+	byID := make(map[int64]model.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+	paths := make(map[int64]string, len(categories))
+	for _, category := range categories {
+		names := []string{category.Name}
+		visited := map[int64]bool{category.ID: true}
+		for current := category; ; {
+			parent, ok := byID[current.ParentID]
+			if !ok || parent.ShopID != current.ShopID || visited[parent.ID] {
+				break
+			}
+			visited[parent.ID] = true
+			names = append([]string{parent.Name}, names...)
+			current = parent
+		}
+		paths[category.ID] = strings.Join(names, "/")
+	}
+	return paths
+}
+
+// normalizeCategoryPath 规范分类路径：去掉各级名称两端的空格和空的层级，如 " 内墙 / 乳胶漆 " -> 内墙/乳胶漆
+func normalizeCategoryPath(path string) string {
+	var names []string
+	for _, name := range strings.Split(path, "/") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+// importCategories 导入时匹配的店铺已有分类：完整路径，以及各名称对应的路径(兼容只填分类名称的旧文件)
+type importCategories struct {
+	paths  map[string]bool
+	byName map[string][]string
+}
+
+func newImportCategories(categories []model.Category) importCategories {
+	ic := importCategories{paths: make(map[string]bool), byName: make(map[string][]string)}
+	paths := categoryPaths(categories)
+	for _, category := range categories {
+		path := paths[category.ID]
+		ic.paths[path] = true
+		ic.byName[category.Name] = append(ic.byName[category.Name], path)
+	}
+	return ic
+}
+
+// resolve 按路径匹配分类，返回规范后的路径和是否需要新建；
+// 只填一级名称且没有同名一级分类时，店铺中唯一的同名分类视为该分类，有多个同名分类时需填写完整路径
+func (ic importCategories) resolve(value string) (string, bool, error) {
+	path := normalizeCategoryPath(value)
+	if ic.paths[path] {
+		return path, false, nil
+	}
+	if !strings.Contains(path, "/") {
+		switch matches := ic.byName[path]; len(matches) {
+		case 0:
+		case 1:
+			return matches[0], false, nil
+		default:
+			return "", false, fmt.Errorf("分类 %s 有多个(%s)，请填写完整路径", path, strings.Join(matches, "、"))
+		}
+	}
+	return path, true, nil
+}
+
+// pruneCategoryTree 去掉子树中没有商品的分类
+func pruneCategoryTree(nodes []*model.CategoryNode, hasProducts map[int64]bool) []*model.CategoryNode {
+	pruned := []*model.CategoryNode{}
+	for _, node := range nodes {
+		node.Children = pruneCategoryTree(node.Children, hasProducts)
+		if hasProducts[node.ID] || len(node.Children) > 0 {
+			pruned = append(pruned, node)
+		}
+	}
+	return pruned
+}
+
+// categoryDescendantIDs 分类及其所有子分类的ID
+func categoryDescendantIDs(categories []model.Category, id int64) []int64 {
+	children := make(map[int64][]int64)
+	for _, category := range categories {
+		if category.ID != category.ParentID {
+			children[category.ParentID] = append(children[category.ParentID], category.ID)
+		}
+	}
+	ids := []int64{id}
+	visited := map[int64]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, childID := range children[ids[i]] {
+			if !visited[childID] {
+				visited[childID] = true
+				ids = append(ids, childID)
+			}
+		}
+	}
+	return ids
+}
+
+// expandCategoryIDs 获取店铺内某分类及其所有子分类的ID
+func (ps *productService) expandCategoryIDs(shopID int64, categoryID int64) ([]int64, error) {
+	categories, err := ps.productRepo.GetCategoriesByShop(shopID)
+	if err != nil {
+		return nil, err
+	}
+	return categoryDescendantIDs(categories, categoryID), nil
+}
+
+// GetCategoryTree 获取店铺的分类树
+func (ps *productService) GetCategoryTree(shopID int64) ([]*model.CategoryNode, error) {
+	categories, err := ps.productRepo.GetCategoriesByShop(shopID)
+	if err != nil {
+		return nil, err
+	}
+	return buildCategoryTree(categories), nil
+}
+
+// validateParentCategory 校验上级分类存在且属于同一店铺
+func (ps *productService) validateParentCategory(parentID int64, shopID int64) error {
+	if parentID == 0 {
+		return nil
+	}
+	parent, err := ps.productRepo.GetCategoryByID(parentID)
+	if err != nil {
+		return errors.New("上级分类不存在")
+	}
+	if parent.ShopID != shopID {
+		return errors.New("上级分类不属于该店铺")
+	}
+	return nil
+}
+
+// MoveCategory 移动分类到新的上级分类下，不能移到自己或自己的子分类下
+func (ps *productService) MoveCategory(category *model.Category, parentID int64, sortOrder *int64) error {
+	if err := ps.validateParentCategory(parentID, category.ShopID); err != nil {
+		return err
+	}
+	if parentID != 0 {
+		categories, err := ps.productRepo.GetCategoriesByShop(category.ShopID)
+		if err != nil {
+			return err
+		}
+		for _, id := range categoryDescendantIDs(categories, category.ID) {
+			if id == parentID {
+				return errors.New("不能移动到自身或其子分类下")
+			}
+		}
+	}
+	return ps.productRepo.MoveCategory(category.ID, parentID, sortOrder)
+}
+
+// SortCategories 按传入顺序重排同级分类，ids 需为该层级的全部分类
+func (ps *productService) SortCategories(shopID int64, parentID int64, ids []int64) error {
+	categories, err := ps.productRepo.GetCategoriesByShop(shopID)
+	if err != nil {
+		return err
+	}
+	siblings := make(map[int64]bool)
+	for _, category := range categories {
+		if category.ParentID == parentID {
+			siblings[category.ID] = true
+		}
+	}
+	if len(ids) != len(siblings) {
+		return fmt.Errorf("需传入该层级的全部%d个分类", len(siblings))
+	}
+	// 排序权重降序显示，第一个权重最大
+	sortOrders := make(map[int64]int64, len(ids))
+	for i, id := range ids {
+		if !siblings[id] {
+			return fmt.Errorf("分类ID %d 不属于该层级", id)
+		}
+		if _, ok := sortOrders[id]; ok {
+			return fmt.Errorf("分类ID %d 重复", id)
+		}
+		sortOrders[id] = int64(len(ids) - i)
+	}
+	return ps.productRepo.UpdateCategorySortOrders(sortOrders)
+}
+
+// DeleteCategory 删除分类，有子分类或商品时需指定同店铺的转移目标，目标不能是该分类或其子分类
+func (ps *productService) DeleteCategory(category *model.Category, targetID int64) error {
+	if targetID != 0 {
+		target, err := ps.productRepo.GetCategoryByID(targetID)
+		if err != nil {
+			return errors.New("转移目标分类不存在")
+		}
+		if target.ShopID != category.ShopID {
+			return errors.New("转移目标分类不属于该店铺")
+		}
+		categories, err := ps.productRepo.GetCategoriesByShop(category.ShopID)
+		if err != nil {
+			return err
+		}
+		for _, id := range categoryDescendantIDs(categories, category.ID) {
+			if id == targetID {
+				return errors.New("不能转移到该分类自身或其子分类")
+			}
+		}
+	}
+	return ps.productRepo.DeleteCategory(category.ID, targetID)
+}
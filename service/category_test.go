@@ -0,0 +1,55 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"testing"
+)
+
+var testCategories = []model.Category{
+	{ID: 1, Name: "内墙", ShopID: 1},
+	{ID: 2, Name: "乳胶漆", ParentID: 1, ShopID: 1},
+	{ID: 3, Name: "外墙", ShopID: 1},
+	{ID: 4, Name: "乳胶漆", ParentID: 3, ShopID: 1},
+	{ID: 5, Name: "辅料", ShopID: 1},
+	{ID: 6, Name: "腻子", ParentID: 5, ShopID: 1},
+	{ID: 7, Name: "孤立", ParentID: 99, ShopID: 1},
+}
+
+func TestCategoryPaths(t *testing.T) {
+	paths := categoryPaths(testCategories)
+	want := map[int64]string{1: "内墙", 2: "内墙/乳胶漆", 4: "外墙/乳胶漆", 6: "辅料/腻子", 7: "孤立"}
+	for id, path := range want {
+		if paths[id] != path {
+			t.Errorf("分类 %d got %s, want %s", id, paths[id], path)
+		}
+	}
+}
+
+func TestImportCategoriesResolve(t *testing.T) {
+	categories := newImportCategories(testCategories)
+	cases := []struct {
+		value   string
+		path    string
+		isNew   bool
+		wantErr bool
+	}{
+		{value: "内墙/乳胶漆", path: "内墙/乳胶漆"},
+		{value: " 外墙 / 乳胶漆 ", path: "外墙/乳胶漆"},
+		{value: "腻子", path: "辅料/腻子"},                   // 只填名称，店铺中唯一的同名分类
+		{value: "乳胶漆", wantErr: true},                  // 同名分类有多个，需填写完整路径
+		{value: "内墙/木器漆", path: "内墙/木器漆", isNew: true}, // 在已有上级分类下新建
+		{value: "防水", path: "防水", isNew: true},
+	}
+	for _, tc := range cases {
+		path, isNew, err := categories.resolve(tc.value)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q 应返回错误, got %s", tc.value, path)
+			}
+			continue
+		}
+		if err != nil || path != tc.path || isNew != tc.isNew {
+			t.Errorf("%q got %s %v %v, want %s %v", tc.value, path, isNew, err, tc.path, tc.isNew)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"cmf/paint_proj/configs"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/repository"
+	"sync"
+	"time"
+)
+
+// dashboardCacheTTL 首页看板缓存时长，期间内同一店铺直接返回缓存结果
+const dashboardCacheTTL = time.Minute
+
+// dashboardTopProducts 首页看板热销商品数量
+const dashboardTopProducts = 10
+
+var orderStatusNames = map[model.OrderStatusCode]string{
+	model.OrderStatusPendingPayment: "待付款",
+	model.OrderStatusPaymentSuccess: "待发货",
+	model.OrderStatusPendingReceipt: "待收货",
+	model.OrderStatusCancelled:      "已取消",
+	model.OrderStatusCompleted:      "已完成",
+}
+
+type DashboardService interface {
+	GetDashboard(shopID int64, refresh bool) (*model.Dashboard, error)
+}
+
+type dashboardService struct {
+	reportRepo    repository.ReportRepository
+	dashboardRepo repository.DashboardRepository
+
+	mu    sync.Mutex
+	cache map[int64]*model.Dashboard
+}
+
+func NewDashboardService(reportRepo repository.ReportRepository, dashboardRepo repository.DashboardRepository) DashboardService {
+	return &dashboardService{
+		reportRepo:    reportRepo,
+		dashboardRepo: dashboardRepo,
+		cache:         make(map[int64]*model.Dashboard),
+	}
+}
+
+// GetDashboard 首页看板，shopID 为0时统计全部店铺；结果缓存 dashboardCacheTTL，refresh 为 true 时重新统计
+func (s *dashboardService) GetDashboard(shopID int64, refresh bool) (*model.Dashboard, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[shopID]
+	s.mu.Unlock()
+	if ok && !refresh && time.Since(cached.GeneratedAt) < dashboardCacheTTL {
+		return cached, nil
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	tomorrow := today.AddDate(0, 0, 1)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	dashboard := &model.Dashboard{ShopID: shopID, GeneratedAt: now, LowStockThreshold: stockWarningThreshold()}
+	var err error
+	if dashboard.Today, err = s.period(shopID, today, tomorrow); err != nil {
+		return nil, err
+	}
+	if dashboard.Month, err = s.period(shopID, monthStart, tomorrow); err != nil {
+		return nil, err
+	}
+
+	unpaid, err := s.dashboardRepo.GetUnpaidOutboundSummary(shopID)
+	if err != nil {
+		return nil, err
+	}
+	dashboard.Unpaid = *unpaid
+	if dashboard.LowStockCount, err = s.dashboardRepo.CountLowStockProducts(shopID, dashboard.LowStockThreshold); err != nil {
+		return nil, err
+	}
+
+	// 本月热销商品：按销售额排序取前N
+	products, err := s.reportRepo.GetSalesReport(&model.SalesReportQuery{
+		ShopID: shopID, StartTime: monthStart, EndTime: tomorrow, GroupBy: model.ReportGroupProduct,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range products {
+		fillSalesReportRow(&products[i])
+	}
+	sortSalesReportRows(products)
+	if len(products) > dashboardTopProducts {
+		products = products[:dashboardTopProducts]
+	}
+	dashboard.TopProducts = products
+
+	s.mu.Lock()
+	s.cache[shopID] = dashboard
+	s.mu.Unlock()
+	return dashboard, nil
+}
+
+// period 统计 [start, end) 的销售、订单和新增客户
+func (s *dashboardService) period(shopID int64, start, end time.Time) (model.DashboardPeriod, error) {
+	period := model.DashboardPeriod{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+
+	sales, err := s.reportRepo.GetSalesReportTotal(&model.SalesReportQuery{ShopID: shopID, StartTime: start, EndTime: end})
+	if err != nil {
+		return period, err
+	}
+	fillSalesReportRow(sales)
+	sales.Name = "合计"
+	period.Sales = *sales
+
+	// 各状态都返回，没有订单的为0
+	counts, err := s.dashboardRepo.CountOrdersByStatus(shopID, start, end)
+	if err != nil {
+		return period, err
+	}
+	byStatus := make(map[model.OrderStatusCode]int64, len(counts))
+	for _, count := range counts {
+		byStatus[count.Status] = count.Count
+		period.OrderTotal += count.Count
+	}
+	for status := model.OrderStatusPendingPayment; status <= model.OrderStatusCompleted; status++ {
+		period.Orders = append(period.Orders, model.DashboardOrderCount{
+			Status: status,
+			Name:   orderStatusNames[status],
+			Count:  byStatus[status],
+		})
+	}
+
+	if period.NewUsers, err = s.dashboardRepo.CountNewUsers(shopID, start, end); err != nil {
+		return period, err
+	}
+	return period, nil
+}
+
+// stockWarningThreshold 后台低库存预警阈值(stock.low_stock_threshold)，未配置时为5
+func stockWarningThreshold() int {
+	if configs.Cfg != nil && configs.Cfg.Stock.LowStockThreshold > 0 {
+		return configs.Cfg.Stock.LowStockThreshold
+	}
+	return 5
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"bytes"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// GetDeliveryNote 组装出库单的送货单数据
+// 小程序订单出库取订单的收货人信息，后台出库和门店收银取客户的手机号和默认地址
+func (ss *stockService) GetDeliveryNote(operationID int64) (*model.DeliveryNote, error) {
+	operation, err := ss.stockRepo.GetStockOperationByID(operationID)
+	if err != nil {
+		return nil, errors.New("出库单不存在")
+	}
+	if operation.Types != model.StockTypeOutbound {
+		return nil, errors.New("只有出库单可以打印送货单")
+	}
+	if operation.IsVoided == 1 {
+		return nil, errors.New("出库单已作废")
+	}
+	items, err := ss.stockRepo.GetStockOperationItems(operationID)
+	if err != nil {
+		return nil, err
+	}
+
+	note := &model.DeliveryNote{
+		ShopID:       operation.ShopID,
+		NoteNo:       operation.OperationNo,
+		CustomerName: operation.UserName,
+		Operator:     operation.Operator,
+		Remark:       operation.Remark,
+		TotalAmount:  operation.TotalAmount,
+	}
+	if operation.CreatedAt != nil {
+		note.Date = *operation.CreatedAt
+	} else {
+		note.Date = time.Now()
+	}
+
+	shop, err := ss.shopRepo.GetShopByID(operation.ShopID)
+	if err != nil {
+		return nil, fmt.Errorf("获取店铺信息失败: %v", err)
+	}
+	note.ShopName = shop.Name
+	note.ShopAddress = shop.Address
+	note.ShopPhone = shop.Phone
+
+	// 套装组件明细不单独列出，金额已计入套装明细
+	for _, item := range items {
+		if item.BundleID > 0 {
+			continue
+		}
+		if item.TotalPrice == 0 {
+			item.TotalPrice = model.Amount(int64(item.UnitPrice) * int64(item.Quantity))
+		}
+		note.Items = append(note.Items, item)
+		note.TotalQuantity += item.Quantity
+		if note.OrderNo == "" && item.OrderNo != "" {
+			note.OrderNo = item.OrderNo
+		}
+	}
+
+	// 收货人信息
+	if note.OrderNo != "" {
+		if order, err := ss.orderRepo.GetOrderByOrderNo(note.OrderNo); err == nil {
+			note.CustomerName = order.ReceiverName
+			note.CustomerPhone = order.ReceiverPhone
+			note.CustomerAddress = order.ReceiverAddress
+		}
+	} else if operation.UserID > 0 {
+		if user, err := ss.userRepo.GetUserByID(operation.UserID); err == nil {
+			note.CustomerPhone = user.MobilePhone
+		}
+		if address, err := ss.addressRepo.GetDefaultOrFirstAddressID(operation.UserID); err == nil {
+			note.CustomerAddress = address.Province + address.City + address.District + address.Detail
+			if address.RecipientPhone != "" {
+				note.CustomerPhone = address.RecipientPhone
+			}
+		}
+	}
+	return note, nil
+}
+
+// deliveryNoteLayout 送货单版式参数(毫米)
+type deliveryNoteLayout struct {
+	size       gofpdf.SizeType
+	margin     float64
+	titleSize  float64 // 标题字号
+	fontSize   float64 // 正文字号
+	lineHeight float64 // 表头信息行高
+	rowHeight  float64 // 明细行高
+	signHeight float64 // 签字栏高度
+	footer     string  // 页脚说明
+}
+
+var deliveryNoteLayouts = map[string]deliveryNoteLayout{
+	model.DeliveryNoteLayoutA4: {
+		size:       gofpdf.SizeType{Wd: 210, Ht: 297},
+		margin:     15,
+		titleSize:  16,
+		fontSize:   10,
+		lineHeight: 6.5,
+		rowHeight:  8,
+		signHeight: 24,
+	},
+	// 241mm 连续纸二等分(241×139.7mm)，针式打印机一次打出三联
+	model.DeliveryNoteLayoutTriplicate: {
+		size:       gofpdf.SizeType{Wd: 241, Ht: 139.7},
+		margin:     8,
+		titleSize:  13,
+		fontSize:   9,
+		lineHeight: 5,
+		rowHeight:  6.5,
+		signHeight: 14,
+		footer:     "第一联：存根(白)  第二联：客户(红)  第三联：回单(黄)",
+	},
+}
+
+// 明细列：标题、宽度比例、对齐方式
+var deliveryNoteColumns = []struct {
+	title string
+	ratio float64
+	align string
+}{
+	{"序号", 10, "C"},
+	{"商品名称", 58, "L"},
+	{"规格", 30, "L"},
+	{"单位", 14, "C"},
+	{"数量", 18, "R"},
+	{"单价", 24, "R"},
+	{"金额", 26, "R"},
+}
+
+const deliveryNoteFooterHeight = 5
+
+// RenderDeliveryNotePDF 生成送货单PDF，明细较多时自动分页，合计、大写金额和签字栏在最后一页
+func RenderDeliveryNotePDF(note *model.DeliveryNote, layoutName string) (*bytes.Buffer, error) {
+	if layoutName == "" {
+		layoutName = model.DeliveryNoteLayoutA4
+	}
+	layout, ok := deliveryNoteLayouts[layoutName]
+	if !ok {
+		return nil, errors.New("送货单版式只能为 a4 或 triplicate")
+	}
+	pdf, err := pkg.NewPDF("P", layout.size)
+	if err != nil {
+		return nil, err
+	}
+	pdf.SetMargins(layout.margin, layout.margin, layout.margin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetLineWidth(0.2)
+
+	contentWidth := layout.size.Wd - 2*layout.margin
+	var ratioSum float64
+	for _, col := range deliveryNoteColumns {
+		ratioSum += col.ratio
+	}
+	widths := make([]float64, len(deliveryNoteColumns))
+	for i, col := range deliveryNoteColumns {
+		widths[i] = contentWidth * col.ratio / ratioSum
+	}
+
+	// 1. 分页：抬头为标题和4行信息，最后一页需留出合计、备注和签字栏
+	headerHeight := layout.titleSize*0.5 + 2 + 4*layout.lineHeight + 2
+	tableTop := layout.margin + headerHeight
+	bottom := layout.size.Ht - layout.margin - deliveryNoteFooterHeight
+	rowsPerPage := int((bottom - tableTop - layout.rowHeight) / layout.rowHeight)
+	summaryHeight := 2*layout.rowHeight + layout.signHeight + 2
+	if note.Remark != "" {
+		summaryHeight += layout.rowHeight
+	}
+	lastPageRows := int((bottom - tableTop - layout.rowHeight - summaryHeight) / layout.rowHeight)
+	if lastPageRows < 1 {
+		lastPageRows = 1
+	}
+	var pages [][]model.StockOperationItem
+	remaining := note.Items
+	for len(remaining) > lastPageRows {
+		n := rowsPerPage
+		if n >= len(remaining) {
+			n = len(remaining) - 1
+		}
+		pages = append(pages, remaining[:n])
+		remaining = remaining[n:]
+	}
+	pages = append(pages, remaining)
+
+	// 2. 逐页绘制
+	index := 0
+	for pageNo, rows := range pages {
+		pdf.AddPage()
+		drawDeliveryNoteHeader(pdf, note, &layout, contentWidth)
+
+		pdf.SetFont(pkg.PDFFontFamily, "", layout.fontSize)
+		pdf.SetXY(layout.margin, tableTop)
+		for i, col := range deliveryNoteColumns {
+			pdf.CellFormat(widths[i], layout.rowHeight, col.title, "1", 0, "C", false, 0, "")
+		}
+		pdf.Ln(-1)
+		for _, item := range rows {
+			index++
+			cells := []string{
+				fmt.Sprintf("%d", index),
+				item.ProductName,
+				item.Specification,
+				item.Unit,
+				fmt.Sprintf("%d", item.Quantity),
+				fmt.Sprintf("%.2f", item.UnitPrice.Yuan()),
+				fmt.Sprintf("%.2f", item.TotalPrice.Yuan()),
+			}
+			pdf.SetX(layout.margin)
+			for i, col := range deliveryNoteColumns {
+				text := pkg.FitPDFText(pdf, cells[i], widths[i]-2)
+				pdf.CellFormat(widths[i], layout.rowHeight, text, "1", 0, col.align, false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+
+		if pageNo == len(pages)-1 {
+			drawDeliveryNoteSummary(pdf, note, &layout, contentWidth, widths)
+		}
+		drawDeliveryNoteFooter(pdf, &layout, contentWidth, pageNo+1, len(pages))
+	}
+	return pkg.OutputPDF(pdf)
+}
+
+// drawDeliveryNoteHeader 绘制抬头：店铺名称、店铺地址电话、客户和单据信息
+func drawDeliveryNoteHeader(pdf *gofpdf.Fpdf, note *model.DeliveryNote, layout *deliveryNoteLayout, contentWidth float64) {
+	x := layout.margin
+	pdf.SetXY(x, layout.margin)
+	pdf.SetFont(pkg.PDFFontFamily, "", layout.titleSize)
+	pdf.CellFormat(contentWidth, layout.titleSize*0.5, note.ShopName+" 送货单", "", 1, "C", false, 0, "")
+
+	pdf.SetFont(pkg.PDFFontFamily, "", layout.fontSize-1)
+	contact := note.ShopAddress
+	if note.ShopPhone != "" {
+		if contact != "" {
+			contact += "    "
+		}
+		contact += "电话：" + note.ShopPhone
+	}
+	pdf.SetXY(x, layout.margin+layout.titleSize*0.5+1)
+	pdf.CellFormat(contentWidth, layout.lineHeight, contact, "", 1, "C", false, 0, "")
+
+	// 客户信息在左，单据信息在右
+	pdf.SetFont(pkg.PDFFontFamily, "", layout.fontSize)
+	leftWidth := contentWidth * 0.68
+	rightWidth := contentWidth - leftWidth
+	noteNo := "单号：" + note.NoteNo
+	if note.OrderNo != "" {
+		noteNo = "订单号：" + note.OrderNo
+	}
+	lines := [][2]string{
+		{"客户：" + note.CustomerName, noteNo},
+		{"电话：" + note.CustomerPhone, "日期：" + note.Date.Format("2006-01-02")},
+		{"地址：" + note.CustomerAddress, ""},
+	}
+	if note.OrderNo != "" {
+		lines[2][1] = "单号：" + note.NoteNo
+	}
+	y := layout.margin + layout.titleSize*0.5 + 2 + layout.lineHeight
+	for _, line := range lines {
+		pdf.SetXY(x, y)
+		pdf.CellFormat(leftWidth, layout.lineHeight, pkg.FitPDFText(pdf, line[0], leftWidth-2), "", 0, "L", false, 0, "")
+		pdf.CellFormat(rightWidth, layout.lineHeight, pkg.FitPDFText(pdf, line[1], rightWidth), "", 0, "L", false, 0, "")
+		y += layout.lineHeight
+	}
+}
+
+// drawDeliveryNoteSummary 绘制合计、大写金额、备注和签字栏
+func drawDeliveryNoteSummary(pdf *gofpdf.Fpdf, note *model.DeliveryNote, layout *deliveryNoteLayout, contentWidth float64, widths []float64) {
+	x := layout.margin
+	h := layout.rowHeight
+
+	// 合计行：数量和金额对齐到对应列
+	labelWidth := widths[0] + widths[1] + widths[2] + widths[3]
+	pdf.SetX(x)
+	pdf.CellFormat(labelWidth, h, "合计", "1", 0, "C", false, 0, "")
+	pdf.CellFormat(widths[4], h, fmt.Sprintf("%d", note.TotalQuantity), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[5], h, "", "1", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[6], h, fmt.Sprintf("%.2f", note.TotalAmount.Yuan()), "1", 1, "R", false, 0, "")
+
+	pdf.SetX(x)
+	upper := fmt.Sprintf("金额大写：%s    ￥%.2f", pkg.RMBUpper(int64(note.TotalAmount)), note.TotalAmount.Yuan())
+	pdf.CellFormat(contentWidth, h, upper, "1", 1, "L", false, 0, "")
+
+	if note.Remark != "" {
+		pdf.SetX(x)
+		pdf.CellFormat(contentWidth, h, pkg.FitPDFText(pdf, "备注："+note.Remark, contentWidth-2), "1", 1, "L", false, 0, "")
+	}
+
+	// 签字栏
+	y := pdf.GetY() + 2
+	boxWidth := contentWidth / 3
+	labels := []string{"开单人：" + note.Operator, "送货人(签字)：", "收货人(签字)："}
+	for i, label := range labels {
+		bx := x + float64(i)*boxWidth
+		pdf.Rect(bx, y, boxWidth, layout.signHeight, "D")
+		pdf.SetXY(bx+1, y+1)
+		pdf.CellFormat(boxWidth-2, layout.lineHeight, pkg.FitPDFText(pdf, label, boxWidth-2), "", 0, "L", false, 0, "")
+		pdf.SetXY(bx+1, y+layout.signHeight-layout.lineHeight-1)
+		pdf.CellFormat(boxWidth-2, layout.lineHeight, "日期：      年    月    日", "", 0, "R", false, 0, "")
+	}
+}
+
+// drawDeliveryNoteFooter 绘制页脚：联次说明和页码
+func drawDeliveryNoteFooter(pdf *gofpdf.Fpdf, layout *deliveryNoteLayout, contentWidth float64, pageNo, pageCount int) {
+	pdf.SetFont(pkg.PDFFontFamily, "", layout.fontSize-1.5)
+	pdf.SetXY(layout.margin, layout.size.Ht-layout.margin-deliveryNoteFooterHeight+1)
+	pdf.CellFormat(contentWidth*0.75, deliveryNoteFooterHeight-1, layout.footer, "", 0, "L", false, 0, "")
+	pdf.CellFormat(contentWidth*0.25, deliveryNoteFooterHeight-1, fmt.Sprintf("第 %d/%d 页", pageNo, pageCount), "", 0, "R", false, 0, "")
+}
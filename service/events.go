@@ -0,0 +1,65 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"log"
+)
+
+// publishOrderEvent 发布订单事件
+func publishOrderEvent(eventType string, order *model.Order) {
+	pkg.PublishEvent(eventType, order.ShopID, orderEventData(order))
+}
+
+// orderEventData 订单事件数据
+func orderEventData(order *model.Order) model.OrderEvent {
+	return model.OrderEvent{
+		OrderID:       order.ID,
+		OrderNo:       order.OrderNo,
+		UserID:        order.UserId,
+		ShopID:        order.ShopID,
+		TotalAmount:   order.TotalAmount,
+		PaymentAmount: order.PaymentAmount,
+		OrderStatus:   order.OrderStatus,
+		PaymentStatus: order.PaymentStatus,
+		ReceiverName:  order.ReceiverName,
+		ReceiverPhone: order.ReceiverPhone,
+		ItemCount:     len(order.Items),
+		CreatedAt:     order.CreatedAt,
+	}
+}
+
+// publishLowStockEvents 库存减少后，对本次降到低库存阈值的商品发布低库存事件(已低于阈值的不重复发布)
+func publishLowStockEvents(productRepo repository.ProductRepository, operation *model.StockOperation, items []model.StockOperationItem) {
+	decreased := make(map[int64]int)
+	var productIDs []int64
+	for _, item := range items {
+		if item.IsBundle == model.BundleYes {
+			continue
+		}
+		if _, ok := decreased[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		decreased[item.ProductID] += item.Quantity
+	}
+
+	threshold := stockWarningThreshold()
+	for _, productID := range productIDs {
+		product, err := productRepo.GetByID(productID)
+		if err != nil {
+			log.Printf("检查低库存失败(商品 %d): %v", productID, err)
+			continue
+		}
+		if product.Stock > threshold || product.Stock+decreased[productID] <= threshold {
+			continue
+		}
+		pkg.PublishEvent(model.EventStockLow, product.ShopID, model.StockLowEvent{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Stock:       product.Stock,
+			Threshold:   threshold,
+			OperationNo: operation.OperationNo,
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"cmf/paint_proj/configs"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 短信限流默认值
+const (
+	defaultSmsPerMinute = 1
+	defaultSmsPerDay    = 10
+)
+
+// smsOperatorSystem 自动发送的短信记录的操作人
+const smsOperatorSystem = "system"
+
+// smsRecipientError 客户不接收短信(非后台客户、没有手机号等)，自动发送时忽略
+type smsRecipientError struct {
+	msg string
+}
+
+func (e *smsRecipientError) Error() string {
+	return e.msg
+}
+
+type SmsService interface {
+	StartJobs() // 订阅后台出库事件发送出库短信，并启动月度对账短信任务
+
+	SendOutboundDelivery(operationID int64, operator string) (*model.SmsLog, error)                                         // 出库送货确认
+	SendPaymentReminder(shopID, userID int64, operator string) (*model.SmsLog, error)                                       // 客户全部未结清出库单的欠款提醒
+	SendOperationsReminder(shopID, userID int64, operations []model.StockOperation, operator string) (*model.SmsLog, error) // 按指定的未结清出库单发送欠款提醒
+	SendMonthlyBalance(shopID int64, month time.Time, operator string) (*model.SmsBatchResult, error)                       // 店铺后台客户的月度对账
+	GetLogs(query *model.SmsLogQuery) ([]model.SmsLog, int64, error)
+}
+
+type smsService struct {
+	notifyRepo repository.NotifyRepository
+	stockRepo  repository.StockRepository
+	userRepo   repository.UserRepository
+	shopRepo   repository.ShopRepository
+	provider   pkg.SmsProvider
+	mu         sync.Mutex     // 限流检查和名额预占串行执行，避免并发超发
+	sending    map[string]int // 手机号 => 已预占名额、发送结果尚未记录的条数
+}
+
+func NewSmsService(notifyRepo repository.NotifyRepository, stockRepo repository.StockRepository, userRepo repository.UserRepository, shopRepo repository.ShopRepository, provider pkg.SmsProvider) SmsService {
+	return &smsService{
+		notifyRepo: notifyRepo,
+		stockRepo:  stockRepo,
+		userRepo:   userRepo,
+		shopRepo:   shopRepo,
+		provider:   provider,
+		sending:    make(map[string]int),
+	}
+}
+
+// StartJobs 后台出库后给后台客户发送出库短信；每小时检查一次，到了配置的日期给各店铺客户发送上月对账短信(已发送的不重复发送)
+func (s *smsService) StartJobs() {
+	events, _ := pkg.Events.Subscribe("短信通知", 100, func(event pkg.Event) bool {
+		return event.Type == model.EventStockOutbound
+	})
+	go func() {
+		for event := range events {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Printf("出库短信发送异常: %v", r)
+					}
+				}()
+				data, ok := event.Data.(model.StockOutboundEvent)
+				if !ok {
+					return
+				}
+				if _, ok := configs.Cfg.Sms.Templates[model.SmsSceneOutboundDelivery]; !ok {
+					return
+				}
+				var recipientErr *smsRecipientError
+				if _, err := s.SendOutboundDelivery(data.OperationID, smsOperatorSystem); err != nil && !errors.As(err, &recipientErr) {
+					log.Printf("出库单 %s 发送短信失败: %v", data.OperationNo, err)
+				}
+			}()
+		}
+	}()
+
+	pkg.RunEvery("月度对账短信任务", time.Hour, func() error {
+		return s.sendDueMonthlyBalances(time.Now())
+	})
+}
+
+// sendDueMonthlyBalances 每月配置的日期9点后，给全部启用店铺发送上月对账短信
+func (s *smsService) sendDueMonthlyBalances(now time.Time) error {
+	day := configs.Cfg.Sms.MonthlyBalanceDay
+	if day <= 0 || now.Day() != day || now.Hour() < 9 {
+		return nil
+	}
+	if _, ok := configs.Cfg.Sms.Templates[model.SmsSceneMonthlyBalance]; !ok {
+		return nil
+	}
+	shops, err := s.shopRepo.GetAllActiveShops()
+	if err != nil {
+		return err
+	}
+	lastMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, -1, 0)
+	for _, shop := range shops {
+		result, err := s.SendMonthlyBalance(shop.ID, lastMonth, smsOperatorSystem)
+		if err != nil {
+			log.Printf("店铺 %d 发送月度对账短信失败: %v", shop.ID, err)
+			continue
+		}
+		if result.Sent > 0 || result.Failed > 0 {
+			log.Printf("店铺 %d 月度对账短信: 发送 %d，失败 %d，未发送 %d", shop.ID, result.Sent, result.Failed, result.Skipped)
+		}
+	}
+	return nil
+}
+
+// SendOutboundDelivery 出库送货确认短信，只发给后台添加且有手机号的客户
+func (s *smsService) SendOutboundDelivery(operationID int64, operator string) (*model.SmsLog, error) {
+	operation, err := s.stockRepo.GetStockOperationByID(operationID)
+	if err != nil {
+		return nil, errors.New("出库单不存在")
+	}
+	if operation.Types != model.StockTypeOutbound || operation.OutboundType != model.OutboundTypeAdmin {
+		return nil, errors.New("只有后台出库单可以发送出库短信")
+	}
+	if operation.IsVoided == 1 {
+		return nil, errors.New("出库单已作废")
+	}
+	user, err := s.smsCustomer(operation.UserID)
+	if err != nil {
+		return nil, err
+	}
+	shop := s.shop(operation.ShopID)
+	vars := map[string]string{
+		"customer_name": smsCustomerName(user, operation.UserName),
+		"shop_name":     shop.Name,
+		"shop_phone":    shop.Phone,
+		"operation_no":  operation.OperationNo,
+		"quantity":      fmt.Sprintf("%d", operation.TotalQuantity),
+		"amount":        fmt.Sprintf("%.2f", operation.TotalAmount.Yuan()),
+		"date":          smsDate(operation.CreatedAt),
+	}
+	return s.send(operation.ShopID, user, model.SmsSceneOutboundDelivery, operation.OperationNo, vars, operator)
+}
+
+// SendPaymentReminder 客户在店铺全部未结清出库单的欠款提醒
+func (s *smsService) SendPaymentReminder(shopID, userID int64, operator string) (*model.SmsLog, error) {
+	operations, err := s.notifyRepo.GetUnpaidOutbounds(shopID, userID, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(operations) == 0 {
+		return nil, errors.New("客户没有未结清的出库单")
+	}
+	return s.sendPaymentReminder(shopID, userID, operations, operator)
+}
+
+// SendOperationsReminder 按指定的未结清出库单发送欠款提醒，客户不接收短信时返回 smsRecipientError
+func (s *smsService) SendOperationsReminder(shopID, userID int64, operations []model.StockOperation, operator string) (*model.SmsLog, error) {
+	if len(operations) == 0 {
+		return nil, errors.New("没有需要提醒的出库单")
+	}
+	return s.sendPaymentReminder(shopID, userID, operations, operator)
+}
+
+// sendPaymentReminder 按给定的未结清出库单汇总欠款发送提醒，引用单号为最早一笔出库单号
+func (s *smsService) sendPaymentReminder(shopID, userID int64, operations []model.StockOperation, operator string) (*model.SmsLog, error) {
+	user, err := s.smsCustomer(userID)
+	if err != nil {
+		return nil, err
+	}
+	var total model.Amount
+	for _, operation := range operations {
+		total += operation.UnpaidAmount
+	}
+	shop := s.shop(shopID)
+	vars := map[string]string{
+		"customer_name": smsCustomerName(user, operations[0].UserName),
+		"shop_name":     shop.Name,
+		"shop_phone":    shop.Phone,
+		"count":         fmt.Sprintf("%d", len(operations)),
+		"amount":        fmt.Sprintf("%.2f", total.Yuan()),
+		"oldest_date":   smsDate(operations[0].CreatedAt),
+	}
+	return s.send(shopID, user, model.SmsScenePaymentReminder, operations[0].OperationNo, vars, operator)
+}
+
+// SendMonthlyBalance 给店铺有提货或欠款的后台客户发送月度对账短信，同一客户同一月份只发送一次
+func (s *smsService) SendMonthlyBalance(shopID int64, month time.Time, operator string) (*model.SmsBatchResult, error) {
+	if _, ok := configs.Cfg.Sms.Templates[model.SmsSceneMonthlyBalance]; !ok {
+		return nil, errors.New("未配置月度对账短信模板")
+	}
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 1, 0)
+	refNo := start.Format("2006-01")
+	rows, err := s.notifyRepo.GetMonthlyBalances(shopID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	shop := s.shop(shopID)
+	result := &model.SmsBatchResult{Total: len(rows)}
+	for _, row := range rows {
+		sent, err := s.notifyRepo.HasSentSms(row.UserID, model.SmsSceneMonthlyBalance, refNo)
+		if err != nil {
+			return nil, err
+		}
+		if sent {
+			result.Skipped++
+			continue
+		}
+		user, err := s.smsCustomer(row.UserID)
+		if err != nil {
+			result.Skipped++
+			continue
+		}
+		vars := map[string]string{
+			"customer_name": smsCustomerName(user, row.UserName),
+			"shop_name":     shop.Name,
+			"shop_phone":    shop.Phone,
+			"month":         fmt.Sprintf("%d年%d月", start.Year(), start.Month()),
+			"month_count":   fmt.Sprintf("%d", row.MonthCount),
+			"month_amount":  fmt.Sprintf("%.2f", row.MonthAmount.Yuan()),
+			"unpaid_count":  fmt.Sprintf("%d", row.UnpaidCount),
+			"unpaid_amount": fmt.Sprintf("%.2f", row.UnpaidAmount.Yuan()),
+		}
+		smsLog, err := s.send(shopID, user, model.SmsSceneMonthlyBalance, refNo, vars, operator)
+		if err != nil {
+			return nil, err
+		}
+		switch smsLog.Status {
+		case model.NotifyStatusSent:
+			result.Sent++
+		case model.NotifyStatusFailed:
+			result.Failed++
+		default:
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+// GetLogs 短信发送记录
+func (s *smsService) GetLogs(query *model.SmsLogQuery) ([]model.SmsLog, int64, error) {
+	return s.notifyRepo.GetSmsLogs(query)
+}
+
+// smsCustomer 获取短信接收客户：只给后台添加且有手机号的客户发送(小程序客户使用订阅消息)
+func (s *smsService) smsCustomer(userID int64) (*model.User, error) {
+	if userID <= 0 {
+		return nil, &smsRecipientError{"出库单未关联客户"}
+	}
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, &smsRecipientError{"客户不存在"}
+	}
+	if user.Source != model.UserSourceAdmin {
+		return nil, &smsRecipientError{"短信只发送给后台添加的客户"}
+	}
+	if user.MobilePhone == "" {
+		return nil, &smsRecipientError{"客户没有手机号"}
+	}
+	return user, nil
+}
+
+// shop 获取店铺名称和电话，查询失败时返回空店铺，不影响发送
+func (s *smsService) shop(shopID int64) *model.Shop {
+	shop, err := s.shopRepo.GetShopByID(shopID)
+	if err != nil {
+		return &model.Shop{ID: shopID}
+	}
+	return shop
+}
+
+// send 按场景模板渲染并发送短信，记录发送结果；场景未配置或触发限流时记录为未发送
+// 限流检查通过后先预占名额再释放锁，调用短信服务商期间不阻塞其他手机号的发送
+func (s *smsService) send(shopID int64, user *model.User, scene, refNo string, vars map[string]string, operator string) (*model.SmsLog, error) {
+	smsLog, params, err := renderSms(scene, vars)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	smsLog.ShopID = shopID
+	smsLog.UserID = user.ID
+	smsLog.Phone = user.MobilePhone
+	smsLog.RefNo = refNo
+	smsLog.Provider = s.provider.Name()
+	smsLog.Operator = operator
+	smsLog.CreatedAt = &now
+
+	reason, err := s.reserve(user.MobilePhone, now)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		smsLog.Status = model.NotifyStatusSkipped
+		smsLog.Error = reason
+	} else {
+		// 发送结果写入记录后再释放名额，之后的限流检查按记录计数
+		defer s.release(user.MobilePhone)
+		bizID, err := s.provider.Send(&pkg.SmsMessage{
+			Phone:        user.MobilePhone,
+			TemplateCode: smsLog.TemplateCode,
+			Params:       params,
+			Content:      smsLog.Content,
+		})
+		if err != nil {
+			smsLog.Status = model.NotifyStatusFailed
+			smsLog.Error = err.Error()
+		} else {
+			smsLog.Status = model.NotifyStatusSent
+			smsLog.BizID = bizID
+		}
+	}
+	if err := s.notifyRepo.CreateSmsLog(smsLog); err != nil {
+		return nil, err
+	}
+	return smsLog, nil
+}
+
+// renderSms 按场景模板渲染短信内容和模板参数
+func renderSms(scene string, vars map[string]string) (*model.SmsLog, map[string]string, error) {
+	tpl, ok := configs.Cfg.Sms.Templates[scene]
+	if !ok {
+		return nil, nil, fmt.Errorf("未配置短信模板: %s", scene)
+	}
+	replacer := smsReplacer(vars)
+	params := make(map[string]string, len(tpl.Params))
+	for key, value := range tpl.Params {
+		params[key] = replacer.Replace(value)
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	smsLog := &model.SmsLog{
+		UserName:     vars["customer_name"],
+		Scene:        scene,
+		TemplateCode: tpl.TemplateCode,
+		Params:       string(paramsJSON),
+		Content:      replacer.Replace(tpl.Content),
+	}
+	return smsLog, params, nil
+}
+
+// reserve 检查手机号限流，未超限时预占一条名额；超限时返回原因
+func (s *smsService) reserve(phone string, now time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reason, err := s.rateLimited(phone, now)
+	if err != nil || reason != "" {
+		return reason, err
+	}
+	s.sending[phone]++
+	return "", nil
+}
+
+// release 释放预占的名额
+func (s *smsService) release(phone string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sending[phone]--
+	if s.sending[phone] <= 0 {
+		delete(s.sending, phone)
+	}
+}
+
+// rateLimited 同一手机号按分钟和按天限流，已预占名额、尚未记录结果的短信一并计入，超限时返回原因；调用方需持有 s.mu
+func (s *smsService) rateLimited(phone string, now time.Time) (string, error) {
+	perMinute := configs.Cfg.Sms.RateLimitPerMinute
+	if perMinute <= 0 {
+		perMinute = defaultSmsPerMinute
+	}
+	perDay := configs.Cfg.Sms.RateLimitPerDay
+	if perDay <= 0 {
+		perDay = defaultSmsPerDay
+	}
+	sending := int64(s.sending[phone])
+	count, err := s.notifyRepo.CountSentSms(phone, now.Add(-time.Minute))
+	if err != nil {
+		return "", err
+	}
+	if count+sending >= int64(perMinute) {
+		return fmt.Sprintf("发送过于频繁(每分钟最多%d条)", perMinute), nil
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	count, err = s.notifyRepo.CountSentSms(phone, today)
+	if err != nil {
+		return "", err
+	}
+	if count+sending >= int64(perDay) {
+		return fmt.Sprintf("超过每天发送上限(%d条)", perDay), nil
+	}
+	return "", nil
+}
+
+// smsReplacer 把 {变量名} 替换为变量值
+func smsReplacer(vars map[string]string) *strings.Replacer {
+	pairs := make([]string, 0, len(vars)*2)
+	for key, value := range vars {
+		pairs = append(pairs, "{"+key+"}", value)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// smsCustomerName 短信中的客户称呼
+func smsCustomerName(user *model.User, fallback string) string {
+	for _, name := range []string{user.AdminDisplayName, fallback, user.Nickname} {
+		if name != "" {
+			return name
+		}
+	}
+	return "客户"
+}
+
+// smsDate 短信中的日期
+func smsDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"cmf/paint_proj/configs"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func (r *fakeNotifyRepo) CreateSmsLog(smsLog *model.SmsLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	smsLog.ID = r.nextID
+	r.smsLogs = append(r.smsLogs, *smsLog)
+	return nil
+}
+
+func (r *fakeNotifyRepo) CountSentSms(phone string, since time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, smsLog := range r.smsLogs {
+		if smsLog.Phone == phone && smsLog.Status == model.NotifyStatusSent && !smsLog.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// fakeSmsProvider 记录发送的短信；block 不为 nil 时发送前等待，用于模拟服务商接口耗时
+type fakeSmsProvider struct {
+	mu      sync.Mutex
+	sent    []pkg.SmsMessage
+	started chan string
+	block   chan struct{}
+}
+
+func (p *fakeSmsProvider) Name() string { return "fake" }
+
+func (p *fakeSmsProvider) Send(msg *pkg.SmsMessage) (string, error) {
+	if p.started != nil {
+		p.started <- msg.Phone
+	}
+	if p.block != nil {
+		<-p.block
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, *msg)
+	return fmt.Sprintf("biz-%d", len(p.sent)), nil
+}
+
+var testSmsTemplates = map[string]configs.SmsTemplateConfig{
+	model.SmsSceneMonthlyBalance: {
+		TemplateCode: "SMS_1",
+		Content:      "{customer_name}您好，您{month}在{shop_name}提货{month_count}笔，金额{month_amount}元；目前未结清{unpaid_count}笔，合计{unpaid_amount}元。",
+		Params: map[string]string{
+			"name":          "{customer_name}",
+			"month_count":   "{month_count}",
+			"unpaid_count":  "{unpaid_count}",
+			"unpaid_amount": "{unpaid_amount}",
+		},
+	},
+}
+
+func setupSmsTest(t *testing.T, perMinute, perDay int) (*smsService, *fakeNotifyRepo, *fakeSmsProvider) {
+	t.Helper()
+	old := configs.Cfg
+	configs.Cfg = &configs.Config{Sms: configs.SmsConfig{RateLimitPerMinute: perMinute, RateLimitPerDay: perDay, Templates: testSmsTemplates}}
+	t.Cleanup(func() { configs.Cfg = old })
+
+	repo := newFakeNotifyRepo()
+	provider := &fakeSmsProvider{}
+	return NewSmsService(repo, nil, nil, nil, provider).(*smsService), repo, provider
+}
+
+func TestRenderSms(t *testing.T) {
+	setupSmsTest(t, 1, 10)
+	smsLog, params, err := renderSms(model.SmsSceneMonthlyBalance, map[string]string{
+		"customer_name": "王师傅",
+		"shop_name":     "城东店",
+		"month":         "2026年9月",
+		"month_count":   "3",
+		"month_amount":  "450.00",
+		"unpaid_count":  "2",
+		"unpaid_amount": "300.00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "王师傅您好，您2026年9月在城东店提货3笔，金额450.00元；目前未结清2笔，合计300.00元。"
+	if smsLog.Content != want {
+		t.Fatalf("content got %s, want %s", smsLog.Content, want)
+	}
+	if params["month_count"] != "3" || params["unpaid_count"] != "2" || params["name"] != "王师傅" {
+		t.Fatalf("params got %v", params)
+	}
+	var saved map[string]string
+	if err := json.Unmarshal([]byte(smsLog.Params), &saved); err != nil || saved["unpaid_amount"] != "300.00" {
+		t.Fatalf("记录的参数 got %s (%v)", smsLog.Params, err)
+	}
+	if smsLog.TemplateCode != "SMS_1" || smsLog.UserName != "王师傅" || smsLog.Scene != model.SmsSceneMonthlyBalance {
+		t.Fatalf("got %+v", smsLog)
+	}
+
+	if _, _, err := renderSms(model.SmsSceneOutboundDelivery, nil); err == nil {
+		t.Fatal("未配置的场景应返回错误")
+	}
+}
+
+// 默认配置中短信内容用到的变量都要作为模板参数传给服务商，否则阿里云模板中该变量为空
+func TestDefaultSmsTemplateParams(t *testing.T) {
+	v := viper.New()
+	v.SetConfigFile("../config.yaml")
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	var cfg configs.SmsConfig
+	if err := v.UnmarshalKey("sms", &cfg); err != nil {
+		t.Fatal(err)
+	}
+	varPattern := regexp.MustCompile(`\{[a-z_]+\}`)
+	for scene, tpl := range cfg.Templates {
+		used := make(map[string]bool)
+		for _, value := range tpl.Params {
+			used[value] = true
+		}
+		for _, name := range varPattern.FindAllString(tpl.Content, -1) {
+			if !used[name] {
+				t.Errorf("%s: 内容中的 %s 没有对应的模板参数", scene, name)
+			}
+		}
+	}
+}
+
+func TestSmsRateLimitPerPhone(t *testing.T) {
+	service, repo, provider := setupSmsTest(t, 1, 2)
+	user := &model.User{ID: 1, MobilePhone: "13800000001"}
+	vars := map[string]string{"customer_name": "王师傅"}
+
+	smsLog, err := service.send(1, user, model.SmsSceneMonthlyBalance, "2026-09", vars, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smsLog.Status != model.NotifyStatusSent || smsLog.BizID != "biz-1" {
+		t.Fatalf("第一条 got %+v, want 已发送", smsLog)
+	}
+
+	// 同一手机号一分钟内第二条被限流，记录为未发送
+	smsLog, err = service.send(1, user, model.SmsSceneMonthlyBalance, "2026-09", vars, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smsLog.Status != model.NotifyStatusSkipped || smsLog.Error == "" {
+		t.Fatalf("第二条 got %+v, want 限流未发送", smsLog)
+	}
+
+	// 其他手机号不受影响
+	smsLog, err = service.send(1, &model.User{ID: 2, MobilePhone: "13800000002"}, model.SmsSceneMonthlyBalance, "2026-09", vars, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smsLog.Status != model.NotifyStatusSent {
+		t.Fatalf("其他手机号 got %+v, want 已发送", smsLog)
+	}
+
+	// 一分钟前发送的计入当天上限
+	earlier := time.Now().Add(-2 * time.Minute)
+	repo.mu.Lock()
+	for i := range repo.smsLogs {
+		repo.smsLogs[i].CreatedAt = &earlier
+	}
+	repo.mu.Unlock()
+	if smsLog, _ = service.send(1, user, model.SmsSceneMonthlyBalance, "2026-09", vars, "admin"); smsLog.Status != model.NotifyStatusSent {
+		t.Fatalf("分钟限流过后 got %+v, want 已发送", smsLog)
+	}
+	repo.mu.Lock()
+	for i := range repo.smsLogs {
+		repo.smsLogs[i].CreatedAt = &earlier
+	}
+	repo.mu.Unlock()
+	if smsLog, _ = service.send(1, user, model.SmsSceneMonthlyBalance, "2026-09", vars, "admin"); smsLog.Status != model.NotifyStatusSkipped {
+		t.Fatalf("超过每天上限 got %+v, want 未发送", smsLog)
+	}
+	if len(provider.sent) != 3 {
+		t.Fatalf("服务商收到 %d 条, want 3", len(provider.sent))
+	}
+}
+
+// 调用服务商期间不持有锁：同一手机号的并发发送按预占名额限流，其他手机号可以同时发送
+func TestSmsRateLimitReservesWhileSending(t *testing.T) {
+	service, _, provider := setupSmsTest(t, 1, 10)
+	provider.started = make(chan string, 2)
+	provider.block = make(chan struct{})
+	vars := map[string]string{"customer_name": "王师傅"}
+
+	results := make(chan *model.SmsLog, 2)
+	go func() {
+		smsLog, _ := service.send(1, &model.User{ID: 1, MobilePhone: "13800000001"}, model.SmsSceneMonthlyBalance, "2026-09", vars, "admin")
+		results <- smsLog
+	}()
+	<-provider.started
+
+	// 第一条还在发送中，同一手机号的第二条按预占名额限流，不等待第一条发送完成
+	smsLog, err := service.send(1, &model.User{ID: 1, MobilePhone: "13800000001"}, model.SmsSceneMonthlyBalance, "2026-09", vars, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smsLog.Status != model.NotifyStatusSkipped {
+		t.Fatalf("发送中的手机号 got %+v, want 限流未发送", smsLog)
+	}
+
+	// 其他手机号可以同时调用服务商
+	go func() {
+		smsLog, _ := service.send(1, &model.User{ID: 2, MobilePhone: "13800000002"}, model.SmsSceneMonthlyBalance, "2026-09", vars, "admin")
+		results <- smsLog
+	}()
+	select {
+	case <-provider.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("其他手机号的发送被阻塞")
+	}
+
+	close(provider.block)
+	for i := 0; i < 2; i++ {
+		if smsLog := <-results; smsLog == nil || smsLog.Status != model.NotifyStatusSent {
+			t.Fatalf("got %+v, want 已发送", smsLog)
+		}
+	}
+	if len(service.sending) != 0 {
+		t.Fatalf("发送完成后应释放名额, got %v", service.sending)
+	}
+}
@@ -0,0 +1,428 @@
+package service
+
+import (
+	"cmf/paint_proj/configs"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 订阅消息默认最多发送次数(含重试)
+const defaultSubscribeMaxAttempts = 3
+
+// subscribeSendGrace 新消息创建时设置的重试时间：立即发送未完成(如进程退出)时，由重试任务在此之后补发
+const subscribeSendGrace = time.Minute
+
+// 订单事件对应的通知场景
+var orderEventScenes = map[string]string{
+	model.EventOrderPaid:    model.NotifySceneOrderPaid,
+	model.EventOrderShipped: model.NotifySceneOrderShipped,
+	model.EventOrderPickup:  model.NotifySceneOrderPickup,
+}
+
+// 订阅消息模板字段类型的长度限制(字符数)，字段名去掉末尾数字即为类型，如 thing3 => thing
+var subscribeFieldLimits = map[string]int{
+	"thing":            20,
+	"character_string": 32,
+	"number":           32,
+	"letter":           32,
+	"symbol":           5,
+	"amount":           32,
+	"phrase":           5,
+	"name":             10,
+	"phone_number":     17,
+	"car_number":       8,
+}
+
+type WechatNotifyService interface {
+	Start() // 订阅订单事件并启动失败重试任务
+
+	// 小程序订阅授权
+	GetSubscribeTemplates() []model.SubscribeTemplate
+	RecordConsent(userID int64, req *model.SubscribeConsentRequest) error
+
+	// 后台发送记录
+	GetMessages(query *model.WechatMessageQuery) ([]model.WechatSubscribeMessage, int64, error)
+	GetMessageByID(id int64) (*model.WechatSubscribeMessage, error)
+	RetryMessage(id int64) error
+
+	SendPaymentReminder(user *model.User, reminder *model.PaymentReminder) (*model.WechatSubscribeMessage, error) // 欠款提醒
+}
+
+type wechatNotifyService struct {
+	notifyRepo repository.NotifyRepository
+	orderRepo  repository.OrderRepository
+	userRepo   repository.UserRepository
+	stockRepo  repository.StockRepository
+	shopRepo   repository.ShopRepository
+	api        pkg.WechatAPI
+	tokens     *pkg.WechatTokenCache
+}
+
+func NewWechatNotifyService(notifyRepo repository.NotifyRepository, orderRepo repository.OrderRepository, userRepo repository.UserRepository, stockRepo repository.StockRepository, shopRepo repository.ShopRepository, api pkg.WechatAPI) WechatNotifyService {
+	return &wechatNotifyService{
+		notifyRepo: notifyRepo,
+		orderRepo:  orderRepo,
+		userRepo:   userRepo,
+		stockRepo:  stockRepo,
+		shopRepo:   shopRepo,
+		api:        api,
+		tokens:     pkg.NewWechatTokenCache(api),
+	}
+}
+
+// Start 处理订单支付、发货、备货事件发送订阅消息，并每分钟重发到期的失败消息
+// 事件由同步处理方在发货、备货、支付回调的请求中处理，先写入发送记录再另起协程发送，不会因事件丢弃而漏发
+func (s *wechatNotifyService) Start() {
+	pkg.Events.Handle("微信订阅消息", func(event pkg.Event) bool {
+		_, ok := orderEventScenes[event.Type]
+		return ok
+	}, func(event pkg.Event) {
+		data, ok := event.Data.(model.OrderEvent)
+		if !ok {
+			return
+		}
+		msg, err := s.notifyOrder(orderEventScenes[event.Type], &data, event.Time)
+		if err != nil {
+			log.Printf("订单 %s 记录订阅消息失败: %v", data.OrderNo, err)
+			return
+		}
+		if msg != nil && msg.Status == model.NotifyStatusPending {
+			go s.deliverLogged(msg)
+		}
+	})
+
+	pkg.RunEvery("订阅消息重试任务", time.Minute, s.retryDueMessages)
+}
+
+// GetSubscribeTemplates 已配置的订阅消息模板，小程序据此调用 wx.requestSubscribeMessage
+func (s *wechatNotifyService) GetSubscribeTemplates() []model.SubscribeTemplate {
+	templates := make([]model.SubscribeTemplate, 0)
+	for scene, tpl := range configs.Cfg.Wechat.SubscribeTemplates {
+		if tpl.TemplateID != "" {
+			templates = append(templates, model.SubscribeTemplate{Scene: scene, TemplateID: tpl.TemplateID})
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Scene < templates[j].Scene })
+	return templates
+}
+
+// RecordConsent 记录小程序 wx.requestSubscribeMessage 的授权结果，只接受已配置的模板
+func (s *wechatNotifyService) RecordConsent(userID int64, req *model.SubscribeConsentRequest) error {
+	known := make(map[string]bool)
+	for _, tpl := range s.GetSubscribeTemplates() {
+		known[tpl.TemplateID] = true
+	}
+	for templateID, result := range req.Results {
+		if !known[templateID] {
+			continue
+		}
+		switch result {
+		case model.SubscribeResultAccept, model.SubscribeResultReject, model.SubscribeResultBan:
+		default:
+			continue
+		}
+		if err := s.notifyRepo.AddConsent(userID, templateID, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMessages 订阅消息发送记录
+func (s *wechatNotifyService) GetMessages(query *model.WechatMessageQuery) ([]model.WechatSubscribeMessage, int64, error) {
+	return s.notifyRepo.GetWechatMessages(query)
+}
+
+// GetMessageByID 根据ID获取订阅消息发送记录
+func (s *wechatNotifyService) GetMessageByID(id int64) (*model.WechatSubscribeMessage, error) {
+	return s.notifyRepo.GetWechatMessageByID(id)
+}
+
+// RetryMessage 重发失败的消息：重置为待发送，由重试任务在一分钟内发送
+func (s *wechatNotifyService) RetryMessage(id int64) error {
+	msg, err := s.notifyRepo.GetWechatMessageByID(id)
+	if err != nil {
+		return errors.New("消息不存在")
+	}
+	if msg.Status != model.NotifyStatusFailed {
+		return errors.New("只有发送失败的消息可以重发")
+	}
+	now := time.Now()
+	return s.notifyRepo.UpdateWechatMessage(id, map[string]interface{}{
+		"status":        model.NotifyStatusPending,
+		"attempts":      0,
+		"error":         "",
+		"next_retry_at": &now,
+	})
+}
+
+// notifyOrder 按通知场景给下单用户生成订阅消息发送记录(不发送)；场景未配置模板时不生成，返回 nil
+func (s *wechatNotifyService) notifyOrder(scene string, event *model.OrderEvent, eventTime time.Time) (*model.WechatSubscribeMessage, error) {
+	tpl, ok := configs.Cfg.Wechat.SubscribeTemplates[scene]
+	if !ok || tpl.TemplateID == "" {
+		return nil, nil
+	}
+	order, err := s.orderRepo.GetOrderByID(event.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.stockRepo.GetStockOperationItemsByOrderID(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetUserByID(order.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := s.orderTemplateVars(order, items, event.Remark, eventTime)
+	return s.notify(scene, tpl, user, order.ShopID, order.ID, order.OrderNo, vars)
+}
+
+// SendPaymentReminder 给客户发送欠款提醒订阅消息；未配置模板或客户未绑定微信时返回 nil(该渠道不可用)
+func (s *wechatNotifyService) SendPaymentReminder(user *model.User, reminder *model.PaymentReminder) (*model.WechatSubscribeMessage, error) {
+	tpl, ok := configs.Cfg.Wechat.SubscribeTemplates[model.NotifyScenePaymentReminder]
+	if !ok || tpl.TemplateID == "" || user.Openid == "" {
+		return nil, nil
+	}
+	shopName := ""
+	if shop, err := s.shopRepo.GetShopByID(reminder.ShopID); err == nil {
+		shopName = shop.Name
+	}
+	oldestDate := ""
+	if reminder.OldestAt != nil {
+		oldestDate = reminder.OldestAt.Format("2006-01-02")
+	}
+	vars := strings.NewReplacer(
+		"{customer_name}", reminder.UserName,
+		"{shop_name}", shopName,
+		"{count}", fmt.Sprintf("%d", reminder.OperationCount),
+		"{amount}", fmt.Sprintf("%.2f元", reminder.TotalAmount.Yuan()),
+		"{oldest_date}", oldestDate,
+		"{operation_no}", reminder.OldestOperationNo,
+		"{event_time}", time.Now().Format("2006-01-02 15:04"),
+	)
+	msg, err := s.notify(model.NotifyScenePaymentReminder, tpl, user, reminder.ShopID, 0, reminder.OldestOperationNo, vars)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Status == model.NotifyStatusPending {
+		s.deliverLogged(msg)
+	}
+	return msg, nil
+}
+
+// notify 按模板渲染订阅消息并写入发送记录，用户未绑定微信或没有订阅次数时记录为未发送，
+// 否则消耗一次订阅次数，记录为待发送并设置重试时间，由调用方立即发送
+func (s *wechatNotifyService) notify(scene string, tpl configs.SubscribeTemplateConfig, user *model.User, shopID, orderID int64, orderNo string, vars *strings.Replacer) (*model.WechatSubscribeMessage, error) {
+	content := &pkg.SubscribeMessage{
+		ToUser:           user.Openid,
+		TemplateID:       tpl.TemplateID,
+		Page:             vars.Replace(tpl.Page),
+		MiniprogramState: configs.Cfg.Wechat.MiniprogramState,
+		Lang:             "zh_CN",
+		Data:             make(map[string]pkg.SubscribeValue),
+	}
+	for key, value := range tpl.Data {
+		content.Data[key] = pkg.SubscribeValue{Value: limitSubscribeValue(key, vars.Replace(value))}
+	}
+	body, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	msg := &model.WechatSubscribeMessage{
+		ShopID:     shopID,
+		UserID:     user.ID,
+		Openid:     user.Openid,
+		OrderID:    orderID,
+		OrderNo:    orderNo,
+		Scene:      scene,
+		TemplateID: tpl.TemplateID,
+		Content:    string(body),
+		Status:     model.NotifyStatusPending,
+		CreatedAt:  &now,
+	}
+	next := now.Add(subscribeSendGrace)
+	if user.Openid == "" {
+		msg.Status = model.NotifyStatusSkipped
+		msg.Error = "用户未绑定微信"
+	} else if consumed, err := s.notifyRepo.ConsumeConsent(user.ID, tpl.TemplateID); err != nil {
+		return nil, err
+	} else if !consumed {
+		msg.Status = model.NotifyStatusSkipped
+		msg.Error = "用户未订阅该消息或订阅次数已用完"
+	} else {
+		msg.NextRetryAt = &next
+	}
+	if err := s.notifyRepo.CreateWechatMessage(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// orderTemplateVars 模板中可用的订单变量
+func (s *wechatNotifyService) orderTemplateVars(order *model.Order, items []model.StockOperationItem, remark string, eventTime time.Time) *strings.Replacer {
+	var goods []string
+	for _, item := range items {
+		if item.BundleID == 0 { // 套装组件不单独列出
+			goods = append(goods, item.ProductName)
+		}
+	}
+	goodsText := ""
+	if len(goods) > 0 {
+		goodsText = goods[0]
+		if len(goods) > 1 {
+			goodsText = fmt.Sprintf("%s等%d件商品", goods[0], len(goods))
+		}
+	}
+	paymentTime := ""
+	if order.PaymentTime != nil {
+		paymentTime = order.PaymentTime.Format("2006-01-02 15:04")
+	}
+	shopName, shopAddress := "", ""
+	if shop, err := s.shopRepo.GetShopByID(order.ShopID); err == nil {
+		shopName, shopAddress = shop.Name, shop.Address
+	}
+	if remark == "" {
+		remark = "无"
+	}
+	return strings.NewReplacer(
+		"{order_no}", order.OrderNo,
+		"{payment_amount}", fmt.Sprintf("%.2f元", order.PaymentAmount.Yuan()),
+		"{total_amount}", fmt.Sprintf("%.2f元", order.TotalAmount.Yuan()),
+		"{goods}", goodsText,
+		"{goods_count}", fmt.Sprintf("%d", len(goods)),
+		"{payment_time}", paymentTime,
+		"{event_time}", eventTime.Format("2006-01-02 15:04"),
+		"{receiver_name}", order.ReceiverName,
+		"{receiver_phone}", order.ReceiverPhone,
+		"{receiver_address}", order.ReceiverAddress,
+		"{shop_name}", shopName,
+		"{shop_address}", shopAddress,
+		"{remark}", remark,
+	)
+}
+
+// limitSubscribeValue 按模板字段类型截断内容，超长时微信会拒绝整条消息
+func limitSubscribeValue(key, value string) string {
+	limit, ok := subscribeFieldLimits[strings.TrimRight(key, "0123456789")]
+	if !ok || utf8.RuneCountInString(value) <= limit {
+		return value
+	}
+	runes := []rune(value)
+	if limit > 3 {
+		return string(runes[:limit-1]) + "…"
+	}
+	return string(runes[:limit])
+}
+
+// retryDueMessages 重发到了重试时间的消息
+func (s *wechatNotifyService) retryDueMessages() error {
+	msgs, err := s.notifyRepo.GetDueWechatMessages(time.Now(), 50)
+	if err != nil {
+		return err
+	}
+	for i := range msgs {
+		s.deliverLogged(&msgs[i])
+	}
+	return nil
+}
+
+// deliverLogged 发送一条消息，失败只记录日志(发送记录中已保存错误和重试时间)
+func (s *wechatNotifyService) deliverLogged(msg *model.WechatSubscribeMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("订阅消息 %d 发送异常: %v", msg.ID, r)
+		}
+	}()
+	if err := s.deliver(msg); err != nil {
+		log.Printf("订阅消息 %d 发送失败: %v", msg.ID, err)
+	}
+}
+
+// deliver 发送一条消息并更新发送记录：
+// access_token 失效时刷新后立即重发一次；用户拒收或模板参数错误不再重试；其他错误按次数退避重试，超过最多次数后标记失败
+func (s *wechatNotifyService) deliver(msg *model.WechatSubscribeMessage) error {
+	var content pkg.SubscribeMessage
+	if err := json.Unmarshal([]byte(msg.Content), &content); err != nil {
+		msg.Status = model.NotifyStatusFailed
+		msg.Error = "消息内容无效: " + err.Error()
+		return s.notifyRepo.UpdateWechatMessage(msg.ID, map[string]interface{}{
+			"status":        msg.Status,
+			"error":         msg.Error,
+			"next_retry_at": nil,
+		})
+	}
+
+	sendErr := s.send(&content)
+	msg.Attempts++
+	now := time.Now()
+	if sendErr == nil {
+		msg.Status = model.NotifyStatusSent
+		msg.Error = ""
+		msg.SentAt = &now
+		return s.notifyRepo.UpdateWechatMessage(msg.ID, map[string]interface{}{
+			"status":        model.NotifyStatusSent,
+			"attempts":      msg.Attempts,
+			"error":         "",
+			"next_retry_at": nil,
+			"sent_at":       &now,
+		})
+	}
+
+	fields := map[string]interface{}{
+		"attempts": msg.Attempts,
+		"error":    sendErr.Error(),
+	}
+	maxAttempts := configs.Cfg.Wechat.SubscribeMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultSubscribeMaxAttempts
+	}
+	var wxErr *pkg.WechatError
+	permanent := errors.As(sendErr, &wxErr) &&
+		(wxErr.Code == pkg.WechatErrUserRefused || wxErr.Code == pkg.WechatErrTemplateParam)
+	msg.Error = sendErr.Error()
+	if permanent || msg.Attempts >= maxAttempts {
+		msg.Status = model.NotifyStatusFailed
+		msg.NextRetryAt = nil
+	} else {
+		// 第 n 次失败后等待 n*n 分钟
+		next := now.Add(time.Duration(msg.Attempts*msg.Attempts) * time.Minute)
+		msg.Status = model.NotifyStatusPending
+		msg.NextRetryAt = &next
+	}
+	fields["status"] = msg.Status
+	fields["next_retry_at"] = msg.NextRetryAt
+	if err := s.notifyRepo.UpdateWechatMessage(msg.ID, fields); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// send 调用微信接口发送，access_token 失效时强制刷新后重发一次
+func (s *wechatNotifyService) send(content *pkg.SubscribeMessage) error {
+	token, err := s.tokens.Token(false)
+	if err != nil {
+		return err
+	}
+	err = s.api.SendSubscribeMessage(token, content)
+	var wxErr *pkg.WechatError
+	if errors.As(err, &wxErr) && wxErr.TokenInvalid() {
+		if token, err = s.tokens.Token(true); err != nil {
+			return err
+		}
+		err = s.api.SendSubscribeMessage(token, content)
+	}
+	return err
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"cmf/paint_proj/configs"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotifyRepo 内存中的通知记录，只实现测试用到的方法
+type fakeNotifyRepo struct {
+	repository.NotifyRepository
+	mu       sync.Mutex
+	consents map[string]int // user_id:template_id => 剩余次数
+	messages map[int64]*model.WechatSubscribeMessage
+	smsLogs  []model.SmsLog
+	nextID   int64
+}
+
+func newFakeNotifyRepo() *fakeNotifyRepo {
+	return &fakeNotifyRepo{consents: make(map[string]int), messages: make(map[int64]*model.WechatSubscribeMessage)}
+}
+
+func (r *fakeNotifyRepo) ConsumeConsent(userID int64, templateID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := fmt.Sprintf("%d:%s", userID, templateID)
+	if r.consents[key] <= 0 {
+		return false, nil
+	}
+	r.consents[key]--
+	return true, nil
+}
+
+func (r *fakeNotifyRepo) CreateWechatMessage(msg *model.WechatSubscribeMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	msg.ID = r.nextID
+	saved := *msg
+	r.messages[msg.ID] = &saved
+	return nil
+}
+
+func (r *fakeNotifyRepo) UpdateWechatMessage(id int64, fields map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg := r.messages[id]
+	for key, value := range fields {
+		switch key {
+		case "status":
+			switch v := value.(type) {
+			case int8:
+				msg.Status = v
+			case int:
+				msg.Status = int8(v)
+			}
+		case "attempts":
+			msg.Attempts = value.(int)
+		case "error":
+			msg.Error = value.(string)
+		case "next_retry_at":
+			msg.NextRetryAt, _ = value.(*time.Time)
+		case "sent_at":
+			msg.SentAt, _ = value.(*time.Time)
+		}
+	}
+	return nil
+}
+
+// wechatSendServer 模拟微信接口：token 每次获取递增，发送接口按 codes 依次返回错误码(用完后返回成功)
+type wechatSendServer struct {
+	mu         sync.Mutex
+	codes      []int
+	tokenCalls int
+	sentTokens []string
+}
+
+func (w *wechatSendServer) start(t *testing.T) pkg.WechatAPI {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		switch r.URL.Path {
+		case "/cgi-bin/token":
+			w.tokenCalls++
+			fmt.Fprintf(rw, `{"access_token":"token-%d","expires_in":7200}`, w.tokenCalls)
+		case "/cgi-bin/message/subscribe/send":
+			w.sentTokens = append(w.sentTokens, r.URL.Query().Get("access_token"))
+			code := 0
+			if len(w.codes) > 0 {
+				code, w.codes = w.codes[0], w.codes[1:]
+			}
+			fmt.Fprintf(rw, `{"errcode":%d,"errmsg":"test"}`, code)
+		default:
+			http.NotFound(rw, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return pkg.NewWechatAPI(server.URL, "wx-app", "wx-secret")
+}
+
+func setupWechatNotifyTest(t *testing.T, codes ...int) (*wechatNotifyService, *fakeNotifyRepo, *wechatSendServer) {
+	t.Helper()
+	old := configs.Cfg
+	configs.Cfg = &configs.Config{Wechat: configs.WechatConfig{SubscribeMaxAttempts: 3}}
+	t.Cleanup(func() { configs.Cfg = old })
+
+	repo := newFakeNotifyRepo()
+	server := &wechatSendServer{codes: codes}
+	service := NewWechatNotifyService(repo, nil, nil, nil, nil, server.start(t)).(*wechatNotifyService)
+	return service, repo, server
+}
+
+var testSubscribeTemplate = configs.SubscribeTemplateConfig{
+	TemplateID: "TPL_PAID",
+	Page:       "pages/order/detail?no={order_no}",
+	Data:       map[string]string{"character_string1": "{order_no}", "thing2": "{goods}"},
+}
+
+func TestNotifyConsumesConsent(t *testing.T) {
+	service, repo, _ := setupWechatNotifyTest(t)
+	repo.consents["7:TPL_PAID"] = 1
+	user := &model.User{ID: 7, Openid: "openid-7"}
+	vars := strings.NewReplacer("{order_no}", "ORD001", "{goods}", "一种名称非常长的水性环保防锈金属漆和配套稀释剂")
+
+	before := time.Now()
+	msg, err := service.notify(model.NotifySceneOrderPaid, testSubscribeTemplate, user, 1, 10, "ORD001", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != model.NotifyStatusPending {
+		t.Fatalf("有订阅次数时 status got %d, want 待发送", msg.Status)
+	}
+	if msg.NextRetryAt == nil || msg.NextRetryAt.Before(before.Add(subscribeSendGrace)) {
+		t.Fatalf("创建时应设置重试时间, got %v", msg.NextRetryAt)
+	}
+	if repo.consents["7:TPL_PAID"] != 0 {
+		t.Fatalf("应扣减一次订阅次数, 剩余 %d", repo.consents["7:TPL_PAID"])
+	}
+	if !strings.Contains(msg.Content, `"page":"pages/order/detail?no=ORD001"`) || !strings.Contains(msg.Content, "…") {
+		t.Fatalf("消息内容未按模板渲染或截断: %s", msg.Content)
+	}
+
+	// 次数用完后记录为未发送，不设置重试时间
+	msg, err = service.notify(model.NotifySceneOrderPaid, testSubscribeTemplate, user, 1, 10, "ORD001", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != model.NotifyStatusSkipped || msg.NextRetryAt != nil {
+		t.Fatalf("次数用完 got status %d next_retry_at %v, want 未发送且不重试", msg.Status, msg.NextRetryAt)
+	}
+
+	// 未绑定微信的用户不扣减次数
+	repo.consents["8:TPL_PAID"] = 1
+	msg, err = service.notify(model.NotifySceneOrderPaid, testSubscribeTemplate, &model.User{ID: 8}, 1, 11, "ORD002", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Status != model.NotifyStatusSkipped || repo.consents["8:TPL_PAID"] != 1 {
+		t.Fatalf("未绑定微信 got status %d 剩余次数 %d, want 未发送且不扣减", msg.Status, repo.consents["8:TPL_PAID"])
+	}
+}
+
+func TestDeliverRefreshesInvalidToken(t *testing.T) {
+	for _, code := range []int{pkg.WechatErrTokenInvalid, pkg.WechatErrTokenExpired} {
+		service, repo, server := setupWechatNotifyTest(t, code)
+		repo.consents["7:TPL_PAID"] = 1
+		msg, err := service.notify(model.NotifySceneOrderPaid, testSubscribeTemplate, &model.User{ID: 7, Openid: "openid-7"}, 1, 10, "ORD001", strings.NewReplacer())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.deliver(msg); err != nil {
+			t.Fatalf("%d: token 刷新后应发送成功, got %v", code, err)
+		}
+		saved := repo.messages[msg.ID]
+		if saved.Status != model.NotifyStatusSent || saved.Attempts != 1 || saved.NextRetryAt != nil || saved.SentAt == nil {
+			t.Fatalf("%d: got %+v, want 已发送且只计一次", code, saved)
+		}
+		if server.tokenCalls != 2 || len(server.sentTokens) != 2 || server.sentTokens[1] != "token-2" {
+			t.Fatalf("%d: token 请求 %d 次，发送使用 %v, want 刷新后用 token-2 重发", code, server.tokenCalls, server.sentTokens)
+		}
+	}
+}
+
+func TestDeliverRetryBackoff(t *testing.T) {
+	service, repo, server := setupWechatNotifyTest(t, pkg.WechatErrSystemBusy, pkg.WechatErrSystemBusy, pkg.WechatErrSystemBusy)
+	repo.consents["7:TPL_PAID"] = 1
+	msg, err := service.notify(model.NotifySceneOrderPaid, testSubscribeTemplate, &model.User{ID: 7, Openid: "openid-7"}, 1, 10, "ORD001", strings.NewReplacer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第 n 次失败后等待 n*n 分钟
+	for attempt, wait := range []time.Duration{time.Minute, 4 * time.Minute} {
+		before := time.Now()
+		if err := service.deliver(msg); err == nil {
+			t.Fatal("系统繁忙应返回错误")
+		}
+		saved := repo.messages[msg.ID]
+		if saved.Status != model.NotifyStatusPending || saved.Attempts != attempt+1 {
+			t.Fatalf("第%d次失败 got status %d attempts %d, want 待重试", attempt+1, saved.Status, saved.Attempts)
+		}
+		if saved.NextRetryAt == nil || saved.NextRetryAt.Before(before.Add(wait)) || saved.NextRetryAt.After(time.Now().Add(wait)) {
+			t.Fatalf("第%d次失败 next_retry_at got %v, want 约 %v 后", attempt+1, saved.NextRetryAt, wait)
+		}
+	}
+
+	// 达到最多发送次数后标记失败，不再重试
+	if err := service.deliver(msg); err == nil {
+		t.Fatal("系统繁忙应返回错误")
+	}
+	saved := repo.messages[msg.ID]
+	if saved.Status != model.NotifyStatusFailed || saved.Attempts != 3 || saved.NextRetryAt != nil {
+		t.Fatalf("超过最多次数 got %+v, want 失败且不再重试", saved)
+	}
+	if len(server.sentTokens) != 3 {
+		t.Fatalf("发送了 %d 次, want 3", len(server.sentTokens))
+	}
+}
+
+func TestDeliverUserRefusedNotRetried(t *testing.T) {
+	service, repo, _ := setupWechatNotifyTest(t, pkg.WechatErrUserRefused)
+	repo.consents["7:TPL_PAID"] = 1
+	msg, err := service.notify(model.NotifySceneOrderPaid, testSubscribeTemplate, &model.User{ID: 7, Openid: "openid-7"}, 1, 10, "ORD001", strings.NewReplacer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.deliver(msg); err == nil {
+		t.Fatal("用户拒收应返回错误")
+	}
+	saved := repo.messages[msg.ID]
+	if saved.Status != model.NotifyStatusFailed || saved.Attempts != 1 || saved.NextRetryAt != nil {
+		t.Fatalf("用户拒收 got %+v, want 直接失败", saved)
+	}
+}
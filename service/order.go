@@ -16,6 +16,12 @@ type OrderService interface {
 
 	CancelOrder(ctx context.Context, userID int64, order *model.Order) error // 取消订单
 	DeleteOrder(ctx context.Context, userID int64, order *model.Order) error // 删除订单
+
+	// 后台订单管理
+	GetAdminOrderList(req *model.AdminOrderListRequest) ([]model.Order, int64, error)
+	GetOrderByID(orderID int64) (*model.Order, error)                                              // 订单详情(含商品)
+	ShipOrder(order *model.Order, operatorID int64, operatorName string, remark string) error      // 发货
+	ReadyForPickup(order *model.Order, operatorID int64, operatorName string, remark string) error // 备货完成待自提
 }
 
 type orderService struct {
@@ -137,12 +143,27 @@ func (os *orderService) CheckoutOrder(ctx context.Context, userID int64, shopID
 			return nil, fmt.Errorf("获取商品信息失败: %v", err)
 		}
 
+		// 套装：校验组件库存并拆分为套装明细和组件明细
+		if product.IsBundle == model.BundleYes {
+			bundleItems, profit, err := buildBundleOutboundItems(os.productRepo, product, item.Quantity, item.UnitPrice, item.TotalPrice, "小程序用户购买")
+			if err != nil {
+				return nil, err
+			}
+			operation.TotalProfit += profit
+			operationItems = append(operationItems, bundleItems...)
+			continue
+		}
+
 		// 检查库存是否足够
 		if product.Stock < item.Quantity {
 			return nil, fmt.Errorf("商品 %s 库存不足，当前库存: %d，需要数量: %d", product.Name, product.Stock, item.Quantity)
 		}
 
-		// 构建库存操作明细
+		// 计算利润：(卖价 - 总成本) * 数量，与后台出库一致
+		profit := model.Amount((int64(item.UnitPrice) - int64(product.Cost)) * int64(item.Quantity))
+		operation.TotalProfit += profit
+
+		// 构建库存操作明细(操作前后库存在下单事务中按实际库存记录)
 		operationItem := model.StockOperationItem{
 			ProductID:     item.ProductID,
 			ProductName:   product.Name,
@@ -150,9 +171,8 @@ func (os *orderService) CheckoutOrder(ctx context.Context, userID int64, shopID
 			Quantity:      item.Quantity,
 			UnitPrice:     item.UnitPrice,
 			TotalPrice:    item.TotalPrice,
-			BeforeStock:   product.Stock,
-			AfterStock:    product.Stock - item.Quantity,
-			ProductCost:   0, // 出库时不记录货物成本
+			ProductCost:   product.ProductCost, // 记录进价
+			Profit:        profit,
 			Remark:        "小程序用户购买",
 		}
 		operationItems = append(operationItems, operationItem)
@@ -163,6 +183,8 @@ func (os *orderService) CheckoutOrder(ctx context.Context, userID int64, shopID
 	if err != nil {
 		return nil, err
 	}
+	publishOrderEvent(model.EventOrderCreated, order)
+	publishLowStockEvents(os.productRepo, operation, operationItems)
 
 	// 3. 返回订单信息
 	return &model.CheckoutResponse{
@@ -344,8 +366,12 @@ func (os *orderService) CancelOrder(ctx context.Context, userID int64, order *mo
 		OperatorType: model.OperatorTypeUser,
 		Content:      "用户取消订单",
 	}
-	err := os.orderRepo.CancelOrder(userID, order, log)
-	return err
+	if err := os.orderRepo.CancelOrder(userID, order, log); err != nil {
+		return err
+	}
+	order.OrderStatus = model.OrderStatusCancelled
+	publishOrderEvent(model.EventOrderCancelled, order)
+	return nil
 }
 
 func (os *orderService) DeleteOrder(ctx context.Context, userID int64, order *model.Order) error {
@@ -361,3 +387,73 @@ func (os *orderService) DeleteOrder(ctx context.Context, userID int64, order *mo
 	err := os.orderRepo.DeleteOrder(userID, order, log)
 	return err
 }
+
+// GetAdminOrderList 后台订单列表(含商品)
+func (os *orderService) GetAdminOrderList(req *model.AdminOrderListRequest) ([]model.Order, int64, error) {
+	orders, total, err := os.orderRepo.GetAdminOrderList(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range orders {
+		items, err := os.stockRepo.GetStockOperationItemsByOrderID(orders[i].ID)
+		if err != nil {
+			return nil, 0, err
+		}
+		orders[i].Items = items
+	}
+	return orders, total, nil
+}
+
+// GetOrderByID 根据ID获取订单(含商品)
+func (os *orderService) GetOrderByID(orderID int64) (*model.Order, error) {
+	order, err := os.orderRepo.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := os.stockRepo.GetStockOperationItemsByOrderID(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	order.Items = items
+	return order, nil
+}
+
+// ShipOrder 已付款订单发货，订单变为待收货
+func (os *orderService) ShipOrder(order *model.Order, operatorID int64, operatorName string, remark string) error {
+	return os.advanceOrder(order, operatorID, operatorName, "ship_order", "订单发货", remark, model.EventOrderShipped)
+}
+
+// ReadyForPickup 已付款订单备货完成，通知用户到店自提，订单变为待收货
+func (os *orderService) ReadyForPickup(order *model.Order, operatorID int64, operatorName string, remark string) error {
+	return os.advanceOrder(order, operatorID, operatorName, "ready_for_pickup", "订单备货完成，待自提", remark, model.EventOrderPickup)
+}
+
+// advanceOrder 已付款(待发货)订单流转为待收货，记录订单日志并发布事件(用于订阅消息等通知)
+func (os *orderService) advanceOrder(order *model.Order, operatorID int64, operatorName string, action, content, remark, eventType string) error {
+	if order.OrderStatus != model.OrderStatusPaymentSuccess {
+		return errors.New("只有已付款待发货的订单可以发货或备货")
+	}
+	if remark != "" {
+		content += "：" + remark
+	}
+	log := &model.OrderLog{
+		OrderNo:      order.OrderNo,
+		Action:       action,
+		Operator:     operatorName,
+		OperatorID:   operatorID,
+		OperatorType: model.OperatorTypeAdmin,
+		Content:      content,
+	}
+	updated, err := os.orderRepo.UpdateOrderStatus(order, model.OrderStatusPaymentSuccess, model.OrderStatusPendingReceipt, log)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.New("订单状态已变化，请刷新后重试")
+	}
+	order.OrderStatus = model.OrderStatusPendingReceipt
+	event := orderEventData(order)
+	event.Remark = remark
+	pkg.PublishEvent(eventType, order.ShopID, event)
+	return nil
+}
@@ -7,29 +7,41 @@ import (
 	"cmf/paint_proj/repository"
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/notify"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/jsapi"
 	"github.com/wechatpay-apiv3/wechatpay-go/utils"
 )
 
 type PayService interface {
 	PayOrder(ctx context.Context, userID int64, shopID int64, openid, orderNo string, total model.Amount) (*jsapi.PrepayWithRequestPaymentResponse, error)
-	PaidCallback(ctx context.Context, req *model.PaidCallbackData) error // 订单支付成功回调
+	ParsePaidNotify(ctx context.Context, r *http.Request) (*model.PaidCallbackData, error) // 验证并解密微信支付回调通知，非支付成功的通知返回 nil
+	PaidCallback(ctx context.Context, req *model.PaidCallbackData) error                   // 订单支付成功回调
 }
 
 type payService struct {
 	orderRepo   repository.OrderRepository
 	cartRepo    repository.CartRepository
 	productRepo repository.ProductRepository
+	printRepo   repository.PrintRepository
+
+	notifyMu      sync.Mutex
+	notifyHandler *notify.Handler // 回调通知处理器，首次收到通知时创建，失败时下次重试
 }
 
-func NewPayService(or repository.OrderRepository, cr repository.CartRepository, pr repository.ProductRepository) PayService {
+func NewPayService(or repository.OrderRepository, cr repository.CartRepository, pr repository.ProductRepository, pnr repository.PrintRepository) PayService {
 	return &payService{
 		orderRepo:   or,
 		cartRepo:    cr,
 		productRepo: pr,
+		printRepo:   pnr,
 	}
 }
 
@@ -74,6 +86,116 @@ func (ps *payService) PayOrder(ctx context.Context, userID int64, shopID int64,
 	return resp, nil
 
 }
+
+// getNotifyHandler 获取微信支付回调通知处理器
+func (ps *payService) getNotifyHandler(ctx context.Context) (*notify.Handler, error) {
+	ps.notifyMu.Lock()
+	defer ps.notifyMu.Unlock()
+	if ps.notifyHandler != nil {
+		return ps.notifyHandler, nil
+	}
+	privateKey, err := utils.LoadPrivateKeyWithPath("apiclient_key.pem")
+	if err != nil {
+		return nil, fmt.Errorf("加载商户私钥失败: %w", err)
+	}
+	handler, err := pkg.NewWechatPayNotifyHandler(ctx, pkg.MchID, pkg.SerialNo, pkg.APIv3Key, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	ps.notifyHandler = handler
+	return handler, nil
+}
+
+// ParsePaidNotify 验证微信支付回调通知的签名并解密支付结果，只信任解密后的内容
+// 交易状态不是 SUCCESS 时返回 nil(无需处理)
+func (ps *payService) ParsePaidNotify(ctx context.Context, r *http.Request) (*model.PaidCallbackData, error) {
+	handler, err := ps.getNotifyHandler(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transaction := new(payments.Transaction)
+	if _, err := handler.ParseNotifyRequest(ctx, r, transaction); err != nil {
+		return nil, err
+	}
+	if transaction.TradeState == nil || *transaction.TradeState != "SUCCESS" {
+		return nil, nil
+	}
+	if transaction.Mchid != nil && *transaction.Mchid != pkg.MchID {
+		return nil, fmt.Errorf("商户号 %s 不匹配", *transaction.Mchid)
+	}
+	if transaction.OutTradeNo == nil || transaction.Amount == nil || transaction.Amount.Total == nil {
+		return nil, errors.New("支付通知缺少订单号或金额")
+	}
+	data := &model.PaidCallbackData{
+		OrderNo:       *transaction.OutTradeNo,
+		PaymentType:   int32(model.PaymentTypeWX),
+		PaymentAmount: model.Amount(*transaction.Amount.Total),
+	}
+	if transaction.TransactionId != nil {
+		data.PaymentNo = *transaction.TransactionId
+	}
+	if transaction.SuccessTime != nil {
+		if t, err := time.Parse(time.RFC3339, *transaction.SuccessTime); err == nil {
+			data.PaymentTime = t.Unix()
+		}
+	}
+	return data, nil
+}
+
+// PaidCallback 订单支付成功回调，重复通知直接返回成功；首次更新为已付款时为店铺自动打印机创建打印任务。
+// 订单已取消等非待付款状态时不改为已付款，支付状态标记为退款中并通知后台处理退款
 func (ps *payService) PaidCallback(ctx context.Context, req *model.PaidCallbackData) error {
-	panic("implement me")
+	// 1. 获取订单
+	order, err := ps.orderRepo.GetOrderByOrderNo(req.OrderNo)
+	if err != nil {
+		return err
+	}
+	switch order.PaymentStatus {
+	case model.PaymentStatusPaid, model.PaymentStatusRefunding, model.PaymentStatusRefunded:
+		return nil
+	}
+	// 2. 校验支付金额
+	if req.PaymentAmount != order.PaymentAmount {
+		return fmt.Errorf("支付金额 %.2f 与订单实付金额 %.2f 不一致", req.PaymentAmount.Yuan(), order.PaymentAmount.Yuan())
+	}
+
+	// 3. 更新订单为已付款
+	paymentTime := time.Now()
+	if req.PaymentTime > 0 {
+		paymentTime = time.Unix(req.PaymentTime, 0)
+	}
+	order.PaymentType = model.PaymentTypeCode(req.PaymentType)
+	order.PaymentTime = &paymentTime
+	orderLog := &model.OrderLog{
+		OrderId:      order.ID,
+		OrderNo:      order.OrderNo,
+		Action:       "pay_success",
+		Operator:     fmt.Sprintf("user:%d", order.UserId),
+		OperatorID:   order.UserId,
+		OperatorType: model.OperatorTypeUser,
+		Content:      "订单支付成功，支付单号 " + req.PaymentNo,
+		CreatedAt:    &paymentTime,
+	}
+	paid, refund, err := ps.orderRepo.MarkOrderPaid(order, orderLog)
+	if err != nil {
+		return err
+	}
+	if refund {
+		log.Printf("订单 %s 状态为 %d 时收到支付 %s，已标记退款中", order.OrderNo, order.OrderStatus, req.PaymentNo)
+		order.PaymentStatus = model.PaymentStatusRefunding
+		publishOrderEvent(model.EventOrderRefund, order)
+		return nil
+	}
+	if !paid {
+		return nil
+	}
+
+	// 4. 自动打印订单小票
+	enqueueAutoPrintJobs(ps.printRepo, order.ShopID, model.PrintSourceOrder, order.ID, order.OrderNo)
+
+	// 5. 通知后台
+	order.PaymentStatus = model.PaymentStatusPaid
+	order.OrderStatus = model.OrderStatusPaymentSuccess
+	publishOrderEvent(model.EventOrderPaid, order)
+	return nil
 }
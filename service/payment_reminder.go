@@ -0,0 +1,370 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 欠款提醒默认设置
+const (
+	defaultReminderThresholds = "7,15,30"
+	defaultReminderSendHour   = 10
+	defaultReminderChannels   = model.ReminderChannelWechat + "," + model.ReminderChannelSms
+)
+
+type PaymentReminderService interface {
+	StartScheduler(interval time.Duration) // 定时给启用的店铺发送欠款提醒
+
+	GetSetting(shopID int64) (*model.PaymentReminderSetting, error)
+	SaveSetting(req *model.PaymentReminderSettingRequest, operator string) (*model.PaymentReminderSetting, error)
+	RunShop(shopID int64, operator string) (*model.PaymentReminderRunResult, error) // 立即检查店铺的超期欠款并发送提醒
+	GetReminders(query *model.PaymentReminderQuery) ([]model.PaymentReminder, int64, error)
+	GetReminderByID(id int64) (*model.PaymentReminder, error)
+}
+
+type paymentReminderService struct {
+	notifyRepo          repository.NotifyRepository
+	userRepo            repository.UserRepository
+	wechatNotifyService WechatNotifyService
+	smsService          SmsService
+	mu                  sync.Mutex // 定时任务和手动执行串行，避免同一客户重复提醒
+}
+
+func NewPaymentReminderService(notifyRepo repository.NotifyRepository, userRepo repository.UserRepository, wechatNotifyService WechatNotifyService, smsService SmsService) PaymentReminderService {
+	return &paymentReminderService{
+		notifyRepo:          notifyRepo,
+		userRepo:            userRepo,
+		wechatNotifyService: wechatNotifyService,
+		smsService:          smsService,
+	}
+}
+
+// StartScheduler 按间隔检查启用欠款提醒的店铺，每天到了设置的时间后发送；同一出库单同一账龄阈值只提醒一次，重复检查不会重复发送
+func (s *paymentReminderService) StartScheduler(interval time.Duration) {
+	pkg.RunEvery("欠款提醒任务", interval, func() error {
+		return s.runDueShops(time.Now())
+	})
+}
+
+// runDueShops 给已到发送时间的启用店铺发送欠款提醒
+func (s *paymentReminderService) runDueShops(now time.Time) error {
+	settings, err := s.notifyRepo.GetEnabledPaymentReminderSettings()
+	if err != nil {
+		return err
+	}
+	for i := range settings {
+		setting := &settings[i]
+		if now.Hour() < setting.SendHour {
+			continue
+		}
+		result, err := s.run(setting, smsOperatorSystem, now)
+		if err != nil {
+			log.Printf("店铺 %d 欠款提醒失败: %v", setting.ShopID, err)
+			continue
+		}
+		if result.Reminded > 0 {
+			log.Printf("店铺 %d 欠款提醒: 提醒 %d 个客户，发送 %d，发送中 %d，失败 %d，未发送 %d",
+				setting.ShopID, result.Reminded, result.Sent, result.Pending, result.Failed, result.Skipped)
+		}
+	}
+	return nil
+}
+
+// GetSetting 店铺欠款提醒设置，未设置时返回默认设置(停用)
+func (s *paymentReminderService) GetSetting(shopID int64) (*model.PaymentReminderSetting, error) {
+	setting, err := s.notifyRepo.GetPaymentReminderSetting(shopID)
+	if err != nil {
+		return nil, err
+	}
+	if setting == nil {
+		setting = &model.PaymentReminderSetting{
+			ShopID:     shopID,
+			Thresholds: defaultReminderThresholds,
+			SendHour:   defaultReminderSendHour,
+			Channels:   defaultReminderChannels,
+		}
+	}
+	return setting, nil
+}
+
+// SaveSetting 保存店铺欠款提醒设置，账龄阈值去重并从小到大排列
+func (s *paymentReminderService) SaveSetting(req *model.PaymentReminderSettingRequest, operator string) (*model.PaymentReminderSetting, error) {
+	if req.Enabled != 0 && req.Enabled != 1 {
+		return nil, errors.New("启用状态无效")
+	}
+	if len(req.Thresholds) == 0 {
+		return nil, errors.New("请设置至少一个账龄阈值")
+	}
+	seen := make(map[int]bool)
+	thresholds := make([]int, 0, len(req.Thresholds))
+	for _, days := range req.Thresholds {
+		if days <= 0 || days > 365 {
+			return nil, errors.New("账龄阈值须在1-365天之间")
+		}
+		if !seen[days] {
+			seen[days] = true
+			thresholds = append(thresholds, days)
+		}
+	}
+	sort.Ints(thresholds)
+	if req.SendHour < 0 || req.SendHour > 23 {
+		return nil, errors.New("发送时间须在0-23点之间")
+	}
+	if len(req.Channels) == 0 {
+		return nil, errors.New("请选择至少一个提醒渠道")
+	}
+	for _, c := range req.Channels {
+		if c != model.ReminderChannelWechat && c != model.ReminderChannelSms {
+			return nil, fmt.Errorf("不支持的提醒渠道: %s", c)
+		}
+	}
+	channels := make([]string, 0, len(req.Channels))
+	for _, channel := range []string{model.ReminderChannelWechat, model.ReminderChannelSms} {
+		if containsString(req.Channels, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	var minAmount model.Amount
+	if req.MinAmount != "" {
+		amount, err := model.ParseAmount(req.MinAmount)
+		if err != nil || amount < 0 {
+			return nil, errors.New("最低提醒金额格式错误")
+		}
+		minAmount = amount
+	}
+
+	parts := make([]string, len(thresholds))
+	for i, days := range thresholds {
+		parts[i] = strconv.Itoa(days)
+	}
+	now := time.Now()
+	setting := &model.PaymentReminderSetting{
+		ShopID:     req.ShopID,
+		Enabled:    req.Enabled,
+		Thresholds: strings.Join(parts, ","),
+		SendHour:   req.SendHour,
+		Channels:   strings.Join(channels, ","),
+		MinAmount:  minAmount,
+		UpdatedBy:  operator,
+		UpdatedAt:  &now,
+	}
+	if err := s.notifyRepo.SavePaymentReminderSetting(setting); err != nil {
+		return nil, err
+	}
+	return s.GetSetting(req.ShopID)
+}
+
+// RunShop 按店铺设置立即检查超期欠款并发送提醒(停用时也可手动执行)
+func (s *paymentReminderService) RunShop(shopID int64, operator string) (*model.PaymentReminderRunResult, error) {
+	setting, err := s.GetSetting(shopID)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(setting, operator, time.Now())
+}
+
+// run 找出账龄达到阈值的未结清出库单，按客户汇总；客户有出库单达到了尚未提醒过的阈值时生成一条提醒，并通过设置的渠道发送
+func (s *paymentReminderService) run(setting *model.PaymentReminderSetting, operator string, now time.Time) (*model.PaymentReminderRunResult, error) {
+	thresholds := parseReminderThresholds(setting.Thresholds)
+	if len(thresholds) == 0 {
+		return nil, errors.New("未设置账龄阈值")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	operations, err := s.notifyRepo.GetUnpaidOutbounds(setting.ShopID, 0, now.AddDate(0, 0, -thresholds[0]))
+	if err != nil {
+		return nil, err
+	}
+	operationIDs := make([]int64, len(operations))
+	for i, operation := range operations {
+		operationIDs[i] = operation.ID
+	}
+	// 失败和未发送的提醒当天不再重复，次日重新提醒
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	remindedStages, err := s.notifyRepo.GetRemindedStages(operationIDs, today)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.PaymentReminderRunResult{Reminders: []*model.PaymentReminder{}}
+	// 出库单已按客户、出库时间排序
+	for start := 0; start < len(operations); {
+		end := start
+		for end < len(operations) && operations[end].UserID == operations[start].UserID {
+			end++
+		}
+		customerOps := operations[start:end]
+		start = end
+
+		reminder, due := buildPaymentReminder(setting.ShopID, customerOps, thresholds, remindedStages, now)
+		if reminder.TotalAmount < setting.MinAmount {
+			continue
+		}
+		result.Customers++
+		if !due {
+			continue
+		}
+		reminder.Operator = operator
+		if err := s.remind(setting, reminder, customerOps); err != nil {
+			log.Printf("客户 %d 欠款提醒失败: %v", reminder.UserID, err)
+			continue
+		}
+		result.Reminded++
+		switch reminder.Status {
+		case model.NotifyStatusSent:
+			result.Sent++
+		case model.NotifyStatusPending:
+			result.Pending++
+		case model.NotifyStatusFailed:
+			result.Failed++
+		default:
+			result.Skipped++
+		}
+		result.Reminders = append(result.Reminders, reminder)
+	}
+	return result, nil
+}
+
+// buildPaymentReminder 汇总客户的超期出库单，金额按未结清金额(门店收银只计挂账部分)；有出库单达到比已提醒过的更高的阈值时需要提醒
+func buildPaymentReminder(shopID int64, operations []model.StockOperation, thresholds []int, remindedStages map[int64]int, now time.Time) (*model.PaymentReminder, bool) {
+	reminder := &model.PaymentReminder{
+		ShopID:            shopID,
+		UserID:            operations[0].UserID,
+		UserName:          operations[0].UserName,
+		OperationCount:    len(operations),
+		OldestAt:          operations[0].CreatedAt,
+		OldestOperationNo: operations[0].OperationNo,
+	}
+	due := false
+	for _, operation := range operations {
+		ageDays := 0
+		if operation.CreatedAt != nil {
+			ageDays = int(now.Sub(*operation.CreatedAt).Hours() / 24)
+		}
+		stage := 0
+		for _, days := range thresholds {
+			if ageDays >= days {
+				stage = days
+			}
+		}
+		if stage > remindedStages[operation.ID] {
+			due = true
+		}
+		if stage > reminder.Stage {
+			reminder.Stage = stage
+		}
+		reminder.TotalAmount += operation.UnpaidAmount
+		reminder.Items = append(reminder.Items, model.PaymentReminderItem{
+			UserID:      operation.UserID,
+			OperationID: operation.ID,
+			OperationNo: operation.OperationNo,
+			Amount:      operation.UnpaidAmount,
+			OperationAt: operation.CreatedAt,
+			AgeDays:     ageDays,
+			Stage:       stage,
+		})
+	}
+	return reminder, due
+}
+
+// remind 依次通过订阅消息和短信发送，再按各渠道结果记录提醒；
+// 有渠道发送成功或发送中时才算已提醒该阈值，失败和未发送的次日重新提醒
+func (s *paymentReminderService) remind(setting *model.PaymentReminderSetting, reminder *model.PaymentReminder, operations []model.StockOperation) error {
+	user, err := s.userRepo.GetUserByID(reminder.UserID)
+	if err != nil {
+		return fmt.Errorf("客户不存在: %w", err)
+	}
+	reminder.UserName = smsCustomerName(user, reminder.UserName)
+	reminder.Phone = user.MobilePhone
+
+	var reasons []string
+	channels := strings.Split(setting.Channels, ",")
+	if containsString(channels, model.ReminderChannelWechat) {
+		msg, err := s.wechatNotifyService.SendPaymentReminder(user, reminder)
+		switch {
+		case err != nil:
+			reminder.WechatStatus = model.NotifyStatusFailed
+			reasons = append(reasons, "订阅消息: "+err.Error())
+		case msg == nil:
+			reasons = append(reasons, "订阅消息: 未配置模板或客户未绑定微信")
+		default:
+			reminder.WechatMessageID = msg.ID
+			reminder.WechatStatus = msg.Status
+			if msg.Error != "" {
+				reasons = append(reasons, "订阅消息: "+msg.Error)
+			}
+		}
+	}
+	if containsString(channels, model.ReminderChannelSms) {
+		smsLog, err := s.smsService.SendOperationsReminder(reminder.ShopID, reminder.UserID, operations, reminder.Operator)
+		var recipientErr *smsRecipientError
+		switch {
+		case errors.As(err, &recipientErr):
+			reasons = append(reasons, "短信: "+err.Error())
+		case err != nil:
+			reminder.SmsStatus = model.NotifyStatusFailed
+			reasons = append(reasons, "短信: "+err.Error())
+		default:
+			reminder.SmsLogID = smsLog.ID
+			reminder.SmsStatus = smsLog.Status
+			if smsLog.Error != "" {
+				reasons = append(reasons, "短信: "+smsLog.Error)
+			}
+		}
+	}
+
+	switch {
+	case reminder.WechatStatus == model.NotifyStatusSent || reminder.SmsStatus == model.NotifyStatusSent:
+		reminder.Status = model.NotifyStatusSent
+	case reminder.WechatStatus == model.NotifyStatusPending:
+		reminder.Status = model.NotifyStatusPending
+	case reminder.WechatStatus == model.NotifyStatusFailed || reminder.SmsStatus == model.NotifyStatusFailed:
+		reminder.Status = model.NotifyStatusFailed
+	default:
+		reminder.Status = model.NotifyStatusSkipped
+	}
+	reminder.Error = strings.Join(reasons, "; ")
+	now := time.Now()
+	reminder.CreatedAt = &now
+	return s.notifyRepo.CreatePaymentReminder(reminder)
+}
+
+func (s *paymentReminderService) GetReminders(query *model.PaymentReminderQuery) ([]model.PaymentReminder, int64, error) {
+	return s.notifyRepo.GetPaymentReminders(query)
+}
+
+func (s *paymentReminderService) GetReminderByID(id int64) (*model.PaymentReminder, error) {
+	return s.notifyRepo.GetPaymentReminderByID(id)
+}
+
+// parseReminderThresholds 解析逗号分隔的账龄阈值，从小到大排列
+func parseReminderThresholds(value string) []int {
+	var thresholds []int
+	for _, part := range strings.Split(value, ",") {
+		if days, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && days > 0 {
+			thresholds = append(thresholds, days)
+		}
+	}
+	sort.Ints(thresholds)
+	return thresholds
+}
+
+func containsString(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
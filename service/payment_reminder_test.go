@@ -0,0 +1,83 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseReminderThresholds(t *testing.T) {
+	cases := map[string][]int{
+		"7,15,30":         {7, 15, 30},
+		" 30, 7 ,15":      {7, 15, 30},
+		"15,abc,0,-3,,60": {15, 60},
+		"":                nil,
+	}
+	for value, want := range cases {
+		if got := parseReminderThresholds(value); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q got %v, want %v", value, got, want)
+		}
+	}
+}
+
+// reminderOperations 按账龄天数生成同一客户的出库单，未结清金额为总金额的一部分(门店收银挂账)
+func reminderOperations(now time.Time, ageDays ...int) []model.StockOperation {
+	var operations []model.StockOperation
+	for i, days := range ageDays {
+		createdAt := now.Add(-time.Duration(days)*24*time.Hour - time.Hour)
+		operations = append(operations, model.StockOperation{
+			ID:           int64(i + 1),
+			OperationNo:  fmt.Sprintf("OUT%03d", i+1),
+			UserID:       9,
+			UserName:     "王师傅",
+			TotalAmount:  model.Amount(10000),
+			UnpaidAmount: model.Amount(3000 * (i + 1)),
+			CreatedAt:    &createdAt,
+		})
+	}
+	return operations
+}
+
+func TestBuildPaymentReminderStages(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local)
+	thresholds := []int{7, 15, 30}
+	operations := reminderOperations(now, 40, 16, 3)
+
+	reminder, due := buildPaymentReminder(1, operations, thresholds, map[int64]int{}, now)
+	if !due {
+		t.Fatal("未提醒过且有超期出库单应提醒")
+	}
+	if reminder.Stage != 30 {
+		t.Fatalf("stage got %d, want 最高达到的阈值 30", reminder.Stage)
+	}
+	// 金额按未结清金额汇总，不按出库单总金额
+	if reminder.TotalAmount != model.Amount(3000+6000+9000) {
+		t.Fatalf("total_amount got %d, want 18000", reminder.TotalAmount)
+	}
+	wantStages := []int{30, 15, 0}
+	for i, item := range reminder.Items {
+		if item.Stage != wantStages[i] || item.Amount != operations[i].UnpaidAmount {
+			t.Fatalf("第%d张 got stage %d amount %d, want stage %d amount %d", i+1, item.Stage, item.Amount, wantStages[i], operations[i].UnpaidAmount)
+		}
+	}
+	if reminder.Items[1].AgeDays != 16 || reminder.OldestOperationNo != operations[0].OperationNo {
+		t.Fatalf("got %+v", reminder)
+	}
+
+	// 各出库单都已按当前阈值提醒过，不重复提醒
+	if _, due := buildPaymentReminder(1, operations, thresholds, map[int64]int{1: 30, 2: 15}, now); due {
+		t.Fatal("同一阈值已提醒过不应再提醒")
+	}
+
+	// 有出库单达到比已提醒过的更高的阈值时再次提醒
+	if _, due := buildPaymentReminder(1, operations, thresholds, map[int64]int{1: 30, 2: 7}, now); !due {
+		t.Fatal("达到更高阈值应再次提醒")
+	}
+
+	// 都未达到最小阈值时不提醒
+	if _, due := buildPaymentReminder(1, reminderOperations(now, 3, 6), thresholds, map[int64]int{}, now); due {
+		t.Fatal("未达到阈值不应提醒")
+	}
+}
@@ -0,0 +1,573 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PosService interface {
+	// 收银班次
+	OpenShift(shopID int64, req *model.PosOpenShiftRequest, operatorID int64, operator string) (*model.PosShift, error)
+	CloseShift(shopID int64, req *model.PosCloseShiftRequest, operatorID int64) (*model.PosShiftSummary, error)
+	GetCurrentShift(shopID int64, operatorID int64) (*model.PosShiftSummary, error)
+	GetShiftSummary(shiftID int64) (*model.PosShiftSummary, error)
+	GetShifts(page, pageSize int, shopID int64, operatorID int64) ([]model.PosShift, int64, error)
+
+	// 收银单
+	OpenSale(shopID int64, req *model.PosOpenSaleRequest, operatorID int64, operator string) (*model.PosSale, error)
+	GetSale(saleID int64) (*model.PosSale, error)
+	GetSales(page, pageSize int, shopID int64, status int8) ([]model.PosSale, int64, error)
+	SetCustomer(saleID int64, req *model.PosSetCustomerRequest) (*model.PosSale, error)
+	AddItem(saleID int64, req *model.PosAddItemRequest) (*model.PosSale, error)
+	UpdateItem(saleID int64, itemID int64, req *model.PosUpdateItemRequest) (*model.PosSale, error)
+	RemoveItem(saleID int64, itemID int64) (*model.PosSale, error)
+	Checkout(saleID int64, req *model.PosCheckoutRequest, operatorID int64, operator string) (*model.PosSale, error)
+	CancelSale(saleID int64) error
+
+	// 客户协议价
+	GetCustomerPrices(shopID int64, userID int64) ([]model.CustomerPrice, error)
+	GetCustomerPriceByID(id int64) (*model.CustomerPrice, error)
+	SetCustomerPrice(req *model.SetCustomerPriceRequest, operatorID int64, operator string) (*model.CustomerPrice, error)
+	DeleteCustomerPrice(id int64) error
+}
+
+type posService struct {
+	posRepo     repository.PosRepository
+	productRepo repository.ProductRepository
+	userRepo    repository.UserRepository
+	printRepo   repository.PrintRepository
+}
+
+func NewPosService(posRepo repository.PosRepository, productRepo repository.ProductRepository, userRepo repository.UserRepository, printRepo repository.PrintRepository) PosService {
+	return &posService{
+		posRepo:     posRepo,
+		productRepo: productRepo,
+		userRepo:    userRepo,
+		printRepo:   printRepo,
+	}
+}
+
+// posWalkInCustomer 散客的默认名称
+const posWalkInCustomer = "散客"
+
+// OpenShift 开班，同一收银员在同一店铺只能有一个当班班次
+func (s *posService) OpenShift(shopID int64, req *model.PosOpenShiftRequest, operatorID int64, operator string) (*model.PosShift, error) {
+	if req.OpeningCash < 0 {
+		return nil, errors.New("备用金不能为负数")
+	}
+	if _, err := s.posRepo.GetOpenShift(shopID, operatorID); err == nil {
+		return nil, errors.New("当前已有未交班的班次，请先交班")
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	shift := &model.PosShift{
+		ShopID:      shopID,
+		OperatorID:  operatorID,
+		Operator:    operator,
+		Status:      model.PosShiftOpen,
+		OpeningCash: req.OpeningCash,
+		OpenedAt:    &now,
+	}
+	if err := s.posRepo.CreateShift(shift); err != nil {
+		return nil, err
+	}
+	return shift, nil
+}
+
+// CloseShift 交班：汇总班次内的收款，与实点现金对账
+func (s *posService) CloseShift(shopID int64, req *model.PosCloseShiftRequest, operatorID int64) (*model.PosShiftSummary, error) {
+	shift, err := s.posRepo.GetOpenShift(shopID, operatorID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("当前没有未交班的班次")
+		}
+		return nil, err
+	}
+	summary, err := s.summarizeShift(shift)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	summary.Status = model.PosShiftClosed
+	summary.CountedCash = req.CountedCash
+	summary.Difference = req.CountedCash - summary.ExpectedCash
+	summary.Remark = req.Remark
+	summary.ClosedAt = &now
+	if err := s.posRepo.CloseShift(&summary.PosShift); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// GetCurrentShift 获取收银员当前班次的实时汇总
+func (s *posService) GetCurrentShift(shopID int64, operatorID int64) (*model.PosShiftSummary, error) {
+	shift, err := s.posRepo.GetOpenShift(shopID, operatorID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("当前没有未交班的班次")
+		}
+		return nil, err
+	}
+	return s.summarizeShift(shift)
+}
+
+// GetShiftSummary 获取班次汇总，已交班的班次返回交班时保存的数据
+func (s *posService) GetShiftSummary(shiftID int64) (*model.PosShiftSummary, error) {
+	shift, err := s.posRepo.GetShiftByID(shiftID)
+	if err != nil {
+		return nil, errors.New("班次不存在")
+	}
+	if shift.Status == model.PosShiftOpen {
+		return s.summarizeShift(shift)
+	}
+	sales, err := s.posRepo.GetSalesByShift(shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PosShiftSummary{PosShift: *shift, Sales: sales}, nil
+}
+
+// summarizeShift 按班次内已结账的收银单计算收款汇总和应有现金
+func (s *posService) summarizeShift(shift *model.PosShift) (*model.PosShiftSummary, error) {
+	totals, err := s.posRepo.GetShiftTotals(shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	sales, err := s.posRepo.GetSalesByShift(shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	summary := &model.PosShiftSummary{PosShift: *shift, Sales: sales}
+	summary.SaleCount = totals.SaleCount
+	summary.SalesAmount = totals.SalesAmount
+	summary.CashSales = totals.CashSales
+	summary.WechatSales = totals.WechatSales
+	summary.CreditSales = totals.CreditSales
+	summary.ExpectedCash = shift.OpeningCash + totals.CashSales
+	return summary, nil
+}
+
+// GetShifts 班次列表
+func (s *posService) GetShifts(page, pageSize int, shopID int64, operatorID int64) ([]model.PosShift, int64, error) {
+	return s.posRepo.GetShifts(page, pageSize, shopID, operatorID)
+}
+
+// OpenSale 开收银单，收银员需已开班
+func (s *posService) OpenSale(shopID int64, req *model.PosOpenSaleRequest, operatorID int64, operator string) (*model.PosSale, error) {
+	if _, err := s.posRepo.GetOpenShift(shopID, operatorID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("请先开班")
+		}
+		return nil, err
+	}
+	userName, err := s.customerName(req.UserID, req.UserName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sale := &model.PosSale{
+		SaleNo:     pkg.GenerateOrderNo(pkg.PosPrefix, operatorID),
+		ShopID:     shopID,
+		OperatorID: operatorID,
+		Operator:   operator,
+		UserID:     req.UserID,
+		UserName:   userName,
+		Status:     model.PosSaleOpen,
+		Remark:     req.Remark,
+		CreatedAt:  &now,
+		Items:      []model.PosSaleItem{},
+	}
+	if err := s.posRepo.CreateSale(sale); err != nil {
+		return nil, err
+	}
+	return sale, nil
+}
+
+// customerName 收银单的客户名称：有客户ID时取后台显示名称，散客取传入的称呼
+func (s *posService) customerName(userID int64, userName string) (string, error) {
+	if userID == 0 {
+		if userName == "" {
+			return posWalkInCustomer, nil
+		}
+		return userName, nil
+	}
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return "", fmt.Errorf("客户ID %d 不存在", userID)
+	}
+	if userName != "" {
+		return userName, nil
+	}
+	if user.AdminDisplayName != "" {
+		return user.AdminDisplayName, nil
+	}
+	if user.WechatDisplayName != "" {
+		return user.WechatDisplayName, nil
+	}
+	return user.Nickname, nil
+}
+
+// GetSale 获取收银单及明细
+func (s *posService) GetSale(saleID int64) (*model.PosSale, error) {
+	sale, err := s.posRepo.GetSaleByID(saleID)
+	if err != nil {
+		return nil, errors.New("收银单不存在")
+	}
+	items, err := s.posRepo.GetSaleItems(saleID)
+	if err != nil {
+		return nil, err
+	}
+	sale.Items = items
+	return sale, nil
+}
+
+// GetSales 收银单列表(不含明细)
+func (s *posService) GetSales(page, pageSize int, shopID int64, status int8) ([]model.PosSale, int64, error) {
+	return s.posRepo.GetSales(page, pageSize, shopID, status)
+}
+
+// getOpenSale 获取收银中的收银单及明细
+func (s *posService) getOpenSale(saleID int64) (*model.PosSale, error) {
+	sale, err := s.GetSale(saleID)
+	if err != nil {
+		return nil, err
+	}
+	if sale.Status != model.PosSaleOpen {
+		return nil, errors.New("收银单已结账或已取消")
+	}
+	return sale, nil
+}
+
+// SetCustomer 更换收银单客户，非手工改价的明细按新客户重新取价
+func (s *posService) SetCustomer(saleID int64, req *model.PosSetCustomerRequest) (*model.PosSale, error) {
+	sale, err := s.getOpenSale(saleID)
+	if err != nil {
+		return nil, err
+	}
+	userName, err := s.customerName(req.UserID, req.UserName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.posRepo.UpdateSale(sale.ID, map[string]interface{}{"user_id": req.UserID, "user_name": userName}); err != nil {
+		return nil, err
+	}
+	sale.UserID = req.UserID
+	sale.UserName = userName
+
+	customerPrices, err := s.customerPriceMap(sale.ShopID, sale.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sale.Items {
+		item := &sale.Items[i]
+		if item.PriceSource == model.PosPriceManual {
+			continue
+		}
+		product, err := s.productRepo.GetByID(item.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("获取商品ID %d 信息失败: %v", item.ProductID, err)
+		}
+		item.UnitPrice, item.PriceSource = posItemPrice(product, customerPrices, nil)
+		item.TotalPrice = model.Amount(int64(item.UnitPrice) * int64(item.Quantity))
+	}
+	return s.saveItems(sale, nil)
+}
+
+// AddItem 收银单添加商品，已有同一商品时累加数量
+func (s *posService) AddItem(saleID int64, req *model.PosAddItemRequest) (*model.PosSale, error) {
+	sale, err := s.getOpenSale(saleID)
+	if err != nil {
+		return nil, err
+	}
+	quantity := req.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+	if quantity < 0 {
+		return nil, errors.New("数量必须大于0")
+	}
+	if req.UnitPrice != nil && *req.UnitPrice < 0 {
+		return nil, errors.New("单价不能为负数")
+	}
+
+	var product *model.Product
+	if req.Barcode != "" {
+		product, err = s.productRepo.GetByBarcodeAndShop(req.Barcode, sale.ShopID)
+		if err != nil {
+			return nil, fmt.Errorf("条码 %s 未找到商品", req.Barcode)
+		}
+	} else if req.ProductID > 0 {
+		product, err = s.productRepo.GetByID(req.ProductID)
+		if err != nil || product.ShopID != sale.ShopID {
+			return nil, fmt.Errorf("商品ID %d 不存在", req.ProductID)
+		}
+	} else {
+		return nil, errors.New("商品ID和条码不能同时为空")
+	}
+
+	var item *model.PosSaleItem
+	for i := range sale.Items {
+		if sale.Items[i].ProductID == product.ID {
+			item = &sale.Items[i]
+			break
+		}
+	}
+	if item == nil {
+		sale.Items = append(sale.Items, model.PosSaleItem{
+			SaleID:        sale.ID,
+			ProductID:     product.ID,
+			ProductName:   product.Name,
+			Specification: product.Specification,
+			Unit:          product.Unit,
+		})
+		item = &sale.Items[len(sale.Items)-1]
+	}
+	item.Quantity += quantity
+	if req.UnitPrice != nil || item.PriceSource != model.PosPriceManual {
+		customerPrices, err := s.customerPriceMap(sale.ShopID, sale.UserID)
+		if err != nil {
+			return nil, err
+		}
+		item.UnitPrice, item.PriceSource = posItemPrice(product, customerPrices, req.UnitPrice)
+	}
+	item.TotalPrice = model.Amount(int64(item.UnitPrice) * int64(item.Quantity))
+	return s.saveItems(sale, nil)
+}
+
+// UpdateItem 修改收银明细的数量或单价，数量为0时删除该明细
+func (s *posService) UpdateItem(saleID int64, itemID int64, req *model.PosUpdateItemRequest) (*model.PosSale, error) {
+	if req.Quantity == 0 {
+		return s.RemoveItem(saleID, itemID)
+	}
+	if req.UnitPrice != nil && *req.UnitPrice < 0 {
+		return nil, errors.New("单价不能为负数")
+	}
+	sale, err := s.getOpenSale(saleID)
+	if err != nil {
+		return nil, err
+	}
+	var item *model.PosSaleItem
+	for i := range sale.Items {
+		if sale.Items[i].ID == itemID {
+			item = &sale.Items[i]
+			break
+		}
+	}
+	if item == nil {
+		return nil, errors.New("收银明细不存在")
+	}
+	item.Quantity = req.Quantity
+	if req.UnitPrice != nil {
+		item.UnitPrice = *req.UnitPrice
+		item.PriceSource = model.PosPriceManual
+	}
+	item.TotalPrice = model.Amount(int64(item.UnitPrice) * int64(item.Quantity))
+	return s.saveItems(sale, nil)
+}
+
+// RemoveItem 删除收银明细
+func (s *posService) RemoveItem(saleID int64, itemID int64) (*model.PosSale, error) {
+	sale, err := s.getOpenSale(saleID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]model.PosSaleItem, 0, len(sale.Items))
+	for _, item := range sale.Items {
+		if item.ID != itemID {
+			items = append(items, item)
+		}
+	}
+	if len(items) == len(sale.Items) {
+		return nil, errors.New("收银明细不存在")
+	}
+	sale.Items = items
+	return s.saveItems(sale, []int64{itemID})
+}
+
+// saveItems 重新计算应收金额并保存明细
+func (s *posService) saveItems(sale *model.PosSale, deleteIDs []int64) (*model.PosSale, error) {
+	var total model.Amount
+	for _, item := range sale.Items {
+		total += item.TotalPrice
+	}
+	if err := s.posRepo.SaveSaleItems(sale.ID, sale.Items, deleteIDs, total); err != nil {
+		return nil, err
+	}
+	sale.TotalAmount = total
+	return sale, nil
+}
+
+// customerPriceMap 客户在店铺的协议价，散客返回空
+func (s *posService) customerPriceMap(shopID int64, userID int64) (map[int64]model.Amount, error) {
+	prices := make(map[int64]model.Amount)
+	if userID == 0 {
+		return prices, nil
+	}
+	list, err := s.posRepo.GetCustomerPrices(shopID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		prices[p.ProductID] = p.Price
+	}
+	return prices, nil
+}
+
+// posItemPrice 收银单价：手工改价 > 客户协议价 > 零售价
+func posItemPrice(product *model.Product, customerPrices map[int64]model.Amount, manual *model.Amount) (model.Amount, int8) {
+	if manual != nil {
+		return *manual, model.PosPriceManual
+	}
+	if price, ok := customerPrices[product.ID]; ok {
+		return price, model.PosPriceCustomer
+	}
+	return product.SellerPrice, model.PosPriceRetail
+}
+
+// Checkout 结账：校验收款金额和库存，生成门店收银出库单并扣减库存
+// 有挂账金额时出库单为未支付，后续按出库单收款；否则为已支付
+func (s *posService) Checkout(saleID int64, req *model.PosCheckoutRequest, operatorID int64, operator string) (*model.PosSale, error) {
+	sale, err := s.getOpenSale(saleID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sale.Items) == 0 {
+		return nil, errors.New("收银单没有商品")
+	}
+	shift, err := s.posRepo.GetOpenShift(sale.ShopID, operatorID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("请先开班")
+		}
+		return nil, err
+	}
+
+	// 收款校验：微信和挂账不能超过应收，现金补足剩余部分，多收的现金找零
+	if req.PaidCredit > 0 && sale.UserID == 0 {
+		return nil, errors.New("散客不能挂账，请先选择客户")
+	}
+	nonCash := req.PaidWechat + req.PaidCredit
+	if nonCash > sale.TotalAmount {
+		return nil, fmt.Errorf("微信和挂账金额合计 %.2f 超过应收金额 %.2f", nonCash.Yuan(), sale.TotalAmount.Yuan())
+	}
+	cashDue := sale.TotalAmount - nonCash
+	if req.PaidCash < cashDue {
+		return nil, fmt.Errorf("收款不足，还需收取现金 %.2f", (cashDue - req.PaidCash).Yuan())
+	}
+
+	// 库存预校验，套装在拆分组件时校验；结账事务中锁定商品后会再次校验
+	outboundReq := &model.BatchOutboundRequest{
+		TotalAmount: sale.TotalAmount,
+		UserName:    sale.UserName,
+		UserID:      sale.UserID,
+		Operator:    operator,
+		OperatorID:  operatorID,
+		ShopID:      sale.ShopID,
+		Remark:      "门店收银 " + sale.SaleNo,
+	}
+	for _, item := range sale.Items {
+		product, err := s.productRepo.GetByID(item.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("商品ID %d 不存在", item.ProductID)
+		}
+		if product.IsBundle != model.BundleYes && product.Stock < item.Quantity {
+			return nil, fmt.Errorf("商品 %s 库存不足，当前库存: %d，需要出库: %d", product.Name, product.Stock, item.Quantity)
+		}
+		outboundReq.Items = append(outboundReq.Items, model.BatchOutboundItem{
+			ProductID:  item.ProductID,
+			Quantity:   item.Quantity,
+			UnitPrice:  item.UnitPrice,
+			TotalPrice: item.TotalPrice,
+		})
+	}
+	operation, err := buildOutboundOperation(s.productRepo, outboundReq)
+	if err != nil {
+		return nil, err
+	}
+	// 应收为0时(如全部赠送) buildOutboundOperation 会按明细重新计算总金额，这里以收银单为准
+	operation.TotalAmount = sale.TotalAmount
+	operation.OutboundType = model.OutboundTypePOS
+
+	// 现金和微信当场收清，只有挂账部分计入欠款
+	now := time.Now()
+	operation.UnpaidAmount = req.PaidCredit
+	if req.PaidCredit == 0 {
+		operation.PaymentFinishStatus = model.PaymentStatusPaid
+		operation.PaymentFinishTime = &now
+	}
+
+	sale.ShiftID = shift.ID
+	sale.OperatorID = operatorID
+	sale.Operator = operator
+	sale.PaidCash = req.PaidCash
+	sale.PaidWechat = req.PaidWechat
+	sale.PaidCredit = req.PaidCredit
+	sale.ChangeAmount = req.PaidCash - cashDue
+	if req.Remark != "" {
+		sale.Remark = req.Remark
+	}
+	sale.CompletedAt = &now
+	if err := s.posRepo.CompleteSale(sale, operation); err != nil {
+		return nil, err
+	}
+	sale.Status = model.PosSaleCompleted
+	enqueueAutoPrintJobs(s.printRepo, sale.ShopID, model.PrintSourcePosSale, sale.ID, sale.SaleNo)
+	publishLowStockEvents(s.productRepo, operation, operation.Items)
+	return sale, nil
+}
+
+// CancelSale 取消收银中的收银单
+func (s *posService) CancelSale(saleID int64) error {
+	return s.posRepo.CancelSale(saleID)
+}
+
+// GetCustomerPrices 获取客户协议价
+func (s *posService) GetCustomerPrices(shopID int64, userID int64) ([]model.CustomerPrice, error) {
+	return s.posRepo.GetCustomerPrices(shopID, userID)
+}
+
+// GetCustomerPriceByID 根据ID获取协议价
+func (s *posService) GetCustomerPriceByID(id int64) (*model.CustomerPrice, error) {
+	return s.posRepo.GetCustomerPriceByID(id)
+}
+
+// SetCustomerPrice 设置客户某个商品的协议价
+func (s *posService) SetCustomerPrice(req *model.SetCustomerPriceRequest, operatorID int64, operator string) (*model.CustomerPrice, error) {
+	if *req.Price < 0 {
+		return nil, errors.New("协议价不能为负数")
+	}
+	if _, err := s.userRepo.GetUserByID(req.UserID); err != nil {
+		return nil, fmt.Errorf("客户ID %d 不存在", req.UserID)
+	}
+	product, err := s.productRepo.GetByID(req.ProductID)
+	if err != nil || product.ShopID != req.ShopID {
+		return nil, fmt.Errorf("商品ID %d 不存在", req.ProductID)
+	}
+	price := &model.CustomerPrice{
+		ShopID:     req.ShopID,
+		UserID:     req.UserID,
+		ProductID:  req.ProductID,
+		Price:      *req.Price,
+		Operator:   operator,
+		OperatorID: operatorID,
+	}
+	if err := s.posRepo.SaveCustomerPrice(price); err != nil {
+		return nil, err
+	}
+	return price, nil
+}
+
+// DeleteCustomerPrice 删除协议价
+func (s *posService) DeleteCustomerPrice(id int64) error {
+	return s.posRepo.DeleteCustomerPrice(id)
+}
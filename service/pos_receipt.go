@@ -0,0 +1,77 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"fmt"
+)
+
+// BuildPosReceipt 生成收银小票文本，按热敏打印机等宽字体排版，中文按两个字符宽度计算
+func BuildPosReceipt(shop *model.Shop, sale *model.PosSale, width int) string {
+	w := &textReceiptWriter{width: ReceiptWidth(width)}
+	writePosReceipt(w, shop, sale)
+	return w.b.String()
+}
+
+// BuildPosReceiptEscPos 生成收银小票的 ESC/POS 指令，有现金收款时先弹出钱箱
+func BuildPosReceiptEscPos(shop *model.Shop, sale *model.PosSale, width int) []byte {
+	w := newEscposReceiptWriter(ReceiptWidth(width))
+	if sale.PaidCash > 0 && sale.Status == model.PosSaleCompleted {
+		w.p.OpenDrawer()
+	}
+	writePosReceipt(w, shop, sale)
+	return w.finish()
+}
+
+// writePosReceipt 收银小票排版
+func writePosReceipt(w receiptWriter, shop *model.Shop, sale *model.PosSale) {
+	// 1. 抬头
+	w.Title(shop.Name)
+	if shop.Address != "" {
+		w.Center(shop.Address)
+	}
+	if shop.Phone != "" {
+		w.Center("电话:" + shop.Phone)
+	}
+	w.Separator()
+	w.Line("单号:" + sale.SaleNo)
+	if sale.CompletedAt != nil {
+		w.Line("时间:" + sale.CompletedAt.Format("2006-01-02 15:04:05"))
+	} else if sale.CreatedAt != nil {
+		w.Line("时间:" + sale.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	w.Line("收银:" + sale.Operator)
+	w.Line("客户:" + sale.UserName)
+	w.Separator()
+
+	// 2. 明细
+	var totalQuantity int
+	for _, item := range sale.Items {
+		writeReceiptItem(w, item.ProductName, item.Specification, item.Unit, item.Quantity, item.UnitPrice, item.TotalPrice)
+		totalQuantity += item.Quantity
+	}
+	w.Separator()
+
+	// 3. 合计和收款
+	w.Justify(fmt.Sprintf("合计(%d件)", totalQuantity), fmt.Sprintf("%.2f", sale.TotalAmount.Yuan()))
+	if sale.PaidCash > 0 {
+		w.Justify("现金", fmt.Sprintf("%.2f", sale.PaidCash.Yuan()))
+	}
+	if sale.PaidWechat > 0 {
+		w.Justify("微信", fmt.Sprintf("%.2f", sale.PaidWechat.Yuan()))
+	}
+	if sale.PaidCredit > 0 {
+		w.Justify("挂账", fmt.Sprintf("%.2f", sale.PaidCredit.Yuan()))
+	}
+	if sale.ChangeAmount > 0 {
+		w.Justify("找零", fmt.Sprintf("%.2f", sale.ChangeAmount.Yuan()))
+	}
+	if sale.Status != model.PosSaleCompleted {
+		w.Center("*** 未结账 ***")
+	}
+	if sale.Remark != "" {
+		w.Separator()
+		w.Line("备注:" + sale.Remark)
+	}
+	w.Separator()
+	w.Center("谢谢惠顾，欢迎再次光临")
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PriceService interface {
+	// 批量调价
+	PreviewAdjustment(req *model.PriceAdjustmentRequest) (*model.PriceAdjustmentPreview, error)
+	CreateAdjustment(req *model.PriceAdjustmentRequest, operatorID int64, operator string) (*model.PriceAdjustment, *model.PriceAdjustmentPreview, error)
+	GetAdjustmentByID(id int64) (*model.PriceAdjustment, error)
+	GetAdjustmentList(page, pageSize int, shopID int64, status int8) ([]model.PriceAdjustment, int64, error)
+	CancelAdjustment(id int64) error
+
+	// 定时调价
+	ApplyDueAdjustments() error
+	StartScheduler(interval time.Duration)
+
+	// 价格变动记录
+	GetPriceHistory(productID int64, page, pageSize int) ([]model.ProductPriceHistory, int64, error)
+}
+
+type priceService struct {
+	priceRepo   repository.PriceRepository
+	productRepo repository.ProductRepository
+}
+
+func NewPriceService(pr repository.PriceRepository, productRepo repository.ProductRepository) PriceService {
+	return &priceService{
+		priceRepo:   pr,
+		productRepo: productRepo,
+	}
+}
+
+// validateAdjustmentRequest 校验调价请求
+func validateAdjustmentRequest(req *model.PriceAdjustmentRequest) error {
+	if req.ShopID <= 0 {
+		return errors.New("请指定调价的店铺")
+	}
+
+	selectors := 0
+	if req.CategoryID > 0 {
+		selectors++
+	}
+	if req.Supplier != "" {
+		selectors++
+	}
+	if len(req.ProductIDs) > 0 {
+		selectors++
+	}
+	if selectors != 1 {
+		return errors.New("分类、供货商、商品ID必须且只能指定一种调价范围")
+	}
+
+	switch req.Mode {
+	case model.PriceAdjustModePercent:
+		if req.Percent == 0 {
+			return errors.New("调整百分比不能为0")
+		}
+		if req.Percent <= -100 {
+			return errors.New("下调百分比不能达到或超过100%")
+		}
+	case model.PriceAdjustModeFixed:
+		if req.Delta == 0 {
+			return errors.New("调整金额不能为0")
+		}
+	default:
+		return errors.New("调价方式只能为 1(按百分比) 或 2(按固定金额)")
+	}
+
+	if req.Rounding < model.PriceRoundingCent || req.Rounding > model.PriceRoundingCeilYuan {
+		return errors.New("取整规则只能为 0(到分)、1(到角)、2(四舍五入到元)、3(向上取整到元)")
+	}
+	return nil
+}
+
+// adjustAmount 按调价规则计算调整后的金额
+func adjustAmount(a model.Amount, mode int8, percent float64, delta model.Amount, rounding int8) model.Amount {
+	var cents float64
+	if mode == model.PriceAdjustModePercent {
+		cents = float64(a) * (1 + percent/100)
+	} else {
+		cents = float64(a + delta)
+	}
+	// 先精确到分，避免浮点误差影响向上取整
+	cents = math.Round(cents)
+
+	switch rounding {
+	case model.PriceRoundingJiao:
+		cents = math.Round(cents/10) * 10
+	case model.PriceRoundingYuan:
+		cents = math.Round(cents/100) * 100
+	case model.PriceRoundingCeilYuan:
+		cents = math.Ceil(cents/100) * 100
+	}
+	return model.Amount(cents)
+}
+
+// buildPriceChanges 计算调价范围内每个商品的调价结果
+// 按分类调价时包含其所有子分类下的商品
+func (ps *priceService) buildPriceChanges(adjustment *model.PriceAdjustment, productIDs []int64) (*model.PriceAdjustmentPreview, error) {
+	var categoryIDs []int64
+	if adjustment.CategoryID > 0 {
+		categories, err := ps.productRepo.GetCategoriesByShop(adjustment.ShopID)
+		if err != nil {
+			return nil, err
+		}
+		categoryIDs = categoryDescendantIDs(categories, adjustment.CategoryID)
+	}
+	products, err := ps.priceRepo.GetProductsForAdjustment(adjustment.ShopID, categoryIDs, adjustment.Supplier, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(products) == 0 {
+		return nil, errors.New("没有符合条件的商品")
+	}
+	if len(productIDs) > 0 && len(products) != len(productIDs) {
+		return nil, errors.New("部分商品不存在或不属于该店铺")
+	}
+	return ps.priceChanges(adjustment, products)
+}
+
+// priceChanges 按调价规则计算每个商品的调价结果；执行调价时在事务中对锁定的商品重新计算
+func (ps *priceService) priceChanges(adjustment *model.PriceAdjustment, products []model.Product) (*model.PriceAdjustmentPreview, error) {
+	// 套装成本按组件推算，不参与成本调价
+	fillBundleStock(ps.productRepo, products)
+
+	preview := &model.PriceAdjustmentPreview{Total: len(products)}
+	for _, p := range products {
+		item := model.PriceChangeItem{
+			ProductID:      p.ID,
+			ProductName:    p.Name,
+			Specification:  p.Specification,
+			Unit:           p.Unit,
+			IsBundle:       p.IsBundle,
+			OldSellerPrice: p.SellerPrice,
+			NewSellerPrice: adjustAmount(p.SellerPrice, adjustment.Mode, adjustment.Percent, adjustment.Delta, adjustment.Rounding),
+			OldCost:        p.Cost,
+			NewCost:        p.Cost,
+			ShopID:         p.ShopID,
+			NewProductCost: p.ProductCost,
+		}
+		if adjustment.AdjustCost == 1 && p.IsBundle != model.BundleYes {
+			item.NewCost = adjustAmount(p.Cost, adjustment.Mode, adjustment.Percent, adjustment.Delta, adjustment.Rounding)
+			// 运费成本不变，差额计入货物成本
+			item.NewProductCost = item.NewCost - p.ShippingCost
+		}
+
+		if item.NewSellerPrice < 0 {
+			return nil, fmt.Errorf("商品 %s 调整后售价为负数，请检查调价规则", p.Name)
+		}
+		if item.NewCost < 0 || item.NewProductCost < 0 {
+			return nil, fmt.Errorf("商品 %s 调整后成本价低于运费成本，请检查调价规则", p.Name)
+		}
+		if item.NewSellerPrice < item.NewCost {
+			item.Warning = "调整后售价低于成本价"
+		}
+		item.Changed = item.NewSellerPrice != item.OldSellerPrice || item.NewCost != item.OldCost
+		if item.Changed {
+			preview.Changed++
+		}
+		preview.Items = append(preview.Items, item)
+	}
+	return preview, nil
+}
+
+// applyAdjustment 执行调价：在事务中锁定预览范围内的商品，按当时的价格重新计算后写入，preview 更新为实际执行的结果
+func (ps *priceService) applyAdjustment(adjustment *model.PriceAdjustment, preview *model.PriceAdjustmentPreview) error {
+	productIDs := make([]int64, 0, len(preview.Items))
+	for _, item := range preview.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	return ps.priceRepo.ApplyAdjustment(adjustment, productIDs, func(products []model.Product) ([]model.PriceChangeItem, error) {
+		locked, err := ps.priceChanges(adjustment, products)
+		if err != nil {
+			return nil, err
+		}
+		*preview = *locked
+		return changedItems(locked), nil
+	})
+}
+
+// changedItems 过滤出价格有变化的商品
+func changedItems(preview *model.PriceAdjustmentPreview) []model.PriceChangeItem {
+	items := make([]model.PriceChangeItem, 0, preview.Changed)
+	for _, item := range preview.Items {
+		if item.Changed {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newAdjustment 根据请求构建调价单
+func newAdjustment(req *model.PriceAdjustmentRequest) *model.PriceAdjustment {
+	ids := make([]string, 0, len(req.ProductIDs))
+	for _, id := range req.ProductIDs {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	return &model.PriceAdjustment{
+		ShopID:     req.ShopID,
+		CategoryID: req.CategoryID,
+		Supplier:   req.Supplier,
+		ProductIDs: strings.Join(ids, ","),
+		Mode:       req.Mode,
+		Percent:    req.Percent,
+		Delta:      req.Delta,
+		AdjustCost: req.AdjustCost,
+		Rounding:   req.Rounding,
+		Remark:     req.Remark,
+	}
+}
+
+// parseProductIDs 解析调价单中保存的商品ID
+func parseProductIDs(s string) ([]int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	ids := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("商品ID格式错误: %s", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// PreviewAdjustment 预览调价结果，不修改数据
+func (ps *priceService) PreviewAdjustment(req *model.PriceAdjustmentRequest) (*model.PriceAdjustmentPreview, error) {
+	if err := validateAdjustmentRequest(req); err != nil {
+		return nil, err
+	}
+	return ps.buildPriceChanges(newAdjustment(req), req.ProductIDs)
+}
+
+// CreateAdjustment 创建调价单：未指定生效时间或生效时间已到则立即执行，否则等待定时任务执行
+func (ps *priceService) CreateAdjustment(req *model.PriceAdjustmentRequest, operatorID int64, operator string) (*model.PriceAdjustment, *model.PriceAdjustmentPreview, error) {
+	if err := validateAdjustmentRequest(req); err != nil {
+		return nil, nil, err
+	}
+
+	adjustment := newAdjustment(req)
+	adjustment.Operator = operator
+	adjustment.OperatorID = operatorID
+
+	// 1. 先计算一遍，提前暴露规则错误
+	preview, err := ps.buildPriceChanges(adjustment, req.ProductIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 2. 定时生效
+	now := time.Now()
+	if req.EffectiveAt != "" {
+		effectiveAt, err := time.ParseInLocation("2006-01-02 15:04:05", req.EffectiveAt, time.Local)
+		if err != nil {
+			return nil, nil, errors.New("生效时间格式错误，应为 2006-01-02 15:04:05")
+		}
+		adjustment.EffectiveAt = &effectiveAt
+		if effectiveAt.After(now) {
+			adjustment.Status = model.PriceAdjustmentPending
+			if err := ps.priceRepo.CreateAdjustment(adjustment); err != nil {
+				return nil, nil, fmt.Errorf("创建调价单失败: %v", err)
+			}
+			return adjustment, preview, nil
+		}
+	}
+
+	// 3. 立即生效
+	if adjustment.EffectiveAt == nil {
+		adjustment.EffectiveAt = &now
+	}
+	if preview.Changed == 0 {
+		return nil, nil, errors.New("调价后所有商品价格均无变化")
+	}
+	if err := ps.applyAdjustment(adjustment, preview); err != nil {
+		return nil, nil, fmt.Errorf("执行调价失败: %v", err)
+	}
+	return adjustment, preview, nil
+}
+
+// GetAdjustmentByID 获取调价单
+func (ps *priceService) GetAdjustmentByID(id int64) (*model.PriceAdjustment, error) {
+	return ps.priceRepo.GetAdjustmentByID(id)
+}
+
+// GetAdjustmentList 分页获取调价单列表
+func (ps *priceService) GetAdjustmentList(page, pageSize int, shopID int64, status int8) ([]model.PriceAdjustment, int64, error) {
+	offset := (page - 1) * pageSize
+	return ps.priceRepo.GetAdjustmentList(offset, pageSize, shopID, status)
+}
+
+// CancelAdjustment 取消待生效的调价单
+func (ps *priceService) CancelAdjustment(id int64) error {
+	ok, err := ps.priceRepo.UpdateAdjustmentStatus(id, model.PriceAdjustmentPending, model.PriceAdjustmentCancelled, "")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("只有待生效的调价单可以取消")
+	}
+	return nil
+}
+
+// ApplyDueAdjustments 执行所有到期的调价单，按当前价格重新计算
+func (ps *priceService) ApplyDueAdjustments() error {
+	adjustments, err := ps.priceRepo.GetDueAdjustments(time.Now())
+	if err != nil {
+		return err
+	}
+
+	for i := range adjustments {
+		adjustment := &adjustments[i]
+		if err := ps.applyScheduledAdjustment(adjustment); err != nil {
+			log.Printf("定时调价单 %d 执行失败: %v", adjustment.ID, err)
+			if _, updateErr := ps.priceRepo.UpdateAdjustmentStatus(adjustment.ID, model.PriceAdjustmentPending, model.PriceAdjustmentFailed, err.Error()); updateErr != nil {
+				log.Printf("更新调价单 %d 状态失败: %v", adjustment.ID, updateErr)
+			}
+			continue
+		}
+		log.Printf("定时调价单 %d 已生效，调价商品数: %d", adjustment.ID, adjustment.ProductCount)
+	}
+	return nil
+}
+
+// applyScheduledAdjustment 执行单个定时调价单
+func (ps *priceService) applyScheduledAdjustment(adjustment *model.PriceAdjustment) error {
+	productIDs, err := parseProductIDs(adjustment.ProductIDs)
+	if err != nil {
+		return err
+	}
+	preview, err := ps.buildPriceChanges(adjustment, productIDs)
+	if err != nil {
+		return err
+	}
+	return ps.applyAdjustment(adjustment, preview)
+}
+
+// StartScheduler 启动定时调价任务，按间隔检查到期的调价单
+func (ps *priceService) StartScheduler(interval time.Duration) {
+	pkg.RunEvery("定时调价任务", interval, ps.ApplyDueAdjustments)
+}
+
+// GetPriceHistory 分页获取商品价格变动记录
+func (ps *priceService) GetPriceHistory(productID int64, page, pageSize int) ([]model.ProductPriceHistory, int64, error) {
+	offset := (page - 1) * pageSize
+	return ps.priceRepo.GetPriceHistory(productID, offset, pageSize)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"testing"
+)
+
+func TestAdjustAmount(t *testing.T) {
+	cases := []struct {
+		name     string
+		amount   model.Amount
+		mode     int8
+		percent  float64
+		delta    model.Amount
+		rounding int8
+		want     model.Amount
+	}{
+		{"上调8%精确到分", 26800, model.PriceAdjustModePercent, 8, 0, model.PriceRoundingCent, 28944},
+		{"下调5%精确到分四舍五入", 1999, model.PriceAdjustModePercent, -5, 0, model.PriceRoundingCent, 1899}, // 1899.05
+		{"上调10%到角", 12345, model.PriceAdjustModePercent, 10, 0, model.PriceRoundingJiao, 13580},    // 13579.5 -> 13580
+		{"上调3%四舍五入到元", 26800, model.PriceAdjustModePercent, 3, 0, model.PriceRoundingYuan, 27600},  // 27604
+		{"上调3%向上取整到元", 26800, model.PriceAdjustModePercent, 3, 0, model.PriceRoundingCeilYuan, 27700},
+		{"整元不再向上取整", 10000, model.PriceAdjustModePercent, 10, 0, model.PriceRoundingCeilYuan, 11000}, // 浮点误差不多进一元
+		{"固定加5元", 26800, model.PriceAdjustModeFixed, 0, 500, model.PriceRoundingCent, 27300},
+		{"固定减0.35元到角", 1000, model.PriceAdjustModeFixed, 0, -35, model.PriceRoundingJiao, 970}, // 965 -> 970
+		{"固定减0.5元四舍五入到元", 1000, model.PriceAdjustModeFixed, 0, -50, model.PriceRoundingYuan, 1000},
+		{"固定加0.01元向上取整到元", 1000, model.PriceAdjustModeFixed, 0, 1, model.PriceRoundingCeilYuan, 1100},
+		{"减到负数", 300, model.PriceAdjustModeFixed, 0, -500, model.PriceRoundingCent, -200},
+	}
+	for _, tc := range cases {
+		if got := adjustAmount(tc.amount, tc.mode, tc.percent, tc.delta, tc.rounding); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
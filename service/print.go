@@ -0,0 +1,499 @@
+package service
+
+import (
+	"bytes"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/repository"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	printJobMaxAttempts  = 3               // 打印任务最多领取次数
+	printJobClaimTimeout = 2 * time.Minute // 领取后超过该时间未回报结果，可被重新领取
+	printJobMaxCopies    = 5               // 单个任务最多打印份数
+	printSystemOperator  = "system"        // 自动打印任务的创建人
+)
+
+type PrintService interface {
+	// 打印机管理
+	AddPrinter(shopID int64, req *model.AddPrinterRequest) (*model.PrinterWithToken, error)
+	EditPrinter(id int64, req *model.EditPrinterRequest) (*model.Printer, error)
+	ResetPrinterToken(id int64) (*model.PrinterWithToken, error)
+	DeletePrinter(id int64) error
+	GetPrinterByID(id int64) (*model.Printer, error)
+	GetPrinters(shopID int64) ([]model.Printer, error)
+
+	// 打印任务
+	CreateJob(shopID int64, req *model.CreatePrintJobRequest, operator string) (*model.PrintJob, error)
+	GetJobByID(id int64) (*model.PrintJob, error)
+	GetJobs(page, pageSize int, shopID int64, status int8) ([]model.PrintJob, int64, error)
+	RetryJob(id int64) error
+	Preview(sourceType string, sourceID int64, paperWidth int) (int64, string, []byte, error) // 返回店铺ID、文本预览和 ESC/POS 指令
+
+	// 打印机轮询
+	AuthPrinter(token string) (*model.Printer, error)
+	ClaimJob(printer *model.Printer) (*model.PrintJob, error) // 领取任务并生成 ESC/POS 指令，没有任务时返回 nil
+	ReportJob(printer *model.Printer, jobID int64, req *model.PrintJobResultRequest) error
+}
+
+type printService struct {
+	printRepo repository.PrintRepository
+	orderRepo repository.OrderRepository
+	stockRepo repository.StockRepository
+	posRepo   repository.PosRepository
+	shopRepo  repository.ShopRepository
+}
+
+func NewPrintService(printRepo repository.PrintRepository, orderRepo repository.OrderRepository, stockRepo repository.StockRepository, posRepo repository.PosRepository, shopRepo repository.ShopRepository) PrintService {
+	return &printService{
+		printRepo: printRepo,
+		orderRepo: orderRepo,
+		stockRepo: stockRepo,
+		posRepo:   posRepo,
+		shopRepo:  shopRepo,
+	}
+}
+
+// enqueueAutoPrintJobs 为店铺开启自动打印的每台打印机创建打印任务
+// 打印是业务完成后的通知，失败只记录日志，不影响支付或结账结果
+func enqueueAutoPrintJobs(printRepo repository.PrintRepository, shopID int64, sourceType string, sourceID int64, sourceNo string) {
+	printers, err := printRepo.GetAutoPrinters(shopID)
+	if err != nil {
+		log.Printf("获取店铺 %d 自动打印机失败: %v", shopID, err)
+		return
+	}
+	for _, printer := range printers {
+		now := time.Now()
+		job := &model.PrintJob{
+			ShopID:     shopID,
+			PrinterID:  printer.ID,
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			SourceNo:   sourceNo,
+			Copies:     1,
+			Status:     model.PrintJobPending,
+			Operator:   printSystemOperator,
+			CreatedAt:  &now,
+		}
+		if err := printRepo.CreateJob(job); err != nil {
+			log.Printf("创建 %s %s 的打印任务失败: %v", sourceType, sourceNo, err)
+		}
+	}
+}
+
+// newPrinterToken 生成打印机轮询令牌
+func newPrinterToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// normalizePaperWidth 纸宽只支持58mm和80mm，默认58mm
+func normalizePaperWidth(width int) (int, error) {
+	switch width {
+	case 0, 58:
+		return 58, nil
+	case 80:
+		return 80, nil
+	}
+	return 0, errors.New("纸宽只能为58或80")
+}
+
+// AddPrinter 添加打印机，生成轮询令牌
+func (s *printService) AddPrinter(shopID int64, req *model.AddPrinterRequest) (*model.PrinterWithToken, error) {
+	width, err := normalizePaperWidth(req.PaperWidth)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newPrinterToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	printer := &model.Printer{
+		ShopID:     shopID,
+		Name:       req.Name,
+		Token:      token,
+		PaperWidth: width,
+		AutoPrint:  req.AutoPrint,
+		IsActive:   1,
+		CreatedAt:  &now,
+	}
+	if err := s.printRepo.CreatePrinter(printer); err != nil {
+		return nil, err
+	}
+	return &model.PrinterWithToken{Printer: *printer, Token: token}, nil
+}
+
+// EditPrinter 编辑打印机
+func (s *printService) EditPrinter(id int64, req *model.EditPrinterRequest) (*model.Printer, error) {
+	fields := make(map[string]interface{})
+	if req.Name != "" {
+		fields["name"] = req.Name
+	}
+	if req.PaperWidth != 0 {
+		width, err := normalizePaperWidth(req.PaperWidth)
+		if err != nil {
+			return nil, err
+		}
+		fields["paper_width"] = width
+	}
+	if req.AutoPrint != nil {
+		fields["auto_print"] = *req.AutoPrint
+	}
+	if req.IsActive != nil {
+		fields["is_active"] = *req.IsActive
+	}
+	if len(fields) > 0 {
+		if err := s.printRepo.UpdatePrinter(id, fields); err != nil {
+			return nil, err
+		}
+	}
+	return s.printRepo.GetPrinterByID(id)
+}
+
+// ResetPrinterToken 重置轮询令牌(令牌泄露或更换打印机时)，旧令牌立即失效
+func (s *printService) ResetPrinterToken(id int64) (*model.PrinterWithToken, error) {
+	token, err := newPrinterToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.printRepo.UpdatePrinter(id, map[string]interface{}{"token": token}); err != nil {
+		return nil, err
+	}
+	printer, err := s.printRepo.GetPrinterByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PrinterWithToken{Printer: *printer, Token: token}, nil
+}
+
+// DeletePrinter 删除打印机
+func (s *printService) DeletePrinter(id int64) error {
+	return s.printRepo.DeletePrinter(id)
+}
+
+// GetPrinterByID 根据ID获取打印机
+func (s *printService) GetPrinterByID(id int64) (*model.Printer, error) {
+	return s.printRepo.GetPrinterByID(id)
+}
+
+// GetPrinters 打印机列表
+func (s *printService) GetPrinters(shopID int64) ([]model.Printer, error) {
+	return s.printRepo.GetPrinters(shopID)
+}
+
+// CreateJob 后台手动创建打印任务(补打)，打印内容需属于该店铺
+func (s *printService) CreateJob(shopID int64, req *model.CreatePrintJobRequest, operator string) (*model.PrintJob, error) {
+	sourceShopID, sourceNo, err := s.sourceInfo(req.SourceType, req.SourceID)
+	if err != nil {
+		return nil, err
+	}
+	if sourceShopID != shopID {
+		return nil, errors.New("打印内容不属于该店铺")
+	}
+	if req.PrinterID > 0 {
+		printer, err := s.printRepo.GetPrinterByID(req.PrinterID)
+		if err != nil || printer.ShopID != shopID {
+			return nil, errors.New("打印机不存在")
+		}
+	}
+	copies := req.Copies
+	if copies <= 0 {
+		copies = 1
+	}
+	if copies > printJobMaxCopies {
+		return nil, fmt.Errorf("单次最多打印%d份", printJobMaxCopies)
+	}
+
+	now := time.Now()
+	job := &model.PrintJob{
+		ShopID:     shopID,
+		PrinterID:  req.PrinterID,
+		SourceType: req.SourceType,
+		SourceID:   req.SourceID,
+		SourceNo:   sourceNo,
+		Copies:     copies,
+		Status:     model.PrintJobPending,
+		Operator:   operator,
+		CreatedAt:  &now,
+	}
+	if err := s.printRepo.CreateJob(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// sourceInfo 打印内容所属店铺和单号
+func (s *printService) sourceInfo(sourceType string, sourceID int64) (int64, string, error) {
+	switch sourceType {
+	case model.PrintSourceOrder:
+		order, err := s.orderRepo.GetOrderByID(sourceID)
+		if err != nil {
+			return 0, "", errors.New("订单不存在")
+		}
+		return order.ShopID, order.OrderNo, nil
+	case model.PrintSourceOperation:
+		operation, err := s.stockRepo.GetStockOperationByID(sourceID)
+		if err != nil {
+			return 0, "", errors.New("出库单不存在")
+		}
+		if operation.Types != model.StockTypeOutbound {
+			return 0, "", errors.New("只能打印出库单")
+		}
+		if operation.IsVoided == 1 {
+			return 0, "", errors.New("出库单已作废")
+		}
+		return operation.ShopID, operation.OperationNo, nil
+	case model.PrintSourcePosSale:
+		sale, err := s.posRepo.GetSaleByID(sourceID)
+		if err != nil {
+			return 0, "", errors.New("收银单不存在")
+		}
+		return sale.ShopID, sale.SaleNo, nil
+	}
+	return 0, "", errors.New("打印内容类型只能为 order、operation 或 pos_sale")
+}
+
+// GetJobByID 根据ID获取打印任务
+func (s *printService) GetJobByID(id int64) (*model.PrintJob, error) {
+	return s.printRepo.GetJobByID(id)
+}
+
+// GetJobs 打印任务列表
+func (s *printService) GetJobs(page, pageSize int, shopID int64, status int8) ([]model.PrintJob, int64, error) {
+	return s.printRepo.GetJobs(page, pageSize, shopID, status)
+}
+
+// RetryJob 重新打印
+func (s *printService) RetryJob(id int64) error {
+	return s.printRepo.RetryJob(id)
+}
+
+// Preview 预览打印内容，返回所属店铺ID、文本和 ESC/POS 指令
+func (s *printService) Preview(sourceType string, sourceID int64, paperWidth int) (int64, string, []byte, error) {
+	width := ReceiptWidth(paperWidth)
+	text := &textReceiptWriter{width: width}
+	shopID, content, err := s.render(sourceType, sourceID, width, text)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return shopID, text.b.String(), content, nil
+}
+
+// AuthPrinter 按令牌识别打印机并记录轮询时间
+func (s *printService) AuthPrinter(token string) (*model.Printer, error) {
+	if token == "" {
+		return nil, errors.New("缺少打印机令牌")
+	}
+	printer, err := s.printRepo.GetPrinterByToken(token)
+	if err != nil {
+		return nil, errors.New("打印机令牌无效或打印机已停用")
+	}
+	now := time.Now()
+	if err := s.printRepo.UpdatePrinter(printer.ID, map[string]interface{}{"last_seen_at": &now}); err != nil {
+		return nil, err
+	}
+	printer.LastSeenAt = &now
+	return printer, nil
+}
+
+// ClaimJob 领取下一个打印任务，按打印机纸宽生成 ESC/POS 指令(多份时重复输出)
+// 打印内容已不存在时任务直接标记失败并继续领取下一个
+func (s *printService) ClaimJob(printer *model.Printer) (*model.PrintJob, error) {
+	for {
+		job, err := s.printRepo.ClaimNextJob(printer, time.Now().Add(-printJobClaimTimeout), printJobMaxAttempts)
+		if err != nil || job == nil {
+			return nil, err
+		}
+		_, content, err := s.render(job.SourceType, job.SourceID, ReceiptWidth(printer.PaperWidth), nil)
+		if err != nil {
+			if finishErr := s.printRepo.FinishJob(job.ID, printer.ID, map[string]interface{}{
+				"status": model.PrintJobFailed,
+				"error":  err.Error(),
+			}); finishErr != nil {
+				return nil, finishErr
+			}
+			continue
+		}
+		content = bytes.Repeat(content, job.Copies)
+		if err := s.printRepo.SaveJobContent(job.ID, content); err != nil {
+			return nil, err
+		}
+		job.Content = content
+		return job, nil
+	}
+}
+
+// ReportJob 打印机回报结果，失败且未超过重试次数时重新排队
+func (s *printService) ReportJob(printer *model.Printer, jobID int64, req *model.PrintJobResultRequest) error {
+	job, err := s.printRepo.GetJobByID(jobID)
+	if err != nil {
+		return errors.New("打印任务不存在")
+	}
+	now := time.Now()
+	fields := map[string]interface{}{"error": req.Error}
+	switch {
+	case req.Success:
+		fields["status"] = model.PrintJobDone
+		fields["printed_at"] = &now
+		fields["error"] = ""
+	case job.Attempts < printJobMaxAttempts:
+		fields["status"] = model.PrintJobPending
+	default:
+		fields["status"] = model.PrintJobFailed
+	}
+	return s.printRepo.FinishJob(jobID, printer.ID, fields)
+}
+
+// render 生成打印内容的 ESC/POS 指令，text 不为空时同时输出文本排版
+func (s *printService) render(sourceType string, sourceID int64, width int, text *textReceiptWriter) (int64, []byte, error) {
+	writers := func(w *escposReceiptWriter, write func(receiptWriter)) {
+		write(w)
+		if text != nil {
+			write(text)
+		}
+	}
+	escpos := newEscposReceiptWriter(width)
+
+	switch sourceType {
+	case model.PrintSourceOrder:
+		order, err := s.orderRepo.GetOrderByID(sourceID)
+		if err != nil {
+			return 0, nil, errors.New("订单不存在")
+		}
+		items, err := s.stockRepo.GetStockOperationItemsByOrderID(order.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		shop, err := s.shopRepo.GetShopByID(order.ShopID)
+		if err != nil {
+			return 0, nil, fmt.Errorf("获取店铺信息失败: %v", err)
+		}
+		writers(escpos, func(w receiptWriter) { writeOrderTicket(w, shop, order, items) })
+		return order.ShopID, escpos.finish(), nil
+
+	case model.PrintSourceOperation:
+		operation, err := s.stockRepo.GetStockOperationByID(sourceID)
+		if err != nil {
+			return 0, nil, errors.New("出库单不存在")
+		}
+		items, err := s.stockRepo.GetStockOperationItems(operation.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		shop, err := s.shopRepo.GetShopByID(operation.ShopID)
+		if err != nil {
+			return 0, nil, fmt.Errorf("获取店铺信息失败: %v", err)
+		}
+		writers(escpos, func(w receiptWriter) { writeOperationTicket(w, shop, operation, items) })
+		return operation.ShopID, escpos.finish(), nil
+
+	case model.PrintSourcePosSale:
+		sale, err := s.posRepo.GetSaleByID(sourceID)
+		if err != nil {
+			return 0, nil, errors.New("收银单不存在")
+		}
+		if sale.Items, err = s.posRepo.GetSaleItems(sale.ID); err != nil {
+			return 0, nil, err
+		}
+		shop, err := s.shopRepo.GetShopByID(sale.ShopID)
+		if err != nil {
+			return 0, nil, fmt.Errorf("获取店铺信息失败: %v", err)
+		}
+		if text != nil {
+			writePosReceipt(text, shop, sale)
+		}
+		return sale.ShopID, BuildPosReceiptEscPos(shop, sale, width), nil
+	}
+	return 0, nil, errors.New("打印内容类型只能为 order、operation 或 pos_sale")
+}
+
+// writeOrderTicket 小程序订单小票(店铺接单联)
+func writeOrderTicket(w receiptWriter, shop *model.Shop, order *model.Order, items []model.StockOperationItem) {
+	w.Title(shop.Name)
+	w.Center("小程序订单")
+	w.Separator()
+	w.Line("订单号:" + order.OrderNo)
+	if order.CreatedAt != nil {
+		w.Line("下单:" + order.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	if order.PaymentTime != nil {
+		w.Line("支付:" + order.PaymentTime.Format("2006-01-02 15:04:05"))
+	}
+	w.Separator()
+
+	var totalQuantity int
+	for _, item := range items {
+		if item.BundleID > 0 {
+			continue
+		}
+		writeReceiptItem(w, item.ProductName, item.Specification, item.Unit, item.Quantity, item.UnitPrice, item.TotalPrice)
+		totalQuantity += item.Quantity
+	}
+	w.Separator()
+	w.Justify(fmt.Sprintf("商品金额(%d件)", totalQuantity), fmt.Sprintf("%.2f", order.TotalAmount.Yuan()))
+	if order.ShippingFee > 0 {
+		w.Justify("运费", fmt.Sprintf("%.2f", order.ShippingFee.Yuan()))
+	}
+	if order.DiscountAmount+order.CouponAmount > 0 {
+		w.Justify("优惠", fmt.Sprintf("-%.2f", (order.DiscountAmount+order.CouponAmount).Yuan()))
+	}
+	w.Justify("实付", fmt.Sprintf("%.2f", order.PaymentAmount.Yuan()))
+	if order.PaymentStatus != model.PaymentStatusPaid {
+		w.Center("*** 未支付 ***")
+	}
+	w.Separator()
+	w.Line("收货人:" + order.ReceiverName + " " + order.ReceiverPhone)
+	w.Line("地址:" + order.ReceiverAddress)
+	if order.Note != "" {
+		w.Line("备注:" + order.Note)
+	}
+}
+
+// writeOperationTicket 出库单小票，套装只列套装本身
+func writeOperationTicket(w receiptWriter, shop *model.Shop, operation *model.StockOperation, items []model.StockOperationItem) {
+	w.Title(shop.Name)
+	w.Center("出库单")
+	w.Separator()
+	w.Line("单号:" + operation.OperationNo)
+	if operation.CreatedAt != nil {
+		w.Line("时间:" + operation.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	w.Line("客户:" + operation.UserName)
+	if operation.Operator != "" {
+		w.Line("开单:" + operation.Operator)
+	}
+	w.Separator()
+
+	var totalQuantity int
+	for _, item := range items {
+		if item.BundleID > 0 {
+			continue
+		}
+		totalPrice := item.TotalPrice
+		if totalPrice == 0 {
+			totalPrice = model.Amount(int64(item.UnitPrice) * int64(item.Quantity))
+		}
+		writeReceiptItem(w, item.ProductName, item.Specification, item.Unit, item.Quantity, item.UnitPrice, totalPrice)
+		totalQuantity += item.Quantity
+	}
+	w.Separator()
+	w.Justify(fmt.Sprintf("合计(%d件)", totalQuantity), fmt.Sprintf("%.2f", operation.TotalAmount.Yuan()))
+	if operation.PaymentFinishStatus == model.PaymentStatusPaid {
+		w.Justify("付款状态", "已付款")
+	} else {
+		w.Justify("付款状态", "未付款")
+	}
+	if operation.Remark != "" {
+		w.Separator()
+		w.Line("备注:" + operation.Remark)
+	}
+}
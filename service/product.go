@@ -1,30 +1,65 @@
 package service
 
 import (
+	"bytes"
 	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
 	"cmf/paint_proj/repository"
+	"errors"
+	"fmt"
+	"time"
 )
 
 type ProductService interface {
-	GetProductList() ([]model.Category, map[int64][]model.ProductSimple, error)
-	GetProductListByShop(shopID int64) ([]model.Category, map[int64][]model.ProductSimple, error)
+	GetProductList() ([]*model.CategoryNode, map[int64][]model.ProductSimple, error)
+	GetProductListByShop(shopID int64) ([]*model.CategoryNode, map[int64][]model.ProductSimple, error)
 
 	GetAdminProductList(page, pageSize int, shopID int64, name string) ([]model.Product, int64, error)
 	GetProductByID(id int64) (*model.Product, error)
 	GetProductByIDAndShop(id int64, shopID int64) (*model.Product, error)
 	AddProduct(p *model.Product) error
 	UpdateProduct(p *model.Product) error
-	UpdateProductFields(id int64, fields map[string]interface{}) error
+	UpdateProductFields(id int64, fields map[string]interface{}, operatorID int64, operator string) error
 	DeleteProduct(id int64) error
-	CheckProductNameExists(name string, excludeID ...int64) (bool, error)
+	CheckProductNameExists(shopID int64, name string, excludeID ...int64) (bool, error)
 
 	// 分类管理方法
 	GetAllCategories() ([]model.Category, error)
 	GetCategoriesByShop(shopID int64) ([]model.Category, error)
 	AddCategory(category *model.Category) error
 	UpdateCategory(category *model.Category) error
-	DeleteCategory(id int64) error
+	DeleteCategory(category *model.Category, targetID int64) error
 	GetCategoryByID(id int64) (*model.Category, error)
+	GetCategoryTree(shopID int64) ([]*model.CategoryNode, error)
+	MoveCategory(category *model.Category, parentID int64, sortOrder *int64) error
+	SortCategories(shopID int64, parentID int64, ids []int64) error
+
+	// 套装管理方法
+	AddBundleProduct(p *model.Product, items []model.BundleItemRequest) error
+	SetBundleItems(bundleID int64, items []model.BundleItemRequest) error
+	GetBundleItems(bundleID int64) ([]model.BundleItemDetail, error)
+
+	// 导入导出
+	ImportProducts(shopID int64, rows [][]string, dryRun bool, operatorID int64, operator string) (*model.ProductImportResult, error)
+	ExportProducts(shopID int64, name string) (*bytes.Buffer, error)
+	CopyCatalog(req *model.CatalogCopyRequest, operatorID int64, operator string) (*model.CatalogCopyReport, error) // 店铺间复制分类和商品
+
+	// 条码
+	ValidateProductBarcode(shopID int64, barcode string, excludeID int64) error
+	GetProductByBarcode(shopID int64, barcode string) (*model.Product, error)
+	PrintBarcodeLabels(shopID int64, req *model.BarcodeLabelRequest) (*bytes.Buffer, error)
+
+	// 小程序搜索
+	SearchProducts(query *model.ProductSearchQuery, cursor string) (*model.ProductSearchResponse, error)
+	FillMissingPinyinInitials() (int, error)
+	GenerateMissingImageVariants() (int, error)
+
+	// 商品图集和详情
+	GetProductDetail(id int64, shopID int64) (*model.ProductDetailResponse, error)
+	GetProductGalleryAndDetail(id int64) ([]model.ProductImage, *model.ProductDetail, error)
+	AddProductImage(product *model.Product, url string) (*model.ProductImage, error)
+	SetProductGallery(product *model.Product, urls []string) error
+	SetProductDetail(product *model.Product, req *model.SetProductDetailRequest) error
 }
 
 type productService struct {
@@ -36,9 +71,9 @@ func NewProductService(pr repository.ProductRepository) ProductService {
 		productRepo: pr,
 	}
 }
-func (ps *productService) GetProductList() ([]model.Category, map[int64][]model.ProductSimple, error) {
-	// 1 从product表查分类
-	categories, categoryMap, err := ps.productRepo.GetProductCategory()
+func (ps *productService) GetProductList() ([]*model.CategoryNode, map[int64][]model.ProductSimple, error) {
+	// 1 获取所有分类
+	categories, err := ps.productRepo.GetAllCategories()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -47,27 +82,13 @@ func (ps *productService) GetProductList() ([]model.Category, map[int64][]model.
 	if err != nil {
 		return nil, nil, err
 	}
-	// 3.按分类分组
-	productMap := make(map[int64][]model.ProductSimple)
-	for _, p := range products {
-		sp := model.ProductSimple{
-			ID:           p.ID,
-			Name:         p.Name,
-			SellerPrice:  p.SellerPrice,
-			CategoryID:   p.CategoryId,
-			CategoryName: categoryMap[p.CategoryId],
-			Image:        p.Image,
-			Unit:         p.Unit,
-			Remark:       p.Remark,
-		}
-		productMap[p.CategoryId] = append(productMap[p.CategoryId], sp)
-	}
-	return categories, productMap, nil
+	tree, productMap := buildProductList(categories, products)
+	return tree, productMap, nil
 }
 
-func (ps *productService) GetProductListByShop(shopID int64) ([]model.Category, map[int64][]model.ProductSimple, error) {
-	// 1 根据店铺从product表查分类
-	categories, categoryMap, err := ps.productRepo.GetProductCategoryByShop(shopID)
+func (ps *productService) GetProductListByShop(shopID int64) ([]*model.CategoryNode, map[int64][]model.ProductSimple, error) {
+	// 1 根据店铺获取分类
+	categories, err := ps.productRepo.GetCategoriesByShop(shopID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -76,22 +97,23 @@ func (ps *productService) GetProductListByShop(shopID int64) ([]model.Category,
 	if err != nil {
 		return nil, nil, err
 	}
-	// 3.按分类分组
+	tree, productMap := buildProductList(categories, products)
+	return tree, productMap, nil
+}
+
+// buildProductList 商品按分类分组，分类组装为树并去掉没有商品的分支
+func buildProductList(categories []model.Category, products []model.Product) ([]*model.CategoryNode, map[int64][]model.ProductSimple) {
+	categoryMap := make(map[int64]string)
+	for _, category := range categories {
+		categoryMap[category.ID] = category.Name
+	}
 	productMap := make(map[int64][]model.ProductSimple)
+	hasProducts := make(map[int64]bool)
 	for _, p := range products {
-		sp := model.ProductSimple{
-			ID:           p.ID,
-			Name:         p.Name,
-			SellerPrice:  p.SellerPrice,
-			CategoryID:   p.CategoryId,
-			CategoryName: categoryMap[p.CategoryId],
-			Image:        p.Image,
-			Unit:         p.Unit,
-			Remark:       p.Remark,
-		}
-		productMap[p.CategoryId] = append(productMap[p.CategoryId], sp)
+		productMap[p.CategoryId] = append(productMap[p.CategoryId], newProductSimple(p, categoryMap[p.CategoryId]))
+		hasProducts[p.CategoryId] = true
 	}
-	return categories, productMap, nil
+	return pruneCategoryTree(buildCategoryTree(categories), hasProducts), productMap
 }
 
 func (ps *productService) GetAllCategories() ([]model.Category, error) {
@@ -104,44 +126,138 @@ func (ps *productService) GetCategoriesByShop(shopID int64) ([]model.Category, e
 
 func (ps *productService) GetAdminProductList(page, pageSize int, shopID int64, name string) ([]model.Product, int64, error) {
 	offset := (page - 1) * pageSize
+	var products []model.Product
+	var total int64
+	var err error
 	if shopID > 0 {
 		if name != "" {
-			return ps.productRepo.GetListByShopWithName(offset, pageSize, shopID, name)
+			products, total, err = ps.productRepo.GetListByShopWithName(offset, pageSize, shopID, name)
+		} else {
+			products, total, err = ps.productRepo.GetListByShop(offset, pageSize, shopID)
 		}
-		return ps.productRepo.GetListByShop(offset, pageSize, shopID)
+	} else if name != "" {
+		products, total, err = ps.productRepo.GetListWithName(offset, pageSize, name)
+	} else {
+		products, total, err = ps.productRepo.GetList(offset, pageSize)
 	}
-	if name != "" {
-		return ps.productRepo.GetListWithName(offset, pageSize, name)
+	if err != nil {
+		return nil, 0, err
 	}
-	return ps.productRepo.GetList(offset, pageSize)
+	// 套装库存由组件推算
+	fillBundleStock(ps.productRepo, products)
+	return products, total, nil
 }
 
 func (ps *productService) AddProduct(p *model.Product) error {
+	p.PinyinInitial = pkg.PinyinInitials(p.Name)
 	return ps.productRepo.Create(p)
 }
 
 func (ps *productService) UpdateProduct(p *model.Product) error {
+	p.PinyinInitial = pkg.PinyinInitials(p.Name)
 	return ps.productRepo.Update(p)
 }
 
-func (ps *productService) UpdateProductFields(id int64, fields map[string]interface{}) error {
-	return ps.productRepo.UpdateFields(id, fields)
+// UpdateProductFields 更新商品字段，售价有变化时记录价格变动；
+// 修改库存时不直接改库存字段，而是生成库存调整单，库存和库存日志在同一事务中写入
+func (ps *productService) UpdateProductFields(id int64, fields map[string]interface{}, operatorID int64, operator string) error {
+	newPrice, hasPrice := fields["seller_price"].(model.Amount)
+	stock, hasStock := fields["stock"].(int64)
+	delete(fields, "stock")
+	if !hasPrice && !hasStock {
+		return ps.productRepo.UpdateFields(id, fields, nil, nil)
+	}
+
+	product, err := ps.productRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	var adjustment *model.StockOperation
+	if hasStock {
+		now := time.Now()
+		adjustment = &model.StockOperation{
+			OperationNo:  pkg.GenerateOrderNo(pkg.StockPrefix, operatorID),
+			Types:        model.StockTypeAdjustment,
+			Operator:     operator,
+			OperatorID:   operatorID,
+			OperatorType: model.OperatorTypeAdmin,
+			ShopID:       product.ShopID,
+			Remark:       "编辑商品修改库存",
+			CreatedAt:    &now,
+			Items: []model.StockOperationItem{{
+				ProductID:     product.ID,
+				AfterStock:    int(stock),
+				ProductName:   product.Name,
+				Specification: product.Specification,
+				Unit:          product.Unit,
+				Remark:        "编辑商品修改库存",
+			}},
+		}
+	}
+	var history *model.ProductPriceHistory
+	if hasPrice && newPrice != product.SellerPrice {
+		history = &model.ProductPriceHistory{
+			ProductID:      product.ID,
+			ShopID:         product.ShopID,
+			ProductName:    product.Name,
+			OldSellerPrice: product.SellerPrice,
+			NewSellerPrice: newPrice,
+			OldCost:        product.Cost,
+			NewCost:        product.Cost,
+			Source:         model.PriceSourceEdit,
+			Operator:       operator,
+			OperatorID:     operatorID,
+		}
+	}
+	return ps.productRepo.UpdateFields(id, fields, history, adjustment)
 }
 
 func (ps *productService) GetProductByID(id int64) (*model.Product, error) {
-	return ps.productRepo.GetByID(id)
+	product, err := ps.productRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	ps.fillProductBundleStock(product)
+	return product, nil
 }
 
 func (ps *productService) GetProductByIDAndShop(id int64, shopID int64) (*model.Product, error) {
-	return ps.productRepo.GetByIDAndShop(id, shopID)
+	product, err := ps.productRepo.GetByIDAndShop(id, shopID)
+	if err != nil {
+		return nil, err
+	}
+	ps.fillProductBundleStock(product)
+	return product, nil
+}
+
+// fillProductBundleStock 为单个套装商品填充推算库存
+func (ps *productService) fillProductBundleStock(product *model.Product) {
+	if product.IsBundle != model.BundleYes {
+		return
+	}
+	products := []model.Product{*product}
+	fillBundleStock(ps.productRepo, products)
+	*product = products[0]
 }
 
 func (ps *productService) DeleteProduct(id int64) error {
+	// 被套装引用的商品不能删除
+	bundleIDs, err := ps.productRepo.GetBundleIDsByComponentID(id)
+	if err != nil {
+		return err
+	}
+	if len(bundleIDs) > 0 {
+		return fmt.Errorf("该商品是套装ID %v 的组件，无法删除", bundleIDs)
+	}
+	// 删除套装时在同一事务中清除其组件配置
 	return ps.productRepo.Delete(id)
 }
 
 // 分类管理方法实现
 func (ps *productService) AddCategory(category *model.Category) error {
+	if err := ps.validateParentCategory(category.ParentID, category.ShopID); err != nil {
+		return err
+	}
 	return ps.productRepo.CreateCategory(category)
 }
 
@@ -149,15 +265,59 @@ func (ps *productService) UpdateCategory(category *model.Category) error {
 	return ps.productRepo.UpdateCategory(category)
 }
 
-func (ps *productService) DeleteCategory(id int64) error {
-	return ps.productRepo.DeleteCategory(id)
-}
-
 func (ps *productService) GetCategoryByID(id int64) (*model.Category, error) {
 	return ps.productRepo.GetCategoryByID(id)
 }
 
-// CheckProductNameExists 检查商品名称是否已存在
-func (ps *productService) CheckProductNameExists(name string, excludeID ...int64) (bool, error) {
-	return ps.productRepo.CheckNameExists(name, excludeID...)
+// CheckProductNameExists 检查商品名称在店铺内是否已存在(与导入、复制商品的规则一致)
+func (ps *productService) CheckProductNameExists(shopID int64, name string, excludeID ...int64) (bool, error) {
+	return ps.productRepo.CheckNameExists(shopID, name, excludeID...)
+}
+
+// AddBundleProduct 新增套装商品
+func (ps *productService) AddBundleProduct(p *model.Product, items []model.BundleItemRequest) error {
+	bundleItems, err := buildBundleItems(ps.productRepo, 0, p.ShopID, items)
+	if err != nil {
+		return err
+	}
+	p.IsBundle = model.BundleYes
+	p.Stock = 0 // 套装不单独记库存
+	p.PinyinInitial = pkg.PinyinInitials(p.Name)
+	return ps.productRepo.CreateBundle(p, bundleItems)
+}
+
+// SetBundleItems 设置套装组件
+func (ps *productService) SetBundleItems(bundleID int64, items []model.BundleItemRequest) error {
+	bundle, err := ps.productRepo.GetByID(bundleID)
+	if err != nil {
+		return fmt.Errorf("套装商品不存在: %v", err)
+	}
+	if bundle.IsBundle != model.BundleYes {
+		return errors.New("该商品不是套装")
+	}
+	bundleItems, err := buildBundleItems(ps.productRepo, bundleID, bundle.ShopID, items)
+	if err != nil {
+		return err
+	}
+	return ps.productRepo.ReplaceBundleItems(bundleID, bundleItems)
+}
+
+// GetBundleItems 获取套装组件详情
+func (ps *productService) GetBundleItems(bundleID int64) ([]model.BundleItemDetail, error) {
+	components, err := loadBundleComponents(ps.productRepo, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	details := make([]model.BundleItemDetail, 0, len(components))
+	for _, c := range components {
+		details = append(details, model.BundleItemDetail{
+			ProductBundleItem: c.item,
+			ComponentName:     c.product.Name,
+			Specification:     c.product.Specification,
+			Unit:              c.product.Unit,
+			ComponentStock:    c.product.Stock,
+			ComponentCost:     c.product.Cost,
+		})
+	}
+	return details, nil
 }
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"bytes"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// 条码标签纸规格：A4，每页3列8行，每张 70mm×37mm
+const (
+	labelColumns  = 3
+	labelRows     = 8
+	labelWidth    = 70.0
+	labelHeight   = 37.0
+	labelPadding  = 4.0
+	labelMaxPages = 50 // 单次最多打印的页数
+)
+
+// ValidateProductBarcode 校验条码格式及在店铺内是否已被其他商品使用
+func (ps *productService) ValidateProductBarcode(shopID int64, barcode string, excludeID int64) error {
+	if err := pkg.ValidateBarcode(barcode); err != nil {
+		return err
+	}
+	other, err := ps.productRepo.GetByBarcodeAndShop(barcode, shopID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if other.ID != excludeID {
+		return fmt.Errorf("条码 %s 已被商品 %s 使用", barcode, other.Name)
+	}
+	return nil
+}
+
+// GetProductByBarcode 根据扫码得到的条码查找店铺内的商品
+func (ps *productService) GetProductByBarcode(shopID int64, barcode string) (*model.Product, error) {
+	product, err := ps.productRepo.GetByBarcodeAndShop(strings.TrimSpace(barcode), shopID)
+	if err != nil {
+		return nil, err
+	}
+	ps.fillProductBundleStock(product)
+	return product, nil
+}
+
+// PrintBarcodeLabels 生成商品条码标签PDF，标签上印商品名称、规格、售价和条码(或二维码)
+// 没有条码的商品默认报错；req.Assign 为 true 时分配店内码并保存，之后可直接扫码出入库
+func (ps *productService) PrintBarcodeLabels(shopID int64, req *model.BarcodeLabelRequest) (*bytes.Buffer, error) {
+	symbol := req.Symbol
+	if symbol == "" {
+		symbol = model.LabelSymbolBarcode
+	}
+	if symbol != model.LabelSymbolBarcode && symbol != model.LabelSymbolQR {
+		return nil, errors.New("标签图形只能为 barcode 或 qr")
+	}
+	copies := req.Copies
+	if copies <= 0 {
+		copies = 1
+	}
+	perPage := labelColumns * labelRows
+	if req.Skip < 0 || req.Skip >= perPage {
+		return nil, fmt.Errorf("跳过的标签数需在0到%d之间", perPage-1)
+	}
+	if req.Skip+len(req.ProductIDs)*copies > perPage*labelMaxPages {
+		return nil, fmt.Errorf("单次最多打印%d张标签", perPage*labelMaxPages)
+	}
+
+	// 1. 商品需属于该店铺，没有条码的按要求分配店内码
+	products, err := ps.productRepo.GetByIDs(req.ProductIDs)
+	if err != nil {
+		return nil, err
+	}
+	productMap := make(map[int64]model.Product, len(products))
+	for _, product := range products {
+		productMap[product.ID] = product
+	}
+	labels := make([]model.Product, 0, len(req.ProductIDs))
+	for _, id := range req.ProductIDs {
+		product, ok := productMap[id]
+		if !ok || product.ShopID != shopID {
+			return nil, fmt.Errorf("商品ID %d 不存在或不属于该店铺", id)
+		}
+		if product.Barcode == "" {
+			if !req.Assign {
+				return nil, fmt.Errorf("商品 %s 没有条码，请先设置条码或选择分配店内码", product.Name)
+			}
+			barcode := pkg.InternalBarcode(product.ID)
+			if err := ps.ValidateProductBarcode(shopID, barcode, product.ID); err != nil {
+				return nil, err
+			}
+			if err := ps.productRepo.UpdateFields(product.ID, map[string]interface{}{"barcode": barcode}, nil, nil); err != nil {
+				return nil, err
+			}
+			product.Barcode = barcode
+			productMap[id] = product
+		}
+		labels = append(labels, product)
+	}
+
+	// 2. 排版
+	pdf, err := pkg.NewPDF("P", gofpdf.SizeType{Wd: 210, Ht: 297})
+	if err != nil {
+		return nil, err
+	}
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	marginX := (210 - labelColumns*labelWidth) / 2
+	marginY := (297 - labelRows*labelHeight) / 2
+
+	slot := req.Skip
+	pdf.AddPage()
+	for _, product := range labels {
+		for i := 0; i < copies; i++ {
+			if slot == perPage {
+				pdf.AddPage()
+				slot = 0
+			}
+			x := marginX + float64(slot%labelColumns)*labelWidth
+			y := marginY + float64(slot/labelColumns)*labelHeight
+			if err := drawBarcodeLabel(pdf, &product, symbol, x, y); err != nil {
+				return nil, err
+			}
+			slot++
+		}
+	}
+	return pkg.OutputPDF(pdf)
+}
+
+// drawBarcodeLabel 绘制一张标签
+func drawBarcodeLabel(pdf *gofpdf.Fpdf, product *model.Product, symbol string, x, y float64) error {
+	price := fmt.Sprintf("¥%.2f", product.SellerPrice.Yuan())
+	if product.Unit != "" {
+		price += "/" + product.Unit
+	}
+	textX := x + labelPadding
+	textWidth := labelWidth - 2*labelPadding
+
+	if symbol == model.LabelSymbolQR {
+		// 二维码在右侧，文字在左侧
+		qrSize := 24.0
+		code, err := pkg.EncodeQRCode(product.Barcode)
+		if err != nil {
+			return fmt.Errorf("商品 %s 生成二维码失败: %v", product.Name, err)
+		}
+		pkg.DrawPDFBarcode(pdf, code, x+labelWidth-labelPadding-qrSize, y+(labelHeight-qrSize)/2, qrSize, qrSize)
+		textWidth -= qrSize + 2
+		drawLabelText(pdf, product.Name, 9, textX, y+5, textWidth)
+		drawLabelText(pdf, product.Specification, 7.5, textX, y+11, textWidth)
+		drawLabelText(pdf, price, 12, textX, y+19, textWidth)
+		drawLabelText(pdf, product.Barcode, 7, textX, y+28, textWidth)
+		return nil
+	}
+
+	drawLabelText(pdf, product.Name, 9, textX, y+3, textWidth)
+	drawLabelText(pdf, product.Specification, 7.5, textX, y+8, textWidth)
+	drawLabelText(pdf, price, 12, textX, y+12, textWidth)
+
+	code, err := pkg.EncodeBarcode(product.Barcode)
+	if err != nil {
+		return fmt.Errorf("商品 %s 的条码 %s 无法生成: %v", product.Name, product.Barcode, err)
+	}
+	// 每个模块0.33mm(EAN-13标准尺寸)，超出标签宽度时压缩
+	barWidth := float64(code.Bounds().Dx()) * 0.33
+	if barWidth > textWidth {
+		barWidth = textWidth
+	}
+	pkg.DrawPDFBarcode(pdf, code, x+(labelWidth-barWidth)/2, y+19.5, barWidth, 10)
+	pdf.SetFont(pkg.PDFFontFamily, "", 7.5)
+	pdf.SetXY(textX, y+30)
+	pdf.CellFormat(textWidth, 3.5, product.Barcode, "", 0, "C", false, 0, "")
+	return nil
+}
+
+// drawLabelText 在标签上输出一行文字，超出宽度截断
+func drawLabelText(pdf *gofpdf.Fpdf, text string, size float64, x, y, width float64) {
+	if text == "" {
+		return
+	}
+	pdf.SetFont(pkg.PDFFontFamily, "", size)
+	pdf.SetXY(x, y)
+	pdf.CellFormat(width, size*0.45, pkg.FitPDFText(pdf, text, width), "", 0, "L", false, 0, "")
+}
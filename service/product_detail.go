@@ -0,0 +1,192 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const relatedProductLimit = 6 // 相关商品数量
+
+// stockText 库存提示文案，库存不高于低库存阈值(与后台首页、低库存提醒相同)时提示"仅剩N件"
+func stockText(stock int, unit string) string {
+	switch {
+	case stock <= 0:
+		return "暂时缺货"
+	case stock <= stockWarningThreshold():
+		return fmt.Sprintf("仅剩%d%s", stock, unit)
+	}
+	return "现货充足"
+}
+
+// GetProductDetail 小程序商品详情：图集、图文详情、技术参数、库存提示和相关商品
+func (ps *productService) GetProductDetail(id int64, shopID int64) (*model.ProductDetailResponse, error) {
+	product, err := ps.productRepo.GetByIDAndShop(id, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if product.IsOnShelf != 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	ps.fillProductBundleStock(product)
+
+	response := &model.ProductDetailResponse{
+		ID:            product.ID,
+		Name:          product.Name,
+		SellerPrice:   product.SellerPrice,
+		CategoryID:    product.CategoryId,
+		Unit:          product.Unit,
+		Specification: product.Specification,
+		Remark:        product.Remark,
+		Images:        []string{},
+		RawImages:     []string{},
+		Stock:         product.Stock,
+		StockText:     stockText(product.Stock, product.Unit),
+		IsBundle:      product.IsBundle,
+		Related:       []model.ProductSimple{},
+	}
+
+	// 1. 图集，未设置时使用主图；展示详情图，点击预览原图
+	images, err := ps.productRepo.GetProductImages(id)
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, image := range images {
+		urls = append(urls, image.URL)
+	}
+	if len(urls) == 0 && product.Image != "" {
+		urls = append(urls, product.Image)
+	}
+	for _, url := range urls {
+		response.Images = append(response.Images, pkg.ImageVariantURL(url, pkg.ImageVariantDetail, pkg.ImageFormatJPEG))
+		response.RawImages = append(response.RawImages, url)
+	}
+
+	// 2. 图文详情和技术参数
+	detail, err := ps.productRepo.GetProductDetail(id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if detail != nil {
+		response.Description = detail.Description
+		response.TechData = model.ProductTechData{
+			Coverage:   detail.Coverage,
+			DryingTime: detail.DryingTime,
+			VOC:        detail.VOC,
+		}
+	}
+
+	// 3. 分类名称
+	categories, err := ps.productRepo.GetCategoriesByShop(shopID)
+	if err != nil {
+		return nil, err
+	}
+	categoryMap := make(map[int64]string)
+	for _, category := range categories {
+		categoryMap[category.ID] = category.Name
+	}
+	response.CategoryName = categoryMap[product.CategoryId]
+
+	// 4. 相关商品：套装的组件或包含该商品的套装优先，再补充同分类商品
+	var relatedIDs []int64
+	if product.IsBundle == model.BundleYes {
+		response.BundleItems, err = ps.GetBundleItems(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range response.BundleItems {
+			relatedIDs = append(relatedIDs, item.ComponentID)
+		}
+	} else {
+		relatedIDs, err = ps.productRepo.GetBundleIDsByComponentID(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var related []model.Product
+	if len(relatedIDs) > 0 {
+		products, err := ps.productRepo.GetByIDs(relatedIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range products {
+			if p.IsOnShelf == 1 && p.ShopID == shopID && len(related) < relatedProductLimit {
+				related = append(related, p)
+			}
+		}
+	}
+	if len(related) < relatedProductLimit {
+		excludeIDs := []int64{id}
+		for _, p := range related {
+			excludeIDs = append(excludeIDs, p.ID)
+		}
+		sameCategory, err := ps.productRepo.GetRelatedProducts(shopID, product.CategoryId, excludeIDs, relatedProductLimit-len(related))
+		if err != nil {
+			return nil, err
+		}
+		related = append(related, sameCategory...)
+	}
+	for _, p := range related {
+		response.Related = append(response.Related, newProductSimple(p, categoryMap[p.CategoryId]))
+	}
+	return response, nil
+}
+
+// GetProductGalleryAndDetail 后台获取商品图集和详情
+func (ps *productService) GetProductGalleryAndDetail(id int64) ([]model.ProductImage, *model.ProductDetail, error) {
+	images, err := ps.productRepo.GetProductImages(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	detail, err := ps.productRepo.GetProductDetail(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return images, &model.ProductDetail{ProductID: id}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return images, detail, nil
+}
+
+// AddProductImage 向商品图集追加一张图片
+func (ps *productService) AddProductImage(product *model.Product, url string) (*model.ProductImage, error) {
+	image := &model.ProductImage{
+		ProductID: product.ID,
+		ShopID:    product.ShopID,
+		URL:       url,
+	}
+	if err := ps.productRepo.AddProductImage(image); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// SetProductGallery 按顺序重设商品图集
+func (ps *productService) SetProductGallery(product *model.Product, urls []string) error {
+	images := make([]model.ProductImage, 0, len(urls))
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			return errors.New("图片地址不能为空")
+		}
+		images = append(images, model.ProductImage{ShopID: product.ShopID, URL: url})
+	}
+	return ps.productRepo.ReplaceProductImages(product.ID, images)
+}
+
+// SetProductDetail 设置商品图文详情和技术参数
+func (ps *productService) SetProductDetail(product *model.Product, req *model.SetProductDetailRequest) error {
+	return ps.productRepo.SaveProductDetail(&model.ProductDetail{
+		ProductID:   product.ID,
+		ShopID:      product.ShopID,
+		Description: req.Description,
+		Coverage:    req.Coverage,
+		DryingTime:  req.DryingTime,
+		VOC:         req.VOC,
+	})
+}
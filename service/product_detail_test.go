@@ -0,0 +1,20 @@
+package service
+
+import "testing"
+
+func TestStockText(t *testing.T) {
+	// 未加载配置时低库存阈值为默认值5
+	cases := map[int]string{
+		0:  "暂时缺货",
+		-2: "暂时缺货",
+		1:  "仅剩1桶",
+		5:  "仅剩5桶",
+		6:  "现货充足",
+		9:  "现货充足",
+	}
+	for stock, want := range cases {
+		if got := stockText(stock, "桶"); got != want {
+			t.Errorf("库存 %d got %s, want %s", stock, got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"log"
+)
+
+// listImageURLs 商品列表展示用的图片地址：列表图、缩略图
+func listImageURLs(image string) (string, string) {
+	return pkg.ImageVariantURL(image, pkg.ImageVariantList, pkg.ImageFormatJPEG),
+		pkg.ImageVariantURL(image, pkg.ImageVariantThumb, pkg.ImageFormatJPEG)
+}
+
+// newProductSimple 小程序商品列表项
+func newProductSimple(p model.Product, categoryName string) model.ProductSimple {
+	sp := model.ProductSimple{
+		ID:           p.ID,
+		Name:         p.Name,
+		SellerPrice:  p.SellerPrice,
+		CategoryID:   p.CategoryId,
+		CategoryName: categoryName,
+		Unit:         p.Unit,
+		Remark:       p.Remark,
+	}
+	sp.Image, sp.Thumb = listImageURLs(p.Image)
+	return sp
+}
+
+// GenerateMissingImageVariants 为历史商品图片补生成各规格缩放图，返回处理数量
+// 单张图片失败只记录日志，不影响其他图片
+func (ps *productService) GenerateMissingImageVariants() (int, error) {
+	urls, err := ps.productRepo.GetAllImageURLs()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, url := range urls {
+		generated, err := pkg.GenerateImageVariants(url)
+		if err != nil {
+			log.Printf("生成图片缩放图失败 %s: %v", url, err)
+			continue
+		}
+		if generated {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"bytes"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 商品导入导出的表头，导出文件可直接修改后再导入
+const (
+	colProductID     = "商品ID"
+	colSku           = "商品编码"
+	colBarcode       = "条码"
+	colName          = "商品名称"
+	colCategory      = "分类"
+	colSellerPrice   = "售价"
+	colCost          = "成本价"
+	colShippingCost  = "运费成本"
+	colProductCost   = "货物成本"
+	colStock         = "库存"
+	colSpecification = "规格"
+	colUnit          = "单位"
+	colIsOnShelf     = "是否上架"
+	colImage         = "图片"
+	colRemark        = "备注"
+)
+
+var productSheetHeaders = []string{
+	colProductID, colSku, colName, colCategory, colSellerPrice, colCost, colShippingCost,
+	colProductCost, colStock, colSpecification, colUnit, colIsOnShelf, colImage, colRemark, colBarcode,
+}
+
+// productSheetRow 按表头读取一行数据
+type productSheetRow struct {
+	cells  []string
+	header map[string]int
+}
+
+// has 表头中是否包含该列
+func (r productSheetRow) has(col string) bool {
+	_, ok := r.header[col]
+	return ok
+}
+
+// get 获取某列的值（列不存在或越界时返回空）
+func (r productSheetRow) get(col string) string {
+	idx, ok := r.header[col]
+	if !ok || idx >= len(r.cells) {
+		return ""
+	}
+	return strings.TrimSpace(r.cells[idx])
+}
+
+// blank 是否为空行
+func (r productSheetRow) blank() bool {
+	for _, cell := range r.cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseOnShelf 解析上架状态
+func parseOnShelf(s string) (int8, error) {
+	switch s {
+	case "1", "是", "上架":
+		return 1, nil
+	case "0", "否", "下架":
+		return 0, nil
+	}
+	return 0, fmt.Errorf("是否上架只能填 1/0、是/否 或 上架/下架，当前为: %s", s)
+}
+
+// ImportProducts 导入商品：按商品ID、商品编码或名称在店铺内匹配，已存在则更新，否则新增
+// dryRun 为 true 时只做校验并返回每行结果；存在校验失败的行时不会写入任何数据
+func (ps *productService) ImportProducts(shopID int64, rows [][]string, dryRun bool, operatorID int64, operator string) (*model.ProductImportResult, error) {
+	if len(rows) < 2 {
+		return nil, errors.New("文件中没有数据行")
+	}
+
+	// 1. 解析表头
+	header := make(map[string]int)
+	for i, h := range rows[0] {
+		header[strings.TrimSpace(h)] = i
+	}
+	for _, col := range []string{colName, colCategory, colSellerPrice, colUnit} {
+		if _, ok := header[col]; !ok {
+			return nil, fmt.Errorf("缺少必需的列: %s", col)
+		}
+	}
+
+	// 2. 已有分类
+	categories, err := ps.productRepo.GetCategoriesByShop(shopID)
+	if err != nil {
+		return nil, err
+	}
+	existingCategories := newImportCategories(categories)
+
+	// 3. 逐行校验
+	result := &model.ProductImportResult{DryRun: dryRun}
+	seenNames := make(map[string]int)
+	seenSkus := make(map[string]int)
+	seenBarcodes := make(map[string]int)
+	for i, cells := range rows[1:] {
+		sheetRow := productSheetRow{cells: cells, header: header}
+		if sheetRow.blank() {
+			continue
+		}
+		row, err := ps.validateImportRow(shopID, i+2, sheetRow, existingCategories, seenNames, seenSkus, seenBarcodes)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Total++
+		switch row.Action {
+		case model.ImportActionCreate:
+			result.Created++
+		case model.ImportActionUpdate:
+			result.Updated++
+		default:
+			result.Failed++
+		}
+		result.Rows = append(result.Rows, *row)
+	}
+	if result.Total == 0 {
+		return nil, errors.New("文件中没有数据行")
+	}
+
+	// 4. 仅校验或存在错误时不写入
+	if dryRun || result.Failed > 0 {
+		return result, nil
+	}
+	// 新增商品的库存通过期初入库单写入，保证库存流水和库存日志完整
+	inbound := &model.StockOperation{
+		OperationNo:  pkg.GenerateOrderNo(pkg.StockPrefix, operatorID),
+		Operator:     operator,
+		OperatorID:   operatorID,
+		OperatorType: model.OperatorTypeAdmin,
+		ShopID:       shopID,
+		Remark:       "导入商品期初库存",
+	}
+	if err := ps.productRepo.ImportProducts(shopID, result.Rows, inbound); err != nil {
+		return nil, fmt.Errorf("导入商品失败: %v", err)
+	}
+	return result, nil
+}
+
+// validateImportRow 校验单行数据，返回该行的导入动作
+func (ps *productService) validateImportRow(shopID int64, rowNum int, r productSheetRow, categories importCategories, seenNames map[string]int, seenSkus map[string]int, seenBarcodes map[string]int) (*model.ProductImportRow, error) {
+	row := &model.ProductImportRow{
+		Row:          rowNum,
+		Name:         r.get(colName),
+		Sku:          r.get(colSku),
+		CategoryName: r.get(colCategory),
+	}
+	addErr := func(format string, args ...interface{}) {
+		row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+	}
+
+	// 1. 必填项和文件内重复
+	if row.Name == "" {
+		addErr("商品名称不能为空")
+	} else if prev, ok := seenNames[row.Name]; ok {
+		addErr("%s（与第%d行重复）", pkg.ErrProductNameExists, prev)
+	} else {
+		seenNames[row.Name] = rowNum
+	}
+	if row.Sku != "" {
+		if prev, ok := seenSkus[row.Sku]; ok {
+			addErr("商品编码 %s 与第%d行重复", row.Sku, prev)
+		} else {
+			seenSkus[row.Sku] = rowNum
+		}
+	}
+	barcode := r.get(colBarcode)
+	if barcode != "" {
+		if err := pkg.ValidateBarcode(barcode); err != nil {
+			addErr("%s", err.Error())
+		} else if prev, ok := seenBarcodes[barcode]; ok {
+			addErr("条码 %s 与第%d行重复", barcode, prev)
+		} else {
+			seenBarcodes[barcode] = rowNum
+		}
+	}
+	if normalizeCategoryPath(row.CategoryName) == "" {
+		addErr("分类不能为空")
+	} else if path, isNew, err := categories.resolve(row.CategoryName); err != nil {
+		addErr("%s", err.Error())
+	} else {
+		row.CategoryName, row.NewCategory = path, isNew
+	}
+	if r.get(colUnit) == "" {
+		addErr("单位不能为空")
+	}
+
+	// 2. 匹配已有商品：商品ID > 商品编码 > 商品名称
+	var existing *model.Product
+	if idStr := r.get(colProductID); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			addErr("商品ID格式错误: %s", idStr)
+		} else {
+			product, err := ps.productRepo.GetByIDAndShop(id, shopID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			if err != nil {
+				addErr("商品ID %d 在该店铺中不存在", id)
+			} else {
+				existing = product
+			}
+		}
+	} else if row.Sku != "" {
+		product, err := ps.productRepo.GetBySkuAndShop(row.Sku, shopID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			existing = product
+		}
+	}
+	if row.Name != "" {
+		byName, err := ps.productRepo.GetByNameAndShop(row.Name, shopID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			if existing == nil && row.Sku == "" && r.get(colProductID) == "" {
+				existing = byName
+			} else if existing == nil || existing.ID != byName.ID {
+				addErr(pkg.ErrProductNameExists)
+			}
+		}
+	}
+	if existing != nil && row.Sku != "" && existing.Sku != row.Sku {
+		other, err := ps.productRepo.GetBySkuAndShop(row.Sku, shopID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil && other.ID != existing.ID {
+			addErr("商品编码 %s 已被商品 %s 使用", row.Sku, other.Name)
+		}
+	}
+
+	if barcode != "" {
+		other, err := ps.productRepo.GetByBarcodeAndShop(barcode, shopID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil && (existing == nil || other.ID != existing.ID) {
+			addErr("条码 %s 已被商品 %s 使用", barcode, other.Name)
+		}
+	}
+
+	// 3. 组装商品数据：更新时以现有数据为基础，只覆盖表格中出现的列
+	product := &model.Product{IsOnShelf: 1}
+	if existing != nil {
+		copied := *existing
+		product = &copied
+		row.ProductID = existing.ID
+	}
+	product.Name = row.Name
+	product.PinyinInitial = pkg.PinyinInitials(row.Name)
+	product.Unit = r.get(colUnit)
+	if r.has(colSku) {
+		product.Sku = row.Sku
+	}
+	if r.has(colBarcode) {
+		product.Barcode = barcode
+	}
+	if r.has(colSpecification) {
+		product.Specification = r.get(colSpecification)
+	}
+	if r.has(colImage) {
+		product.Image = r.get(colImage)
+	}
+	if r.has(colRemark) {
+		product.Remark = r.get(colRemark)
+	}
+	amountCols := []struct {
+		col      string
+		target   *model.Amount
+		required bool
+	}{
+		{colSellerPrice, &product.SellerPrice, true},
+		{colCost, &product.Cost, false},
+		{colShippingCost, &product.ShippingCost, false},
+		{colProductCost, &product.ProductCost, false},
+	}
+	for _, ac := range amountCols {
+		value := r.get(ac.col)
+		if value == "" {
+			if ac.required {
+				addErr("%s不能为空", ac.col)
+			}
+			continue
+		}
+		amount, err := model.ParseAmount(value)
+		if err != nil || amount < 0 {
+			addErr("%s格式错误: %s", ac.col, value)
+			continue
+		}
+		*ac.target = amount
+	}
+	// 未填写成本价时，成本价=运费成本+货物成本
+	if r.get(colCost) == "" && (r.get(colShippingCost) != "" || r.get(colProductCost) != "") {
+		product.Cost = product.ShippingCost + product.ProductCost
+	}
+	if value := r.get(colIsOnShelf); value != "" {
+		onShelf, err := parseOnShelf(value)
+		if err != nil {
+			addErr("%s", err.Error())
+		}
+		product.IsOnShelf = onShelf
+	}
+	// 库存只在新增时生效，导入时生成期初入库单；已有商品的库存需通过入库/出库调整
+	if value := r.get(colStock); value != "" && existing == nil {
+		stock, err := strconv.Atoi(value)
+		if err != nil || stock < 0 {
+			addErr("库存格式错误: %s", value)
+		}
+		product.Stock = stock
+	}
+	row.Product = product
+
+	switch {
+	case len(row.Errors) > 0:
+		row.Action = model.ImportActionError
+	case existing != nil:
+		row.Action = model.ImportActionUpdate
+	default:
+		row.Action = model.ImportActionCreate
+	}
+	return row, nil
+}
+
+// ExportProducts 按后台商品列表的筛选条件导出商品Excel
+func (ps *productService) ExportProducts(shopID int64, name string) (*bytes.Buffer, error) {
+	products, err := ps.productRepo.GetAllForExport(shopID, name)
+	if err != nil {
+		return nil, err
+	}
+	fillBundleStock(ps.productRepo, products)
+
+	var categories []model.Category
+	if shopID > 0 {
+		categories, err = ps.productRepo.GetCategoriesByShop(shopID)
+	} else {
+		categories, err = ps.productRepo.GetAllCategories()
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 分类导出完整路径(如 内墙/乳胶漆)，不同上级下的同名分类导入时不会混淆
+	categoryMap := categoryPaths(categories)
+
+	rows := make([][]interface{}, 0, len(products))
+	for _, p := range products {
+		rows = append(rows, []interface{}{
+			p.ID, p.Sku, p.Name, categoryMap[p.CategoryId], p.SellerPrice.Yuan(), p.Cost.Yuan(), p.ShippingCost.Yuan(),
+			p.ProductCost.Yuan(), p.Stock, p.Specification, p.Unit, p.IsOnShelf, p.Image, p.Remark, p.Barcode,
+		})
+	}
+	return pkg.WriteXlsx("商品", productSheetHeaders, rows)
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// encodeSearchCursor 将上一页最后一条记录的排序值和ID编码为游标
+func encodeSearchCursor(value int64, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", value, id)))
+}
+
+// decodeSearchCursor 解析游标
+func decodeSearchCursor(cursor string) (int64, int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, errors.New("游标格式错误")
+	}
+	parts := strings.Split(string(data), ":")
+	if len(parts) != 2 {
+		return 0, 0, errors.New("游标格式错误")
+	}
+	value, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("游标格式错误")
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, 0, errors.New("游标格式错误")
+	}
+	return value, id, nil
+}
+
+// searchCursorValue 获取记录在当前排序方式下的排序值
+func searchCursorValue(item model.ProductSearchItem, sort string) int64 {
+	switch sort {
+	case model.ProductSortPriceAsc, model.ProductSortPriceDesc:
+		return int64(item.SellerPrice)
+	case model.ProductSortSales:
+		return item.SalesVolume
+	}
+	return 0
+}
+
+// SearchProducts 小程序商品搜索，按游标分页
+func (ps *productService) SearchProducts(query *model.ProductSearchQuery, cursor string) (*model.ProductSearchResponse, error) {
+	switch query.Sort {
+	case model.ProductSortDefault, model.ProductSortPriceAsc, model.ProductSortPriceDesc, model.ProductSortSales:
+	default:
+		return nil, fmt.Errorf("不支持的排序方式: %s", query.Sort)
+	}
+	if query.CategoryID > 0 {
+		categoryIDs, err := ps.expandCategoryIDs(query.ShopID, query.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		query.CategoryIDs = categoryIDs
+	}
+	if cursor != "" {
+		value, id, err := decodeSearchCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.CursorValue, query.CursorID = value, id
+	}
+
+	// 1. 多查一条用于判断是否还有下一页
+	pageSize := query.Limit
+	query.Limit = pageSize + 1
+	items, err := ps.productRepo.SearchProducts(query)
+	if err != nil {
+		return nil, err
+	}
+	hasMore := len(items) > pageSize
+	if hasMore {
+		items = items[:pageSize]
+	}
+
+	response := &model.ProductSearchResponse{List: []model.ProductSearchItem{}, HasMore: hasMore}
+	if len(items) == 0 {
+		return response, nil
+	}
+	// 游标取本页最后一条，不受后续套装库存过滤影响
+	if hasMore {
+		last := items[len(items)-1]
+		response.NextCursor = encodeSearchCursor(searchCursorValue(last, query.Sort), last.ID)
+	}
+
+	// 2. 分类名称
+	categories, err := ps.productRepo.GetCategoriesByShop(query.ShopID)
+	if err != nil {
+		return nil, err
+	}
+	categoryMap := make(map[int64]string)
+	for _, category := range categories {
+		categoryMap[category.ID] = category.Name
+	}
+
+	// 3. 套装库存由组件推算(本页套装一次批量查询组件)
+	var bundles []model.Product
+	for _, item := range items {
+		if item.IsBundle == model.BundleYes {
+			bundles = append(bundles, model.Product{ID: item.ID, IsBundle: model.BundleYes})
+		}
+	}
+	fillBundleStock(ps.productRepo, bundles)
+	bundleStock := make(map[int64]int, len(bundles))
+	for _, bundle := range bundles {
+		bundleStock[bundle.ID] = bundle.Stock
+	}
+	for _, item := range items {
+		item.CategoryName = categoryMap[item.CategoryID]
+		item.Image, item.Thumb = listImageURLs(item.Image)
+		if item.IsBundle == model.BundleYes {
+			item.Stock = bundleStock[item.ID]
+			if query.InStock && item.Stock <= 0 {
+				continue
+			}
+		}
+		response.List = append(response.List, item)
+	}
+	return response, nil
+}
+
+// FillMissingPinyinInitials 为未生成拼音首字母的商品补齐，返回处理数量
+func (ps *productService) FillMissingPinyinInitials() (int, error) {
+	products, err := ps.productRepo.GetWithoutPinyinInitial()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, p := range products {
+		initial := pkg.PinyinInitials(p.Name)
+		if initial == "" {
+			continue
+		}
+		if err := ps.productRepo.UpdateFields(p.ID, map[string]interface{}{"pinyin_initial": initial}, nil, nil); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 小票纸宽(半角字符数)
+const (
+	ReceiptWidth58 = 32 // 58mm 热敏纸
+	ReceiptWidth80 = 48 // 80mm 热敏纸
+)
+
+// ReceiptWidth 按纸宽(毫米)取每行字符数，只支持58mm和80mm
+func ReceiptWidth(paperWidth int) int {
+	if paperWidth == 80 || paperWidth == ReceiptWidth80 {
+		return ReceiptWidth80
+	}
+	return ReceiptWidth58
+}
+
+// receiptWriter 小票排版输出，纯文本预览和 ESC/POS 打印共用同一套排版
+type receiptWriter interface {
+	Title(s string)             // 大字居中标题
+	Center(s string)            // 居中
+	Line(s string)              // 左对齐，超宽自动折行
+	Justify(left, right string) // 左右对齐
+	Separator()                 // 分隔线
+}
+
+// textReceiptWriter 纯文本小票，按等宽字体排版
+type textReceiptWriter struct {
+	b     strings.Builder
+	width int
+}
+
+func (w *textReceiptWriter) Title(s string)  { w.Center(s) }
+func (w *textReceiptWriter) Center(s string) { w.write(receiptCenter(s, w.width)) }
+func (w *textReceiptWriter) Separator()      { w.write(strings.Repeat("-", w.width)) }
+
+func (w *textReceiptWriter) Line(s string) {
+	for _, l := range receiptWrap(s, w.width) {
+		w.write(l)
+	}
+}
+
+func (w *textReceiptWriter) Justify(left, right string) {
+	w.write(receiptJustify(left, right, w.width))
+}
+
+func (w *textReceiptWriter) write(s string) {
+	w.b.WriteString(s)
+	w.b.WriteString("\n")
+}
+
+// escposReceiptWriter ESC/POS 小票，标题倍宽倍高加粗，居中由打印机完成
+type escposReceiptWriter struct {
+	p     *pkg.EscPos
+	width int
+}
+
+func newEscposReceiptWriter(width int) *escposReceiptWriter {
+	return &escposReceiptWriter{p: pkg.NewEscPos(), width: width}
+}
+
+func (w *escposReceiptWriter) Title(s string) {
+	w.p.Align(pkg.EscPosAlignCenter).Bold(true).Size(2, 2).Line(s).
+		Size(1, 1).Bold(false).Align(pkg.EscPosAlignLeft)
+}
+
+func (w *escposReceiptWriter) Center(s string) {
+	w.p.Align(pkg.EscPosAlignCenter).Line(s).Align(pkg.EscPosAlignLeft)
+}
+
+func (w *escposReceiptWriter) Line(s string) {
+	for _, l := range receiptWrap(s, w.width) {
+		w.p.Line(l)
+	}
+}
+
+func (w *escposReceiptWriter) Justify(left, right string) {
+	for _, l := range strings.Split(receiptJustify(left, right, w.width), "\n") {
+		w.p.Line(l)
+	}
+}
+
+func (w *escposReceiptWriter) Separator() {
+	w.p.Line(strings.Repeat("-", w.width))
+}
+
+// finish 走纸并切纸
+func (w *escposReceiptWriter) finish() []byte {
+	w.p.Feed(3).Cut()
+	return w.p.Bytes()
+}
+
+// writeReceiptItem 输出一条明细：商品名称和规格一行(超宽折行)，下一行为 数量x单价 和小计
+func writeReceiptItem(w receiptWriter, name, specification, unit string, quantity int, unitPrice, totalPrice model.Amount) {
+	if specification != "" {
+		name += " " + specification
+	}
+	w.Line(name)
+	w.Justify(fmt.Sprintf("  %d%s x %.2f", quantity, unit, unitPrice.Yuan()), fmt.Sprintf("%.2f", totalPrice.Yuan()))
+}
+
+// receiptTextWidth 文本在热敏打印机上的显示宽度，非ASCII字符按两个字符宽度计算
+func receiptTextWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			w++
+		} else {
+			w += 2
+		}
+	}
+	return w
+}
+
+// receiptCenter 居中
+func receiptCenter(s string, width int) string {
+	w := receiptTextWidth(s)
+	if w >= width {
+		return s
+	}
+	return strings.Repeat(" ", (width-w)/2) + s
+}
+
+// receiptJustify 左右对齐，放不下时右侧内容换到下一行
+func receiptJustify(left, right string, width int) string {
+	gap := width - receiptTextWidth(left) - receiptTextWidth(right)
+	if gap < 1 {
+		pad := width - receiptTextWidth(right)
+		if pad < 0 {
+			pad = 0
+		}
+		return left + "\n" + strings.Repeat(" ", pad) + right
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+// receiptWrap 按显示宽度折行
+func receiptWrap(s string, width int) []string {
+	var lines []string
+	var current strings.Builder
+	w := 0
+	for _, r := range s {
+		rw := 1
+		if r >= utf8.RuneSelf {
+			rw = 2
+		}
+		if w+rw > width {
+			lines = append(lines, current.String())
+			current.Reset()
+			w = 0
+		}
+		current.WriteRune(r)
+		w += rw
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+	return lines
+}
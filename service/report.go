@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bytes"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+type ReportService interface {
+	GetSalesReport(query *model.SalesReportQuery) (*model.SalesReport, error)
+	ExportSalesReport(query *model.SalesReportQuery) (*bytes.Buffer, error)
+	GetInventoryValuation(shopID int64, asOf time.Time) (*model.InventoryValuation, error)
+	ExportInventoryValuation(shopID int64, asOf time.Time) (*bytes.Buffer, error)
+	GetStockMovementReport(shopID int64, start, end time.Time, productID int64) (*model.StockMovementReport, error)
+	ExportStockMovementReport(shopID int64, start, end time.Time, productID int64) (*bytes.Buffer, error)
+}
+
+type reportService struct {
+	reportRepo repository.ReportRepository
+}
+
+func NewReportService(reportRepo repository.ReportRepository) ReportService {
+	return &reportService{reportRepo: reportRepo}
+}
+
+// salesReportGroupNames 分组方式对应的分组列名称
+var salesReportGroupNames = map[string]string{
+	model.ReportGroupDay:      "日期",
+	model.ReportGroupWeek:     "周",
+	model.ReportGroupMonth:    "月份",
+	model.ReportGroupProduct:  "商品",
+	model.ReportGroupCategory: "分类",
+	model.ReportGroupCustomer: "客户",
+	model.ReportGroupOperator: "操作人",
+	model.ReportGroupShop:     "店铺",
+}
+
+// GetSalesReport 销售利润报表：按时间分组时按时间顺序排列，其余按销售额从高到低
+func (s *reportService) GetSalesReport(query *model.SalesReportQuery) (*model.SalesReport, error) {
+	if _, ok := salesReportGroupNames[query.GroupBy]; !ok {
+		return nil, errors.New("分组方式只能为 day、week、month、product、category、customer、operator 或 shop")
+	}
+	if !query.EndTime.After(query.StartTime) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+
+	rows, err := s.reportRepo.GetSalesReport(query)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.reportRepo.GetSalesReportTotal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		fillSalesReportRow(&rows[i])
+		if rows[i].Name == "" {
+			rows[i].Name = salesReportEmptyName(query.GroupBy)
+		}
+	}
+	fillSalesReportRow(total)
+	total.Name = "合计"
+
+	switch query.GroupBy {
+	case model.ReportGroupDay, model.ReportGroupWeek, model.ReportGroupMonth:
+	default:
+		sortSalesReportRows(rows)
+	}
+
+	return &model.SalesReport{
+		GroupBy:   query.GroupBy,
+		StartDate: query.StartTime.Format("2006-01-02"),
+		EndDate:   query.EndTime.AddDate(0, 0, -1).Format("2006-01-02"),
+		Rows:      rows,
+		Total:     *total,
+	}, nil
+}
+
+// ExportSalesReport 导出销售利润报表Excel，最后一行为合计
+func (s *reportService) ExportSalesReport(query *model.SalesReportQuery) (*bytes.Buffer, error) {
+	report, err := s.GetSalesReport(query)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := []string{salesReportGroupNames[query.GroupBy], "编号", "销售额", "成本", "毛利", "毛利率(%)", "销售数量", "出库单数"}
+	rows := make([][]interface{}, 0, len(report.Rows)+1)
+	for _, row := range append(report.Rows, report.Total) {
+		rows = append(rows, []interface{}{
+			row.Name, row.Key, row.Revenue.Yuan(), row.Cost.Yuan(), row.GrossProfit.Yuan(), row.Margin, row.Quantity, row.OperationCount,
+		})
+	}
+	return pkg.WriteXlsx("销售利润", headers, rows)
+}
+
+// fillSalesReportRow 计算毛利和毛利率(保留两位小数)
+func fillSalesReportRow(row *model.SalesReportRow) {
+	row.GrossProfit = row.Revenue - row.Cost
+	if row.Revenue != 0 {
+		row.Margin = math.Round(float64(row.GrossProfit)/float64(row.Revenue)*10000) / 100
+	}
+}
+
+// sortSalesReportRows 按销售额从高到低排序
+func sortSalesReportRows(rows []model.SalesReportRow) {
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Revenue > rows[j].Revenue })
+}
+
+// salesReportEmptyName 分组名称为空时的显示名称
+func salesReportEmptyName(groupBy string) string {
+	switch groupBy {
+	case model.ReportGroupCategory:
+		return "未分类"
+	case model.ReportGroupCustomer:
+		return "散客"
+	case model.ReportGroupOperator:
+		return "小程序用户"
+	}
+	return "-"
+}
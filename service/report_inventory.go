@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"errors"
+	"time"
+)
+
+// GetInventoryValuation 库存估值：按库存流水推算估值日期当天结束时各商品的库存数量和进价，
+// 没有任何库存流水的商品无法推算，单独列出当前库存且不计入合计，没有入库进价的商品取当前货物成本
+func (s *reportService) GetInventoryValuation(shopID int64, asOf time.Time) (*model.InventoryValuation, error) {
+	products, err := s.reportRepo.GetInventoryProducts(shopID)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := s.stockSnapshots(products, shopID, asOf.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	valuation := &model.InventoryValuation{AsOfDate: asOf.Format("2006-01-02"), Rows: []model.InventoryValuationRow{}, NoLedgerRows: []model.InventoryValuationRow{}}
+	for _, product := range products {
+		snapshot := snapshots[product.ID]
+		row := model.InventoryValuationRow{
+			ShopID:        product.ShopID,
+			ProductID:     product.ID,
+			ProductName:   product.Name,
+			Specification: product.Specification,
+			Unit:          product.Unit,
+			Quantity:      snapshot.Stock,
+			UnitCost:      snapshot.UnitCost,
+			Value:         snapshot.UnitCost * model.Amount(snapshot.Stock),
+		}
+		if !snapshot.HasLedger {
+			row.NoLedger = true
+			row.Quantity = int64(product.Stock)
+			row.Value = row.UnitCost * model.Amount(row.Quantity)
+			if row.Quantity != 0 {
+				valuation.NoLedgerRows = append(valuation.NoLedgerRows, row)
+			}
+			continue
+		}
+		if row.Quantity == 0 {
+			continue
+		}
+		valuation.Rows = append(valuation.Rows, row)
+		valuation.TotalQuantity += row.Quantity
+		valuation.TotalValue += row.Value
+	}
+	return valuation, nil
+}
+
+// ExportInventoryValuation 导出库存估值Excel，合计行之后列出没有库存流水的商品
+func (s *reportService) ExportInventoryValuation(shopID int64, asOf time.Time) (*bytes.Buffer, error) {
+	valuation, err := s.GetInventoryValuation(shopID, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := []string{"店铺ID", "商品ID", "商品名称", "规格", "单位", "库存数量", "单位成本", "库存金额", "备注"}
+	rows := make([][]interface{}, 0, len(valuation.Rows)+len(valuation.NoLedgerRows)+1)
+	for _, row := range valuation.Rows {
+		rows = append(rows, []interface{}{
+			row.ShopID, row.ProductID, row.ProductName, row.Specification, row.Unit, row.Quantity, row.UnitCost.Yuan(), row.Value.Yuan(), "",
+		})
+	}
+	rows = append(rows, []interface{}{"合计", "", "", "", "", valuation.TotalQuantity, "", valuation.TotalValue.Yuan(), ""})
+	for _, row := range valuation.NoLedgerRows {
+		rows = append(rows, []interface{}{
+			row.ShopID, row.ProductID, row.ProductName, row.Specification, row.Unit, row.Quantity, row.UnitCost.Yuan(), row.Value.Yuan(), "无库存流水，当前库存，未计入合计",
+		})
+	}
+	return pkg.WriteXlsx("库存估值", headers, rows)
+}
+
+// GetStockMovementReport 库存收发存报表，期间为 [start, end)，productID 大于0时只统计该商品
+// 期初、期末库存由库存流水推算，入库、出库按明细数量汇总，退货和冲销按实际库存变动汇总
+func (s *reportService) GetStockMovementReport(shopID int64, start, end time.Time, productID int64) (*model.StockMovementReport, error) {
+	if !end.After(start) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+
+	products, err := s.reportRepo.GetInventoryProducts(shopID)
+	if err != nil {
+		return nil, err
+	}
+	if productID > 0 {
+		filtered := products[:0]
+		for _, product := range products {
+			if product.ID == productID {
+				filtered = append(filtered, product)
+			}
+		}
+		products = filtered
+	}
+
+	opening, err := s.stockSnapshots(products, shopID, start)
+	if err != nil {
+		return nil, err
+	}
+	closing, err := s.stockSnapshots(products, shopID, end)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := s.reportRepo.GetStockMovementSums(shopID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	movements := make(map[int64]*model.StockMovementRow, len(products))
+	for _, sum := range sums {
+		row, ok := movements[sum.ProductID]
+		if !ok {
+			row = &model.StockMovementRow{}
+			movements[sum.ProductID] = row
+		}
+		switch sum.Types {
+		case model.StockTypeInbound:
+			row.Inbound += sum.Quantity
+		case model.StockTypeOutbound:
+			row.Outbound += sum.Quantity
+		case model.StockTypeReturn:
+			row.Returns += sum.Delta
+		case model.StockTypeReversal, model.StockTypeAdjustment:
+			row.Adjustments += sum.Delta
+		}
+	}
+
+	report := &model.StockMovementReport{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Rows:      []model.StockMovementRow{},
+		Total:     model.StockMovementRow{ProductName: "合计"},
+	}
+	for _, product := range products {
+		if !closing[product.ID].HasLedger {
+			report.NoLedgerCount++
+			continue
+		}
+		row := model.StockMovementRow{}
+		if movement, ok := movements[product.ID]; ok {
+			row = *movement
+		}
+		row.ShopID = product.ShopID
+		row.ProductID = product.ID
+		row.ProductName = product.Name
+		row.Specification = product.Specification
+		row.Unit = product.Unit
+		row.Opening = opening[product.ID].Stock
+		row.Closing = closing[product.ID].Stock
+		row.Other = row.Closing - (row.Opening + row.Inbound - row.Outbound + row.Returns + row.Adjustments)
+		row.OpeningValue = opening[product.ID].UnitCost * model.Amount(row.Opening)
+		row.ClosingValue = closing[product.ID].UnitCost * model.Amount(row.Closing)
+		if row.Opening == 0 && row.Closing == 0 && row.Inbound == 0 && row.Outbound == 0 && row.Returns == 0 && row.Adjustments == 0 {
+			continue
+		}
+
+		report.Rows = append(report.Rows, row)
+		report.Total.Opening += row.Opening
+		report.Total.Inbound += row.Inbound
+		report.Total.Outbound += row.Outbound
+		report.Total.Returns += row.Returns
+		report.Total.Adjustments += row.Adjustments
+		report.Total.Other += row.Other
+		report.Total.Closing += row.Closing
+		report.Total.OpeningValue += row.OpeningValue
+		report.Total.ClosingValue += row.ClosingValue
+	}
+	return report, nil
+}
+
+// ExportStockMovementReport 导出库存收发存Excel，最后一行为合计
+func (s *reportService) ExportStockMovementReport(shopID int64, start, end time.Time, productID int64) (*bytes.Buffer, error) {
+	report, err := s.GetStockMovementReport(shopID, start, end, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := []string{"店铺ID", "商品ID", "商品名称", "规格", "单位", "期初库存", "期初金额", "入库", "出库", "退货", "冲销调整", "其他变动", "期末库存", "期末金额"}
+	rows := make([][]interface{}, 0, len(report.Rows)+1)
+	for _, row := range append(report.Rows, report.Total) {
+		rows = append(rows, []interface{}{
+			row.ShopID, row.ProductID, row.ProductName, row.Specification, row.Unit,
+			row.Opening, row.OpeningValue.Yuan(), row.Inbound, row.Outbound, row.Returns, row.Adjustments, row.Other,
+			row.Closing, row.ClosingValue.Yuan(),
+		})
+	}
+	return pkg.WriteXlsx("收发存", headers, rows)
+}
+
+// stockSnapshots 各商品在时点 t(不含)的库存和单位成本，没有入库进价的取当前货物成本
+// 流水中没有的商品无法推算历史库存，库存记为0并由 HasLedger 标记，由调用方单独处理
+func (s *reportService) stockSnapshots(products []model.Product, shopID int64, t time.Time) (map[int64]model.StockSnapshot, error) {
+	ledger, err := s.reportRepo.GetStockSnapshots(shopID, t)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make(map[int64]model.StockSnapshot, len(products))
+	for _, product := range products {
+		snapshot := model.StockSnapshot{ProductID: product.ID}
+		if found, ok := ledger[product.ID]; ok {
+			snapshot = *found
+		}
+		if snapshot.UnitCost == 0 {
+			snapshot.UnitCost = product.ProductCost
+		}
+		snapshots[product.ID] = snapshot
+	}
+	return snapshots, nil
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"bytes"
 	"cmf/paint_proj/model"
 	"cmf/paint_proj/pkg"
 	"cmf/paint_proj/repository"
@@ -17,25 +18,48 @@ type StockService interface {
 	// 更新出库单支付状态
 	UpdateOutboundPaymentStatus(req *model.UpdateOutboundPaymentStatusRequest) error
 
+	// 作废库存操作(生成冲销单)
+	VoidStockOperation(operationID int64, req *model.VoidStockOperationRequest, operatorID int64, operator string) (*model.StockOperation, error)
+
 	// 库存操作查询
 	GetStockOperations(page, pageSize int, types *int8) ([]model.StockOperation, int64, error)
 	GetStockOperationsByShop(page, pageSize int, types *int8, shopID int64) ([]model.StockOperation, int64, error)
 	GetStockOperationDetail(operationID int64) (*model.StockOperation, []model.StockOperationItem, error)
 	GetStockOperationItemsByShop(page, pageSize int, shopID int64, productID *int64) ([]model.StockOperationItem, int64, error)
+	SearchStockOperations(query *model.StockOperationQuery) ([]model.StockOperation, int64, *model.StockOperationSummary, error)
+	ExportStockOperations(query *model.StockOperationQuery) (*bytes.Buffer, error)
 
 	// 供货商管理
 	GetSupplierList() ([]*model.Supplier, error)
+
+	// 送货单
+	GetDeliveryNote(operationID int64) (*model.DeliveryNote, error)
+
+	// 库存核对和修复
+	CheckStockLedger(shopID, productID int64) (*model.StockLedgerCheckReport, error)
+	RepairStockLedger(req *model.RepairStockLedgerRequest, operatorID int64, operator string) (*model.RepairStockLedgerResult, error)
+
+	// 商品库存变动记录
+	GetProductStockHistory(query *model.StockLogQuery) ([]model.StockLog, int64, error)
 }
 
 type stockService struct {
 	stockRepo   repository.StockRepository
 	productRepo repository.ProductRepository
+	shopRepo    repository.ShopRepository
+	orderRepo   repository.OrderRepository
+	userRepo    repository.UserRepository
+	addressRepo repository.AddressRepository
 }
 
-func NewStockService(sr repository.StockRepository, pr repository.ProductRepository) StockService {
+func NewStockService(sr repository.StockRepository, pr repository.ProductRepository, shr repository.ShopRepository, or repository.OrderRepository, ur repository.UserRepository, ar repository.AddressRepository) StockService {
 	return &stockService{
 		stockRepo:   sr,
 		productRepo: pr,
+		shopRepo:    shr,
+		orderRepo:   or,
+		userRepo:    ur,
+		addressRepo: ar,
 	}
 }
 
@@ -80,19 +104,13 @@ func (ss *stockService) BatchInboundStock(req *model.BatchInboundRequest) error
 			return fmt.Errorf("获取商品ID %d 信息失败: %v", item.ProductID, err)
 		}
 
-		// 获取当前库存
-		beforeStock := product.Stock
-		afterStock := beforeStock + item.Quantity
-
 		operationItem := &model.StockOperationItem{
 			OperationID:   operation.ID,
 			ShopID:        req.ShopID, // 设置店铺ID
 			ProductID:     item.ProductID,
 			ProductCost:   item.ProductCost, // 前端传入的货物成本（进价）
-			Quantity:      item.Quantity,
-			BeforeStock:   beforeStock,
-			AfterStock:    afterStock,
-			TotalPrice:    item.TotalPrice, // 使用前端传入的单个商品总价
+			Quantity:      item.Quantity,    // 操作前后库存在入库事务中按实际库存记录
+			TotalPrice:    item.TotalPrice,  // 使用前端传入的单个商品总价
 			Remark:        item.Remark,
 			UnitPrice:     0,                     // 入库时不记录卖价
 			ProductName:   product.Name,          // 从商品表获取的商品名称
@@ -118,6 +136,32 @@ func (ss *stockService) BatchInboundStock(req *model.BatchInboundRequest) error
 
 // BatchOutboundStock 批量出库操作（新结构）
 func (ss *stockService) BatchOutboundStock(req *model.BatchOutboundRequest) error {
+	operation, err := buildOutboundOperation(ss.productRepo, req)
+	if err != nil {
+		return err
+	}
+
+	// 执行事务：创建主表记录、子表记录、更新库存
+	err = ss.stockRepo.ProcessOutboundTransaction(operation)
+	if err != nil {
+		return fmt.Errorf("批量出库事务失败: %v", err)
+	}
+	publishLowStockEvents(ss.productRepo, operation, operation.Items)
+	pkg.PublishEvent(model.EventStockOutbound, operation.ShopID, model.StockOutboundEvent{
+		OperationID:   operation.ID,
+		OperationNo:   operation.OperationNo,
+		UserID:        operation.UserID,
+		UserName:      operation.UserName,
+		TotalAmount:   operation.TotalAmount,
+		TotalQuantity: operation.TotalQuantity,
+	})
+
+	return nil
+}
+
+// buildOutboundOperation 构建出库单：按商品计算单价和利润，套装拆分为套装明细和组件明细
+// 出库单默认为后台出库、未支付，调用方可按需修改
+func buildOutboundOperation(productRepo repository.ProductRepository, req *model.BatchOutboundRequest) (*model.StockOperation, error) {
 	// 使用前端提供的总金额，如果没有提供则使用计算值
 	totalAmount := req.TotalAmount
 	if totalAmount == 0 {
@@ -155,6 +199,7 @@ func (ss *stockService) BatchOutboundStock(req *model.BatchOutboundRequest) erro
 		TotalQuantity:       totalQuantity,
 		TotalProfit:         0,                         // 初始化为0，后面会计算
 		PaymentFinishStatus: model.PaymentStatusUnpaid, // 初始化为未支付
+		UnpaidAmount:        totalAmount,               // 未支付时全部计入欠款
 	}
 
 	// 如果前端传了操作时间，则设置到CreatedAt字段
@@ -168,26 +213,36 @@ func (ss *stockService) BatchOutboundStock(req *model.BatchOutboundRequest) erro
 
 	for _, item := range req.Items {
 		// 获取商品信息（包含库存、成本价、售价等）
-		product, err := ss.productRepo.GetByID(item.ProductID)
+		product, err := productRepo.GetByID(item.ProductID)
 		if err != nil {
-			return fmt.Errorf("获取商品ID %d 信息失败: %v", item.ProductID, err)
+			return nil, fmt.Errorf("获取商品ID %d 信息失败: %v", item.ProductID, err)
 		}
 
-		// 从商品信息中获取当前库存
-		beforeStock := product.Stock
-
 		// 确定单价：优先使用前端传入的单价，如果没有则使用商品售价
 		unitPrice := item.UnitPrice
 		if unitPrice == 0 {
 			unitPrice = product.SellerPrice
 		}
 
+		// 套装出库：拆分为套装明细和组件明细，扣减组件库存
+		if product.IsBundle == model.BundleYes {
+			bundleItems, profit, err := buildBundleOutboundItems(productRepo, product, item.Quantity, unitPrice, item.TotalPrice, item.Remark)
+			if err != nil {
+				return nil, err
+			}
+			for i := range bundleItems {
+				bundleItems[i].ShopID = req.ShopID
+			}
+			totalProfit += profit
+			operationItems = append(operationItems, bundleItems...)
+			continue
+		}
+
 		// 计算利润：(卖价 - 总成本) * 数量
 		profit := model.Amount((int64(unitPrice) - int64(product.Cost)) * int64(item.Quantity))
 		totalProfit += profit
 
-		afterStock := beforeStock - item.Quantity
-
+		// 操作前后库存在出库事务中按实际库存记录
 		operationItem := model.StockOperationItem{
 			OperationID:   operation.ID,
 			ShopID:        req.ShopID, // 设置店铺ID
@@ -195,8 +250,6 @@ func (ss *stockService) BatchOutboundStock(req *model.BatchOutboundRequest) erro
 			Quantity:      item.Quantity,
 			UnitPrice:     unitPrice,
 			TotalPrice:    item.TotalPrice,
-			BeforeStock:   beforeStock,
-			AfterStock:    afterStock,
 			ProductCost:   product.ProductCost,   // 记录进价
 			Profit:        profit,                // 记录利润
 			ProductName:   product.Name,          // 从商品表获取的商品名称
@@ -210,14 +263,7 @@ func (ss *stockService) BatchOutboundStock(req *model.BatchOutboundRequest) erro
 	// 设置总利润
 	operation.TotalProfit = totalProfit
 	operation.Items = operationItems
-
-	// 执行事务：创建主表记录、子表记录、更新库存
-	err := ss.stockRepo.ProcessOutboundTransaction(operation)
-	if err != nil {
-		return fmt.Errorf("批量出库事务失败: %v", err)
-	}
-
-	return nil
+	return operation, nil
 }
 
 // processInboundItemWithNewStructure 处理单个入库商品（新结构）
@@ -312,6 +358,9 @@ func (ss *stockService) UpdateOutboundPaymentStatus(req *model.UpdateOutboundPay
 	if operation.Types != model.StockTypeOutbound {
 		return fmt.Errorf("只能更新出库单的支付状态")
 	}
+	if operation.IsVoided == 1 {
+		return fmt.Errorf("出库单已作废，不能更新支付状态")
+	}
 
 	// 验证支付完成状态是否有效（只允许未支付和已支付）
 	if req.PaymentFinishStatus != model.PaymentStatusUnpaid && req.PaymentFinishStatus != model.PaymentStatusPaid {
@@ -334,6 +383,124 @@ func (ss *stockService) UpdateOutboundPaymentStatus(req *model.UpdateOutboundPay
 	return nil
 }
 
+// SearchStockOperations 按条件查询库存操作列表(含明细)和合计，合计不含已作废的操作单和冲销单
+func (ss *stockService) SearchStockOperations(query *model.StockOperationQuery) ([]model.StockOperation, int64, *model.StockOperationSummary, error) {
+	operations, total, err := ss.stockRepo.SearchStockOperations(query)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if err := ss.fillStockOperationItems(operations); err != nil {
+		return nil, 0, nil, err
+	}
+	summary, err := ss.stockRepo.GetStockOperationsSummary(query)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return operations, total, summary, nil
+}
+
+// fillStockOperationItems 批量填充操作单明细
+func (ss *stockService) fillStockOperationItems(operations []model.StockOperation) error {
+	ids := make([]int64, 0, len(operations))
+	for _, operation := range operations {
+		ids = append(ids, operation.ID)
+	}
+	items, err := ss.stockRepo.GetStockOperationItemsByOperationIDs(ids)
+	if err != nil {
+		return fmt.Errorf("获取操作明细失败: %v", err)
+	}
+	itemMap := make(map[int64][]model.StockOperationItem, len(operations))
+	for _, item := range items {
+		itemMap[item.OperationID] = append(itemMap[item.OperationID], item)
+	}
+	for i := range operations {
+		operations[i].Items = itemMap[operations[i].ID]
+	}
+	return nil
+}
+
+// VoidStockOperation 作废录错的入库单或后台出库单：生成反向变动库存的冲销单，原操作单标记为已作废
+// 小程序订单和门店收银的出库单关联订单和收银单，不能在这里作废
+func (ss *stockService) VoidStockOperation(operationID int64, req *model.VoidStockOperationRequest, operatorID int64, operator string) (*model.StockOperation, error) {
+	original, err := ss.stockRepo.GetStockOperationByID(operationID)
+	if err != nil {
+		return nil, fmt.Errorf("库存操作不存在: %v", err)
+	}
+	if original.IsVoided == 1 {
+		return nil, errors.New("该库存操作已作废")
+	}
+	switch {
+	case original.Types == model.StockTypeInbound:
+	case original.Types == model.StockTypeOutbound && original.OutboundType == model.OutboundTypeAdmin:
+	case original.Types == model.StockTypeOutbound:
+		return nil, errors.New("小程序订单和门店收银的出库单不能作废，请通过订单或收银单处理")
+	default:
+		return nil, errors.New("只能作废入库单和后台出库单")
+	}
+
+	items, err := ss.stockRepo.GetStockOperationItems(original.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 冲销单：数量与原操作单相同，金额和利润取反，合计时与原操作单相抵
+	now := time.Now()
+	reversal := &model.StockOperation{
+		OperationNo:         pkg.GenerateOrderNo(pkg.StockPrefix, operatorID),
+		Types:               model.StockTypeReversal,
+		OutboundType:        original.OutboundType,
+		Operator:            operator,
+		OperatorID:          operatorID,
+		OperatorType:        model.OperatorTypeAdmin,
+		ShopID:              original.ShopID,
+		UserName:            original.UserName,
+		UserID:              original.UserID,
+		Remark:              fmt.Sprintf("冲销 %s：%s", original.OperationNo, req.Reason),
+		TotalAmount:         -original.TotalAmount,
+		TotalQuantity:       original.TotalQuantity,
+		TotalProfit:         -original.TotalProfit,
+		PaymentFinishStatus: original.PaymentFinishStatus,
+		Supplier:            original.Supplier,
+		CreatedAt:           &now,
+		ReversalOfID:        original.ID,
+	}
+	for _, item := range items {
+		reversal.Items = append(reversal.Items, model.StockOperationItem{
+			ShopID:        item.ShopID,
+			ProductID:     item.ProductID,
+			Quantity:      item.Quantity,
+			UnitPrice:     item.UnitPrice,
+			TotalPrice:    -item.TotalPrice,
+			ProductCost:   item.ProductCost,
+			Profit:        -item.Profit,
+			Remark:        item.Remark,
+			ProductName:   item.ProductName,
+			Specification: item.Specification,
+			Unit:          item.Unit,
+			IsBundle:      item.IsBundle,
+			BundleID:      item.BundleID,
+		})
+	}
+
+	original.VoidReason = req.Reason
+	original.VoidOperator = operator
+	original.VoidOperatorID = operatorID
+	original.VoidedAt = &now
+	if err := ss.stockRepo.VoidStockOperation(original, reversal); err != nil {
+		return nil, err
+	}
+	// 作废入库单会减少库存
+	if original.Types == model.StockTypeInbound {
+		publishLowStockEvents(ss.productRepo, reversal, reversal.Items)
+	}
+	return reversal, nil
+}
+
+// GetProductStockHistory 商品库存变动记录(库存日志)，按时间倒序
+func (ss *stockService) GetProductStockHistory(query *model.StockLogQuery) ([]model.StockLog, int64, error) {
+	return ss.stockRepo.GetStockLogs(query)
+}
+
 // GetSupplierList 获取供货商列表
 func (ss *stockService) GetSupplierList() ([]*model.Supplier, error) {
 	return ss.stockRepo.GetSupplierList()
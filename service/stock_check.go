@@ -0,0 +1,201 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CheckStockLedger 库存核对：按商品重放库存流水，找出商品库存与流水不一致的商品和流水断点
+// productID 大于0时只核对该商品
+func (ss *stockService) CheckStockLedger(shopID, productID int64) (*model.StockLedgerCheckReport, error) {
+	report, _, err := ss.checkStockLedger(shopID, productID)
+	return report, err
+}
+
+// RepairStockLedger 库存核对修复：对有问题的商品按店铺生成库存调整单，调整单的操作后库存作为之后核对的起点
+// 以商品库存为准时只补记流水；以流水为准时同时把商品库存改为重放结果
+func (ss *stockService) RepairStockLedger(req *model.RepairStockLedgerRequest, operatorID int64, operator string) (*model.RepairStockLedgerResult, error) {
+	if req.Trust == "" {
+		req.Trust = model.StockRepairTrustProduct
+	}
+	if req.Trust != model.StockRepairTrustProduct && req.Trust != model.StockRepairTrustLedger {
+		return nil, errors.New("trust 只能为 product 或 ledger")
+	}
+
+	report, products, err := ss.checkStockLedger(req.ShopID, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.RepairStockLedgerResult{Check: report, Operations: []model.StockOperation{}}
+	if !req.Confirm {
+		return result, nil
+	}
+
+	selected := make(map[int64]bool, len(req.ProductIDs))
+	for _, id := range req.ProductIDs {
+		selected[id] = true
+	}
+	remark := req.Remark
+	if remark == "" {
+		remark = "库存核对调整"
+	}
+
+	// 每个店铺一张调整单
+	var shopIDs []int64
+	operations := make(map[int64]*model.StockOperation)
+	checks := make(map[int64]model.StockLedgerCheckRow)
+	for _, row := range report.Rows {
+		if len(selected) > 0 && !selected[row.ProductID] {
+			continue
+		}
+		target := row.ProductStock
+		if req.Trust == model.StockRepairTrustLedger {
+			target = row.ReplayedStock
+			if target < 0 {
+				return nil, fmt.Errorf("商品 %s 的流水重放库存为 %d，不能以流水为准修复", row.ProductName, target)
+			}
+		}
+
+		operation, ok := operations[row.ShopID]
+		if !ok {
+			now := time.Now()
+			operation = &model.StockOperation{
+				OperationNo:  pkg.GenerateOrderNo(pkg.StockPrefix, operatorID),
+				Types:        model.StockTypeAdjustment,
+				Operator:     operator,
+				OperatorID:   operatorID,
+				OperatorType: model.OperatorTypeAdmin,
+				ShopID:       row.ShopID,
+				Remark:       remark,
+				CreatedAt:    &now,
+			}
+			operations[row.ShopID] = operation
+			shopIDs = append(shopIDs, row.ShopID)
+		}
+		product := products[row.ProductID]
+		quantity := target - row.LedgerStock
+		if quantity < 0 {
+			quantity = -quantity
+		}
+		operation.Items = append(operation.Items, model.StockOperationItem{
+			ShopID:        row.ShopID,
+			ProductID:     row.ProductID,
+			Quantity:      quantity,
+			BeforeStock:   row.LedgerStock,
+			AfterStock:    target,
+			Remark:        fmt.Sprintf("商品库存 %d，流水重放库存 %d，断点 %d 处", row.ProductStock, row.ReplayedStock, len(row.Breaks)),
+			ProductName:   product.Name,
+			Specification: product.Specification,
+			Unit:          product.Unit,
+		})
+		operation.TotalQuantity += quantity
+		checks[row.ProductID] = row
+	}
+
+	for _, shopID := range shopIDs {
+		operation := operations[shopID]
+		if err := ss.stockRepo.PostStockAdjustment(operation, checks, req.Trust == model.StockRepairTrustLedger); err != nil {
+			return result, err
+		}
+		result.Operations = append(result.Operations, *operation)
+	}
+	return result, nil
+}
+
+// checkStockLedger 核对库存流水，同时返回参与核对的商品
+func (ss *stockService) checkStockLedger(shopID, productID int64) (*model.StockLedgerCheckReport, map[int64]model.Product, error) {
+	products, err := ss.stockRepo.GetStockLedgerProducts(shopID, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := ss.stockRepo.GetStockLedgerEntries(shopID, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ledgers := make(map[int64][]model.StockLedgerEntry)
+	for _, entry := range entries {
+		ledgers[entry.ProductID] = append(ledgers[entry.ProductID], entry)
+	}
+
+	report := &model.StockLedgerCheckReport{CheckedAt: time.Now(), Rows: []model.StockLedgerCheckRow{}}
+	productMap := make(map[int64]model.Product, len(products))
+	for _, product := range products {
+		productMap[product.ID] = product
+		ledger := ledgers[product.ID]
+		if len(ledger) == 0 {
+			report.NoLedgerCount++
+			continue
+		}
+		report.CheckedProducts++
+
+		row := replayStockLedger(ledger)
+		row.ShopID = product.ShopID
+		row.ProductID = product.ID
+		row.ProductName = product.Name
+		row.ProductStock = product.Stock
+		row.Diff = product.Stock - row.ReplayedStock
+		if row.Diff != 0 {
+			report.MismatchCount++
+		}
+		if len(row.Breaks) > 0 {
+			report.BrokenCount++
+		}
+		if row.Diff != 0 || len(row.Breaks) > 0 {
+			report.Rows = append(report.Rows, row)
+		}
+	}
+	return report, productMap, nil
+}
+
+// replayStockLedger 按明细ID顺序重放单个商品的库存流水：
+// 入库加数量、出库减数量，冲销和退货按操作前后库存之差，库存调整单直接取操作后库存并作为新的起点(之前的断点视为已处理)
+func replayStockLedger(ledger []model.StockLedgerEntry) model.StockLedgerCheckRow {
+	row := model.StockLedgerCheckRow{Breaks: []model.StockLedgerBreak{}}
+	stock := ledger[0].BeforeStock
+	for i, entry := range ledger {
+		if entry.Types == model.StockTypeAdjustment {
+			stock = entry.AfterStock
+			row.ItemCount = 0
+			row.Breaks = row.Breaks[:0]
+			continue
+		}
+		row.ItemCount++
+
+		if i > 0 && entry.BeforeStock != ledger[i-1].AfterStock {
+			row.Breaks = append(row.Breaks, stockLedgerBreak(model.StockLedgerBreakChain, entry, ledger[i-1].AfterStock, entry.BeforeStock))
+		}
+		delta := entry.AfterStock - entry.BeforeStock
+		switch entry.Types {
+		case model.StockTypeInbound:
+			delta = entry.Quantity
+		case model.StockTypeOutbound:
+			delta = -entry.Quantity
+		}
+		if entry.BeforeStock+delta != entry.AfterStock {
+			row.Breaks = append(row.Breaks, stockLedgerBreak(model.StockLedgerBreakDelta, entry, entry.BeforeStock+delta, entry.AfterStock))
+		}
+		stock += delta
+	}
+
+	last := ledger[len(ledger)-1]
+	row.ReplayedStock = stock
+	row.LedgerStock = last.AfterStock
+	row.LastItemID = last.ItemID
+	return row
+}
+
+func stockLedgerBreak(kind string, entry model.StockLedgerEntry, expected, actual int) model.StockLedgerBreak {
+	return model.StockLedgerBreak{
+		Kind:        kind,
+		ItemID:      entry.ItemID,
+		OperationID: entry.OperationID,
+		OperationNo: entry.OperationNo,
+		Types:       entry.Types,
+		Expected:    expected,
+		Actual:      actual,
+		CreatedAt:   entry.CreatedAt,
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"cmf/paint_proj/model"
+	"testing"
+)
+
+// ledgerEntry 构造一条库存流水，明细ID按传入顺序递增
+func ledgerEntry(id int64, types int8, quantity, before, after int) model.StockLedgerEntry {
+	return model.StockLedgerEntry{ItemID: id, OperationID: id, Types: types, ProductID: 1, Quantity: quantity, BeforeStock: before, AfterStock: after}
+}
+
+type wantLedgerBreak struct {
+	kind     string
+	itemID   int64
+	expected int
+	actual   int
+}
+
+func TestReplayStockLedger(t *testing.T) {
+	cases := []struct {
+		name      string
+		ledger    []model.StockLedgerEntry
+		replayed  int
+		ledgerEnd int
+		itemCount int
+		breaks    []wantLedgerBreak
+	}{
+		{
+			name: "连续的入库和出库",
+			ledger: []model.StockLedgerEntry{
+				ledgerEntry(1, model.StockTypeInbound, 10, 0, 10),
+				ledgerEntry(2, model.StockTypeOutbound, 3, 10, 7),
+				ledgerEntry(3, model.StockTypeInbound, 5, 7, 12),
+			},
+			replayed: 12, ledgerEnd: 12, itemCount: 3,
+		},
+		{
+			name: "断链：操作前库存与上一条的操作后库存不一致",
+			ledger: []model.StockLedgerEntry{
+				ledgerEntry(1, model.StockTypeInbound, 10, 0, 10),
+				ledgerEntry(2, model.StockTypeOutbound, 3, 8, 5),
+			},
+			replayed: 7, ledgerEnd: 5, itemCount: 2,
+			breaks: []wantLedgerBreak{{model.StockLedgerBreakChain, 2, 10, 8}},
+		},
+		{
+			name: "变动量与数量不一致",
+			ledger: []model.StockLedgerEntry{
+				ledgerEntry(1, model.StockTypeInbound, 10, 0, 10),
+				ledgerEntry(2, model.StockTypeOutbound, 3, 10, 8),
+			},
+			replayed: 7, ledgerEnd: 8, itemCount: 2,
+			breaks: []wantLedgerBreak{{model.StockLedgerBreakDelta, 2, 7, 8}},
+		},
+		{
+			name: "库存调整作为新起点，之前的断点视为已处理",
+			ledger: []model.StockLedgerEntry{
+				ledgerEntry(1, model.StockTypeInbound, 10, 0, 10),
+				ledgerEntry(2, model.StockTypeOutbound, 3, 8, 5),
+				ledgerEntry(3, model.StockTypeAdjustment, 2, 5, 7),
+				ledgerEntry(4, model.StockTypeOutbound, 2, 7, 5),
+			},
+			replayed: 5, ledgerEnd: 5, itemCount: 1,
+		},
+		{
+			name: "冲销和退货按操作前后库存之差",
+			ledger: []model.StockLedgerEntry{
+				ledgerEntry(1, model.StockTypeInbound, 10, 0, 10),
+				ledgerEntry(2, model.StockTypeOutbound, 4, 10, 6),
+				ledgerEntry(3, model.StockTypeReversal, 4, 6, 10), // 作废出库单，库存加回
+				ledgerEntry(4, model.StockTypeReturn, 2, 10, 12),
+				ledgerEntry(5, model.StockTypeReversal, 10, 12, 2), // 作废入库单，库存扣回
+			},
+			replayed: 2, ledgerEnd: 2, itemCount: 5,
+		},
+		{
+			name: "第一条流水的操作前库存作为起点",
+			ledger: []model.StockLedgerEntry{
+				ledgerEntry(7, model.StockTypeOutbound, 1, 20, 19),
+			},
+			replayed: 19, ledgerEnd: 19, itemCount: 1,
+		},
+	}
+	for _, tc := range cases {
+		row := replayStockLedger(tc.ledger)
+		if row.ReplayedStock != tc.replayed || row.LedgerStock != tc.ledgerEnd || row.ItemCount != tc.itemCount {
+			t.Errorf("%s: got replayed %d ledger %d items %d, want %d %d %d",
+				tc.name, row.ReplayedStock, row.LedgerStock, row.ItemCount, tc.replayed, tc.ledgerEnd, tc.itemCount)
+		}
+		if last := tc.ledger[len(tc.ledger)-1].ItemID; row.LastItemID != last {
+			t.Errorf("%s: last_item_id got %d, want %d", tc.name, row.LastItemID, last)
+		}
+		if len(row.Breaks) != len(tc.breaks) {
+			t.Errorf("%s: breaks got %+v, want %+v", tc.name, row.Breaks, tc.breaks)
+			continue
+		}
+		for i, want := range tc.breaks {
+			got := row.Breaks[i]
+			if got.Kind != want.kind || got.ItemID != want.itemID || got.Expected != want.expected || got.Actual != want.actual {
+				t.Errorf("%s: 第%d个断点 got %+v, want %+v", tc.name, i+1, got, want)
+			}
+		}
+	}
+}
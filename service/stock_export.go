@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"fmt"
+)
+
+// stockOperationExportLimit 单次最多导出的操作单数量
+const stockOperationExportLimit = 5000
+
+var stockOperationSheetHeaders = []string{
+	"操作单号", "操作类型", "出库类型", "操作时间", "客户", "供货商", "操作人", "支付状态", "作废", "单据金额", "单据利润", "单据备注",
+	"明细类型", "商品ID", "商品名称", "规格", "单位", "数量", "单价", "小计", "进价", "利润", "操作前库存", "操作后库存", "明细备注",
+}
+
+var stockTypeNames = map[int8]string{
+	model.StockTypeInbound:    "入库",
+	model.StockTypeOutbound:   "出库",
+	model.StockTypeReturn:     "退货",
+	model.StockTypeReversal:   "冲销",
+	model.StockTypeAdjustment: "库存调整",
+}
+
+var outboundTypeNames = map[int8]string{
+	model.OutboundTypeMiniProgram: "小程序购买",
+	model.OutboundTypeAdmin:       "后台出库",
+	model.OutboundTypePOS:         "门店收银",
+}
+
+// ExportStockOperations 导出筛选条件下的库存操作Excel，每条明细一行，操作单信息在每行重复
+func (ss *stockService) ExportStockOperations(query *model.StockOperationQuery) (*bytes.Buffer, error) {
+	operations, err := ss.stockRepo.GetStockOperationsForExport(query, stockOperationExportLimit+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(operations) > stockOperationExportLimit {
+		return nil, fmt.Errorf("单次最多导出%d张操作单，请缩小筛选范围", stockOperationExportLimit)
+	}
+	if err := ss.fillStockOperationItems(operations); err != nil {
+		return nil, err
+	}
+
+	var rows [][]interface{}
+	for _, operation := range operations {
+		head := stockOperationSheetHead(&operation)
+		if len(operation.Items) == 0 {
+			rows = append(rows, head)
+			continue
+		}
+		for _, item := range operation.Items {
+			itemType := "商品"
+			if item.IsBundle == model.BundleYes {
+				itemType = "套装"
+			} else if item.BundleID > 0 {
+				itemType = "套装组件"
+			}
+			row := append(append([]interface{}{}, head...),
+				itemType, item.ProductID, item.ProductName, item.Specification, item.Unit, item.Quantity,
+				item.UnitPrice.Yuan(), item.TotalPrice.Yuan(), item.ProductCost.Yuan(), item.Profit.Yuan(),
+				item.BeforeStock, item.AfterStock, item.Remark,
+			)
+			rows = append(rows, row)
+		}
+	}
+	return pkg.WriteXlsx("库存操作", stockOperationSheetHeaders, rows)
+}
+
+// stockOperationSheetHead 导出行中的操作单信息
+func stockOperationSheetHead(operation *model.StockOperation) []interface{} {
+	createdAt := ""
+	if operation.CreatedAt != nil {
+		createdAt = operation.CreatedAt.Format("2006-01-02 15:04:05")
+	}
+	paymentStatus := ""
+	if operation.Types == model.StockTypeOutbound {
+		paymentStatus = "未支付"
+		if operation.PaymentFinishStatus == model.PaymentStatusPaid {
+			paymentStatus = "已支付"
+		}
+	}
+	voided := ""
+	if operation.IsVoided == 1 {
+		voided = "已作废:" + operation.VoidReason
+	}
+	return []interface{}{
+		operation.OperationNo, stockTypeNames[operation.Types], outboundTypeNames[operation.OutboundType], createdAt,
+		operation.UserName, operation.Supplier, operation.Operator, paymentStatus, voided,
+		operation.TotalAmount.Yuan(), operation.TotalProfit.Yuan(), operation.Remark,
+	}
+}
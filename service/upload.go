@@ -0,0 +1,132 @@
+package service
+
+import (
+	"cmf/paint_proj/configs"
+	"cmf/paint_proj/model"
+	"cmf/paint_proj/pkg"
+	"cmf/paint_proj/repository"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 未引用图片默认保留时长
+const defaultUploadGraceHours = 72
+
+type UploadService interface {
+	RecordUpload(url string, usage string, shopID int64, operatorID int64) error
+	CleanupOrphanImages(dryRun bool) (*model.UploadCleanupReport, error)
+	StartCleanupJob(interval time.Duration)
+}
+
+type uploadService struct {
+	uploadRepo repository.UploadRepository
+}
+
+func NewUploadService(ur repository.UploadRepository) UploadService {
+	return &uploadService{uploadRepo: ur}
+}
+
+// uploadGracePeriod 未引用图片的保留时长，刚上传还未保存到商品的图片不会被清理
+func uploadGracePeriod() time.Duration {
+	hours := defaultUploadGraceHours
+	if configs.Cfg != nil && configs.Cfg.Storage.CleanupGraceHours > 0 {
+		hours = configs.Cfg.Storage.CleanupGraceHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// RecordUpload 记录上传的图片及其用途，不属于当前存储的地址忽略
+func (us *uploadService) RecordUpload(url string, usage string, shopID int64, operatorID int64) error {
+	key, ok := pkg.StorageKeyFromURL(url)
+	if !ok {
+		return errors.New("图片地址不属于当前存储")
+	}
+	return us.uploadRepo.SaveUploadFile(&model.UploadFile{
+		FileKey:    key,
+		URL:        url,
+		Usage:      usage,
+		ShopID:     shopID,
+		OperatorID: operatorID,
+	})
+}
+
+// CleanupOrphanImages 清理超过保留期且未被商品主图、图集、图文详情引用的商品图片(含各规格缩放图)
+// 只清理商品图片用途的上传记录，其他用途的引用位置不在检查范围内
+// dryRun 为 true 时只返回待清理列表，不删除
+func (us *uploadService) CleanupOrphanImages(dryRun bool) (*model.UploadCleanupReport, error) {
+	report := &model.UploadCleanupReport{
+		DryRun:  dryRun,
+		Before:  time.Now().Add(-uploadGracePeriod()),
+		Orphans: []model.UploadFile{},
+		Failed:  []string{},
+	}
+
+	// 1. 超过保留期的商品图片上传记录
+	files, err := us.uploadRepo.GetUploadFilesBefore(model.UploadUsageProduct, report.Before)
+	if err != nil {
+		return nil, err
+	}
+	report.Checked = len(files)
+	if len(files) == 0 {
+		return report, nil
+	}
+
+	// 2. 所有被商品引用的图片
+	refs, err := us.uploadRepo.GetProductImageReferences()
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool)
+	for _, ref := range refs {
+		for _, base := range pkg.ImageKeyBasesInText(ref) {
+			referenced[base] = true
+		}
+	}
+
+	// 3. 先删除记录再删除文件，删除文件失败时恢复记录，下次重试
+	for _, file := range files {
+		if referenced[pkg.UploadKeyBase(file.FileKey)] {
+			report.Referenced++
+			continue
+		}
+		if dryRun {
+			report.Orphans = append(report.Orphans, file)
+			continue
+		}
+		deleted, err := us.uploadRepo.DeleteUploadFile(file.ID, report.Before)
+		if err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", file.FileKey, err))
+			continue
+		}
+		if !deleted {
+			// 检查期间被重新上传
+			continue
+		}
+		if err := pkg.DeleteImageObjects(file.FileKey); err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", file.FileKey, err))
+			file.ID = 0
+			if err := us.uploadRepo.SaveUploadFile(&file); err != nil {
+				log.Printf("恢复上传记录失败 %s: %v", file.FileKey, err)
+			}
+			continue
+		}
+		report.Orphans = append(report.Orphans, file)
+	}
+	return report, nil
+}
+
+// StartCleanupJob 启动未引用图片清理任务，按间隔执行
+func (us *uploadService) StartCleanupJob(interval time.Duration) {
+	pkg.RunEvery("图片清理任务", interval, func() error {
+		report, err := us.CleanupOrphanImages(false)
+		if err != nil {
+			return err
+		}
+		if len(report.Orphans) > 0 || len(report.Failed) > 0 {
+			log.Printf("图片清理任务：删除 %d 张未引用图片，失败 %d 张", len(report.Orphans), len(report.Failed))
+		}
+		return nil
+	})
+}